@@ -127,7 +127,29 @@ func ConnectDatabase() {
 			&models.Customer{},
 			&models.Order{},
 			&models.OrderItem{},
+			&models.OrderStatusHistory{},
+			&models.OrderTaxLine{},
+			&models.StockReservation{},
+			&models.OrderExpirySetting{},
+			&models.OrderInvoice{},
+			&models.Shipment{},
+			&models.ShipmentItem{},
+			&models.ReturnRequest{},
+			&models.ReturnRequestItem{},
+			&models.ReturnRequestPhoto{},
+			&models.DocumentSequence{},
+			&models.Currency{},
+			&models.TaxRule{},
+			&models.QuoteRequest{},
+			&models.QuoteRequestItem{},
+			&models.Cart{},
+			&models.CartItem{},
+			&models.CheckoutSnapshot{},
+			&models.CheckoutSnapshotItem{},
+			&models.CartRecoveryEmail{},
+			&models.CartRecoverySetting{},
 			&models.PaymentTransaction{},
+			&models.PaymentWebhookEvent{},
 			&models.Banner{},
 			&models.HomepageContent{},
 			&models.CompanyProfile{},
@@ -141,6 +163,8 @@ func ConnectDatabase() {
 			&models.CloudflareCacheSetting{},
 			&models.HotlinkProtectionSetting{},
 			&models.PayPalSetting{},
+			&models.StripeSetting{},
+			&models.IdempotencyKey{},
 			&models.EmailSetting{},
 			&models.EmailVerificationCode{},
 			// Shipping (new template-based)
@@ -196,6 +220,12 @@ func ConnectDatabase() {
 
 	// Create default company profile
 	createDefaultCompanyProfile()
+
+	// Base currency + base amounts for orders placed before multi-currency
+	createDefaultCurrencies()
+
+	// Structured package sizes for products that only have the free-text dimensions
+	backfillProductDimensions()
 }
 
 func createDefaultAdmin() {
@@ -414,6 +444,53 @@ func createDefaultCompanyProfile() {
 	}
 }
 
+func createDefaultCurrencies() {
+	usd := models.Currency{Code: "USD", Name: "US Dollar", Symbol: "$", Rate: 1, Decimals: 2, IsActive: true}
+	if err := DB.Where("code = ?", usd.Code).FirstOrCreate(&usd).Error; err != nil {
+		log.Printf("Error creating base currency: %v", err)
+		return
+	}
+
+	// Legacy orders were always USD: their base amounts equal the charged amounts.
+	res := DB.Exec(`UPDATE orders SET base_currency = 'USD', exchange_rate = 1,
+		base_subtotal_amount = subtotal_amount, base_discount_amount = discount_amount,
+		base_shipping_fee = shipping_fee, base_total_amount = total_amount
+		WHERE (currency = 'USD' OR currency = '' OR currency IS NULL) AND base_total_amount = 0 AND total_amount > 0`)
+	if res.Error != nil {
+		log.Printf("Error backfilling order base amounts: %v", res.Error)
+	} else if res.RowsAffected > 0 {
+		log.Printf("Backfilled base amounts for %d orders", res.RowsAffected)
+	}
+	DB.Exec(`UPDATE order_items SET base_unit_price = unit_price
+		WHERE base_unit_price = 0 AND order_id IN (SELECT id FROM orders WHERE currency = 'USD' OR currency = '' OR currency IS NULL)`)
+}
+
+func backfillProductDimensions() {
+	var rows []struct {
+		ID         uint
+		Dimensions string
+	}
+	if err := DB.Model(&models.Product{}).Select("id, dimensions").
+		Where("length_cm IS NULL AND dimensions <> ''").Find(&rows).Error; err != nil {
+		log.Printf("Error loading product dimensions: %v", err)
+		return
+	}
+	filled := 0
+	for _, r := range rows {
+		l, w, h, ok := utils.ParseDimensionsCm(r.Dimensions)
+		if !ok {
+			continue
+		}
+		if err := DB.Model(&models.Product{}).Where("id = ?", r.ID).
+			UpdateColumns(map[string]interface{}{"length_cm": l, "width_cm": w, "height_cm": h}).Error; err == nil {
+			filled++
+		}
+	}
+	if filled > 0 {
+		log.Printf("Backfilled package dimensions for %d products", filled)
+	}
+}
+
 func GetDB() *gorm.DB {
 	return DB
 }
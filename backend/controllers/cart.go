@@ -0,0 +1,274 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"fanuc-backend/config"
+	"fanuc-backend/models"
+	"fanuc-backend/services"
+	"fanuc-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CartController struct{}
+
+// cartOwner returns who the cart belongs to: the logged-in customer, or the guest token
+// sent in the X-Cart-Token header (or ?cart_token=).
+func cartOwner(c *gin.Context) (*uint, string) {
+	if v, ok := c.Get("customer_id"); ok {
+		if id, ok := v.(uint); ok {
+			return &id, ""
+		}
+	}
+	token := strings.TrimSpace(c.GetHeader("X-Cart-Token"))
+	if token == "" {
+		token = strings.TrimSpace(c.Query("cart_token"))
+	}
+	return nil, token
+}
+
+func respondCartError(c *gin.Context, err error) {
+	var cartErr *services.CartError
+	if errors.As(err, &cartErr) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: cartErr.Message, Error: "cart_error"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Cart operation failed", Error: err.Error()})
+}
+
+// respondCart re-prices the cart in the requested currency and returns it.
+func respondCart(c *gin.Context, status int, cart *models.Cart) {
+	currency, ok := requestCurrency(c)
+	if !ok {
+		return
+	}
+	if currency != nil && cart.ID != 0 && cart.Currency != currency.Code {
+		config.GetDB().Model(&models.Cart{}).Where("id = ?", cart.ID).Update("currency", currency.Code)
+	}
+	c.JSON(status, models.APIResponse{Success: true, Message: "OK", Data: services.PriceCart(cart, currency)})
+}
+
+// loadOwnCart finds the caller's active cart; ok is false when a response was written.
+func loadOwnCart(c *gin.Context, create bool) (*models.Cart, bool) {
+	customerID, token := cartOwner(c)
+	db := config.GetDB()
+	var (
+		cart *models.Cart
+		err  error
+	)
+	if create {
+		cart, err = services.GetOrCreateCart(db, customerID, token)
+	} else {
+		cart, err = services.FindCart(db, customerID, token)
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "Cart not found", Error: "cart_not_found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load cart", Error: err.Error()})
+		return nil, false
+	}
+	return cart, true
+}
+
+// Public: GET /api/v1/cart?currency=EUR
+// Returns an empty cart (without creating one) when the shopper has none yet.
+func (cc *CartController) GetCart(c *gin.Context) {
+	customerID, token := cartOwner(c)
+	cart, err := services.FindCart(config.GetDB(), customerID, token)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondCart(c, http.StatusOK, &models.Cart{CustomerID: customerID})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load cart", Error: err.Error()})
+		return
+	}
+	respondCart(c, http.StatusOK, cart)
+}
+
+type cartItemRequest struct {
+	ProductID uint `json:"product_id" binding:"required"`
+	Quantity  int  `json:"quantity" binding:"required,min=1"`
+}
+
+// Public: POST /api/v1/cart/items
+// Creates the cart on first use; guests must keep the returned token.
+func (cc *CartController) AddItem(c *gin.Context) {
+	var req cartItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request", Error: err.Error()})
+		return
+	}
+	cart, ok := loadOwnCart(c, true)
+	if !ok {
+		return
+	}
+	if err := services.AddCartItem(config.GetDB(), cart, req.ProductID, req.Quantity); err != nil {
+		respondCartError(c, err)
+		return
+	}
+	respondCart(c, http.StatusOK, cart)
+}
+
+// Public: PUT /api/v1/cart/items/:itemId  (quantity 0 removes the line)
+func (cc *CartController) UpdateItem(c *gin.Context) {
+	var req struct {
+		Quantity *int `json:"quantity" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request", Error: err.Error()})
+		return
+	}
+	itemID, err := strconv.ParseUint(c.Param("itemId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid item ID"})
+		return
+	}
+	cart, ok := loadOwnCart(c, false)
+	if !ok {
+		return
+	}
+	if err := services.UpdateCartItem(config.GetDB(), cart, uint(itemID), *req.Quantity); err != nil {
+		respondCartError(c, err)
+		return
+	}
+	respondCart(c, http.StatusOK, cart)
+}
+
+// Public: DELETE /api/v1/cart/items/:itemId
+func (cc *CartController) RemoveItem(c *gin.Context) {
+	itemID, err := strconv.ParseUint(c.Param("itemId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid item ID"})
+		return
+	}
+	cart, ok := loadOwnCart(c, false)
+	if !ok {
+		return
+	}
+	if err := services.UpdateCartItem(config.GetDB(), cart, uint(itemID), 0); err != nil {
+		respondCartError(c, err)
+		return
+	}
+	respondCart(c, http.StatusOK, cart)
+}
+
+// Public: DELETE /api/v1/cart
+func (cc *CartController) ClearCart(c *gin.Context) {
+	cart, ok := loadOwnCart(c, false)
+	if !ok {
+		return
+	}
+	if err := services.ClearCart(config.GetDB(), cart); err != nil {
+		respondCartError(c, err)
+		return
+	}
+	respondCart(c, http.StatusOK, cart)
+}
+
+// Public: POST /api/v1/cart/acknowledge-prices
+// Accepts the current prices after the shopper has seen the price_changed warnings.
+func (cc *CartController) AcknowledgePrices(c *gin.Context) {
+	cart, ok := loadOwnCart(c, false)
+	if !ok {
+		return
+	}
+	if err := services.AcknowledgeCartPrices(config.GetDB(), cart); err != nil {
+		respondCartError(c, err)
+		return
+	}
+	respondCart(c, http.StatusOK, cart)
+}
+
+// Public: GET /api/v1/cart/shipping-options?country=DE&currency=EUR
+// Every shipping option for the cart's weight and volume, with price and transit estimate.
+func (cc *CartController) ShippingOptions(c *gin.Context) {
+	cart, ok := loadOwnCart(c, false)
+	if !ok {
+		return
+	}
+	view := services.PriceCart(cart, nil)
+	respondShippingOptions(c, c.Query("country"), services.ShippingParcel{WeightKg: view.WeightKg, VolumeCm3: view.VolumeCm3})
+}
+
+// Customer: POST /api/v1/cart/merge  {"cart_token": "..."}
+// Folds a guest cart into the logged-in customer's cart (login also does this when given cart_token).
+func (cc *CartController) MergeCart(c *gin.Context) {
+	customerID, _ := cartOwner(c)
+	if customerID == nil {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{Success: false, Message: "Please log in to merge carts"})
+		return
+	}
+	var req struct {
+		CartToken string `json:"cart_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request", Error: err.Error()})
+		return
+	}
+	cart, err := services.MergeGuestCart(config.GetDB(), *customerID, strings.TrimSpace(req.CartToken))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondCart(c, http.StatusOK, &models.Cart{CustomerID: customerID})
+		return
+	}
+	if err != nil {
+		respondCartError(c, err)
+		return
+	}
+	respondCart(c, http.StatusOK, cart)
+}
+
+// Admin: GET /api/v1/admin/carts?page=1&page_size=20&status=active&customer=only
+// Shows what shoppers have in their carts (most recently active first).
+func (cc *CartController) AdminList(c *gin.Context) {
+	db := config.GetDB()
+	page, pageSize := utils.ParsePagination(c.Query("page"), c.Query("page_size"))
+
+	status := strings.TrimSpace(c.Query("status"))
+	if status == "" {
+		status = models.CartStatusActive
+	}
+	query := db.Model(&models.Cart{}).Where("status = ?", status).
+		Where("EXISTS (SELECT 1 FROM cart_items WHERE cart_items.cart_id = carts.id)")
+	switch c.Query("customer") {
+	case "only":
+		query = query.Where("customer_id IS NOT NULL")
+	case "guest":
+		query = query.Where("customer_id IS NULL")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var carts []models.Cart
+	if err := query.Preload("Customer").Preload("Items.Product").
+		Order("last_activity_at DESC").
+		Offset(utils.CalculateOffset(page, pageSize)).Limit(pageSize).
+		Find(&carts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load carts", Error: err.Error()})
+		return
+	}
+
+	out := make([]gin.H, 0, len(carts))
+	for i := range carts {
+		row := gin.H{"cart": services.PriceCart(&carts[i], nil), "status": carts[i].Status, "order_id": carts[i].OrderID}
+		if carts[i].Customer != nil {
+			row["customer"] = gin.H{"id": carts[i].Customer.ID, "email": carts[i].Customer.Email, "full_name": carts[i].Customer.FullName}
+		}
+		out = append(out, row)
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK", Data: models.PaginationResponse{
+		Data:       out,
+		Page:       page,
+		PageSize:   pageSize,
+		Total:      total,
+		TotalPages: utils.CalculateTotalPages(total, pageSize),
+	}})
+}
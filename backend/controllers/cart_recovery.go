@@ -0,0 +1,316 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"fanuc-backend/config"
+	"fanuc-backend/models"
+	"fanuc-backend/services"
+	"fanuc-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type checkoutSnapshotRequest struct {
+	Email            string `json:"email"`
+	Name             string `json:"name"`
+	MarketingConsent *bool  `json:"marketing_consent"` // defaults to the customer's account setting
+	Currency         string `json:"currency"`
+	Items            []struct {
+		ProductID uint `json:"product_id"`
+		Quantity  int  `json:"quantity"`
+	} `json:"items"` // omit to use the caller's server-side cart
+}
+
+// Public: POST /api/v1/cart/checkout-snapshot
+// Called when an identified shopper reaches checkout, so an abandoned checkout can be
+// followed up by email (only with consent). Needs the customer login or the guest cart token.
+func (cc *CartController) SaveCheckoutSnapshot(c *gin.Context) {
+	var req checkoutSnapshotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request", Error: err.Error()})
+		return
+	}
+
+	db := config.GetDB()
+	customerID, token := cartOwner(c)
+	in := services.CheckoutSnapshotInput{
+		Email:      req.Email,
+		Name:       req.Name,
+		CustomerID: customerID,
+		Currency:   req.Currency,
+	}
+	if customerID != nil {
+		var customer models.Customer
+		if err := db.First(&customer, *customerID).Error; err == nil {
+			if strings.TrimSpace(in.Email) == "" {
+				in.Email = customer.Email
+			}
+			if strings.TrimSpace(in.Name) == "" {
+				in.Name = customer.FullName
+			}
+			in.EmailConsent = customer.MarketingConsent
+		}
+	}
+	if req.MarketingConsent != nil {
+		in.EmailConsent = *req.MarketingConsent
+	}
+
+	// The snapshot belongs to the caller's account or cart; guests without a cart of their own
+	// cannot save one (and so cannot sign an arbitrary email up for follow-ups).
+	cart, err := services.FindCart(db, customerID, token)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load cart", Error: err.Error()})
+		return
+	}
+	if cart != nil {
+		in.CartID = &cart.ID
+	}
+	if customerID == nil && cart == nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Cart not found", Error: "cart_not_found"})
+		return
+	}
+	if len(req.Items) > 0 {
+		for _, it := range req.Items {
+			in.Lines = append(in.Lines, services.OrderLineInput{ProductID: it.ProductID, Quantity: it.Quantity})
+		}
+	} else if cart != nil {
+		in.Lines, _ = services.CartOrderLines(cart, nil)
+	}
+
+	snap, err := services.SaveCheckoutSnapshot(db, in)
+	if err != nil {
+		respondCartError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK", Data: gin.H{
+		"id":            snap.ID,
+		"email_consent": snap.EmailConsent,
+		"item_count":    len(snap.Items),
+	}})
+}
+
+// Public: POST /api/v1/cart/restore  {"token": "..."}
+// Rebuilds the cart from a recovery email link. Guests get a new cart token to keep.
+func (cc *CartController) RestoreCart(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request", Error: err.Error()})
+		return
+	}
+	currency, ok := requestCurrency(c)
+	if !ok {
+		return
+	}
+
+	db := config.GetDB()
+	customerID, _ := cartOwner(c)
+	cart, rec, err := services.RestoreRecoveryCart(db, req.Token, customerID)
+	if err != nil {
+		respondCartError(c, err)
+		return
+	}
+
+	out := gin.H{"cart": services.PriceCart(cart, currency), "coupon_code": rec.CouponCode}
+	if rec.SourceOrderID != nil {
+		// The original order may still be payable as it is.
+		var order models.Order
+		if err := db.Select("id, order_number, status, payment_status").First(&order, *rec.SourceOrderID).Error; err == nil &&
+			order.Status == models.OrderStatusPending && !services.IsPaymentCaptured(order.PaymentStatus) {
+			out["pending_order_number"] = order.OrderNumber
+		}
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Cart restored", Data: out})
+}
+
+// Admin: GET /api/v1/admin/cart-recovery/settings
+func (cc *CartController) GetRecoverySettings(c *gin.Context) {
+	s, err := services.GetOrCreateCartRecoverySetting(config.GetDB())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load settings", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK", Data: s})
+}
+
+type updateCartRecoverySettingsRequest struct {
+	Enabled             *bool    `json:"enabled"`
+	AbandonedAfterHours *int     `json:"abandoned_after_hours"`
+	MaxAgeHours         *int     `json:"max_age_hours"`
+	IncludeUnpaidOrders *bool    `json:"include_unpaid_orders"`
+	CouponEnabled       *bool    `json:"coupon_enabled"`
+	CouponType          *string  `json:"coupon_type"`
+	CouponValue         *float64 `json:"coupon_value"`
+	CouponValidDays     *int     `json:"coupon_valid_days"`
+	AttributionDays     *int     `json:"attribution_days"`
+}
+
+// Admin: PUT /api/v1/admin/cart-recovery/settings
+func (cc *CartController) UpdateRecoverySettings(c *gin.Context) {
+	db := config.GetDB()
+	var req updateCartRecoverySettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request", Error: err.Error()})
+		return
+	}
+
+	s, err := services.GetOrCreateCartRecoverySetting(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load settings", Error: err.Error()})
+		return
+	}
+
+	abandonedAfter := s.AbandonedAfterHours
+	if req.AbandonedAfterHours != nil {
+		abandonedAfter = *req.AbandonedAfterHours
+	}
+	maxAge := s.MaxAgeHours
+	if req.MaxAgeHours != nil {
+		maxAge = *req.MaxAgeHours
+	}
+	if abandonedAfter < 1 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "abandoned_after_hours must be at least 1"})
+		return
+	}
+	if maxAge <= abandonedAfter {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "max_age_hours must be greater than abandoned_after_hours"})
+		return
+	}
+	if req.CouponType != nil && *req.CouponType != "percentage" && *req.CouponType != "fixed_amount" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "coupon_type must be percentage or fixed_amount"})
+		return
+	}
+	couponType := s.CouponType
+	if req.CouponType != nil {
+		couponType = *req.CouponType
+	}
+	couponValue := s.CouponValue
+	if req.CouponValue != nil {
+		couponValue = *req.CouponValue
+	}
+	if couponValue < 0 || (couponType == "percentage" && couponValue > 100) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "coupon_value is out of range"})
+		return
+	}
+	if (req.CouponValidDays != nil && *req.CouponValidDays < 1) || (req.AttributionDays != nil && *req.AttributionDays < 1) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "coupon_valid_days and attribution_days must be at least 1"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+	if req.AbandonedAfterHours != nil {
+		updates["abandoned_after_hours"] = *req.AbandonedAfterHours
+	}
+	if req.MaxAgeHours != nil {
+		updates["max_age_hours"] = *req.MaxAgeHours
+	}
+	if req.IncludeUnpaidOrders != nil {
+		updates["include_unpaid_orders"] = *req.IncludeUnpaidOrders
+	}
+	if req.CouponEnabled != nil {
+		updates["coupon_enabled"] = *req.CouponEnabled
+	}
+	if req.CouponType != nil {
+		updates["coupon_type"] = *req.CouponType
+	}
+	if req.CouponValue != nil {
+		updates["coupon_value"] = *req.CouponValue
+	}
+	if req.CouponValidDays != nil {
+		updates["coupon_valid_days"] = *req.CouponValidDays
+	}
+	if req.AttributionDays != nil {
+		updates["attribution_days"] = *req.AttributionDays
+	}
+
+	if len(updates) > 0 {
+		if err := db.Model(s).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to update settings", Error: err.Error()})
+			return
+		}
+	}
+
+	s, _ = services.GetOrCreateCartRecoverySetting(db)
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Settings updated", Data: s})
+}
+
+// Admin: POST /api/v1/admin/cart-recovery/run
+// Runs one recovery pass now (even when the scheduler is disabled).
+func (cc *CartController) RunRecovery(c *gin.Context) {
+	db := config.GetDB()
+	s, err := services.GetOrCreateCartRecoverySetting(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load settings", Error: err.Error()})
+		return
+	}
+	res, err := services.RunCartRecovery(db, s, requestSiteURL(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Cart recovery failed", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK", Data: res})
+}
+
+// Admin: GET /api/v1/admin/cart-recovery/report?days=30
+func (cc *CartController) RecoveryReport(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > 366 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "days must be between 1 and 366"})
+		return
+	}
+	stats, err := services.CartRecoveryReport(config.GetDB(), time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to build report", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK", Data: gin.H{
+		"days":          days,
+		"base_currency": services.BaseCurrency,
+		"stats":         stats,
+	}})
+}
+
+// Admin: GET /api/v1/admin/cart-recovery/emails?page=1&page_size=20&source=order&recovered=true
+func (cc *CartController) RecoveryEmails(c *gin.Context) {
+	db := config.GetDB()
+	page, pageSize := utils.ParsePagination(c.Query("page"), c.Query("page_size"))
+
+	query := db.Model(&models.CartRecoveryEmail{})
+	if source := strings.TrimSpace(c.Query("source")); source != "" {
+		query = query.Where("source = ?", source)
+	}
+	switch c.Query("recovered") {
+	case "true":
+		query = query.Where("recovered_order_id IS NOT NULL")
+	case "false":
+		query = query.Where("recovered_order_id IS NULL")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var rows []models.CartRecoveryEmail
+	if err := query.Order("sent_at DESC").
+		Offset(utils.CalculateOffset(page, pageSize)).Limit(pageSize).
+		Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load recovery emails", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK", Data: models.PaginationResponse{
+		Data:       rows,
+		Page:       page,
+		PageSize:   pageSize,
+		Total:      total,
+		TotalPages: utils.CalculateTotalPages(total, pageSize),
+	}})
+}
@@ -443,8 +443,8 @@ func (cc *CouponController) validateCouponRules(db *gorm.DB, coupon *models.Coup
 	}
 }
 
-// ApplyCoupon applies a coupon to an order (used during order creation)
-func (cc *CouponController) ApplyCoupon(db *gorm.DB, couponCode string, orderID uint, orderAmount float64, customerEmail string) (*models.CouponValidateResponse, error) {
+// CheckCoupon validates a coupon for an order amount without recording usage
+func (cc *CouponController) CheckCoupon(db *gorm.DB, couponCode string, orderAmount float64, customerEmail string) (*models.CouponValidateResponse, error) {
 	if couponCode == "" {
 		return nil, nil // No coupon applied
 	}
@@ -464,9 +464,17 @@ func (cc *CouponController) ApplyCoupon(db *gorm.DB, couponCode string, orderID
 
 	// Validate coupon
 	response := cc.validateCouponRules(db, &coupon, orderAmount, customerEmail)
-	if !response.Valid {
-		return &response, nil
+	return &response, nil
+}
+
+// ApplyCoupon applies a coupon to an order (used during order creation)
+func (cc *CouponController) ApplyCoupon(db *gorm.DB, couponCode string, orderID uint, orderAmount float64, customerEmail string) (*models.CouponValidateResponse, error) {
+	checked, err := cc.CheckCoupon(db, couponCode, orderAmount, customerEmail)
+	if err != nil || checked == nil || !checked.Valid {
+		return checked, err
 	}
+	response := *checked
+	coupon := models.Coupon{ID: response.CouponID}
 
 	// Create usage record
 	usage := models.CouponUsage{
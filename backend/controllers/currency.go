@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"fanuc-backend/config"
+	"fanuc-backend/models"
+	"fanuc-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CurrencyController struct{}
+
+// requestCurrency resolves the optional ?currency= parameter of public endpoints.
+// It returns nil when none was given; on an unknown currency it writes a 400 and returns false.
+func requestCurrency(c *gin.Context) (*models.Currency, bool) {
+	code := strings.TrimSpace(c.Query("currency"))
+	if code == "" {
+		return nil, true
+	}
+	cur, err := services.ResolveCurrency(config.GetDB(), code)
+	if err != nil {
+		var curErr *services.CurrencyError
+		if errors.As(err, &curErr) {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: curErr.Message, Error: "unsupported_currency"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load currency", Error: err.Error()})
+		return nil, false
+	}
+	return cur, true
+}
+
+// Public: GET /api/v1/public/currencies
+func (cc *CurrencyController) PublicList(c *gin.Context) {
+	list, err := services.ListActiveCurrencies(config.GetDB())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load currencies", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK", Data: gin.H{
+		"base_currency": services.BaseCurrency,
+		"currencies":    list,
+	}})
+}
+
+// Admin: GET /api/v1/admin/currencies
+func (cc *CurrencyController) AdminList(c *gin.Context) {
+	var list []models.Currency
+	if err := config.GetDB().
+		Order(fmt.Sprintf("CASE WHEN code = '%s' THEN 0 ELSE 1 END, sort_order ASC, code ASC", services.BaseCurrency)).
+		Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load currencies", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK", Data: list})
+}
+
+type currencyRequest struct {
+	Code      *string  `json:"code"`
+	Name      *string  `json:"name"`
+	Symbol    *string  `json:"symbol"`
+	Rate      *float64 `json:"rate"`
+	Decimals  *int     `json:"decimals"`
+	IsActive  *bool    `json:"is_active"`
+	SortOrder *int     `json:"sort_order"`
+}
+
+// Admin: POST /api/v1/admin/currencies
+func (cc *CurrencyController) Create(c *gin.Context) {
+	var req currencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request", Error: err.Error()})
+		return
+	}
+	if req.Code == nil || len(services.NormalizeCurrencyCode(*req.Code)) != 3 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "code must be a 3-letter ISO 4217 code"})
+		return
+	}
+	if req.Rate == nil || *req.Rate <= 0 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "rate must be greater than 0"})
+		return
+	}
+
+	db := config.GetDB()
+	code := services.NormalizeCurrencyCode(*req.Code)
+	var count int64
+	db.Model(&models.Currency{}).Where("code = ?", code).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, models.APIResponse{Success: false, Message: fmt.Sprintf("Currency %s already exists", code)})
+		return
+	}
+
+	now := time.Now()
+	cur := models.Currency{Code: code, Rate: *req.Rate, Decimals: 2, IsActive: true, RateSource: "manual", RateUpdatedAt: &now}
+	if code == services.BaseCurrency {
+		cur.Rate = 1
+	}
+	if req.Name != nil {
+		cur.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Symbol != nil {
+		cur.Symbol = strings.TrimSpace(*req.Symbol)
+	}
+	if req.Decimals != nil {
+		if *req.Decimals < 0 || *req.Decimals > 4 {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "decimals must be between 0 and 4"})
+			return
+		}
+		cur.Decimals = *req.Decimals
+	}
+	if req.SortOrder != nil {
+		cur.SortOrder = *req.SortOrder
+	}
+	if err := db.Create(&cur).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to create currency", Error: err.Error()})
+		return
+	}
+	if req.IsActive != nil && !*req.IsActive {
+		db.Model(&cur).Update("is_active", false)
+		cur.IsActive = false
+	}
+
+	services.InvalidatePublicCaches(c.Request.Context(), "currency:create", nil)
+	c.JSON(http.StatusCreated, models.APIResponse{Success: true, Message: "Currency created", Data: cur})
+}
+
+// Admin: PUT /api/v1/admin/currencies/:id
+func (cc *CurrencyController) Update(c *gin.Context) {
+	db := config.GetDB()
+	var cur models.Currency
+	if err := db.First(&cur, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "Currency not found"})
+		return
+	}
+
+	var req currencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request", Error: err.Error()})
+		return
+	}
+
+	isBase := cur.Code == services.BaseCurrency
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		updates["name"] = strings.TrimSpace(*req.Name)
+	}
+	if req.Symbol != nil {
+		updates["symbol"] = strings.TrimSpace(*req.Symbol)
+	}
+	if req.Rate != nil {
+		if *req.Rate <= 0 {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "rate must be greater than 0"})
+			return
+		}
+		if isBase && *req.Rate != 1 {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "The base currency rate is always 1"})
+			return
+		}
+		if *req.Rate != cur.Rate {
+			updates["rate"] = *req.Rate
+			updates["rate_source"] = "manual"
+			updates["rate_updated_at"] = time.Now()
+		}
+	}
+	if req.Decimals != nil {
+		if *req.Decimals < 0 || *req.Decimals > 4 {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "decimals must be between 0 and 4"})
+			return
+		}
+		updates["decimals"] = *req.Decimals
+	}
+	if req.IsActive != nil {
+		if isBase && !*req.IsActive {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "The base currency cannot be disabled"})
+			return
+		}
+		updates["is_active"] = *req.IsActive
+	}
+	if req.SortOrder != nil {
+		updates["sort_order"] = *req.SortOrder
+	}
+
+	if len(updates) > 0 {
+		if err := db.Model(&cur).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to update currency", Error: err.Error()})
+			return
+		}
+		services.InvalidatePublicCaches(c.Request.Context(), "currency:update", nil)
+	}
+	db.First(&cur, cur.ID)
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Currency updated", Data: cur})
+}
+
+// Admin: DELETE /api/v1/admin/currencies/:id
+// Orders keep their own currency code and rate, so deleting a currency never rewrites history.
+func (cc *CurrencyController) Delete(c *gin.Context) {
+	db := config.GetDB()
+	var cur models.Currency
+	if err := db.First(&cur, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "Currency not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load currency", Error: err.Error()})
+		return
+	}
+	if cur.Code == services.BaseCurrency {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "The base currency cannot be deleted"})
+		return
+	}
+	if err := db.Delete(&cur).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to delete currency", Error: err.Error()})
+		return
+	}
+	services.InvalidatePublicCaches(c.Request.Context(), "currency:delete", nil)
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Currency deleted"})
+}
+
+// Admin: POST /api/v1/admin/currencies/import
+// multipart form fields:
+// - file: .csv or .xlsx with columns code, rate (per 1 USD) and optionally name, symbol, decimals
+func (cc *CurrencyController) ImportRates(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Missing file", Error: err.Error()})
+		return
+	}
+	if file.Size <= 0 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Empty file", Error: "empty_file"})
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Failed to read file", Error: err.Error()})
+		return
+	}
+	defer src.Close()
+
+	result, err := services.ImportCurrencyRates(config.GetDB(), file.Filename, src)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Import failed", Error: err.Error()})
+		return
+	}
+	if result.Created > 0 || result.Updated > 0 {
+		services.InvalidatePublicCaches(c.Request.Context(), "currency:import", nil)
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Import completed", Data: result})
+}
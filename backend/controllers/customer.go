@@ -6,6 +6,7 @@ import (
 	"fanuc-backend/models"
 	"fanuc-backend/services"
 	"fanuc-backend/utils"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -170,7 +171,8 @@ func (cc *CustomerController) Register(c *gin.Context) {
 		Company:  req.Company,
 		IsActive: true,
 		// If verification is enabled, code above was validated.
-		IsVerified: true,
+		IsVerified:       true,
+		MarketingConsent: req.MarketingConsent,
 	}
 
 	if err := db.Create(&customer).Error; err != nil {
@@ -264,6 +266,13 @@ func (cc *CustomerController) Login(c *gin.Context) {
 		return
 	}
 
+	// Best effort: a failed merge must not block the login.
+	if cartToken := strings.TrimSpace(req.CartToken); cartToken != "" {
+		if _, err := services.MergeGuestCart(db, customer.ID, cartToken); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("customer login: failed to merge guest cart for customer %d: %v", customer.ID, err)
+		}
+	}
+
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
 		Message: "Login successful",
@@ -344,6 +353,9 @@ func (cc *CustomerController) UpdateProfile(c *gin.Context) {
 	customer.State = req.State
 	customer.Country = req.Country
 	customer.PostalCode = req.PostalCode
+	if req.MarketingConsent != nil {
+		customer.MarketingConsent = *req.MarketingConsent
+	}
 
 	if err := db.Save(&customer).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
@@ -7,6 +7,7 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // GetMyOrders returns all orders for the current customer
@@ -101,6 +102,9 @@ func (oc *OrderController) GetMyOrderDetails(c *gin.Context) {
 		Preload("Items.Product").
 		Preload("Items.Product.Images").
 		Preload("Customer").
+		Preload("TaxLines").
+		Preload("Shipments", func(db *gorm.DB) *gorm.DB { return db.Order("shipped_at ASC, id ASC") }).
+		Preload("Shipments.Items").
 		First(&order).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.APIResponse{
 			Success: false,
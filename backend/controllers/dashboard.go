@@ -40,6 +40,7 @@ type RecentOrder struct {
 	CustomerName  string    `json:"customer_name"`
 	CustomerEmail string    `json:"customer_email"`
 	TotalAmount   float64   `json:"total_amount"`
+	Currency      string    `json:"currency"`
 	Status        string    `json:"status"`
 	CreatedAt     time.Time `json:"created_at"`
 }
@@ -181,7 +182,7 @@ func (c *DashboardController) GetDashboardStats(ctx *gin.Context) {
 	if err := db.Model(&models.Order{}).
 		Where("payment_status = ?", "paid").
 		Where("status <> ?", "cancelled").
-		Select("COALESCE(SUM(total_amount), 0)").
+		Select("COALESCE(SUM(base_total_amount), 0)").
 		Scan(&totalRevenue).Error; err != nil {
 		ctx.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
@@ -197,7 +198,7 @@ func (c *DashboardController) GetDashboardStats(ctx *gin.Context) {
 		Where("payment_status = ?", "paid").
 		Where("status <> ?", "cancelled").
 		Where("created_at >= ? AND created_at <= ?", startOfMonth, now).
-		Select("COALESCE(SUM(total_amount), 0)").
+		Select("COALESCE(SUM(base_total_amount), 0)").
 		Scan(&monthlyRevenue).Error; err != nil {
 		ctx.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
@@ -331,6 +332,7 @@ func (c *DashboardController) GetRecentOrders(ctx *gin.Context) {
 			CustomerName:  order.CustomerName,
 			CustomerEmail: order.CustomerEmail,
 			TotalAmount:   order.TotalAmount,
+			Currency:      order.Currency,
 			Status:        order.Status,
 			CreatedAt:     order.CreatedAt,
 		})
@@ -454,7 +456,7 @@ func (c *DashboardController) GetRevenueData(ctx *gin.Context) {
 				Where("payment_status = ?", "paid").
 				Where("status <> ?", "cancelled").
 				Where("created_at >= ? AND created_at < ?", startOfDay, endOfDay).
-				Select("COALESCE(SUM(total_amount), 0)").Scan(&revenue)
+				Select("COALESCE(SUM(base_total_amount), 0)").Scan(&revenue)
 
 			db.Model(&models.Order{}).
 				Where("payment_status = ?", "paid").
@@ -482,7 +484,7 @@ func (c *DashboardController) GetRevenueData(ctx *gin.Context) {
 				Where("payment_status = ?", "paid").
 				Where("status <> ?", "cancelled").
 				Where("created_at >= ? AND created_at < ?", startOfMonth, endOfMonth).
-				Select("COALESCE(SUM(total_amount), 0)").Scan(&revenue)
+				Select("COALESCE(SUM(base_total_amount), 0)").Scan(&revenue)
 
 			db.Model(&models.Order{}).
 				Where("payment_status = ?", "paid").
@@ -510,7 +512,7 @@ func (c *DashboardController) GetRevenueData(ctx *gin.Context) {
 				Where("payment_status = ?", "paid").
 				Where("status <> ?", "cancelled").
 				Where("created_at >= ? AND created_at < ?", startOfDay, endOfDay).
-				Select("COALESCE(SUM(total_amount), 0)").Scan(&revenue)
+				Select("COALESCE(SUM(base_total_amount), 0)").Scan(&revenue)
 
 			db.Model(&models.Order{}).
 				Where("payment_status = ?", "paid").
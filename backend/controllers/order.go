@@ -1,12 +1,15 @@
 package controllers
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"fanuc-backend/config"
@@ -29,17 +32,37 @@ type OrderCreateRequest struct {
 	BillingAddress  string `json:"billing_address" binding:"required"`
 	Notes           string `json:"notes"`
 	CouponCode      string `json:"coupon_code"` // Optional coupon code
+	Currency        string `json:"currency"`    // Presentment currency (defaults to USD)
+	VATNumber       string `json:"vat_number"`  // Optional, B2B reverse charge
 	Items           []struct {
-		ProductID uint    `json:"product_id" binding:"required"`
-		Quantity  int     `json:"quantity" binding:"required,min=1"`
-		UnitPrice float64 `json:"unit_price" binding:"required,min=0"`
-	} `json:"items" binding:"required,min=1"`
+		ProductID uint     `json:"product_id" binding:"required"`
+		Quantity  int      `json:"quantity" binding:"required,min=1"`
+		UnitPrice *float64 `json:"unit_price" binding:"omitempty,min=0"` // Displayed price (in currency), used only for mismatch detection
+	} `json:"items"`
+
+	// Checkout from a server-side cart instead of Items. Guests also send the cart token
+	// (cart_token or the X-Cart-Token header).
+	CartID    *uint  `json:"cart_id"`
+	CartToken string `json:"cart_token"`
+
+	// Shipping option picked at checkout (GET /public/shipping/options); without a carrier the
+	// default country template is used. ShippingFee is the fee the shopper saw (in currency),
+	// used only for mismatch detection.
+	ShippingCarrier string   `json:"shipping_carrier"`
+	ShippingService string   `json:"shipping_service"`
+	ShippingFee     *float64 `json:"shipping_fee" binding:"omitempty,min=0"`
+
+	// Consent to follow-up emails (abandoned cart reminders); defaults to the customer's account setting.
+	MarketingConsent *bool `json:"marketing_consent"`
 }
 
 // PaymentRequest matches frontend payment request format
 type PaymentRequest struct {
 	PaymentMethod string      `json:"payment_method" binding:"required"`
 	PaymentData   interface{} `json:"payment_data"`
+	// Guests prove the order is theirs with the tracking token or the order email.
+	TrackingToken string `json:"tracking_token"`
+	Email         string `json:"email"`
 }
 
 // CreateOrder creates a new order
@@ -55,98 +78,164 @@ func (oc *OrderController) CreateOrder(c *gin.Context) {
 		return
 	}
 
-	// Generate order number
-	orderNumber := fmt.Sprintf("ORD-%d", time.Now().Unix())
-
-	// Calculate total amount and validate products
-	var subtotalAmount float64
-	var orderItems []models.OrderItem
-	var totalWeightKg float64
+	orderNumber, err := services.NewOrderNumber(config.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to create order",
+			"error":   err.Error(),
+		})
+		return
+	}
 
-	for _, item := range req.Items {
-		var product models.Product
-		if err := config.DB.First(&product, item.ProductID).Error; err != nil {
+	currency, err := services.ResolveCurrency(config.DB, req.Currency)
+	if err != nil {
+		var curErr *services.CurrencyError
+		if errors.As(err, &curErr) {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"success": false,
-				"message": fmt.Sprintf("Product with ID %d not found", item.ProductID),
+				"message": curErr.Message,
+				"error":   "unsupported_currency",
 			})
 			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to load currency",
+			"error":   err.Error(),
+		})
+		return
+	}
 
-		// Check stock
-		if product.StockQuantity < item.Quantity {
+	// Price every line server-side; the client's unit_price is only used to detect stale prices.
+	var lines []services.OrderLineInput
+	var recoveryID *uint
+	if req.CartID != nil {
+		cart, ok := checkoutCart(c, *req.CartID, req.CartToken)
+		if !ok {
+			return
+		}
+		recoveryID = cart.RecoveryID
+		if lines, err = services.CartOrderLines(cart, currency); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"success": false,
-				"message": fmt.Sprintf("Insufficient stock for product %s", product.Name),
+				"message": err.Error(),
+				"error":   "cart_error",
 			})
 			return
 		}
-
-		// Use price from frontend if provided, otherwise use product price
-		unitPrice := item.UnitPrice
-		if unitPrice <= 0 {
-			unitPrice = product.Price
+	} else {
+		if len(req.Items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Order must contain at least one item",
+			})
+			return
 		}
-
-		itemTotal := unitPrice * float64(item.Quantity)
-		subtotalAmount += itemTotal
-		if product.Weight != nil {
-			totalWeightKg += float64(item.Quantity) * float64(*product.Weight)
+		for _, item := range req.Items {
+			lines = append(lines, services.OrderLineInput{
+				ProductID:       item.ProductID,
+				Quantity:        item.Quantity,
+				ClientUnitPrice: item.UnitPrice,
+			})
 		}
-
-		orderItems = append(orderItems, models.OrderItem{
-			ProductID:  item.ProductID,
-			Quantity:   item.Quantity,
-			UnitPrice:  unitPrice,
-			TotalPrice: itemTotal,
+	}
+	priced, err := services.PriceOrderLines(config.DB, lines, currency)
+	if err != nil {
+		var lineErr *services.OrderLineError
+		if errors.As(err, &lineErr) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": lineErr.Message,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to price order items",
+			"error":   err.Error(),
 		})
+		return
 	}
+	if len(priced.Mismatches) > 0 {
+		// The shopper saw a different price (stale cart or tampered request): make them confirm the current one.
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "Prices have changed since the items were added to your cart, please review your order",
+			"error":   "price_changed",
+			"data":    priced.Mismatches,
+		})
+		return
+	}
+	subtotalAmount := priced.Subtotal
+	baseSubtotal := priced.BaseSubtotal
+	orderItems := priced.Items
 
-	// Initialize amounts
-	discountAmount := 0.0
-	shippingFee := 0.0
+	// Shipping and coupons are evaluated in the base currency, then converted.
+	baseDiscount := 0.0
 	cc := services.NormalizeCountryCode(req.ShippingCountry)
 
-	// Check free shipping first
-	if services.IsFreeShippingCountry(config.DB, cc) {
-		shippingFee = 0
-	} else if totalWeightKg > 0 {
-		// Use same fallback chain as PublicQuote: default template -> carrier template
-		quote, shipErr := services.CalculateShippingQuote(config.DB, cc, totalWeightKg)
-		if errors.Is(shipErr, gorm.ErrRecordNotFound) {
-			// Default template missing; try any carrier template (prioritize FEDEX)
-			type pair struct {
-				Carrier     string
-				ServiceCode string
-			}
-			var pairs []pair
-			qe := config.DB.Model(&models.ShippingCarrierTemplate{}).
-				Select("carrier, service_code").
-				Where("country_code = ? AND is_active = ?", cc, true).
-				Group("carrier, service_code").
-				Order("CASE WHEN carrier = 'FEDEX' THEN 0 WHEN carrier = 'DHL' THEN 1 ELSE 9 END, carrier ASC, service_code ASC").
-				Scan(&pairs).Error
-			if qe == nil && len(pairs) > 0 {
-				quote, shipErr = services.CalculateCarrierShippingQuote(config.DB, pairs[0].Carrier, pairs[0].ServiceCode, cc, totalWeightKg)
-			}
+	var baseShippingFee float64
+	shippingCarrier := services.NormalizeCarrier(req.ShippingCarrier)
+	shippingService := services.NormalizeServiceCode(req.ShippingService)
+	if shippingCarrier != "" {
+		// Re-quote the chosen option server-side; the client's fee is only compared below.
+		var opt services.ShippingOption
+		if opt, err = services.QuoteShippingOption(config.DB, cc, priced.Parcel, shippingCarrier, shippingService); err == nil {
+			baseShippingFee = opt.ShippingFee
+		}
+	} else {
+		shippingService = ""
+		baseShippingFee, err = services.OrderShippingFee(config.DB, cc, priced.Parcel)
+	}
+	if err != nil {
+		var choiceErr *services.ShippingChoiceError
+		if errors.As(err, &choiceErr) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": choiceErr.Message,
+				"error":   "shipping_unavailable",
+			})
+			return
 		}
-		if shipErr != nil {
+		var curErr *services.CurrencyError
+		if errors.As(err, &curErr) {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"success": false,
-				"message": "Shipping template not configured for country/weight",
-				"error":   shipErr.Error(),
+				"message": "Shipping fee could not be converted",
+				"error":   err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Shipping template not configured for country/weight",
+			"error":   err.Error(),
+		})
+		return
+	}
+	if req.ShippingFee != nil {
+		if fee := services.ConvertFromBase(baseShippingFee, currency); math.Abs(*req.ShippingFee-fee) > 0.005 {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"message": "The shipping fee has changed, please review your order",
+				"error":   "shipping_changed",
+				"data": gin.H{
+					"shipping_carrier": shippingCarrier,
+					"shipping_service": shippingService,
+					"shipping_fee":     fee,
+					"previous_fee":     *req.ShippingFee,
+				},
 			})
 			return
 		}
-		shippingFee = quote.ShippingFee
 	}
-	totalAmount := subtotalAmount + shippingFee
 	var couponID *uint
 
 	// Apply coupon if provided
 	if req.CouponCode != "" {
 		couponController := &CouponController{}
-		couponResponse, err := couponController.ApplyCoupon(config.DB, req.CouponCode, 0, subtotalAmount, req.CustomerEmail)
+		couponResponse, err := couponController.CheckCoupon(config.DB, req.CouponCode, baseSubtotal, req.CustomerEmail)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"success": false,
@@ -165,12 +254,48 @@ func (oc *OrderController) CreateOrder(c *gin.Context) {
 				return
 			}
 
-			discountAmount = couponResponse.DiscountAmount
-			totalAmount = couponResponse.FinalAmount + shippingFee
+			baseDiscount = couponResponse.DiscountAmount
 			couponID = &couponResponse.CouponID
 		}
 	}
 
+	shippingFee := services.ConvertFromBase(baseShippingFee, currency)
+	discountAmount := services.ConvertFromBase(baseDiscount, currency)
+	if discountAmount > subtotalAmount {
+		discountAmount = subtotalAmount
+	}
+	totalAmount := subtotalAmount - discountAmount + shippingFee
+	baseTotal := baseSubtotal - baseDiscount + baseShippingFee
+
+	tax, err := services.CalculateOrderTax(config.DB, services.TaxInput{
+		CountryCode:  cc,
+		VATNumber:    strings.TrimSpace(req.VATNumber),
+		Currency:     currency,
+		Subtotal:     subtotalAmount,
+		Discount:     discountAmount,
+		Shipping:     shippingFee,
+		BaseSubtotal: baseSubtotal,
+		BaseDiscount: baseDiscount,
+		BaseShipping: baseShippingFee,
+	})
+	if err != nil {
+		var vatErr *services.VATNumberError
+		if errors.As(err, &vatErr) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": vatErr.Message,
+				"error":   "invalid_vat_number",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to calculate tax",
+			"error":   err.Error(),
+		})
+		return
+	}
+
 	// Create order
 	order := models.Order{
 		OrderNumber:     orderNumber,
@@ -179,6 +304,8 @@ func (oc *OrderController) CreateOrder(c *gin.Context) {
 		CustomerPhone:   req.CustomerPhone,
 		ShippingAddress: req.ShippingAddress,
 		ShippingCountry: services.NormalizeCountryCode(req.ShippingCountry),
+		ShippingCarrier: shippingCarrier,
+		ShippingService: shippingService,
 		ShippingFee:     shippingFee,
 		BillingAddress:  req.BillingAddress,
 		Status:          "pending",
@@ -189,10 +316,18 @@ func (oc *OrderController) CreateOrder(c *gin.Context) {
 		TotalAmount:     totalAmount,
 		CouponCode:      req.CouponCode,
 		CouponID:        couponID,
-		Currency:        "USD",
+		Currency:        currency.Code,
 		Notes:           req.Notes,
 		Items:           orderItems,
+
+		BaseCurrency:       services.BaseCurrency,
+		ExchangeRate:       currency.Rate,
+		BaseSubtotalAmount: baseSubtotal,
+		BaseDiscountAmount: baseDiscount,
+		BaseShippingFee:    baseShippingFee,
+		BaseTotalAmount:    baseTotal,
 	}
+	services.ApplyOrderTax(&order, tax)
 
 	// Get customer ID if authenticated as customer
 	if customerID, exists := c.Get("customer_id"); exists {
@@ -200,6 +335,14 @@ func (oc *OrderController) CreateOrder(c *gin.Context) {
 			order.CustomerID = &cid
 		}
 	}
+	if req.MarketingConsent != nil {
+		order.MarketingConsent = *req.MarketingConsent
+	} else if order.CustomerID != nil {
+		var customer models.Customer
+		if config.DB.Select("id, marketing_consent").First(&customer, *order.CustomerID).Error == nil {
+			order.MarketingConsent = customer.MarketingConsent
+		}
+	}
 
 	// Get user ID if authenticated as admin
 	if userID, exists := c.Get("user_id"); exists {
@@ -208,7 +351,45 @@ func (oc *OrderController) CreateOrder(c *gin.Context) {
 		}
 	}
 
-	if err := config.DB.Create(&order).Error; err != nil {
+	actor := services.ActorCustomer
+	if order.UserID != nil {
+		actor = services.AdminActor(order.UserID)
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		if err := services.ReserveOrderStock(tx, &order); err != nil {
+			return err
+		}
+		if req.CartID != nil {
+			if err := services.MarkCartConverted(tx, *req.CartID, order.ID); err != nil {
+				return err
+			}
+		}
+		return services.RecordOrderHistory(tx, order.ID, "status", "", order.Status, actor, "order placed")
+	})
+	if err != nil {
+		var cartErr *services.CartError
+		if errors.As(err, &cartErr) {
+			// The same cart was checked out concurrently (double submit).
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"message": cartErr.Message,
+				"error":   "cart_converted",
+			})
+			return
+		}
+		var lineErr *services.OrderLineError
+		if errors.As(err, &lineErr) {
+			// Someone else took the last units between pricing and reservation.
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"message": lineErr.Message,
+				"error":   "insufficient_stock",
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"message": "Failed to create order",
@@ -219,13 +400,97 @@ func (oc *OrderController) CreateOrder(c *gin.Context) {
 	// Apply coupon usage if coupon was used
 	if req.CouponCode != "" && couponID != nil {
 		couponController := &CouponController{}
-		couponController.ApplyCoupon(config.DB, req.CouponCode, order.ID, subtotalAmount, req.CustomerEmail)
+		couponController.ApplyCoupon(config.DB, req.CouponCode, order.ID, baseSubtotal, req.CustomerEmail)
+	}
+
+	// Close the shopper's checkout snapshot and credit any abandoned cart email (best-effort).
+	if err := services.AttributeRecoveredOrder(config.DB, &order, recoveryID); err != nil {
+		log.Printf("cart recovery: attribute order %s: %v", order.OrderNumber, err)
 	}
 
 	// Load order with items, products, and coupon
-	config.DB.Preload("Items.Product").Preload("User").Preload("Coupon").First(&order, order.ID)
+	config.DB.Preload("Items.Product").Preload("User").Preload("Coupon").Preload("TaxLines").First(&order, order.ID)
 
 	// Admin notification: order created (best-effort, async)
+	siteURL := requestSiteURL(c)
+	go func(orderID uint, baseURL string) {
+		if err := services.NotifyAdminOrderCreated(config.DB, baseURL, orderID); err != nil {
+			log.Printf("order notification: %v", err)
+		}
+	}(order.ID, siteURL)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success":        true,
+		"message":        "Order created successfully",
+		"data":           order,
+		"tracking_token": services.OrderTrackingToken(order.OrderNumber),
+	})
+}
+
+// checkoutCart loads an active cart for CreateOrder and checks it belongs to the caller:
+// customer carts to the logged-in customer, guest carts to whoever holds the token.
+func checkoutCart(c *gin.Context, cartID uint, token string) (*models.Cart, bool) {
+	var cart models.Cart
+	err := config.DB.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Where("status = ?", models.CartStatusActive).First(&cart, cartID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "Cart not found or already checked out",
+				"error":   "cart_not_found",
+			})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to load cart",
+			"error":   err.Error(),
+		})
+		return nil, false
+	}
+
+	owned := false
+	if cart.CustomerID != nil {
+		if v, exists := c.Get("customer_id"); exists {
+			cid, _ := v.(uint)
+			owned = cid == *cart.CustomerID
+		}
+	} else {
+		if token == "" {
+			token = c.GetHeader("X-Cart-Token")
+		}
+		owned = strings.TrimSpace(token) != "" && strings.TrimSpace(token) == cart.Token
+	}
+	if !owned {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Cart not found or already checked out",
+			"error":   "cart_not_found",
+		})
+		return nil, false
+	}
+	return &cart, true
+}
+
+// canPayOrder reports whether the caller owns an order: the logged-in customer it belongs to,
+// or whoever holds its tracking token or email (as on the guest tracking page).
+func canPayOrder(c *gin.Context, order *models.Order, token, email string) bool {
+	if order.CustomerID != nil {
+		if v, exists := c.Get("customer_id"); exists {
+			if cid, _ := v.(uint); cid == *order.CustomerID {
+				return true
+			}
+		}
+	}
+	if token == "" {
+		token = c.Query("token")
+	}
+	return services.CanTrackOrder(*order, token, email)
+}
+
+// requestSiteURL returns SITE_URL, or a best-effort base URL from the request headers.
+func requestSiteURL(c *gin.Context) string {
 	siteURL := os.Getenv("SITE_URL")
 	if siteURL == "" {
 		proto := c.GetHeader("X-Forwarded-Proto")
@@ -240,20 +505,12 @@ func (oc *OrderController) CreateOrder(c *gin.Context) {
 			siteURL = fmt.Sprintf("%s://%s", proto, host)
 		}
 	}
-	go func(orderID uint, baseURL string) {
-		if err := services.NotifyAdminOrderCreated(config.DB, baseURL, orderID); err != nil {
-			log.Printf("order notification: %v", err)
-		}
-	}(order.ID, siteURL)
-
-	c.JSON(http.StatusCreated, gin.H{
-		"success": true,
-		"message": "Order created successfully",
-		"data":    order,
-	})
+	return siteURL
 }
 
-// ProcessPayment processes PayPal payment
+// ProcessPayment verifies an online payment with its provider before marking the order paid.
+// The browser only tells us which PayPal order / Stripe PaymentIntent to look at; status,
+// amount and currency come from the provider's API (see services.PaymentProvider).
 func (oc *OrderController) ProcessPayment(c *gin.Context) {
 	orderID := c.Param("id")
 
@@ -270,7 +527,7 @@ func (oc *OrderController) ProcessPayment(c *gin.Context) {
 
 	// Find order
 	var order models.Order
-	if err := config.DB.Preload("Items").First(&order, orderID).Error; err != nil {
+	if err := config.DB.Preload("Items").First(&order, orderID).Error; err != nil || !canPayOrder(c, &order, req.TrackingToken, req.Email) {
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
 			"message": "Order not found",
@@ -279,15 +536,74 @@ func (oc *OrderController) ProcessPayment(c *gin.Context) {
 	}
 
 	// Check if order is already paid
-	if order.PaymentStatus == "paid" {
+	if services.IsPaymentCaptured(order.PaymentStatus) || order.PaymentStatus == models.PaymentStatusRefunded {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"message": "Order is already paid",
 		})
 		return
 	}
+	if order.Status == models.OrderStatusCancelled {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Order has been cancelled",
+		})
+		return
+	}
+
+	paymentMethod := strings.ToLower(strings.TrimSpace(req.PaymentMethod))
+	// Methods switched off in the admin panel cannot take new payments (webhooks still record
+	// payments that were already under way).
+	enabled, err := services.IsCheckoutPaymentMethodEnabled(config.DB, paymentMethod)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to load payment settings",
+		})
+		return
+	}
+	if !enabled {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Unsupported payment method",
+		})
+		return
+	}
+	if paymentMethod == services.PaymentMethodBankTransfer {
+		oc.startBankTransfer(c, &order)
+		return
+	}
+	if order.PaymentStatus == models.PaymentStatusPartiallyPaid {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Part of this order has been paid by bank transfer, please transfer the remaining balance",
+		})
+		return
+	}
+	provider, err := services.GetPaymentProvider(config.DB, paymentMethod)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownPaymentProvider):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Unsupported payment method",
+			})
+		case errors.Is(err, services.ErrPaymentProviderNotConfigured):
+			log.Printf("%s: %v", paymentMethod, err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"success": false,
+				"message": services.PaymentMethodLabel(paymentMethod) + " payment verification is not configured",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to load payment settings",
+			})
+		}
+		return
+	}
+	label := services.PaymentMethodLabel(paymentMethod)
 
-	// Extract payment details from payment_data
 	paymentData, ok := req.PaymentData.(map[string]interface{})
 	if !ok {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -297,111 +613,103 @@ func (oc *OrderController) ProcessPayment(c *gin.Context) {
 		return
 	}
 
-	// Extract payment information from frontend PayPal data structure
-	// Frontend sends: { orderID, payerID, details, paymentSource }
-	paypalOrderID, _ := paymentData["orderID"].(string)
-	payerID, _ := paymentData["payerID"].(string)
-	payerEmail := ""
-	transactionID := ""
-
-	// Try to get details object
-	details, hasDetails := paymentData["details"].(map[string]interface{})
-
-	// If no details, try legacy format
-	if !hasDetails {
-		details = paymentData
-	}
-
-	// Extract payer email from details
-	if payer, ok := details["payer"].(map[string]interface{}); ok {
-		if emailInfo, ok := payer["email_address"].(string); ok {
-			payerEmail = emailInfo
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 45*time.Second)
+	defer cancel()
+	verified, err := provider.Capture(ctx, &order, paymentData)
+	if err != nil {
+		var vErr *services.PaymentVerificationError
+		if errors.As(err, &vErr) {
+			c.JSON(http.StatusPaymentRequired, gin.H{
+				"success": false,
+				"message": "Payment could not be verified",
+				"error":   vErr.Reason,
+			})
+			return
 		}
+		log.Printf("%s verify order %s: %v", paymentMethod, order.OrderNumber, err)
+		c.JSON(http.StatusBadGateway, gin.H{
+			"success": false,
+			"message": "Failed to verify payment with " + label,
+		})
+		return
 	}
 
-	// Extract transaction ID from purchase_units
-	if purchaseUnits, ok := details["purchase_units"].([]interface{}); ok && len(purchaseUnits) > 0 {
-		if unit, ok := purchaseUnits[0].(map[string]interface{}); ok {
-			if payments, ok := unit["payments"].(map[string]interface{}); ok {
-				if captures, ok := payments["captures"].([]interface{}); ok && len(captures) > 0 {
-					if capture, ok := captures[0].(map[string]interface{}); ok {
-						if id, ok := capture["id"].(string); ok {
-							transactionID = id
-						}
-					}
-				}
-			}
-		}
+	// A capture can only ever pay one order.
+	var existing models.PaymentTransaction
+	if err := config.DB.Where("transaction_id = ?", verified.TransactionID).First(&existing).Error; err == nil && existing.OrderID != order.ID {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "This payment has already been used for another order",
+		})
+		return
 	}
 
-	// Fallback: use PayPal orderID or details ID as transaction ID
-	if transactionID == "" {
-		if id, ok := details["id"].(string); ok {
-			transactionID = id
-		} else if paypalOrderID != "" {
-			transactionID = paypalOrderID
-		}
+	txStatus := "completed"
+	if verified.Pending {
+		txStatus = "pending"
 	}
-
-	// Create payment transaction record
 	transaction := models.PaymentTransaction{
 		OrderID:       order.ID,
-		TransactionID: transactionID,
-		PaymentMethod: req.PaymentMethod,
-		Amount:        order.TotalAmount,
-		Currency:      order.Currency,
-		Status:        "completed",
-		PayerID:       payerID,
-		PayerEmail:    payerEmail,
-		PaymentData:   fmt.Sprintf("%+v", req.PaymentData),
+		TransactionID: verified.TransactionID,
+		PaymentMethod: paymentMethod,
+		Amount:        verified.Amount,
+		Currency:      verified.Currency,
+		Status:        txStatus,
+		PayerID:       verified.PayerID,
+		PayerEmail:    verified.PayerEmail,
+		PaymentData:   string(verified.Raw),
 	}
 
-	if err := config.DB.Create(&transaction).Error; err != nil {
+	order.PaymentID = verified.PaymentID
+	order.PaymentMethod = paymentMethod
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if existing.ID != 0 {
+			if err := tx.Model(&existing).Updates(map[string]interface{}{"status": txStatus, "payment_data": transaction.PaymentData}).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Create(&transaction).Error; err != nil {
+			return err
+		}
+		if txStatus == "completed" {
+			// Confirms the order and deducts stock.
+			return services.MarkOrderPaid(tx, &order, services.ActorCustomer, label+" capture "+verified.TransactionID)
+		}
+		return tx.Model(&order).Updates(map[string]interface{}{"payment_id": order.PaymentID, "payment_method": order.PaymentMethod}).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": "Failed to create payment transaction",
+			"message": "Failed to record payment",
 		})
 		return
 	}
 
-	// Update order status
-	order.PaymentStatus = "paid"
-	order.PaymentID = paypalOrderID // Use PayPal order ID
-	order.PaymentMethod = req.PaymentMethod
-	order.Status = "confirmed" // Change from pending to confirmed
-
-	if err := config.DB.Save(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	if order.RefundDue {
+		c.JSON(http.StatusConflict, gin.H{
 			"success": false,
-			"message": "Failed to update order status",
+			"message": "This order was cancelled before the payment completed; the payment will be refunded",
+			"error":   "order_cancelled",
 		})
 		return
 	}
 
-	// Update product stock
-	for _, item := range order.Items {
-		config.DB.Model(&models.Product{}).Where("id = ?", item.ProductID).
-			UpdateColumn("stock_quantity", config.DB.Raw("stock_quantity - ?", item.Quantity))
+	if txStatus != "completed" {
+		// e.g. eCheck, payment review or a card still processing: the provider webhook confirms it later.
+		config.DB.Preload("Items.Product").Preload("User").First(&order, order.ID)
+		c.JSON(http.StatusAccepted, gin.H{
+			"success": true,
+			"message": "Payment is pending confirmation from " + label,
+			"data":    order,
+		})
+		return
 	}
 
 	// Load updated order with relationships
 	config.DB.Preload("Items.Product").Preload("User").First(&order, order.ID)
 
 	// Admin notification (best-effort, async)
-	siteURL := os.Getenv("SITE_URL")
-	if siteURL == "" {
-		proto := c.GetHeader("X-Forwarded-Proto")
-		if proto == "" {
-			proto = "https"
-		}
-		host := c.GetHeader("X-Forwarded-Host")
-		if host == "" {
-			host = c.Request.Host
-		}
-		if host != "" {
-			siteURL = fmt.Sprintf("%s://%s", proto, host)
-		}
-	}
+	siteURL := requestSiteURL(c)
 	go func(orderID uint, baseURL string) {
 		if err := services.NotifyAdminOrderPaid(config.DB, baseURL, orderID); err != nil {
 			log.Printf("order notification: %v", err)
@@ -483,6 +791,12 @@ func (oc *OrderController) GetOrder(c *gin.Context) {
 
 	var order models.Order
 	if err := config.DB.Preload("Items.Product").Preload("User").
+		Preload("Transactions", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("StatusHistory", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC, id ASC") }).
+		Preload("StatusHistory.ActorAdmin").
+		Preload("TaxLines").
+		Preload("Shipments", func(db *gorm.DB) *gorm.DB { return db.Order("shipped_at ASC, id ASC") }).
+		Preload("Shipments.Items").
 		First(&order, orderID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
@@ -516,24 +830,6 @@ func (oc *OrderController) UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
-	// Validate status
-	validStatuses := []string{"pending", "confirmed", "processing", "shipped", "delivered", "cancelled"}
-	validStatus := false
-	for _, status := range validStatuses {
-		if req.Status == status {
-			validStatus = true
-			break
-		}
-	}
-
-	if !validStatus {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"message": "Invalid status. Valid statuses: pending, confirmed, processing, shipped, delivered, cancelled",
-		})
-		return
-	}
-
 	var order models.Order
 	if err := config.DB.First(&order, orderID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -543,16 +839,17 @@ func (oc *OrderController) UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
-	order.Status = req.Status
-	if req.Notes != "" {
-		order.Notes = req.Notes
-	}
-
-	if err := config.DB.Save(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"message": "Failed to update order",
-		})
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := services.TransitionOrderStatus(tx, &order, req.Status, adminActor(c), req.Notes); err != nil {
+			return err
+		}
+		if req.Notes != "" {
+			return tx.Model(&order).Update("notes", req.Notes).Error
+		}
+		return nil
+	})
+	if err != nil {
+		respondOrderUpdateError(c, err)
 		return
 	}
 
@@ -567,6 +864,9 @@ func (oc *OrderController) UpdateOrderStatus(c *gin.Context) {
 }
 
 // GetOrderByNumber gets order by order number (public - for order tracking)
+// Public: GET /api/v1/orders/track/:orderNumber?token=... or ?email=...
+// The caller must present the signed token from the order emails or the order's email address;
+// otherwise the answer is the same 404 as for an unknown number. Only the public view is returned.
 func (oc *OrderController) GetOrderByNumber(c *gin.Context) {
 	orderNumber := c.Param("orderNumber")
 
@@ -579,9 +879,12 @@ func (oc *OrderController) GetOrderByNumber(c *gin.Context) {
 	}
 
 	var order models.Order
-	if err := config.DB.Where("order_number = ?", orderNumber).
-		Preload("Items.Product").Preload("User").
-		First(&order).Error; err != nil {
+	err := config.DB.Where("order_number = ?", orderNumber).
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Shipments", func(db *gorm.DB) *gorm.DB { return db.Order("shipped_at ASC, id ASC") }).
+		Preload("Shipments.Items").
+		First(&order).Error
+	if err != nil || !services.CanTrackOrder(order, c.Query("token"), c.Query("email")) {
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
 			"message": "Order not found",
@@ -589,11 +892,17 @@ func (oc *OrderController) GetOrderByNumber(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	resp := gin.H{
 		"success": true,
 		"message": "Order retrieved successfully",
-		"data":    order,
-	})
+		"data":    services.NewPublicOrderView(order),
+	}
+	if services.IsBankTransferPending(&order) {
+		if instr, err := services.GetBankTransferInstructions(config.DB, &order); err == nil {
+			resp["bank_transfer"] = instr
+		}
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // DeleteOrder deletes an order (admin only)
@@ -618,12 +927,21 @@ func (oc *OrderController) DeleteOrder(c *gin.Context) {
 		return
 	}
 
-	// If order was paid, restore product stock before deletion
-	if order.PaymentStatus == "paid" {
-		for _, item := range order.Items {
-			config.DB.Model(&models.Product{}).Where("id = ?", item.ProductID).
-				UpdateColumn("stock_quantity", config.DB.Raw("stock_quantity + ?", item.Quantity))
+	// If order was paid, restore product stock before deletion; otherwise release its reservation.
+	// Both are no-ops for units already returned (e.g. by a cancellation).
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if services.IsPaymentCaptured(order.PaymentStatus) {
+			return services.RestockOrderItems(tx, order.ID)
 		}
+		_, err := services.ReleaseOrderReservations(tx, order.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to restore stock",
+		})
+		return
 	}
 
 	// Delete order items first
@@ -635,8 +953,11 @@ func (oc *OrderController) DeleteOrder(c *gin.Context) {
 		return
 	}
 
-	// Delete payment transactions
+	// Delete payment transactions, status history and stock reservations
 	config.DB.Where("order_id = ?", order.ID).Delete(&models.PaymentTransaction{})
+	config.DB.Where("order_id = ?", order.ID).Delete(&models.OrderStatusHistory{})
+	config.DB.Where("order_id = ?", order.ID).Delete(&models.StockReservation{})
+	config.DB.Where("order_id = ?", order.ID).Delete(&models.OrderTaxLine{})
 
 	// Delete the order
 	if err := config.DB.Delete(&order).Error; err != nil {
@@ -722,56 +1043,40 @@ func (oc *OrderController) UpdateOrder(c *gin.Context) {
 	if req.ShippingCarrier != "" || c.Query("allow_clear") == "1" {
 		order.ShippingCarrier = req.ShippingCarrier
 	}
-	if req.Status != "" {
-		// Validate status
-		validStatuses := []string{"pending", "confirmed", "processing", "shipped", "delivered", "cancelled"}
-		validStatus := false
-		for _, status := range validStatuses {
-			if req.Status == status {
-				validStatus = true
-				break
-			}
-		}
-		if !validStatus {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"success": false,
-				"message": "Invalid status. Valid statuses: pending, confirmed, processing, shipped, delivered, cancelled",
-			})
-			return
-		}
-		order.Status = req.Status
-	}
-	if req.PaymentStatus != "" {
-		// Validate payment status
-		validPaymentStatuses := []string{"pending", "paid", "failed", "refunded"}
-		validPaymentStatus := false
-		for _, status := range validPaymentStatuses {
-			if req.PaymentStatus == status {
-				validPaymentStatus = true
-				break
-			}
-		}
-		if !validPaymentStatus {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"success": false,
-				"message": "Invalid payment status. Valid statuses: pending, paid, failed, refunded",
-			})
-			return
-		}
-		order.PaymentStatus = req.PaymentStatus
-	}
 	if req.Notes != "" {
 		order.Notes = req.Notes
 	}
-
-	if err := config.DB.Save(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	if order.TrackingNumber == "" && (order.Status == models.OrderStatusShipped || order.Status == models.OrderStatusDelivered) {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "Failed to update order",
+			"message": "Cannot clear the tracking number of a shipped order",
 		})
 		return
 	}
 
+	actor := adminActor(c)
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Status fields go through the state machine; everything else is a plain update.
+		if err := tx.Omit("Status", "PaymentStatus").Save(&order).Error; err != nil {
+			return err
+		}
+		if req.PaymentStatus != "" {
+			if err := services.TransitionPaymentStatus(tx, &order, req.PaymentStatus, actor, req.Notes); err != nil {
+				return err
+			}
+		}
+		if req.Status != "" {
+			if err := services.TransitionOrderStatus(tx, &order, req.Status, actor, req.Notes); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondOrderUpdateError(c, err)
+		return
+	}
+
 	// Send shipping notification email (optional)
 	if req.NotifyShipped {
 		// reload setting and check
@@ -785,26 +1090,12 @@ func (oc *OrderController) UpdateOrder(c *gin.Context) {
 					shouldSend = true
 				}
 				if shouldSend {
-					siteURL := os.Getenv("SITE_URL")
-					if siteURL == "" {
-						// best effort from request headers
-						proto := c.GetHeader("X-Forwarded-Proto")
-						if proto == "" {
-							proto = "https"
-						}
-						host := c.GetHeader("X-Forwarded-Host")
-						if host == "" {
-							host = c.Request.Host
-						}
-						if host != "" {
-							siteURL = fmt.Sprintf("%s://%s", proto, host)
-						}
-					}
+					siteURL := requestSiteURL(c)
 
 					// Load items/products so the email includes what was shipped.
 					sendOrder := order
 					_ = config.DB.Preload("Items.Product").First(&sendOrder, order.ID).Error
-					subj, txt, html := services.BuildShipmentNotificationEmail(siteURL, sendOrder)
+					subj, txt, html := services.BuildShipmentNotificationEmail(siteURL, sendOrder, services.LegacyShipment(sendOrder))
 					err := services.SendEmail(config.DB, services.EmailSendOptions{To: order.CustomerEmail, Subject: subj, Text: txt, HTML: html, Headers: map[string]string{"X-Entity-Ref-ID": "shipment:" + order.OrderNumber}})
 					if err == nil {
 						now := time.Now()
@@ -828,3 +1119,41 @@ func (oc *OrderController) UpdateOrder(c *gin.Context) {
 		"data":    order,
 	})
 }
+
+// adminActor returns the status actor for the authenticated admin.
+func adminActor(c *gin.Context) services.StatusActor {
+	if v, ok := c.Get("user_id"); ok {
+		if id, ok := v.(uint); ok {
+			return services.AdminActor(&id)
+		}
+	}
+	return services.AdminActor(nil)
+}
+
+// respondOrderUpdateError maps state machine errors to 400 and everything else to 500.
+func respondOrderUpdateError(c *gin.Context, err error) {
+	var transErr *services.StatusTransitionError
+	if errors.As(err, &transErr) {
+		allowed := services.AllowedOrderTransitions(transErr.From)
+		if transErr.Field == "payment_status" {
+			allowed = services.AllowedPaymentTransitions(transErr.From)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": transErr.Error(),
+			"error":   "invalid_status_transition",
+			"data": gin.H{
+				"field":   transErr.Field,
+				"from":    transErr.From,
+				"to":      transErr.To,
+				"allowed": allowed,
+			},
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"success": false,
+		"message": "Failed to update order",
+		"error":   err.Error(),
+	})
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"fanuc-backend/config"
+	"fanuc-backend/models"
+	"fanuc-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// startBankTransfer handles ProcessPayment with payment_method "bank_transfer": the order
+// waits for the wire and the customer gets the bank instructions (in the response and by email).
+func (oc *OrderController) startBankTransfer(c *gin.Context, order *models.Order) {
+	alreadyWaiting := services.IsBankTransferPending(order)
+	updated, instr, err := services.StartBankTransfer(config.DB, order.ID, services.ActorCustomer)
+	if err != nil {
+		var btErr *services.BankTransferError
+		var transErr *services.StatusTransitionError
+		switch {
+		case errors.As(err, &btErr):
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": btErr.Message})
+		case errors.As(err, &transErr):
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": transErr.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to start bank transfer", "error": err.Error()})
+		}
+		return
+	}
+
+	if !alreadyWaiting && updated.CustomerEmail != "" {
+		siteURL := requestSiteURL(c)
+		go func(o models.Order, in services.BankTransferInstructions, baseURL string) {
+			subj, txt, html := services.BuildBankTransferEmail(baseURL, o, in)
+			if err := services.SendEmail(config.DB, services.EmailSendOptions{
+				To: o.CustomerEmail, Subject: subj, Text: txt, HTML: html,
+				Headers: map[string]string{"X-Entity-Ref-ID": "bank-transfer:" + o.OrderNumber},
+			}); err != nil {
+				log.Printf("bank transfer email for %s: %v", o.OrderNumber, err)
+			}
+		}(*updated, *instr, siteURL)
+	}
+
+	config.DB.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).First(updated, updated.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Please complete the bank transfer using the details provided",
+		"data": gin.H{
+			"order":         services.NewPublicOrderView(*updated),
+			"bank_transfer": instr,
+		},
+	})
+}
+
+// BankPaymentRequest is a received wire entered by an admin.
+type BankPaymentRequest struct {
+	Amount        float64    `json:"amount" binding:"required,gt=0"` // in the order currency
+	BankReference string     `json:"bank_reference"`                 // bank transaction reference (unique)
+	PayerName     string     `json:"payer_name"`
+	ReceivedAt    *time.Time `json:"received_at"`
+	Note          string     `json:"note"`
+}
+
+// RecordBankPayment books a received bank transfer (partial or full) against an order
+// Admin: POST /api/v1/admin/orders/:id/bank-payments
+func (oc *OrderController) RecordBankPayment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid order ID"})
+		return
+	}
+
+	var req BankPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request data",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	transaction, order, err := services.RecordBankPayment(config.DB, uint(id), services.BankPaymentInput{
+		Amount:        req.Amount,
+		BankReference: req.BankReference,
+		PayerName:     req.PayerName,
+		ReceivedAt:    req.ReceivedAt,
+		Note:          req.Note,
+	}, adminActor(c))
+	if err != nil {
+		var btErr *services.BankTransferError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Order not found"})
+		case errors.As(err, &btErr):
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": btErr.Message})
+		default:
+			respondOrderUpdateError(c, err)
+		}
+		return
+	}
+
+	if order.PaymentStatus == models.PaymentStatusPaid {
+		siteURL := requestSiteURL(c)
+		go func(orderID uint, baseURL string) {
+			if err := services.NotifyAdminOrderPaid(config.DB, baseURL, orderID); err != nil {
+				log.Printf("order notification: %v", err)
+			}
+		}(order.ID, siteURL)
+	}
+
+	paid, _, _ := services.OrderPaymentTotals(config.DB, order.ID)
+	config.DB.Preload("Items.Product").Preload("Transactions").First(order, order.ID)
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Bank payment recorded",
+		"data": gin.H{
+			"transaction": transaction,
+			"order":       order,
+			"amount_paid": paid,
+			"balance":     max(order.TotalAmount-paid, 0),
+		},
+	})
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"fanuc-backend/config"
+	"fanuc-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// OrderItemsUpdateRequest is the complete new list of lines for an unpaid order.
+type OrderItemsUpdateRequest struct {
+	Items []struct {
+		ProductID uint `json:"product_id" binding:"required"`
+		Quantity  int  `json:"quantity" binding:"required,min=1"`
+	} `json:"items" binding:"required,min=1,dive"`
+	RemoveCoupon bool   `json:"remove_coupon"`
+	Note         string `json:"note"`
+}
+
+// UpdateOrderItems adds, removes or re-quantities the lines of an unpaid order and recalculates
+// prices, shipping, coupon discount, tax and totals (admin only)
+// Admin: PUT /api/v1/admin/orders/:id/items
+func (oc *OrderController) UpdateOrderItems(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid order ID"})
+		return
+	}
+
+	var req OrderItemsUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request data",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	in := services.OrderEditInput{RemoveCoupon: req.RemoveCoupon, Note: req.Note}
+	for _, it := range req.Items {
+		in.Items = append(in.Items, services.OrderEditLine{ProductID: it.ProductID, Quantity: it.Quantity})
+	}
+	if uid, ok := c.Get("user_id"); ok {
+		if v, ok := uid.(uint); ok {
+			in.AdminID = &v
+		}
+	}
+
+	couponController := &CouponController{}
+	order, err := services.EditOrderItems(config.DB, uint(id), in, couponController.CheckCoupon)
+	if err != nil {
+		var editErr *services.OrderEditError
+		var lineErr *services.OrderLineError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Order not found"})
+		case errors.As(err, &editErr):
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": editErr.Message})
+		case errors.As(err, &lineErr):
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": lineErr.Message, "error": "order_line_error"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to update order items",
+				"error":   err.Error(),
+			})
+		}
+		return
+	}
+
+	config.DB.Preload("Items.Product").Preload("User").Preload("Coupon").Preload("TaxLines").First(order, order.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Order items updated successfully",
+		"data":    order,
+	})
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"net/http"
+
+	"fanuc-backend/config"
+	"fanuc-backend/models"
+	"fanuc-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Admin: GET /api/v1/admin/orders/expiry-settings
+func (oc *OrderController) GetExpirySettings(c *gin.Context) {
+	db := config.GetDB()
+	if db == nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Database not initialized"})
+		return
+	}
+
+	s, err := services.GetOrCreateOrderExpirySetting(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load settings", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK", Data: s})
+}
+
+type updateOrderExpirySettingsRequest struct {
+	Enabled                      *bool `json:"enabled"`
+	ExpireAfterHours             *int  `json:"expire_after_hours"`
+	BankTransferExpireAfterHours *int  `json:"bank_transfer_expire_after_hours"` // 0 = never expire bank transfers
+	ReminderEnabled              *bool `json:"reminder_enabled"`
+	ReminderBeforeHours          *int  `json:"reminder_before_hours"`
+}
+
+// Admin: PUT /api/v1/admin/orders/expiry-settings
+func (oc *OrderController) UpdateExpirySettings(c *gin.Context) {
+	db := config.GetDB()
+	if db == nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Database not initialized"})
+		return
+	}
+
+	var req updateOrderExpirySettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request", Error: err.Error()})
+		return
+	}
+
+	s, err := services.GetOrCreateOrderExpirySetting(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load settings", Error: err.Error()})
+		return
+	}
+
+	expireAfter := s.ExpireAfterHours
+	if req.ExpireAfterHours != nil {
+		expireAfter = *req.ExpireAfterHours
+	}
+	remindBefore := s.ReminderBeforeHours
+	if req.ReminderBeforeHours != nil {
+		remindBefore = *req.ReminderBeforeHours
+	}
+	if expireAfter < 1 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "expire_after_hours must be at least 1"})
+		return
+	}
+	if req.BankTransferExpireAfterHours != nil && *req.BankTransferExpireAfterHours < 0 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "bank_transfer_expire_after_hours must not be negative"})
+		return
+	}
+	if remindBefore < 0 || remindBefore >= expireAfter {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "reminder_before_hours must be between 0 and expire_after_hours"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+	if req.ExpireAfterHours != nil {
+		updates["expire_after_hours"] = *req.ExpireAfterHours
+	}
+	if req.BankTransferExpireAfterHours != nil {
+		updates["bank_transfer_expire_after_hours"] = *req.BankTransferExpireAfterHours
+	}
+	if req.ReminderEnabled != nil {
+		updates["reminder_enabled"] = *req.ReminderEnabled
+	}
+	if req.ReminderBeforeHours != nil {
+		updates["reminder_before_hours"] = *req.ReminderBeforeHours
+	}
+
+	if len(updates) > 0 {
+		if err := db.Model(s).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to update settings", Error: err.Error()})
+			return
+		}
+	}
+
+	// Reload
+	s, _ = services.GetOrCreateOrderExpirySetting(db)
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Settings updated", Data: s})
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"fanuc-backend/config"
+	"fanuc-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseExportDate accepts YYYY-MM-DD or RFC3339. A plain date used as an upper bound
+// means the end of that day (UTC).
+func parseExportDate(v string, endOfDay bool) (*time.Time, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	d, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		d = d.Add(24*time.Hour - time.Second)
+	}
+	return &d, nil
+}
+
+// ExportOrders streams orders for accounting (admin only)
+// Admin: GET /api/v1/admin/orders/export?format=xlsx|csv&sheet=orders|items&date_from=&date_to=&status=&payment_status=&country=
+// XLSX has an Orders and an Items sheet; CSV returns the sheet selected by `sheet` (default orders).
+func (oc *OrderController) ExportOrders(c *gin.Context) {
+	from, err := parseExportDate(c.Query("date_from"), false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "date_from must be YYYY-MM-DD or RFC3339"})
+		return
+	}
+	to, err := parseExportDate(c.Query("date_to"), true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "date_to must be YYYY-MM-DD or RFC3339"})
+		return
+	}
+	filter := services.OrderExportFilter{
+		DateFrom:      from,
+		DateTo:        to,
+		Status:        strings.TrimSpace(c.Query("status")),
+		PaymentStatus: strings.TrimSpace(c.Query("payment_status")),
+		Country:       c.Query("country"),
+	}
+
+	format := strings.ToLower(strings.TrimSpace(c.DefaultQuery("format", "xlsx")))
+	sheet := strings.ToLower(strings.TrimSpace(c.DefaultQuery("sheet", services.OrderExportSheetOrders)))
+	if sheet != services.OrderExportSheetOrders && sheet != services.OrderExportSheetItems {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "sheet must be orders or items"})
+		return
+	}
+
+	name := "orders-" + time.Now().UTC().Format("20060102-150405")
+	switch format {
+	case "xlsx":
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.xlsx\"", name))
+		c.Status(http.StatusOK)
+		err = services.WriteOrdersXLSX(config.DB, c.Writer, filter)
+	case "csv":
+		if sheet == services.OrderExportSheetItems {
+			name += "-items"
+		}
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", name))
+		c.Status(http.StatusOK)
+		err = services.WriteOrdersCSV(config.DB, c.Writer, filter, sheet)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "format must be xlsx or csv"})
+		return
+	}
+	if err != nil {
+		// Headers are already sent; the client gets a truncated file.
+		log.Printf("order export (%s): %v", format, err)
+	}
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"fanuc-backend/config"
+	"fanuc-backend/models"
+	"fanuc-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Admin: GET /api/v1/admin/orders/:id/invoice?type=proforma|invoice
+// Without type, paid orders get the commercial invoice and unpaid ones the proforma.
+func (oc *OrderController) DownloadInvoice(c *gin.Context) {
+	var order models.Order
+	if err := config.DB.Preload("Items").Preload("TaxLines").First(&order, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "Order not found"})
+		return
+	}
+	sendOrderInvoice(c, order)
+}
+
+// Customer: GET /api/v1/customer/orders/:id/invoice?type=proforma|invoice
+func (oc *OrderController) DownloadMyInvoice(c *gin.Context) {
+	customerID, exists := c.Get("customer_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{Success: false, Message: "Unauthorized"})
+		return
+	}
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid order ID"})
+		return
+	}
+
+	db := config.GetDB()
+	var customer models.Customer
+	if err := db.First(&customer, customerID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to retrieve customer data"})
+		return
+	}
+
+	// Same ownership rule as GetMyOrderDetails (customer_id OR customer_email).
+	var order models.Order
+	if err := db.Where("id = ? AND (customer_id = ? OR customer_email = ?)", orderID, customerID, customer.Email).
+		Preload("Items").Preload("TaxLines").First(&order).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "Order not found"})
+		return
+	}
+	sendOrderInvoice(c, order)
+}
+
+func sendOrderInvoice(c *gin.Context, order models.Order) {
+	docType := strings.ToLower(strings.TrimSpace(c.Query("type")))
+	if docType == "" {
+		docType = services.DefaultInvoiceType(order)
+	}
+
+	inv, err := services.IssueOrderInvoice(config.DB, &order, docType)
+	if err != nil {
+		var invErr *services.InvoiceError
+		if errors.As(err, &invErr) {
+			c.JSON(http.StatusConflict, models.APIResponse{Success: false, Message: invErr.Message, Error: "invoice_unavailable"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to issue invoice", Error: err.Error()})
+		return
+	}
+
+	b, err := services.RenderOrderInvoicePDF(inv, order, services.LoadInvoiceCompanyProfile(config.DB))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to render invoice", Error: err.Error()})
+		return
+	}
+
+	filename := services.OrderInvoiceFilename(inv)
+	disposition := "attachment"
+	if c.Query("inline") == "1" {
+		disposition = "inline"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("%s; filename=\"%s\"", disposition, filename))
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, "application/pdf", b)
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"fanuc-backend/config"
+	"fanuc-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// OrderRefundRequest is the admin refund payload.
+// Use full=true, or items (per line), or amount (arbitrary); see services.RefundInput.
+type OrderRefundRequest struct {
+	Full            bool                       `json:"full"`
+	Amount          *float64                   `json:"amount" binding:"omitempty,gt=0"`
+	Items           []services.RefundLineInput `json:"items"`
+	IncludeShipping bool                       `json:"include_shipping"`
+	Restock         bool                       `json:"restock"`
+	ReverseCoupon   bool                       `json:"reverse_coupon"`
+	Reason          string                     `json:"reason"`
+	// RefundViaProvider defaults to true for PayPal and Stripe orders; false only records the refund.
+	RefundViaProvider *bool `json:"refund_via_provider"`
+	// NotifyCustomer defaults to true.
+	NotifyCustomer *bool `json:"notify_customer"`
+}
+
+// RefundOrder records a full or partial refund (admin only)
+// Admin: POST /api/v1/admin/orders/:id/refunds
+func (oc *OrderController) RefundOrder(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid order ID"})
+		return
+	}
+
+	var req OrderRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request data",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	var order struct{ PaymentMethod string }
+	if err := config.DB.Table("orders").Select("payment_method").Where("id = ?", id).Scan(&order).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to load order"})
+		return
+	}
+	viaProvider := services.IsOnlinePaymentMethod(order.PaymentMethod)
+	if req.RefundViaProvider != nil {
+		viaProvider = *req.RefundViaProvider
+	}
+
+	in := services.RefundInput{
+		Full:            req.Full,
+		Lines:           req.Items,
+		Amount:          req.Amount,
+		IncludeShipping: req.IncludeShipping,
+		Restock:         req.Restock,
+		ReverseCoupon:   req.ReverseCoupon,
+		Reason:          req.Reason,
+		ViaProvider:     viaProvider,
+	}
+	if uid, ok := c.Get("user_id"); ok {
+		if v, ok := uid.(uint); ok {
+			in.ActorAdminID = &v
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 45*time.Second)
+	defer cancel()
+	result, updated, err := services.CreateOrderRefund(ctx, config.DB, uint(id), in)
+	if err != nil {
+		var refundErr *services.RefundError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Order not found"})
+		case errors.As(err, &refundErr):
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": refundErr.Message})
+		default:
+			log.Printf("refund order %d: %v", id, err)
+			c.JSON(http.StatusBadGateway, gin.H{"success": false, "message": "Failed to process refund", "error": err.Error()})
+		}
+		return
+	}
+
+	if req.NotifyCustomer == nil || *req.NotifyCustomer {
+		if updated.CustomerEmail != "" {
+			subj, txt, html := services.BuildRefundNotificationEmail(requestSiteURL(c), *updated, *result, req.Reason)
+			if err := services.SendEmail(config.DB, services.EmailSendOptions{To: updated.CustomerEmail, Subject: subj, Text: txt, HTML: html, Headers: map[string]string{"X-Entity-Ref-ID": "refund:" + result.Transaction.TransactionID}}); err != nil {
+				// Refund is recorded; just surface the email problem.
+				c.Header("X-Email-Warn", err.Error())
+			}
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Refund recorded successfully",
+		"data": gin.H{
+			"refund": result,
+			"order":  updated,
+		},
+	})
+}
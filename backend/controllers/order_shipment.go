@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"fanuc-backend/config"
+	"fanuc-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ShipmentRequest is the admin payload for one parcel. Leave items empty to ship
+// everything that has not shipped yet.
+type ShipmentRequest struct {
+	Carrier        string                       `json:"carrier"`
+	TrackingNumber string                       `json:"tracking_number" binding:"required"`
+	ShippedAt      *time.Time                   `json:"shipped_at"`
+	Notes          string                       `json:"notes"`
+	Items          []services.ShipmentLineInput `json:"items"`
+	// NotifyCustomer defaults to true (needs shipping notifications enabled in email settings).
+	NotifyCustomer *bool `json:"notify_customer"`
+}
+
+// GetOrderShipments lists an order's shipments (admin only)
+// Admin: GET /api/v1/admin/orders/:id/shipments
+func (oc *OrderController) GetOrderShipments(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid order ID"})
+		return
+	}
+	shipments, err := services.ListOrderShipments(config.DB, uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to load shipments"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Shipments retrieved successfully",
+		"data":    shipments,
+	})
+}
+
+// CreateShipment records a (partial) shipment and emails the customer about it (admin only)
+// Admin: POST /api/v1/admin/orders/:id/shipments
+func (oc *OrderController) CreateShipment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Invalid order ID"})
+		return
+	}
+
+	var req ShipmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request data",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	in := services.ShipmentInput{
+		Carrier:        req.Carrier,
+		TrackingNumber: req.TrackingNumber,
+		ShippedAt:      req.ShippedAt,
+		Notes:          req.Notes,
+		Lines:          req.Items,
+	}
+	if uid, ok := c.Get("user_id"); ok {
+		if v, ok := uid.(uint); ok {
+			in.ActorAdminID = &v
+		}
+	}
+
+	shipment, order, err := services.CreateShipment(config.DB, uint(id), in)
+	if err != nil {
+		var shipErr *services.ShipmentError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Order not found"})
+		case errors.As(err, &shipErr):
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": shipErr.Message})
+		default:
+			respondOrderUpdateError(c, err)
+		}
+		return
+	}
+
+	if req.NotifyCustomer == nil || *req.NotifyCustomer {
+		setting, err := services.GetOrCreateEmailSetting(config.DB)
+		if err == nil && setting.Enabled && setting.ShippingNotificationsEnabled && order.CustomerEmail != "" {
+			subj, txt, html := services.BuildShipmentNotificationEmail(requestSiteURL(c), *order, *shipment)
+			ref := "shipment:" + order.OrderNumber + ":" + strconv.FormatUint(uint64(shipment.ID), 10)
+			if err := services.SendEmail(config.DB, services.EmailSendOptions{To: order.CustomerEmail, Subject: subj, Text: txt, HTML: html, Headers: map[string]string{"X-Entity-Ref-ID": ref}}); err != nil {
+				// Shipment is recorded; just surface the email problem.
+				c.Header("X-Email-Warn", err.Error())
+			} else if err := services.MarkShipmentEmailSent(config.DB, shipment); err != nil {
+				log.Printf("mark shipment %d emailed: %v", shipment.ID, err)
+			}
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Shipment recorded successfully",
+		"data": gin.H{
+			"shipment": shipment,
+			"order":    order,
+		},
+	})
+}
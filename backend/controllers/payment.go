@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"fanuc-backend/config"
+	"fanuc-backend/models"
+	"fanuc-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PaymentController struct{}
+
+// Public: GET /api/v1/public/payment-methods
+// Lists the payment methods enabled in the admin panel, with the public config each
+// checkout widget needs (PayPal client ID, Stripe publishable key).
+func (pc *PaymentController) GetPaymentMethods(c *gin.Context) {
+	methods, err := services.CheckoutPaymentMethods(config.GetDB())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load payment methods", Error: err.Error()})
+		return
+	}
+	if methods == nil {
+		methods = []services.CheckoutPaymentMethod{}
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK", Data: methods})
+}
+
+// PaymentIntentRequest selects the online payment method to start.
+type PaymentIntentRequest struct {
+	PaymentMethod string `json:"payment_method" binding:"required"`
+	// Guests prove the order is theirs with the tracking token or the order email.
+	TrackingToken string `json:"tracking_token"`
+	Email         string `json:"email"`
+}
+
+// CreatePaymentIntent starts an online payment for an unpaid order; the checkout completes it
+// in the browser and then calls ProcessPayment.
+// Public: POST /api/v1/orders/:id/payment-intent
+func (oc *OrderController) CreatePaymentIntent(c *gin.Context) {
+	var req PaymentIntentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request data",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	var order models.Order
+	if err := config.DB.First(&order, c.Param("id")).Error; err != nil || !canPayOrder(c, &order, req.TrackingToken, req.Email) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Order not found"})
+		return
+	}
+	switch {
+	case services.IsPaymentCaptured(order.PaymentStatus) || order.PaymentStatus == models.PaymentStatusRefunded:
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Order is already paid"})
+		return
+	case order.Status == models.OrderStatusCancelled:
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Order has been cancelled"})
+		return
+	case order.PaymentStatus == models.PaymentStatusPartiallyPaid:
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Part of this order has been paid by bank transfer, please transfer the remaining balance"})
+		return
+	}
+
+	method := strings.ToLower(strings.TrimSpace(req.PaymentMethod))
+	enabled, err := services.IsCheckoutPaymentMethodEnabled(config.DB, method)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to load payment settings"})
+		return
+	}
+	if !enabled || !services.IsOnlinePaymentMethod(method) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Unsupported payment method"})
+		return
+	}
+	provider, err := services.GetPaymentProvider(config.DB, method)
+	if err != nil {
+		log.Printf("%s: %v", method, err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"message": services.PaymentMethodLabel(method) + " payments are not configured",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+	intent, err := provider.CreateIntent(ctx, &order)
+	if err != nil {
+		log.Printf("%s create intent for %s: %v", method, order.OrderNumber, err)
+		c.JSON(http.StatusBadGateway, gin.H{
+			"success": false,
+			"message": "Failed to start payment with " + services.PaymentMethodLabel(method),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Payment started",
+		"data":    intent,
+	})
+}
+
+// handlePaymentWebhook verifies, deduplicates and applies a provider webhook.
+//
+// Providers retry until they receive a 2xx, so only signature/processing failures return errors;
+// duplicates and events we don't care about are acknowledged with 200.
+func handlePaymentWebhook(c *gin.Context, method string) {
+	db := config.GetDB()
+	if db == nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Database not initialized"})
+		return
+	}
+	label := services.PaymentMethodLabel(method)
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid body", Error: err.Error()})
+		return
+	}
+
+	provider, err := services.GetPaymentProvider(db, method)
+	if err != nil {
+		log.Printf("%s webhook: %v", method, err)
+		if errors.Is(err, services.ErrPaymentProviderNotConfigured) {
+			c.JSON(http.StatusServiceUnavailable, models.APIResponse{Success: false, Message: label + " is not configured"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load settings", Error: err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 20*time.Second)
+	defer cancel()
+	ev, err := provider.ParseWebhook(ctx, c.Request.Header, body)
+	if err != nil {
+		var payloadErr *services.PaymentWebhookPayloadError
+		switch {
+		case errors.Is(err, services.ErrPaymentWebhookSignature):
+			c.JSON(http.StatusUnauthorized, models.APIResponse{Success: false, Message: "Invalid signature"})
+		case errors.As(err, &payloadErr):
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid webhook event", Error: payloadErr.Reason})
+		default:
+			log.Printf("%s webhook: verify: %v", method, err)
+			c.JSON(http.StatusBadGateway, models.APIResponse{Success: false, Message: "Failed to verify signature"})
+		}
+		return
+	}
+
+	// Deduplicate by event ID; failed events may be retried.
+	var rec models.PaymentWebhookEvent
+	err = db.Where("provider = ? AND event_id = ?", method, ev.EventID).First(&rec).Error
+	switch {
+	case err == nil && rec.Status != "failed":
+		c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Duplicate event"})
+		return
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		rec = models.PaymentWebhookEvent{
+			Provider:  method,
+			EventID:   ev.EventID,
+			EventType: ev.EventType,
+			Status:    "received",
+			Payload:   string(body),
+		}
+		if e := db.Create(&rec).Error; e != nil {
+			// Lost a race with a concurrent delivery of the same event.
+			c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Duplicate event"})
+			return
+		}
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load event", Error: err.Error()})
+		return
+	}
+
+	result, herr := services.HandlePaymentWebhookEvent(db, ev)
+	now := time.Now()
+	if herr != nil {
+		log.Printf("%s webhook: %s %s: %v", method, ev.EventType, ev.EventID, herr)
+		db.Model(&rec).Updates(map[string]interface{}{"status": "failed", "error": herr.Error(), "processed_at": &now})
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to process event"})
+		return
+	}
+	db.Model(&rec).Updates(map[string]interface{}{
+		"status":       result.Status,
+		"error":        result.Note,
+		"resource_id":  result.ResourceID,
+		"order_id":     result.OrderID,
+		"processed_at": &now,
+	})
+
+	if result.OrderPaid && result.OrderID != nil {
+		go func(orderID uint, baseURL string) {
+			if err := services.NotifyAdminOrderPaid(config.DB, baseURL, orderID); err != nil {
+				log.Printf("order notification: %v", err)
+			}
+		}(*result.OrderID, requestSiteURL(c))
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK"})
+}
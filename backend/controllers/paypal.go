@@ -6,6 +6,8 @@ import (
 
 	"fanuc-backend/config"
 	"fanuc-backend/models"
+	"fanuc-backend/services"
+	"fanuc-backend/utils"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -14,18 +16,7 @@ import (
 type PayPalController struct{}
 
 func getOrCreatePayPalSetting(db *gorm.DB) (*models.PayPalSetting, error) {
-	var s models.PayPalSetting
-	if err := db.First(&s, 1).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			s = models.PayPalSetting{ID: 1, Enabled: false, Mode: "sandbox", Currency: "USD"}
-			if e := db.Create(&s).Error; e != nil {
-				return nil, e
-			}
-		} else {
-			return nil, err
-		}
-	}
-	return &s, nil
+	return services.GetOrCreatePayPalSetting(db)
 }
 
 // Public: GET /api/v1/public/paypal/config
@@ -59,7 +50,7 @@ func (pc *PayPalController) GetSettings(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK", Data: s})
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK", Data: s.ToResponse()})
 }
 
 type updatePayPalSettingsRequest struct {
@@ -68,6 +59,33 @@ type updatePayPalSettingsRequest struct {
 	ClientIDSandbox *string `json:"client_id_sandbox"`
 	ClientIDLive    *string `json:"client_id_live"`
 	Currency        *string `json:"currency"`
+
+	WebhookIDSandbox *string `json:"webhook_id_sandbox"`
+	WebhookIDLive    *string `json:"webhook_id_live"`
+
+	// Secrets are write-only. Empty means "keep existing" unless ?allow_clear=1.
+	ClientSecretSandbox *string `json:"client_secret_sandbox"`
+	ClientSecretLive    *string `json:"client_secret_live"`
+}
+
+// encryptSettingSecret applies a write-only secret update to dst (PayPal and Stripe settings).
+func encryptSettingSecret(dst *string, v *string, allowClear bool) error {
+	if v == nil {
+		return nil
+	}
+	secret := strings.TrimSpace(*v)
+	if secret == "" {
+		if allowClear {
+			*dst = ""
+		}
+		return nil
+	}
+	enc, err := utils.EncryptSecret(secret)
+	if err != nil {
+		return err
+	}
+	*dst = enc
+	return nil
 }
 
 // Admin: PUT /api/v1/admin/paypal/settings
@@ -117,11 +135,31 @@ func (pc *PayPalController) UpdateSettings(c *gin.Context) {
 		}
 		s.Currency = cur
 	}
+	if req.WebhookIDSandbox != nil {
+		s.WebhookIDSandbox = strings.TrimSpace(*req.WebhookIDSandbox)
+	}
+	if req.WebhookIDLive != nil {
+		s.WebhookIDLive = strings.TrimSpace(*req.WebhookIDLive)
+	}
+	allowClear := c.Query("allow_clear") == "1"
+	if err := encryptSettingSecret(&s.ClientSecretSandboxEnc, req.ClientSecretSandbox, allowClear); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Failed to encrypt client secret", Error: err.Error()})
+		return
+	}
+	if err := encryptSettingSecret(&s.ClientSecretLiveEnc, req.ClientSecretLive, allowClear); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Failed to encrypt client secret", Error: err.Error()})
+		return
+	}
 
 	if err := db.Save(s).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to save settings", Error: err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Saved", Data: s})
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Saved", Data: s.ToResponse()})
+}
+
+// Public: POST /api/v1/public/paypal/webhook
+func (pc *PayPalController) Webhook(c *gin.Context) {
+	handlePaymentWebhook(c, services.PaymentMethodPayPal)
 }
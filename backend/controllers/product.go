@@ -8,6 +8,7 @@ import (
 	"fanuc-backend/utils"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	neturl "net/url"
 	"strconv"
@@ -142,6 +143,25 @@ func deserializeImageURLs(imageURLsJSON string) []string {
 type ProductResponse struct {
 	models.Product
 	ImageURLs []string `json:"image_urls"`
+
+	// Set when the request asked for ?currency=; Price and ComparePrice are then in Currency.
+	Currency  string   `json:"currency,omitempty"`
+	BasePrice *float64 `json:"base_price,omitempty"`
+}
+
+// localizeProductResponse converts the response prices from the base currency into cur.
+func localizeProductResponse(r *ProductResponse, cur *models.Currency) {
+	if cur == nil {
+		return
+	}
+	base := r.Price
+	r.BasePrice = &base
+	r.Price = services.ConvertFromBase(base, cur)
+	if r.ComparePrice != nil {
+		v := services.ConvertFromBase(*r.ComparePrice, cur)
+		r.ComparePrice = &v
+	}
+	r.Currency = cur.Code
 }
 
 // Helper function to convert Product to ProductResponse
@@ -174,6 +194,10 @@ func convertToProductResponse(product models.Product) ProductResponse {
 // GetProducts returns paginated list of products
 func (pc *ProductController) GetProducts(c *gin.Context) {
 	db := config.GetDB()
+	currency, ok := requestCurrency(c)
+	if !ok {
+		return
+	}
 
 	// Parse pagination parameters
 	page, pageSize := utils.ParsePagination(c.Query("page"), c.Query("page_size"))
@@ -254,7 +278,9 @@ func (pc *ProductController) GetProducts(c *gin.Context) {
 	// Convert products to response format with deserialized image URLs
 	var productResponses []ProductResponse
 	for _, product := range products {
-		productResponses = append(productResponses, convertToProductResponse(product))
+		r := convertToProductResponse(product)
+		localizeProductResponse(&r, currency)
+		productResponses = append(productResponses, r)
 	}
 
 	// Calculate total pages
@@ -574,6 +600,10 @@ func buildCandidates(source string, sku string) ([]string, []string) {
 // GetProduct returns a single product by ID
 func (pc *ProductController) GetProduct(c *gin.Context) {
 	id := c.Param("id")
+	currency, ok := requestCurrency(c)
+	if !ok {
+		return
+	}
 
 	var product models.Product
 	db := config.GetDB()
@@ -597,6 +627,7 @@ func (pc *ProductController) GetProduct(c *gin.Context) {
 
 	// Convert to response format with deserialized image URLs
 	productResponse := convertToProductResponse(product)
+	localizeProductResponse(&productResponse, currency)
 
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
@@ -608,6 +639,10 @@ func (pc *ProductController) GetProduct(c *gin.Context) {
 // GetProductBySKU returns a single product by SKU
 func (pc *ProductController) GetProductBySKU(c *gin.Context) {
 	sku := c.Param("sku")
+	currency, ok := requestCurrency(c)
+	if !ok {
+		return
+	}
 
 	product, err := findProductBySKUInternal(sku)
 	if err != nil {
@@ -621,6 +656,7 @@ func (pc *ProductController) GetProductBySKU(c *gin.Context) {
 
 	// Convert to response format with deserialized image URLs
 	productResponse := convertToProductResponse(product)
+	localizeProductResponse(&productResponse, currency)
 
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
@@ -636,6 +672,10 @@ func (pc *ProductController) GetProductBySKUQuery(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Missing sku parameter", Error: "invalid_request"})
 		return
 	}
+	currency, ok := requestCurrency(c)
+	if !ok {
+		return
+	}
 	product, err := findProductBySKUInternal(sku)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -646,6 +686,7 @@ func (pc *ProductController) GetProductBySKUQuery(c *gin.Context) {
 		return
 	}
 	productResponse := convertToProductResponse(product)
+	localizeProductResponse(&productResponse, currency)
 	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Product retrieved successfully", Data: productResponse})
 }
 
@@ -887,13 +928,23 @@ func (pc *ProductController) CreateProduct(c *gin.Context) {
 		MetaDescription:  req.MetaDescription,
 		MetaKeywords:     req.MetaKeywords,
 		ImageURLs:        imageURLsJSON,
+
+		BackorderPolicy:   req.BackorderPolicy,
+		ExpectedArrivalAt: req.ExpectedArrivalAt,
+		LengthCm:          req.LengthCm,
+		WidthCm:           req.WidthCm,
+		HeightCm:          req.HeightCm,
+	}
+	if product.BackorderPolicy == "" {
+		product.BackorderPolicy = models.BackorderDeny
 	}
+	services.ApplyProductDimensions(&product)
 
 	// Start transaction
 	tx := db.Begin()
 
 	// Create product (only known DB columns)
-	if err := tx.Select("SKU", "Name", "Slug", "ShortDescription", "Description", "Price", "ComparePrice", "StockQuantity", "Weight", "Dimensions", "Brand", "Model", "PartNumber", "CategoryID", "IsActive", "IsFeatured", "MetaTitle", "MetaDescription", "MetaKeywords", "ImageURLs").Create(&product).Error; err != nil {
+	if err := tx.Select("SKU", "Name", "Slug", "ShortDescription", "Description", "Price", "ComparePrice", "StockQuantity", "Weight", "Dimensions", "Brand", "Model", "PartNumber", "CategoryID", "IsActive", "IsFeatured", "MetaTitle", "MetaDescription", "MetaKeywords", "ImageURLs", "BackorderPolicy", "ExpectedArrivalAt", "LengthCm", "WidthCm", "HeightCm").Create(&product).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
@@ -957,7 +1008,7 @@ func (pc *ProductController) CreateProduct(c *gin.Context) {
 	services.InvalidatePublicCaches(c.Request.Context(), "product:create", nil)
 
 	// Load created product with relations (select only known columns)
-	db.Select("id,sku,name,slug,short_description,description,price,compare_price,stock_quantity,weight,dimensions,brand,model,part_number,category_id,is_active,is_featured,meta_title,meta_description,meta_keywords,image_urls,created_at,updated_at").
+	db.Select("id,sku,name,slug,short_description,description,price,compare_price,stock_quantity,weight,dimensions,brand,model,part_number,category_id,is_active,is_featured,meta_title,meta_description,meta_keywords,image_urls,backorder_policy,expected_arrival_at,created_at,updated_at").
 		Preload("Category").
 		First(&product, product.ID)
 
@@ -1072,7 +1123,14 @@ func (pc *ProductController) UpdateProduct(c *gin.Context) {
 	product.ComparePrice = req.ComparePrice
 	product.StockQuantity = req.StockQuantity
 	product.Weight = req.Weight
+	if req.LengthCm != nil || req.WidthCm != nil || req.HeightCm != nil {
+		product.LengthCm, product.WidthCm, product.HeightCm = req.LengthCm, req.WidthCm, req.HeightCm
+	} else if req.Dimensions != product.Dimensions {
+		// Clients without the structured fields: re-read the size from the new text.
+		product.LengthCm, product.WidthCm, product.HeightCm = nil, nil, nil
+	}
 	product.Dimensions = req.Dimensions
+	services.ApplyProductDimensions(&product)
 	product.Brand = req.Brand
 	product.Model = req.Model
 	product.PartNumber = req.PartNumber
@@ -1085,6 +1143,13 @@ func (pc *ProductController) UpdateProduct(c *gin.Context) {
 	product.MetaDescription = req.MetaDescription
 	product.MetaKeywords = req.MetaKeywords
 	product.ImageURLs = imageURLsJSON
+	if req.BackorderPolicy != "" {
+		product.BackorderPolicy = req.BackorderPolicy
+	} else if product.BackorderPolicy == "" {
+		product.BackorderPolicy = models.BackorderDeny
+	}
+	product.ExpectedArrivalAt = req.ExpectedArrivalAt
+	oldAvailable := product.AvailableQuantity()
 
 	// Start transaction
 	tx := db.Begin()
@@ -1093,11 +1158,13 @@ func (pc *ProductController) UpdateProduct(c *gin.Context) {
 	// Perform explicit update to avoid referencing non-existent columns on legacy DBs
 	rawSQL := `UPDATE products SET
         sku=?, name=?, slug=?, short_description=?, description=?, price=?, compare_price=?, stock_quantity=?, weight=?, dimensions=?,
-        brand=?, model=?, part_number=?, warranty_period=?, lead_time=?, category_id=?, is_active=?, is_featured=?, meta_title=?, meta_description=?, meta_keywords=?, image_urls=?
+        brand=?, model=?, part_number=?, warranty_period=?, lead_time=?, category_id=?, is_active=?, is_featured=?, meta_title=?, meta_description=?, meta_keywords=?, image_urls=?,
+        backorder_policy=?, expected_arrival_at=?, length_cm=?, width_cm=?, height_cm=?
         WHERE id=?`
 	if err := tx.Exec(rawSQL,
 		product.SKU, product.Name, product.Slug, product.ShortDescription, product.Description, product.Price, product.ComparePrice, product.StockQuantity, product.Weight, product.Dimensions,
 		product.Brand, product.Model, product.PartNumber, product.WarrantyPeriod, product.LeadTime, product.CategoryID, product.IsActive, product.IsFeatured, product.MetaTitle, product.MetaDescription, product.MetaKeywords, product.ImageURLs,
+		product.BackorderPolicy, product.ExpectedArrivalAt, product.LengthCm, product.WidthCm, product.HeightCm,
 		product.ID,
 	).Error; err != nil {
 		tx.Rollback()
@@ -1164,8 +1231,17 @@ func (pc *ProductController) UpdateProduct(c *gin.Context) {
 	// Invalidate caches (Redis + optional Cloudflare)
 	services.InvalidatePublicCaches(c.Request.Context(), "product:update", nil)
 
+	// Received stock goes to waiting backorders first.
+	if product.StockQuantity-product.ReservedQuantity > oldAvailable {
+		if fills, err := services.AllocateBackorderedStock(db, product.ID); err != nil {
+			log.Printf("allocate backorders for %s: %v", product.SKU, err)
+		} else if len(fills) > 0 {
+			go services.NotifyBackorderFilled(config.DB, requestSiteURL(c), fills)
+		}
+	}
+
 	// Load updated product with relations (select only known columns)
-	db.Select("id,sku,name,slug,short_description,description,price,compare_price,stock_quantity,weight,dimensions,brand,model,part_number,category_id,is_active,is_featured,meta_title,meta_description,meta_keywords,image_urls,created_at,updated_at").
+	db.Select("id,sku,name,slug,short_description,description,price,compare_price,stock_quantity,weight,dimensions,brand,model,part_number,category_id,is_active,is_featured,meta_title,meta_description,meta_keywords,image_urls,backorder_policy,expected_arrival_at,created_at,updated_at").
 		Preload("Category").
 		First(&product, product.ID)
 
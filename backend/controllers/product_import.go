@@ -81,6 +81,9 @@ func (pc *ProductController) ImportProductsXLSX(c *gin.Context) {
 	if result.Created > 0 || result.Updated > 0 {
 		services.InvalidatePublicCaches(c.Request.Context(), "product:import:xlsx", nil)
 	}
+	if len(result.BackorderFills) > 0 {
+		go services.NotifyBackorderFilled(db, requestSiteURL(c), result.BackorderFills)
+	}
 
 	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Import completed", Data: result})
 }
@@ -0,0 +1,384 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"fanuc-backend/config"
+	"fanuc-backend/models"
+	"fanuc-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type QuoteRequestController struct{}
+
+type QuoteRequestCreateRequest struct {
+	CustomerEmail   string                    `json:"customer_email"`
+	CustomerName    string                    `json:"customer_name"`
+	CustomerPhone   string                    `json:"customer_phone"`
+	CompanyName     string                    `json:"company_name"`
+	VATNumber       string                    `json:"vat_number"`
+	ShippingCountry string                    `json:"shipping_country"`
+	ShippingAddress string                    `json:"shipping_address"`
+	Message         string                    `json:"message"`
+	Items           []services.QuoteLineInput `json:"items" binding:"required,min=1"`
+}
+
+type QuotePriceRequest struct {
+	Items          []services.QuotePriceLineInput `json:"items" binding:"required,min=1"`
+	ShippingFee    float64                        `json:"shipping_fee"`
+	Currency       string                         `json:"currency"`
+	ValidUntil     string                         `json:"valid_until" binding:"required"` // YYYY-MM-DD or RFC3339
+	QuoteNotes     string                         `json:"quote_notes"`
+	AdminNotes     string                         `json:"admin_notes"`
+	NotifyCustomer *bool                          `json:"notify_customer"`
+}
+
+func respondQuoteError(c *gin.Context, err error, fallback string) {
+	var qErr *services.QuoteError
+	if errors.As(err, &qErr) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: qErr.Message})
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "Quote not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: fallback, Error: err.Error()})
+}
+
+// Public: POST /api/v1/quotes (optional customer auth)
+func (qc *QuoteRequestController) CreateQuoteRequest(c *gin.Context) {
+	var req QuoteRequestCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request data", Error: err.Error()})
+		return
+	}
+
+	db := config.GetDB()
+	in := services.QuoteRequestInput{
+		CustomerEmail:   req.CustomerEmail,
+		CustomerName:    req.CustomerName,
+		CustomerPhone:   req.CustomerPhone,
+		CompanyName:     req.CompanyName,
+		VATNumber:       req.VATNumber,
+		ShippingCountry: req.ShippingCountry,
+		ShippingAddress: req.ShippingAddress,
+		Message:         req.Message,
+		Lines:           req.Items,
+	}
+
+	// Logged-in customers: link the request and default contact details from the account.
+	if customerID, exists := c.Get("customer_id"); exists {
+		if cid, ok := customerID.(uint); ok {
+			var customer models.Customer
+			if err := db.First(&customer, cid).Error; err == nil {
+				in.CustomerID = &cid
+				if strings.TrimSpace(in.CustomerEmail) == "" {
+					in.CustomerEmail = customer.Email
+				}
+				if strings.TrimSpace(in.CustomerName) == "" {
+					in.CustomerName = customer.FullName
+				}
+				if strings.TrimSpace(in.CustomerPhone) == "" {
+					in.CustomerPhone = customer.Phone
+				}
+				if strings.TrimSpace(in.CompanyName) == "" {
+					in.CompanyName = customer.Company
+				}
+			}
+		}
+	}
+
+	q, err := services.CreateQuoteRequest(db, in)
+	if err != nil {
+		respondQuoteError(c, err, "Failed to submit quote request")
+		return
+	}
+
+	siteURL := requestSiteURL(c)
+	go func(quote models.QuoteRequest, baseURL string) {
+		if err := services.NotifyAdminQuoteRequested(config.DB, baseURL, quote); err != nil {
+			log.Printf("quote notification: %v", err)
+		}
+	}(*q, siteURL)
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Quote request submitted, we will get back to you shortly",
+		Data:    gin.H{"quote": customerQuoteView(*q), "access_token": q.AccessToken},
+	})
+}
+
+// customerQuoteView strips admin-only fields.
+func customerQuoteView(q models.QuoteRequest) models.QuoteRequest {
+	q.AdminNotes = ""
+	q.QuotedBy = nil
+	return q
+}
+
+// loadCustomerQuote finds a quote by number for the guest token holder or the owning customer.
+func loadCustomerQuote(c *gin.Context) (*models.QuoteRequest, bool) {
+	db := config.GetDB()
+	var q models.QuoteRequest
+	if err := db.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Where("quote_number = ?", c.Param("number")).First(&q).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "Quote not found"})
+		return nil, false
+	}
+
+	allowed := false
+	if token := c.Query("token"); token != "" {
+		allowed = subtle.ConstantTimeCompare([]byte(token), []byte(q.AccessToken)) == 1
+	}
+	if !allowed {
+		if customerID, exists := c.Get("customer_id"); exists {
+			if cid, ok := customerID.(uint); ok {
+				var customer models.Customer
+				if q.CustomerID != nil && *q.CustomerID == cid {
+					allowed = true
+				} else if db.First(&customer, cid).Error == nil && strings.EqualFold(customer.Email, q.CustomerEmail) {
+					allowed = true
+				}
+			}
+		}
+	}
+	if !allowed {
+		// Same response as a missing quote: do not reveal which quote numbers exist.
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "Quote not found"})
+		return nil, false
+	}
+
+	if err := services.ExpireQuoteIfDue(db, &q); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load quote", Error: err.Error()})
+		return nil, false
+	}
+	return &q, true
+}
+
+// Public: GET /api/v1/quotes/:number?token=...
+func (qc *QuoteRequestController) GetQuoteRequest(c *gin.Context) {
+	q, ok := loadCustomerQuote(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: customerQuoteView(*q)})
+}
+
+// Public: POST /api/v1/quotes/:number/accept?token=...  optional {"vat_number": "..."}
+// Converts the quote into a pending order at the quoted prices, plus tax.
+func (qc *QuoteRequestController) AcceptQuoteRequest(c *gin.Context) {
+	q, ok := loadCustomerQuote(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		VATNumber string `json:"vat_number"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request data", Error: err.Error()})
+			return
+		}
+	}
+
+	order, err := services.AcceptQuoteRequest(config.GetDB(), q.ID, req.VATNumber, services.ActorCustomer)
+	if err != nil {
+		respondQuoteError(c, err, "Failed to accept quote")
+		return
+	}
+
+	config.DB.Preload("Items.Product").Preload("TaxLines").First(order, order.ID)
+
+	siteURL := requestSiteURL(c)
+	go func(orderID uint, baseURL string) {
+		if err := services.NotifyAdminOrderCreated(config.DB, baseURL, orderID); err != nil {
+			log.Printf("order notification: %v", err)
+		}
+	}(order.ID, siteURL)
+
+	c.JSON(http.StatusCreated, models.APIResponse{Success: true, Message: "Quote accepted, your order has been created", Data: order})
+}
+
+// Public: POST /api/v1/quotes/:number/reject?token=...
+func (qc *QuoteRequestController) RejectQuoteRequest(c *gin.Context) {
+	q, ok := loadCustomerQuote(c)
+	if !ok {
+		return
+	}
+	if err := services.RejectQuoteRequest(config.GetDB(), q); err != nil {
+		respondQuoteError(c, err, "Failed to reject quote")
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Quote declined", Data: customerQuoteView(*q)})
+}
+
+// Customer: GET /api/v1/customer/quotes
+func (qc *QuoteRequestController) GetMyQuoteRequests(c *gin.Context) {
+	customerID, exists := c.Get("customer_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{Success: false, Message: "Unauthorized"})
+		return
+	}
+
+	db := config.GetDB()
+	var customer models.Customer
+	if err := db.First(&customer, customerID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to retrieve customer data"})
+		return
+	}
+
+	// Match by customer_id OR email (requests sent as a guest before registering)
+	var quotes []models.QuoteRequest
+	query := db.Where("customer_id = ? OR customer_email = ?", customerID, strings.ToLower(customer.Email)).
+		Preload("Items").Order("created_at DESC")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Find(&quotes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to retrieve quotes", Error: err.Error()})
+		return
+	}
+	for i := range quotes {
+		_ = services.ExpireQuoteIfDue(db, &quotes[i])
+		quotes[i] = customerQuoteView(quotes[i])
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: quotes})
+}
+
+// Admin: GET /api/v1/admin/quotes?status=&q=&page=&page_size=
+func (qc *QuoteRequestController) GetQuoteRequests(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	db := config.GetDB()
+	query := db.Model(&models.QuoteRequest{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if kw := strings.TrimSpace(c.Query("q")); kw != "" {
+		like := "%" + kw + "%"
+		query = query.Where("quote_number LIKE ? OR customer_email LIKE ? OR customer_name LIKE ? OR company_name LIKE ?", like, like, like, like)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var quotes []models.QuoteRequest
+	if err := query.Preload("Items").Offset((page - 1) * pageSize).Limit(pageSize).
+		Order("created_at DESC").Find(&quotes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to fetch quotes", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: models.PaginationResponse{
+			Data:       quotes,
+			Page:       page,
+			PageSize:   pageSize,
+			Total:      total,
+			TotalPages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+		},
+	})
+}
+
+// Admin: GET /api/v1/admin/quotes/:id
+func (qc *QuoteRequestController) GetQuoteRequestAdmin(c *gin.Context) {
+	var q models.QuoteRequest
+	if err := config.GetDB().
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Items.Product").Preload("Customer").Preload("QuotedBy").
+		First(&q, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "Quote not found"})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: q})
+}
+
+// Admin: POST /api/v1/admin/quotes/:id/quote
+// Prices the lines (nil unit_price = not available), sets validity and emails the customer.
+func (qc *QuoteRequestController) PriceQuoteRequest(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid quote ID"})
+		return
+	}
+	var req QuotePriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request data", Error: err.Error()})
+		return
+	}
+
+	validUntil, err := time.Parse(time.RFC3339, req.ValidUntil)
+	if err != nil {
+		d, derr := time.Parse("2006-01-02", req.ValidUntil)
+		if derr != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "valid_until must be YYYY-MM-DD or RFC3339"})
+			return
+		}
+		// A date means "valid through the end of that day" (UTC).
+		validUntil = d.Add(24*time.Hour - time.Second)
+	}
+
+	in := services.QuotePriceInput{
+		Lines:       req.Items,
+		ShippingFee: req.ShippingFee,
+		Currency:    req.Currency,
+		ValidUntil:  validUntil,
+		QuoteNotes:  req.QuoteNotes,
+		AdminNotes:  req.AdminNotes,
+		AdminID:     adminActor(c).AdminID,
+	}
+	db := config.GetDB()
+	q, err := services.PriceQuoteRequest(db, uint(id), in)
+	if err != nil {
+		respondQuoteError(c, err, "Failed to save quote")
+		return
+	}
+
+	message := "Quote saved"
+	if req.NotifyCustomer == nil || *req.NotifyCustomer {
+		if err := services.SendQuoteReadyEmail(db, requestSiteURL(c), *q); err != nil {
+			c.Header("X-Email-Warn", err.Error())
+			message = "Quote saved, but the email could not be sent"
+		} else {
+			message = "Quote sent to customer"
+		}
+	}
+
+	db.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).Preload("Items.Product").First(q, q.ID)
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: message, Data: q})
+}
+
+// Admin: PUT /api/v1/admin/quotes/:id/cancel
+func (qc *QuoteRequestController) CancelQuoteRequest(c *gin.Context) {
+	db := config.GetDB()
+	var q models.QuoteRequest
+	if err := db.First(&q, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "Quote not found"})
+		return
+	}
+	if q.Status == models.QuoteStatusAccepted {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Quote was already accepted, cancel the order instead"})
+		return
+	}
+	if err := db.Model(&q).Update("status", models.QuoteStatusCancelled).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to cancel quote", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Quote cancelled", Data: q})
+}
@@ -0,0 +1,524 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"fanuc-backend/config"
+	"fanuc-backend/models"
+	"fanuc-backend/services"
+	"fanuc-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ReturnRequestController struct{}
+
+const maxReturnPhotos = 10
+
+type ReturnRequestCreateRequest struct {
+	OrderID     uint                       `json:"order_id" binding:"required"`
+	Reason      string                     `json:"reason" binding:"required"`
+	Description string                     `json:"description"`
+	Items       []services.ReturnLineInput `json:"items" binding:"required,min=1"`
+}
+
+type ReturnApproveRequest struct {
+	RMANumber          string `json:"rma_number"`
+	ReturnInstructions string `json:"return_instructions"`
+	AdminNotes         string `json:"admin_notes"`
+	NotifyCustomer     *bool  `json:"notify_customer"`
+}
+
+type ReturnRejectRequest struct {
+	Reason         string `json:"reason" binding:"required"`
+	NotifyCustomer *bool  `json:"notify_customer"`
+}
+
+type ReturnReceiveRequest struct {
+	Items          []services.ReturnLineInput `json:"items"`
+	Carrier        string                     `json:"carrier"`
+	TrackingNumber string                     `json:"tracking_number"`
+	Note           string                     `json:"note"`
+	NotifyCustomer *bool                      `json:"notify_customer"`
+}
+
+type ReturnInspectRequest struct {
+	Passed         bool   `json:"passed"`
+	Notes          string `json:"notes"`
+	NotifyCustomer *bool  `json:"notify_customer"`
+}
+
+type ReturnResolveRequest struct {
+	Resolution      string   `json:"resolution" binding:"required"` // refund, replacement, repair
+	Notes           string   `json:"notes"`
+	Amount          *float64 `json:"amount" binding:"omitempty,gt=0"`
+	IncludeShipping bool     `json:"include_shipping"`
+	Restock         bool     `json:"restock"`
+	// RefundViaProvider defaults to true for PayPal and Stripe orders; false only records the refund.
+	RefundViaProvider *bool  `json:"refund_via_provider"`
+	OutboundCarrier   string `json:"outbound_carrier"`
+	OutboundTracking  string `json:"outbound_tracking_number"`
+	NotifyCustomer    *bool  `json:"notify_customer"`
+}
+
+func respondReturnError(c *gin.Context, err error, fallback string) {
+	var rErr *services.ReturnError
+	if errors.As(err, &rErr) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: rErr.Message})
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "Return not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: fallback, Error: err.Error()})
+}
+
+// customerReturnView strips admin-only fields.
+func customerReturnView(r models.ReturnRequest) models.ReturnRequest {
+	r.AdminNotes = ""
+	return r
+}
+
+func preloadReturn(db *gorm.DB) *gorm.DB {
+	return db.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Items.OrderItem").
+		Preload("Photos", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") })
+}
+
+// loadMyReturn finds a return owned by the logged-in customer.
+func loadMyReturn(c *gin.Context) (*models.ReturnRequest, bool) {
+	customerID, exists := c.Get("customer_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{Success: false, Message: "Unauthorized"})
+		return nil, false
+	}
+	var r models.ReturnRequest
+	if err := preloadReturn(config.GetDB()).Where("id = ? AND customer_id = ?", c.Param("id"), customerID).First(&r).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "Return not found"})
+		return nil, false
+	}
+	return &r, true
+}
+
+// notifyReturnStatus emails the customer unless the admin opted out.
+func notifyReturnStatus(c *gin.Context, notify *bool, returnID uint) {
+	if notify != nil && !*notify {
+		return
+	}
+	if err := services.SendReturnStatusEmail(config.DB, requestSiteURL(c), returnID); err != nil {
+		c.Header("X-Email-Warn", err.Error())
+	}
+}
+
+// Customer: POST /api/v1/customer/returns
+func (rc *ReturnRequestController) CreateReturnRequest(c *gin.Context) {
+	customerID, exists := c.Get("customer_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{Success: false, Message: "Unauthorized"})
+		return
+	}
+	var req ReturnRequestCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request data", Error: err.Error()})
+		return
+	}
+
+	db := config.GetDB()
+	var customer models.Customer
+	if err := db.First(&customer, customerID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to retrieve customer data"})
+		return
+	}
+
+	r, err := services.CreateReturnRequest(db, services.ReturnRequestInput{
+		CustomerID:    customer.ID,
+		CustomerEmail: customer.Email,
+		OrderID:       req.OrderID,
+		Reason:        req.Reason,
+		Description:   req.Description,
+		Lines:         req.Items,
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "Order not found"})
+			return
+		}
+		respondReturnError(c, err, "Failed to submit return request")
+		return
+	}
+
+	siteURL := requestSiteURL(c)
+	go func(id uint, baseURL string) {
+		if err := services.SendReturnStatusEmail(config.DB, baseURL, id); err != nil {
+			log.Printf("return %d confirmation email: %v", id, err)
+		}
+		if err := services.NotifyAdminReturnRequested(config.DB, baseURL, id); err != nil {
+			log.Printf("return %d notification: %v", id, err)
+		}
+	}(r.ID, siteURL)
+
+	preloadReturn(db).First(r, r.ID)
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Return request submitted, we will review it shortly",
+		Data:    customerReturnView(*r),
+	})
+}
+
+// Customer: GET /api/v1/customer/returns
+func (rc *ReturnRequestController) GetMyReturnRequests(c *gin.Context) {
+	customerID, exists := c.Get("customer_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{Success: false, Message: "Unauthorized"})
+		return
+	}
+	var returns []models.ReturnRequest
+	query := preloadReturn(config.GetDB()).Where("customer_id = ?", customerID).Order("created_at DESC")
+	if orderID := c.Query("order_id"); orderID != "" {
+		query = query.Where("order_id = ?", orderID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Find(&returns).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to retrieve returns", Error: err.Error()})
+		return
+	}
+	for i := range returns {
+		returns[i] = customerReturnView(returns[i])
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: returns})
+}
+
+// Customer: GET /api/v1/customer/returns/:id
+func (rc *ReturnRequestController) GetMyReturnRequest(c *gin.Context) {
+	r, ok := loadMyReturn(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: customerReturnView(*r)})
+}
+
+// Customer: POST /api/v1/customer/returns/:id/cancel
+func (rc *ReturnRequestController) CancelMyReturnRequest(c *gin.Context) {
+	r, ok := loadMyReturn(c)
+	if !ok {
+		return
+	}
+	updated, err := services.CancelReturnRequest(config.GetDB(), r.ID, r.CustomerID)
+	if err != nil {
+		respondReturnError(c, err, "Failed to cancel return")
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Return cancelled", Data: customerReturnView(*updated)})
+}
+
+// Customer: POST /api/v1/customer/returns/:id/photos (multipart "files")
+// Photos of the part, its label or the fault; only while the return is still open.
+func (rc *ReturnRequestController) UploadReturnPhotos(c *gin.Context) {
+	r, ok := loadMyReturn(c)
+	if !ok {
+		return
+	}
+	switch r.Status {
+	case models.ReturnStatusRequested, models.ReturnStatusApproved:
+	default:
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: fmt.Sprintf("Return is %s, photos can no longer be added", r.Status)})
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Failed to parse multipart form", Error: err.Error()})
+		return
+	}
+	files := form.File["files"]
+	if len(files) == 0 {
+		files = form.File["file"]
+	}
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "No files provided"})
+		return
+	}
+	if len(r.Photos)+len(files) > maxReturnPhotos {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: fmt.Sprintf("A return can have at most %d photos", maxReturnPhotos)})
+		return
+	}
+
+	dir := filepath.Join(getUploadRoot(), "returns", strconv.FormatUint(uint64(r.ID), 10))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to create upload directory", Error: err.Error()})
+		return
+	}
+
+	db := config.GetDB()
+	photos := make([]models.ReturnRequestPhoto, 0, len(files))
+	for _, fh := range files {
+		if !utils.ValidateImageExtension(fh.Filename) {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid file type. Only JPG, JPEG, PNG, GIF, and WebP are allowed"})
+			return
+		}
+		if fh.Size > 10*1024*1024 {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "File size exceeds 10MB limit"})
+			return
+		}
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to save photo", Error: err.Error()})
+			return
+		}
+		ext := strings.ToLower(filepath.Ext(utils.CleanFilename(fh.Filename)))
+		name := fmt.Sprintf("%d-%s%s", time.Now().Unix(), hex.EncodeToString(b), ext)
+		if err := c.SaveUploadedFile(fh, filepath.Join(dir, name)); err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to save photo", Error: err.Error()})
+			return
+		}
+		photo := models.ReturnRequestPhoto{
+			ReturnRequestID: r.ID,
+			FileName:        fh.Filename,
+			FileURL:         fmt.Sprintf("/uploads/returns/%d/%s", r.ID, name),
+			FileSize:        fh.Size,
+			FileType:        fh.Header.Get("Content-Type"),
+		}
+		if err := db.Create(&photo).Error; err != nil {
+			_ = os.Remove(filepath.Join(dir, name))
+			c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to save photo", Error: err.Error()})
+			return
+		}
+		photos = append(photos, photo)
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{Success: true, Message: "Photos uploaded", Data: photos})
+}
+
+// Admin: GET /api/v1/admin/returns?status=&reason=&q=&page=&page_size=
+func (rc *ReturnRequestController) GetReturnRequests(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	db := config.GetDB()
+	query := db.Model(&models.ReturnRequest{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("return_requests.status = ?", status)
+	}
+	if reason := c.Query("reason"); reason != "" {
+		query = query.Where("return_requests.reason = ?", reason)
+	}
+	if kw := strings.TrimSpace(c.Query("q")); kw != "" {
+		like := "%" + kw + "%"
+		query = query.Joins("JOIN orders ON orders.id = return_requests.order_id").
+			Where("return_requests.rma_number LIKE ? OR orders.order_number LIKE ? OR orders.customer_email LIKE ? OR orders.customer_name LIKE ?", like, like, like, like)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var returns []models.ReturnRequest
+	if err := preloadReturn(query).Preload("Order").Preload("Customer").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		Order("return_requests.created_at DESC").Find(&returns).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to fetch returns", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: models.PaginationResponse{
+			Data:       returns,
+			Page:       page,
+			PageSize:   pageSize,
+			Total:      total,
+			TotalPages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+		},
+	})
+}
+
+// Admin: GET /api/v1/admin/returns/:id
+func (rc *ReturnRequestController) GetReturnRequestAdmin(c *gin.Context) {
+	var r models.ReturnRequest
+	if err := preloadReturn(config.GetDB()).Preload("Order").Preload("Customer").
+		Preload("Ticket").Preload("Ticket.Replies", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		First(&r, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "Return not found"})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    gin.H{"return": r, "allowed_transitions": services.AllowedReturnTransitions(r.Status)},
+	})
+}
+
+// parseReturnID reads :id for admin return actions.
+func parseReturnID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid return ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondReturnUpdated reloads the return after an admin action.
+func respondReturnUpdated(c *gin.Context, id uint, message string) {
+	var r models.ReturnRequest
+	preloadReturn(config.GetDB()).Preload("Order").First(&r, id)
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: message, Data: r})
+}
+
+// Admin: POST /api/v1/admin/returns/:id/approve
+// Issues the RMA number (generated unless rma_number is given) and the return instructions.
+func (rc *ReturnRequestController) ApproveReturnRequest(c *gin.Context) {
+	id, ok := parseReturnID(c)
+	if !ok {
+		return
+	}
+	var req ReturnApproveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request data", Error: err.Error()})
+		return
+	}
+	if _, err := services.ApproveReturnRequest(config.GetDB(), id, services.ReturnApproveInput{
+		RMANumber:          req.RMANumber,
+		ReturnInstructions: req.ReturnInstructions,
+		AdminNotes:         req.AdminNotes,
+		AdminID:            adminActor(c).AdminID,
+	}); err != nil {
+		respondReturnError(c, err, "Failed to approve return")
+		return
+	}
+	notifyReturnStatus(c, req.NotifyCustomer, id)
+	respondReturnUpdated(c, id, "Return approved")
+}
+
+// Admin: POST /api/v1/admin/returns/:id/reject
+func (rc *ReturnRequestController) RejectReturnRequest(c *gin.Context) {
+	id, ok := parseReturnID(c)
+	if !ok {
+		return
+	}
+	var req ReturnRejectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request data", Error: err.Error()})
+		return
+	}
+	if _, err := services.RejectReturnRequest(config.GetDB(), id, req.Reason, adminActor(c).AdminID); err != nil {
+		respondReturnError(c, err, "Failed to reject return")
+		return
+	}
+	notifyReturnStatus(c, req.NotifyCustomer, id)
+	respondReturnUpdated(c, id, "Return rejected")
+}
+
+// Admin: POST /api/v1/admin/returns/:id/receive
+func (rc *ReturnRequestController) ReceiveReturnRequest(c *gin.Context) {
+	id, ok := parseReturnID(c)
+	if !ok {
+		return
+	}
+	var req ReturnReceiveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request data", Error: err.Error()})
+		return
+	}
+	if _, err := services.ReceiveReturnRequest(config.GetDB(), id, services.ReturnReceiveInput{
+		Lines:          req.Items,
+		Carrier:        req.Carrier,
+		TrackingNumber: req.TrackingNumber,
+		Note:           req.Note,
+		AdminID:        adminActor(c).AdminID,
+	}); err != nil {
+		respondReturnError(c, err, "Failed to receive return")
+		return
+	}
+	notifyReturnStatus(c, req.NotifyCustomer, id)
+	respondReturnUpdated(c, id, "Return received")
+}
+
+// Admin: POST /api/v1/admin/returns/:id/inspect
+func (rc *ReturnRequestController) InspectReturnRequest(c *gin.Context) {
+	id, ok := parseReturnID(c)
+	if !ok {
+		return
+	}
+	var req ReturnInspectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request data", Error: err.Error()})
+		return
+	}
+	if _, err := services.InspectReturnRequest(config.GetDB(), id, req.Passed, req.Notes, adminActor(c).AdminID); err != nil {
+		respondReturnError(c, err, "Failed to record inspection")
+		return
+	}
+	notifyReturnStatus(c, req.NotifyCustomer, id)
+	respondReturnUpdated(c, id, "Inspection recorded")
+}
+
+// Admin: POST /api/v1/admin/returns/:id/resolve
+// Closes the return as a refund (of the received units), replacement or repair.
+func (rc *ReturnRequestController) ResolveReturnRequest(c *gin.Context) {
+	id, ok := parseReturnID(c)
+	if !ok {
+		return
+	}
+	var req ReturnResolveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request data", Error: err.Error()})
+		return
+	}
+
+	db := config.GetDB()
+	var order struct{ PaymentMethod string }
+	db.Table("orders").Select("orders.payment_method").
+		Joins("JOIN return_requests ON return_requests.order_id = orders.id").
+		Where("return_requests.id = ?", id).Scan(&order)
+	viaProvider := services.IsOnlinePaymentMethod(order.PaymentMethod)
+	if req.RefundViaProvider != nil {
+		viaProvider = *req.RefundViaProvider
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 45*time.Second)
+	defer cancel()
+	_, refund, err := services.ResolveReturnRequest(ctx, db, id, services.ReturnResolveInput{
+		Resolution:      req.Resolution,
+		Notes:           req.Notes,
+		Amount:          req.Amount,
+		IncludeShipping: req.IncludeShipping,
+		Restock:         req.Restock,
+		ViaProvider:     viaProvider,
+		OutboundCarrier: req.OutboundCarrier,
+		OutboundTrack:   req.OutboundTracking,
+		AdminID:         adminActor(c).AdminID,
+	})
+	if err != nil {
+		var rErr *services.ReturnError
+		if !errors.As(err, &rErr) && !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("resolve return %d: %v", id, err)
+		}
+		respondReturnError(c, err, "Failed to resolve return")
+		return
+	}
+	notifyReturnStatus(c, req.NotifyCustomer, id)
+
+	var r models.ReturnRequest
+	preloadReturn(db).Preload("Order").First(&r, id)
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Return resolved", Data: gin.H{"return": r, "refund": refund}})
+}
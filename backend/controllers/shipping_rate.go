@@ -82,8 +82,50 @@ func (sc *ShippingRateController) PublicCountries(c *gin.Context) {
 	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK", Data: list})
 }
 
-// Public: GET /api/v1/public/shipping/quote?country=US&weight_kg=12.3
+// shippingParcelQuery reads what to quote: weight_kg (or weight) plus the packed size, either
+// volume_cm3 or length_cm/width_cm/height_cm. ok is false when a response was written.
+func shippingParcelQuery(c *gin.Context) (services.ShippingParcel, bool) {
+	num := func(keys ...string) (float64, bool) {
+		for _, k := range keys {
+			str := strings.TrimSpace(c.Query(k))
+			if str == "" {
+				continue
+			}
+			v, err := strconv.ParseFloat(strings.ReplaceAll(str, ",", ""), 64)
+			if err != nil || v < 0 {
+				c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid " + k, Error: "invalid_parcel"})
+				return 0, false
+			}
+			return v, true
+		}
+		return 0, true
+	}
+	var parcel services.ShippingParcel
+	var ok bool
+	if parcel.WeightKg, ok = num("weight_kg", "weight"); !ok {
+		return parcel, false
+	}
+	if parcel.VolumeCm3, ok = num("volume_cm3"); !ok {
+		return parcel, false
+	}
+	if parcel.VolumeCm3 == 0 {
+		l, ok1 := num("length_cm")
+		w, ok2 := num("width_cm")
+		h, ok3 := num("height_cm")
+		if !ok1 || !ok2 || !ok3 {
+			return parcel, false
+		}
+		parcel.VolumeCm3 = l * w * h
+	}
+	return parcel, true
+}
+
+// Public: GET /api/v1/public/shipping/quote?country=US&weight_kg=12.3&volume_cm3=36000&currency=EUR
 func (sc *ShippingRateController) PublicQuote(c *gin.Context) {
+	currency, ok := requestCurrency(c)
+	if !ok {
+		return
+	}
 	carrier := strings.TrimSpace(c.Query("carrier"))
 	serviceCode := strings.TrimSpace(c.Query("service"))
 	cc := strings.TrimSpace(c.Query("country"))
@@ -94,18 +136,9 @@ func (sc *ShippingRateController) PublicQuote(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Missing country", Error: "missing_country"})
 		return
 	}
-	wStr := strings.TrimSpace(c.Query("weight_kg"))
-	if wStr == "" {
-		wStr = strings.TrimSpace(c.Query("weight"))
-	}
-	weight := 0.0
-	if wStr != "" {
-		v, err := strconv.ParseFloat(strings.ReplaceAll(wStr, ",", ""), 64)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid weight", Error: err.Error()})
-			return
-		}
-		weight = v
+	parcel, ok := shippingParcelQuery(c)
+	if !ok {
+		return
 	}
 	db := config.GetDB()
 	var (
@@ -113,10 +146,10 @@ func (sc *ShippingRateController) PublicQuote(c *gin.Context) {
 		err error
 	)
 	if carrier != "" {
-		q, err = services.CalculateCarrierShippingQuote(db, carrier, serviceCode, cc, weight)
+		q, err = services.CalculateCarrierShippingQuote(db, carrier, serviceCode, cc, parcel)
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			// Carrier template missing for this country; fall back to default country template.
-			q, err = services.CalculateShippingQuote(db, cc, weight)
+			q, err = services.CalculateShippingQuote(db, cc, parcel)
 			if err == nil {
 				q.Source = "default_fallback"
 				q.Carrier = services.NormalizeCarrier(carrier)
@@ -124,7 +157,7 @@ func (sc *ShippingRateController) PublicQuote(c *gin.Context) {
 			}
 		}
 	} else {
-		q, err = services.CalculateShippingQuote(db, cc, weight)
+		q, err = services.CalculateShippingQuote(db, cc, parcel)
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			// Checkout/public default flow: if default template is missing, try any carrier template.
 			type pair struct {
@@ -139,7 +172,7 @@ func (sc *ShippingRateController) PublicQuote(c *gin.Context) {
 				Order("CASE WHEN carrier = 'FEDEX' THEN 0 WHEN carrier = 'DHL' THEN 1 ELSE 9 END, carrier ASC, service_code ASC").
 				Scan(&pairs).Error
 			if qe == nil && len(pairs) > 0 {
-				q, err = services.CalculateCarrierShippingQuote(db, pairs[0].Carrier, pairs[0].ServiceCode, cc, weight)
+				q, err = services.CalculateCarrierShippingQuote(db, pairs[0].Carrier, pairs[0].ServiceCode, cc, parcel)
 				if err == nil {
 					q.Source = "carrier_fallback"
 				}
@@ -163,9 +196,59 @@ func (sc *ShippingRateController) PublicQuote(c *gin.Context) {
 		q.AdditionalFee = 0
 		q.Source = "free_shipping"
 	}
+	if currency != nil {
+		if q, err = services.ConvertShippingQuote(db, q, currency); err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Failed to convert shipping quote", Error: err.Error()})
+			return
+		}
+	}
 	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK", Data: q})
 }
 
+// respondShippingOptions lists every shipping option for the parcel, cheapest first, in the
+// requested currency. The shopper sends the chosen carrier/service (and the fee shown) with
+// the order.
+func respondShippingOptions(c *gin.Context, countryCode string, parcel services.ShippingParcel) {
+	currency, ok := requestCurrency(c)
+	if !ok {
+		return
+	}
+	cc := services.NormalizeCountryCode(countryCode)
+	if cc == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Missing country", Error: "missing_country"})
+		return
+	}
+	options, err := services.ListShippingOptions(config.GetDB(), cc, parcel, currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Failed to calculate shipping", Error: err.Error()})
+		return
+	}
+	if len(options) == 0 {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "No shipping option available for this country and weight", Error: "not_found"})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK", Data: gin.H{
+		"country_code": cc,
+		"weight_kg":    parcel.WeightKg,
+		"volume_cm3":   parcel.VolumeCm3,
+		"options":      options,
+	}})
+}
+
+// Public: GET /api/v1/public/shipping/options?country=DE&weight_kg=2.5&volume_cm3=36000&currency=EUR
+// Carts use GET /api/v1/cart/shipping-options, which takes the weight and volume from the cart.
+func (sc *ShippingRateController) PublicOptions(c *gin.Context) {
+	cc := strings.TrimSpace(c.Query("country"))
+	if cc == "" {
+		cc = strings.TrimSpace(c.Query("country_code"))
+	}
+	parcel, ok := shippingParcelQuery(c)
+	if !ok {
+		return
+	}
+	respondShippingOptions(c, cc, parcel)
+}
+
 // Admin: GET /api/v1/admin/shipping-rates
 func (sc *ShippingRateController) AdminList(c *gin.Context) {
 	db := config.GetDB()
@@ -200,17 +283,20 @@ func (sc *ShippingRateController) AdminList(c *gin.Context) {
 			db.Model(&models.ShippingCarrierWeightBracket{}).Where("template_id = ?", t.ID).Count(&wc)
 			db.Model(&models.ShippingCarrierQuoteSurcharge{}).Where("template_id = ?", t.ID).Count(&qc)
 			out = append(out, gin.H{
-				"id":               t.ID,
-				"carrier":          t.Carrier,
-				"service_code":     t.ServiceCode,
-				"country_code":     t.CountryCode,
-				"country_name":     t.CountryName,
-				"currency":         t.Currency,
-				"is_active":        t.IsActive,
-				"weight_brackets":  wc,
-				"quote_surcharges": qc,
-				"created_at":       t.CreatedAt,
-				"updated_at":       t.UpdatedAt,
+				"id":                 t.ID,
+				"carrier":            t.Carrier,
+				"service_code":       t.ServiceCode,
+				"country_code":       t.CountryCode,
+				"country_name":       t.CountryName,
+				"currency":           t.Currency,
+				"is_active":          t.IsActive,
+				"transit_days_min":   t.TransitDaysMin,
+				"transit_days_max":   t.TransitDaysMax,
+				"volumetric_divisor": t.VolumetricDivisor,
+				"weight_brackets":    wc,
+				"quote_surcharges":   qc,
+				"created_at":         t.CreatedAt,
+				"updated_at":         t.UpdatedAt,
 			})
 		}
 		c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK", Data: out})
@@ -241,6 +327,8 @@ func (sc *ShippingRateController) AdminList(c *gin.Context) {
 			"country_name":     t.CountryName,
 			"currency":         t.Currency,
 			"is_active":        t.IsActive,
+			"transit_days_min": t.TransitDaysMin,
+			"transit_days_max": t.TransitDaysMax,
 			"weight_brackets":  wc,
 			"quote_surcharges": qc,
 			"created_at":       t.CreatedAt,
@@ -251,6 +339,94 @@ func (sc *ShippingRateController) AdminList(c *gin.Context) {
 	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK", Data: out})
 }
 
+type updateShippingTemplateReq struct {
+	IsActive          *bool `json:"is_active"`
+	TransitDaysMin    *int  `json:"transit_days_min"`
+	TransitDaysMax    *int  `json:"transit_days_max"`
+	VolumetricDivisor *int  `json:"volumetric_divisor"` // carrier templates only; 0 = default divisor
+}
+
+// Admin: PUT /api/v1/admin/shipping-rates/:id?type=carrier
+// Updates the template settings that are not part of the rate sheets (active flag, transit
+// estimate shown at checkout, volumetric divisor). Omitted fields are left unchanged.
+func (sc *ShippingRateController) AdminUpdate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid template ID", Error: "invalid_id"})
+		return
+	}
+	var req updateShippingTemplateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request", Error: err.Error()})
+		return
+	}
+	isCarrier := strings.ToLower(strings.TrimSpace(c.Query("type"))) == "carrier"
+	if req.VolumetricDivisor != nil && !isCarrier {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "volumetric_divisor can only be set on carrier templates", Error: "invalid_field"})
+		return
+	}
+	if req.VolumetricDivisor != nil && *req.VolumetricDivisor < 0 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "volumetric_divisor must not be negative", Error: "invalid_divisor"})
+		return
+	}
+
+	db := config.GetDB()
+	var (
+		tpl                    interface{}
+		transitMin, transitMax int
+		defaultTpl             models.ShippingTemplate
+		carrierTpl             models.ShippingCarrierTemplate
+	)
+	if isCarrier {
+		err = db.First(&carrierTpl, id).Error
+		tpl, transitMin, transitMax = &carrierTpl, carrierTpl.TransitDaysMin, carrierTpl.TransitDaysMax
+	} else {
+		err = db.First(&defaultTpl, id).Error
+		tpl, transitMin, transitMax = &defaultTpl, defaultTpl.TransitDaysMin, defaultTpl.TransitDaysMax
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "Template not found", Error: "not_found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load template", Error: err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.TransitDaysMin != nil || req.TransitDaysMax != nil {
+		if req.TransitDaysMin != nil {
+			transitMin = *req.TransitDaysMin
+		}
+		if req.TransitDaysMax != nil {
+			transitMax = *req.TransitDaysMax
+		}
+		if transitMin > 0 && transitMax == 0 {
+			transitMax = transitMin
+		}
+		// Same rule as the TransitDays column of the carrier import: 0 (unknown) or min-max with 1 <= min <= max.
+		if transitMin < 0 || transitMax < 0 || (transitMin == 0) != (transitMax == 0) || transitMax < transitMin {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "transit days must be 0 (unknown) or 1 <= transit_days_min <= transit_days_max", Error: "invalid_transit_days"})
+			return
+		}
+		updates["transit_days_min"], updates["transit_days_max"] = transitMin, transitMax
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+	if req.VolumetricDivisor != nil {
+		updates["volumetric_divisor"] = *req.VolumetricDivisor
+	}
+	if len(updates) > 0 {
+		if err := db.Model(tpl).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to update template", Error: err.Error()})
+			return
+		}
+		_ = services.ClearRedisByPrefixes(c.Request.Context(), "cache:public:shipping_countries:")
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Template updated", Data: tpl})
+}
+
 // Admin: GET /api/v1/admin/shipping-rates/import/template
 func (sc *ShippingRateController) DownloadTemplate(c *gin.Context) {
 	typeParam := strings.ToLower(strings.TrimSpace(c.Query("type")))
@@ -289,6 +465,60 @@ func (sc *ShippingRateController) DownloadTemplate(c *gin.Context) {
 	c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", b)
 }
 
+// Admin: GET /api/v1/admin/shipping-rates/export/xlsx?type=carrier-zone&carrier=FEDEX&service=IP
+// Exports the current templates in the import layout; upload the edited file with replace=1.
+// Inactive templates (and carrier countries without brackets) are not exported; replace only
+// rebuilds the countries present in the file, so those are left as they are.
+func (sc *ShippingRateController) ExportXLSX(c *gin.Context) {
+	typeParam := strings.ToLower(strings.TrimSpace(c.Query("type")))
+	var (
+		b   []byte
+		err error
+	)
+	filename := "shipping-templates-export.xlsx"
+	if typeParam == "carrier-zone" {
+		carrier := services.NormalizeCarrier(c.Query("carrier"))
+		serviceCode := services.NormalizeServiceCode(c.Query("service"))
+		if carrier == "" {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "carrier is required", Error: "missing carrier"})
+			return
+		}
+		if serviceCode == "" {
+			codes, err := services.CarrierServiceCodes(config.DB, carrier)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to export templates", Error: err.Error()})
+				return
+			}
+			if len(codes) > 1 {
+				c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "service is required, " + carrier + " has several services: " + strings.Join(codes, ", "), Error: "ambiguous service"})
+				return
+			}
+			if len(codes) == 1 {
+				serviceCode = codes[0]
+			}
+		}
+		b, err = services.ExportCarrierZoneTemplatesXLSX(config.DB, carrier, serviceCode)
+		filename = "shipping-" + strings.ToLower(carrier)
+		if serviceCode != "" {
+			filename += "-" + strings.ToLower(serviceCode)
+		}
+		filename += "-zone-export.xlsx"
+	} else {
+		b, err = services.ExportShippingTemplatesXLSX(config.DB)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "No active templates for this carrier/service", Error: "not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to export templates", Error: err.Error()})
+		return
+	}
+	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", b)
+}
+
 // Admin: POST /api/v1/admin/shipping-rates/import/xlsx?replace=1
 func (sc *ShippingRateController) ImportXLSX(c *gin.Context) {
 	replace := strings.TrimSpace(c.Query("replace")) == "1" || strings.ToLower(strings.TrimSpace(c.Query("replace"))) == "true"
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"fanuc-backend/config"
+	"fanuc-backend/models"
+	"fanuc-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StripeController struct{}
+
+// Public: GET /api/v1/public/stripe/config
+func (sc *StripeController) GetPublicConfig(c *gin.Context) {
+	db := config.GetDB()
+	if db == nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Database not initialized"})
+		return
+	}
+
+	s, err := services.GetOrCreateStripeSetting(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load settings", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK", Data: s.ToPublicConfig()})
+}
+
+// Admin: GET /api/v1/admin/stripe/settings
+func (sc *StripeController) GetSettings(c *gin.Context) {
+	db := config.GetDB()
+	if db == nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Database not initialized"})
+		return
+	}
+
+	s, err := services.GetOrCreateStripeSetting(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load settings", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK", Data: s.ToResponse()})
+}
+
+type updateStripeSettingsRequest struct {
+	Enabled             *bool   `json:"enabled"`
+	Mode                *string `json:"mode"`
+	PublishableKeyTest  *string `json:"publishable_key_test"`
+	PublishableKeyLive  *string `json:"publishable_key_live"`
+	StatementDescriptor *string `json:"statement_descriptor"`
+
+	// Secrets are write-only. Empty means "keep existing" unless ?allow_clear=1.
+	SecretKeyTest     *string `json:"secret_key_test"`
+	SecretKeyLive     *string `json:"secret_key_live"`
+	WebhookSecretTest *string `json:"webhook_secret_test"`
+	WebhookSecretLive *string `json:"webhook_secret_live"`
+}
+
+// Admin: PUT /api/v1/admin/stripe/settings
+func (sc *StripeController) UpdateSettings(c *gin.Context) {
+	var req updateStripeSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request", Error: err.Error()})
+		return
+	}
+
+	db := config.GetDB()
+	if db == nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Database not initialized"})
+		return
+	}
+
+	s, err := services.GetOrCreateStripeSetting(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load settings", Error: err.Error()})
+		return
+	}
+
+	if req.Enabled != nil {
+		s.Enabled = *req.Enabled
+	}
+	if req.Mode != nil {
+		m := strings.ToLower(strings.TrimSpace(*req.Mode))
+		if m != "test" && m != "live" {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid mode", Error: "mode must be test or live"})
+			return
+		}
+		s.Mode = m
+	}
+	if req.PublishableKeyTest != nil {
+		s.PublishableKeyTest = strings.TrimSpace(*req.PublishableKeyTest)
+	}
+	if req.PublishableKeyLive != nil {
+		s.PublishableKeyLive = strings.TrimSpace(*req.PublishableKeyLive)
+	}
+	if req.StatementDescriptor != nil {
+		d := strings.TrimSpace(*req.StatementDescriptor)
+		if len(d) > 22 {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid statement descriptor", Error: "statement_descriptor must be at most 22 characters"})
+			return
+		}
+		s.StatementDescriptor = d
+	}
+	allowClear := c.Query("allow_clear") == "1"
+	for _, secret := range []struct {
+		dst *string
+		v   *string
+	}{
+		{&s.SecretKeyTestEnc, req.SecretKeyTest},
+		{&s.SecretKeyLiveEnc, req.SecretKeyLive},
+		{&s.WebhookSecretTestEnc, req.WebhookSecretTest},
+		{&s.WebhookSecretLiveEnc, req.WebhookSecretLive},
+	} {
+		if err := encryptSettingSecret(secret.dst, secret.v, allowClear); err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Failed to encrypt secret", Error: err.Error()})
+			return
+		}
+	}
+
+	if err := db.Save(s).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to save settings", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Saved", Data: s.ToResponse()})
+}
+
+// Public: POST /api/v1/public/stripe/webhook
+// Verified with the endpoint signing secret (Stripe-Signature header).
+func (sc *StripeController) Webhook(c *gin.Context) {
+	handlePaymentWebhook(c, services.PaymentMethodStripe)
+}
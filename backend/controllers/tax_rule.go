@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"fanuc-backend/config"
+	"fanuc-backend/models"
+	"fanuc-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TaxRuleController struct{}
+
+// Public: GET /api/v1/public/tax/rate?country=DE&vat_number=DE136695976
+// Lets checkout show the tax that will apply and validate a VAT number before ordering.
+func (tc *TaxRuleController) PublicRate(c *gin.Context) {
+	cc := services.NormalizeCountryCode(c.Query("country"))
+	if cc == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Missing country", Error: "missing_country"})
+		return
+	}
+
+	out := gin.H{"country_code": cc, "taxed": false, "reverse_charge": false}
+	var vatCountry string
+	if raw := strings.TrimSpace(c.Query("vat_number")); raw != "" {
+		number, country, err := services.ValidateVATNumber(raw)
+		if err != nil {
+			var vatErr *services.VATNumberError
+			if errors.As(err, &vatErr) {
+				c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: vatErr.Message, Error: "invalid_vat_number"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to validate VAT number", Error: err.Error()})
+			return
+		}
+		vatCountry = country
+		out["vat_number"] = number
+		out["vat_country"] = country
+	}
+
+	rule, err := services.GetTaxRule(config.GetDB(), cc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load tax rule", Error: err.Error()})
+		return
+	}
+	if rule != nil && rule.Rate > 0 {
+		out["taxed"] = true
+		out["name"] = rule.Name
+		out["rate"] = rule.Rate
+		out["prices_include_tax"] = rule.PricesIncludeTax
+		out["shipping_taxable"] = rule.ShippingTaxable
+		out["reverse_charge_enabled"] = rule.ReverseChargeEnabled
+		out["reverse_charge"] = services.ReverseChargeApplies(rule, vatCountry)
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK", Data: out})
+}
+
+// Admin: GET /api/v1/admin/tax-rules
+func (tc *TaxRuleController) AdminList(c *gin.Context) {
+	var rules []models.TaxRule
+	if err := config.GetDB().Order("country_code ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to load tax rules", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "OK", Data: rules})
+}
+
+type taxRuleRequest struct {
+	CountryCode          *string  `json:"country_code"`
+	Name                 *string  `json:"name"`
+	Rate                 *float64 `json:"rate"`
+	PricesIncludeTax     *bool    `json:"prices_include_tax"`
+	ShippingTaxable      *bool    `json:"shipping_taxable"`
+	ReverseChargeEnabled *bool    `json:"reverse_charge_enabled"`
+	IsActive             *bool    `json:"is_active"`
+}
+
+func (r taxRuleRequest) validate() string {
+	if r.Rate != nil && (*r.Rate < 0 || *r.Rate >= 100) {
+		return "rate must be a percentage between 0 and 100"
+	}
+	if r.Name != nil && strings.TrimSpace(*r.Name) == "" {
+		return "name cannot be empty"
+	}
+	return ""
+}
+
+// Admin: POST /api/v1/admin/tax-rules
+func (tc *TaxRuleController) Create(c *gin.Context) {
+	var req taxRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request", Error: err.Error()})
+		return
+	}
+	if req.CountryCode == nil || len(services.NormalizeCountryCode(*req.CountryCode)) != 2 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "country_code must be an ISO 3166-1 alpha-2 code"})
+		return
+	}
+	if req.Rate == nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "rate is required"})
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: msg})
+		return
+	}
+
+	db := config.GetDB()
+	cc := services.NormalizeCountryCode(*req.CountryCode)
+	var count int64
+	db.Model(&models.TaxRule{}).Where("country_code = ?", cc).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, models.APIResponse{Success: false, Message: "A tax rule for this country already exists"})
+		return
+	}
+
+	rule := models.TaxRule{CountryCode: cc, Name: "VAT", Rate: *req.Rate, ShippingTaxable: true, IsActive: true}
+	if req.Name != nil {
+		rule.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.PricesIncludeTax != nil {
+		rule.PricesIncludeTax = *req.PricesIncludeTax
+	}
+	if req.ReverseChargeEnabled != nil {
+		rule.ReverseChargeEnabled = *req.ReverseChargeEnabled
+	}
+	if err := db.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to create tax rule", Error: err.Error()})
+		return
+	}
+	// Boolean columns default to true: write explicit false values after the insert.
+	falses := map[string]interface{}{}
+	if req.ShippingTaxable != nil && !*req.ShippingTaxable {
+		falses["shipping_taxable"] = false
+	}
+	if req.IsActive != nil && !*req.IsActive {
+		falses["is_active"] = false
+	}
+	if len(falses) > 0 {
+		db.Model(&rule).Updates(falses)
+		db.First(&rule, rule.ID)
+	}
+	c.JSON(http.StatusCreated, models.APIResponse{Success: true, Message: "Tax rule created", Data: rule})
+}
+
+// Admin: PUT /api/v1/admin/tax-rules/:id
+func (tc *TaxRuleController) Update(c *gin.Context) {
+	db := config.GetDB()
+	var rule models.TaxRule
+	if err := db.First(&rule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "Tax rule not found"})
+		return
+	}
+
+	var req taxRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid request", Error: err.Error()})
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: msg})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		updates["name"] = strings.TrimSpace(*req.Name)
+	}
+	if req.Rate != nil {
+		updates["rate"] = *req.Rate
+	}
+	if req.PricesIncludeTax != nil {
+		updates["prices_include_tax"] = *req.PricesIncludeTax
+	}
+	if req.ShippingTaxable != nil {
+		updates["shipping_taxable"] = *req.ShippingTaxable
+	}
+	if req.ReverseChargeEnabled != nil {
+		updates["reverse_charge_enabled"] = *req.ReverseChargeEnabled
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+	if len(updates) > 0 {
+		if err := db.Model(&rule).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to update tax rule", Error: err.Error()})
+			return
+		}
+	}
+	db.First(&rule, rule.ID)
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Tax rule updated", Data: rule})
+}
+
+// Admin: DELETE /api/v1/admin/tax-rules/:id
+// Existing orders keep their tax lines; only new orders are affected.
+func (tc *TaxRuleController) Delete(c *gin.Context) {
+	res := config.GetDB().Delete(&models.TaxRule{}, c.Param("id"))
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "Failed to delete tax rule", Error: res.Error.Error()})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "Tax rule not found"})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Tax rule deleted"})
+}
@@ -88,6 +88,9 @@ func main() {
 	// Background jobs (best-effort)
 	services.StartCloudflareAutoPurgeScheduler()
 	services.StartAnalyticsCleanupScheduler()
+	services.StartOrderExpiryScheduler()
+	services.StartCartRecoveryScheduler()
+	services.StartIdempotencyCleanupScheduler()
 
 	// Get host and port from environment
 	host := os.Getenv("HOST")
@@ -38,7 +38,7 @@ func CORSMiddleware() gin.HandlerFunc {
 
 	headers := os.Getenv("CORS_HEADERS")
 	if headers == "" {
-		headers = "Origin,Content-Type,Accept,Authorization,X-Requested-With"
+		headers = "Origin,Content-Type,Accept,Authorization,X-Requested-With,Idempotency-Key,X-Cart-Token"
 	}
 
 	originList := splitAndTrimCSV(origins)
@@ -61,7 +61,7 @@ func CORSMiddleware() gin.HandlerFunc {
 		AllowOrigins:     originList,
 		AllowMethods:     methodList,
 		AllowHeaders:     headerList,
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "Idempotent-Replayed"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fanuc-backend/models"
+	"fanuc-backend/services"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const idempotencyHeader = "Idempotency-Key"
+
+// idempotencyMaxBody is the largest request body that is buffered and hashed (1 MB).
+const idempotencyMaxBody = 1 << 20
+
+// Idempotency makes retried writes safe for clients that send an Idempotency-Key header
+// (e.g. a double-clicked "Place order" or a payment call retried on a flaky connection).
+//
+// The first request with a key runs normally and its response is stored (Redis when available,
+// the database otherwise) for 24h; retries with the same key and body get that response replayed
+// with an Idempotent-Replayed header. A retry while the first request is still running gets 409,
+// and reusing a key for a different request gets 422. Server errors (5xx) are not stored, so the
+// request can be retried with the same key. Requests without the header are not affected.
+//
+// Keys are scoped to the route and the caller (customer ID when logged in), so clients only need
+// keys that are unique per action, such as a UUID.
+func Idempotency(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientKey := strings.TrimSpace(c.GetHeader(idempotencyHeader))
+		if clientKey == "" || c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		if len(clientKey) > 255 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Idempotency-Key must be at most 255 characters"})
+			return
+		}
+
+		// Read one byte past the limit so an oversized body is rejected instead of being
+		// hashed and handed on truncated.
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, idempotencyMaxBody+1))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "Invalid body", Error: err.Error()})
+			return
+		}
+		if len(body) > idempotencyMaxBody {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, models.APIResponse{Success: false, Message: "Request body is too large for an idempotent request"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		owner := "guest"
+		if v, ok := c.Get("customer_id"); ok {
+			owner = fmt.Sprintf("customer:%v", v)
+		}
+		keySum := sha256.Sum256([]byte(scope + "\n" + owner + "\n" + clientKey))
+		key := hex.EncodeToString(keySum[:])
+		reqSum := sha256.New()
+		reqSum.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+		reqSum.Write(body)
+		requestHash := hex.EncodeToString(reqSum.Sum(nil))
+
+		store := services.GetIdempotencyStore()
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+		existing, claimed, err := store.Claim(ctx, key, requestHash)
+		cancel()
+		if err != nil {
+			// Never block checkout on the idempotency store; run the request unguarded.
+			log.Printf("idempotency: claim %s: %v", scope, err)
+			c.Next()
+			return
+		}
+
+		if !claimed {
+			switch {
+			case existing.RequestHash != requestHash:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, models.APIResponse{Success: false, Message: "Idempotency-Key was already used for a different request"})
+			case existing.Status != models.IdempotencyCompleted:
+				c.Header("Retry-After", "1")
+				c.AbortWithStatusJSON(http.StatusConflict, models.APIResponse{Success: false, Message: "A request with this Idempotency-Key is still being processed"})
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(existing.ResponseStatus, existing.ContentType, existing.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		bw := &bodyWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = bw
+		c.Next()
+
+		ctx2, cancel2 := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel2()
+		if bw.status >= http.StatusInternalServerError {
+			if err := store.Release(ctx2, key); err != nil {
+				log.Printf("idempotency: release %s: %v", scope, err)
+			}
+			return
+		}
+		if err := store.Complete(ctx2, key, services.IdempotencyRecord{
+			RequestHash:    requestHash,
+			ResponseStatus: bw.status,
+			ContentType:    bw.Header().Get("Content-Type"),
+			Body:           bw.body,
+		}); err != nil {
+			log.Printf("idempotency: store %s: %v", scope, err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Cart statuses.
+const (
+	CartStatusActive    = "active"
+	CartStatusConverted = "converted" // checked out (see OrderID)
+	CartStatusMerged    = "merged"    // guest cart folded into a customer's cart at login
+)
+
+// Cart is a server-side shopping cart. Customer carts are found by CustomerID; guest carts
+// by Token, which the browser keeps (X-Cart-Token header) and sends again at login to merge.
+type Cart struct {
+	ID    uint   `json:"id" gorm:"primaryKey"`
+	Token string `json:"token" gorm:"type:varchar(64);uniqueIndex;not null"`
+
+	CustomerID *uint     `json:"customer_id" gorm:"index"`
+	Customer   *Customer `json:"customer,omitempty" gorm:"foreignKey:CustomerID"`
+
+	Status         string    `json:"status" gorm:"type:varchar(20);default:'active';index"`
+	Currency       string    `json:"currency" gorm:"type:varchar(10);default:'USD'"` // last currency the cart was viewed in
+	OrderID        *uint     `json:"order_id" gorm:"index"`
+	LastActivityAt time.Time `json:"last_activity_at" gorm:"index"`
+
+	// Set when the cart was rebuilt from a recovery email, to attribute the resulting order.
+	RecoveryID *uint `json:"recovery_id" gorm:"index"`
+
+	Items []CartItem `json:"items,omitempty" gorm:"foreignKey:CartID"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CartItem is one product line. PriceAtAdd (base currency) is remembered so the shopper can
+// be told when the price changed since the item was added.
+type CartItem struct {
+	ID         uint     `json:"id" gorm:"primaryKey"`
+	CartID     uint     `json:"cart_id" gorm:"not null;uniqueIndex:idx_cart_items_cart_product"`
+	ProductID  uint     `json:"product_id" gorm:"not null;uniqueIndex:idx_cart_items_cart_product;index"`
+	Product    *Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Quantity   int      `json:"quantity" gorm:"not null;default:1"`
+	PriceAtAdd float64  `json:"price_at_add" gorm:"type:decimal(10,2);default:0"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,98 @@
+package models
+
+import "time"
+
+// Checkout snapshot statuses.
+const (
+	CheckoutSnapshotOpen    = "open"    // shopper left checkout; eligible for a recovery email
+	CheckoutSnapshotOrdered = "ordered" // an order was placed with the same email
+)
+
+// CheckoutSnapshot records what an identified shopper (email known) had at checkout, so the
+// cart can be restored from a recovery email even when it only lived in the browser.
+// There is at most one open snapshot per email; later visits replace its items.
+type CheckoutSnapshot struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	Email      string    `json:"email" gorm:"type:varchar(255);not null;index"`
+	Name       string    `json:"name" gorm:"type:varchar(255)"`
+	CustomerID *uint     `json:"customer_id" gorm:"index"`
+	CartID     *uint     `json:"cart_id" gorm:"index"`
+	Currency   string    `json:"currency" gorm:"type:varchar(10);default:'USD'"`
+	Subtotal   float64   `json:"subtotal" gorm:"default:0"` // base currency, at snapshot time
+	Status     string    `json:"status" gorm:"type:varchar(20);default:'open';index"`
+	OrderID    *uint     `json:"order_id" gorm:"index"`
+	LastSeenAt time.Time `json:"last_seen_at" gorm:"index"`
+
+	EmailConsent        bool       `json:"email_consent" gorm:"default:false"`
+	RecoveryEmailSentAt *time.Time `json:"recovery_email_sent_at"`
+
+	Items []CheckoutSnapshotItem `json:"items,omitempty" gorm:"foreignKey:SnapshotID"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CheckoutSnapshotItem struct {
+	ID          uint    `json:"id" gorm:"primaryKey"`
+	SnapshotID  uint    `json:"snapshot_id" gorm:"not null;index"`
+	ProductID   uint    `json:"product_id" gorm:"not null"`
+	ProductSKU  string  `json:"product_sku" gorm:"type:varchar(100)"`
+	ProductName string  `json:"product_name" gorm:"type:varchar(255)"`
+	Quantity    int     `json:"quantity" gorm:"not null"`
+	UnitPrice   float64 `json:"unit_price" gorm:"default:0"` // base currency
+}
+
+// Recovery email sources.
+const (
+	CartRecoverySourceSnapshot = "snapshot"
+	CartRecoverySourceOrder    = "order" // unpaid pending order
+)
+
+// CartRecoveryEmail is one recovery email sent for an abandoned checkout or unpaid order.
+// Token is the one-click restore link; RecoveredOrderID is set when the shopper orders
+// (or pays the source order) within the attribution window.
+type CartRecoveryEmail struct {
+	ID            uint    `json:"id" gorm:"primaryKey"`
+	Token         string  `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	Source        string  `json:"source" gorm:"type:varchar(20);not null;index"`
+	SnapshotID    *uint   `json:"snapshot_id" gorm:"index"`
+	SourceOrderID *uint   `json:"source_order_id" gorm:"index"`
+	Email         string  `json:"email" gorm:"type:varchar(255);not null;index"`
+	Amount        float64 `json:"amount" gorm:"default:0"` // abandoned value, base currency
+	CouponID      *uint   `json:"coupon_id"`
+	CouponCode    string  `json:"coupon_code" gorm:"type:varchar(50)"`
+
+	SentAt           time.Time  `json:"sent_at" gorm:"index"`
+	ClickedAt        *time.Time `json:"clicked_at"`
+	RecoveredOrderID *uint      `json:"recovered_order_id" gorm:"index"`
+	RecoveredAt      *time.Time `json:"recovered_at"`
+	RecoveredAmount  float64    `json:"recovered_amount" gorm:"default:0"` // base currency
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CartRecoverySetting holds single-row (ID=1) configuration for abandoned cart emails.
+//
+// Open checkout snapshots (and, when IncludeUnpaidOrders, unpaid pending orders) idle for
+// AbandonedAfterHours get one reminder, only when the shopper consented to emails. Unpaid
+// orders are skipped while the order expiry scheduler sends its own payment reminders. When
+// CouponEnabled, each email carries a single-use coupon valid for CouponValidDays.
+type CartRecoverySetting struct {
+	ID                  uint       `gorm:"primaryKey" json:"id"`
+	Enabled             bool       `gorm:"default:false" json:"enabled"`
+	AbandonedAfterHours int        `gorm:"default:4" json:"abandoned_after_hours"`
+	MaxAgeHours         int        `gorm:"default:72" json:"max_age_hours"` // older carts are not emailed
+	IncludeUnpaidOrders bool       `gorm:"default:true" json:"include_unpaid_orders"`
+	CouponEnabled       bool       `gorm:"default:false" json:"coupon_enabled"`
+	CouponType          string     `gorm:"type:varchar(20);default:'percentage'" json:"coupon_type"` // percentage, fixed_amount
+	CouponValue         float64    `gorm:"type:decimal(10,2);default:5" json:"coupon_value"`
+	CouponValidDays     int        `gorm:"default:7" json:"coupon_valid_days"`
+	AttributionDays     int        `gorm:"default:7" json:"attribution_days"`
+	LastRunAt           *time.Time `json:"last_run_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+func (CartRecoverySetting) TableName() string {
+	return "cart_recovery_settings"
+}
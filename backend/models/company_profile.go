@@ -106,8 +106,18 @@ type CompanyProfile struct {
 	Stats              CompanyStatsArray       `json:"stats" gorm:"type:json"`
 	Expertise          StringArray             `json:"expertise" gorm:"type:json"`
 	WorkshopFacilities WorkshopFacilitiesArray `json:"workshop_facilities" gorm:"type:json"`
-	CreatedAt          time.Time               `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt          time.Time               `json:"updated_at" gorm:"autoUpdateTime"`
+
+	// Invoice / legal details (printed on proforma and commercial invoices)
+	LegalName    string `json:"legal_name" gorm:"size:200"`
+	Address      string `json:"address" gorm:"type:text"`
+	Phone        string `json:"phone" gorm:"size:50"`
+	Email        string `json:"email" gorm:"size:255"`
+	TaxID        string `json:"tax_id" gorm:"size:100"`
+	BankDetails  string `json:"bank_details" gorm:"type:text"`
+	InvoiceNotes string `json:"invoice_notes" gorm:"type:text"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
 // TableName specifies the table name for CompanyProfile
@@ -128,6 +138,13 @@ type CompanyProfileRequest struct {
 	Stats              []CompanyStats     `json:"stats"`
 	Expertise          []string           `json:"expertise"`
 	WorkshopFacilities []WorkshopFacility `json:"workshop_facilities"`
+	LegalName          string             `json:"legal_name"`
+	Address            string             `json:"address"`
+	Phone              string             `json:"phone"`
+	Email              string             `json:"email"`
+	TaxID              string             `json:"tax_id"`
+	BankDetails        string             `json:"bank_details"`
+	InvoiceNotes       string             `json:"invoice_notes"`
 }
 
 // ToCompanyProfile converts CompanyProfileRequest to CompanyProfile
@@ -144,6 +161,13 @@ func (r *CompanyProfileRequest) ToCompanyProfile() *CompanyProfile {
 		Stats:              CompanyStatsArray(r.Stats),
 		Expertise:          StringArray(r.Expertise),
 		WorkshopFacilities: WorkshopFacilitiesArray(r.WorkshopFacilities),
+		LegalName:          r.LegalName,
+		Address:            r.Address,
+		Phone:              r.Phone,
+		Email:              r.Email,
+		TaxID:              r.TaxID,
+		BankDetails:        r.BankDetails,
+		InvoiceNotes:       r.InvoiceNotes,
 	}
 }
 
@@ -161,6 +185,13 @@ type CompanyProfileResponse struct {
 	Stats              []CompanyStats     `json:"stats"`
 	Expertise          []string           `json:"expertise"`
 	WorkshopFacilities []WorkshopFacility `json:"workshop_facilities"`
+	LegalName          string             `json:"legal_name"`
+	Address            string             `json:"address"`
+	Phone              string             `json:"phone"`
+	Email              string             `json:"email"`
+	TaxID              string             `json:"tax_id"`
+	BankDetails        string             `json:"bank_details"`
+	InvoiceNotes       string             `json:"invoice_notes"`
 	CreatedAt          time.Time          `json:"created_at"`
 	UpdatedAt          time.Time          `json:"updated_at"`
 }
@@ -180,6 +211,13 @@ func (c *CompanyProfile) ToResponse() *CompanyProfileResponse {
 		Stats:              []CompanyStats(c.Stats),
 		Expertise:          []string(c.Expertise),
 		WorkshopFacilities: []WorkshopFacility(c.WorkshopFacilities),
+		LegalName:          c.LegalName,
+		Address:            c.Address,
+		Phone:              c.Phone,
+		Email:              c.Email,
+		TaxID:              c.TaxID,
+		BankDetails:        c.BankDetails,
+		InvoiceNotes:       c.InvoiceNotes,
 		CreatedAt:          c.CreatedAt,
 		UpdatedAt:          c.UpdatedAt,
 	}
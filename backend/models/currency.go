@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Currency is a presentment currency shoppers can browse and order in.
+//
+// Catalog prices, coupons and shipping templates are kept in the base currency (USD).
+// Rate is the number of units of this currency per 1 USD; the USD row always has Rate 1.
+type Currency struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	Code          string     `json:"code" gorm:"type:varchar(3);uniqueIndex;not null"` // ISO 4217
+	Name          string     `json:"name" gorm:"type:varchar(100)"`
+	Symbol        string     `json:"symbol" gorm:"type:varchar(10)"`
+	Rate          float64    `json:"rate" gorm:"type:decimal(18,8);not null;default:1"`
+	Decimals      int        `json:"decimals" gorm:"default:2"` // 0 for JPY, KRW, ...
+	IsActive      bool       `json:"is_active" gorm:"default:true;index"`
+	SortOrder     int        `json:"sort_order" gorm:"default:0"`
+	RateSource    string     `json:"rate_source" gorm:"type:varchar(20);default:'manual'"` // manual, import
+	RateUpdatedAt *time.Time `json:"rate_updated_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
@@ -24,6 +24,9 @@ type Customer struct {
 	IsActive   bool `json:"is_active" gorm:"default:true;index"`
 	IsVerified bool `json:"is_verified" gorm:"default:false"`
 
+	// Consent to follow-up emails (abandoned cart reminders, offers)
+	MarketingConsent bool `json:"marketing_consent" gorm:"default:false"`
+
 	// Metadata
 	LastLoginAt *time.Time `json:"last_login_at"`
 	CreatedAt   time.Time  `json:"created_at"`
@@ -42,12 +45,17 @@ type CustomerRegisterRequest struct {
 	FullName  string `json:"full_name" binding:"required"`
 	Phone     string `json:"phone"`
 	Company   string `json:"company"`
+
+	MarketingConsent bool `json:"marketing_consent"`
 }
 
 // CustomerLoginRequest represents the login payload
 type CustomerLoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+
+	// CartToken is the guest cart kept by the browser; it is merged into the customer's cart.
+	CartToken string `json:"cart_token"`
 }
 
 // CustomerLoginResponse represents the login response
@@ -66,6 +74,8 @@ type CustomerProfileUpdateRequest struct {
 	State      string `json:"state"`
 	Country    string `json:"country"`
 	PostalCode string `json:"postal_code"`
+
+	MarketingConsent *bool `json:"marketing_consent"` // nil keeps the current choice
 }
 
 // CustomerPasswordChangeRequest represents password change payload
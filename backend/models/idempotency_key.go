@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Idempotency key statuses.
+const (
+	IdempotencyProcessing = "processing" // first request still running
+	IdempotencyCompleted  = "completed"  // response stored for replay
+)
+
+// IdempotencyKey stores the outcome of a request sent with an Idempotency-Key header, so a
+// retried "place order" or payment call replays the first response instead of running twice.
+// Used when Redis is not configured; Key is a hash of the route scope, caller and client key.
+type IdempotencyKey struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	Key         string `json:"key" gorm:"column:idempotency_key;type:varchar(64);uniqueIndex;not null"`
+	RequestHash string `json:"request_hash" gorm:"type:varchar(64);not null"`
+	Status      string `json:"status" gorm:"type:varchar(20);default:'processing'"`
+
+	ResponseStatus int    `json:"response_status" gorm:"default:0"`
+	ContentType    string `json:"content_type" gorm:"type:varchar(100)"`
+	ResponseBody   string `json:"-" gorm:"type:longtext"`
+
+	// ExpiresAt ends a stale processing claim (crashed request) or the replay window.
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -34,9 +34,13 @@ type Product struct {
 	ComparePrice     *float64 `json:"compare_price" gorm:"type:decimal(10,2)"`
 	CostPrice        *float64 `json:"cost_price" gorm:"type:decimal(10,2)"`
 	StockQuantity    int      `json:"stock_quantity" gorm:"default:0"`
+	ReservedQuantity int      `json:"reserved_quantity" gorm:"default:0"` // held by unpaid orders (see StockReservation)
 	MinStockLevel    int      `json:"min_stock_level" gorm:"default:0"`
 	Weight           *float64 `json:"weight" gorm:"type:decimal(8,2)"`
 	Dimensions       string   `json:"dimensions" gorm:"size:100"`
+	LengthCm         *float64 `json:"length_cm" gorm:"type:decimal(8,2)"` // packed size, for volumetric shipping weight
+	WidthCm          *float64 `json:"width_cm" gorm:"type:decimal(8,2)"`
+	HeightCm         *float64 `json:"height_cm" gorm:"type:decimal(8,2)"`
 	Brand            string   `json:"brand" gorm:"size:100;default:'FANUC';index"`
 	Model            string   `json:"model" gorm:"size:100;index"`
 	PartNumber       string   `json:"part_number" gorm:"size:100;index"`
@@ -55,6 +59,8 @@ type Product struct {
 	OriginCountry        string     `json:"origin_country" gorm:"size:50;default:'China'"`
 	Manufacturer         string     `json:"manufacturer" gorm:"size:100;default:'FANUC'"`
 	LeadTime             string     `json:"lead_time" gorm:"size:50;default:'3-7 days'"`
+	BackorderPolicy      string     `json:"backorder_policy" gorm:"size:20;default:'deny'"` // deny, backorder, preorder (see BackorderDeny)
+	ExpectedArrivalAt    *time.Time `json:"expected_arrival_at"`                            // pre-order: when stock is expected
 	MinimumOrderQuantity int        `json:"minimum_order_quantity" gorm:"default:1"`
 	PackagingInfo        string     `json:"packaging_info" gorm:"type:text"`
 	Certifications       string     `json:"certifications" gorm:"type:text"`
@@ -225,6 +231,15 @@ type ProductCreateRequest struct {
 	Images           []ImageReq              `json:"images"`
 	Attributes       []ProductAttributeReq   `json:"attributes"`
 	Translations     []ProductTranslationReq `json:"translations"`
+
+	// Empty keeps the current policy on update (deny for new products).
+	BackorderPolicy   string     `json:"backorder_policy" binding:"omitempty,oneof=deny backorder preorder"`
+	ExpectedArrivalAt *time.Time `json:"expected_arrival_at"`
+
+	// Packed size in cm; when omitted they are read from Dimensions.
+	LengthCm *float64 `json:"length_cm" binding:"omitempty,gt=0"`
+	WidthCm  *float64 `json:"width_cm" binding:"omitempty,gt=0"`
+	HeightCm *float64 `json:"height_cm" binding:"omitempty,gt=0"`
 }
 
 // ImageReq represents image URL in request
@@ -4,6 +4,29 @@ import (
 	"time"
 )
 
+// Order statuses (fulfillment lifecycle). Allowed transitions live in services/order_state.go.
+const (
+	OrderStatusPending    = "pending"
+	OrderStatusConfirmed  = "confirmed"
+	OrderStatusProcessing = "processing"
+	OrderStatusShipped    = "shipped"
+	OrderStatusDelivered  = "delivered"
+	OrderStatusCancelled  = "cancelled"
+)
+
+// Payment statuses (money lifecycle).
+const (
+	PaymentStatusPending           = "pending"
+	PaymentStatusAwaitingPayment   = "awaiting_payment" // offline payment (bank transfer) expected
+	PaymentStatusPartiallyPaid     = "partially_paid"   // part of an offline payment received
+	PaymentStatusPaid              = "paid"
+	PaymentStatusFailed            = "failed"
+	PaymentStatusPartiallyRefunded = "partially_refunded"
+	PaymentStatusRefunded          = "refunded"
+	PaymentStatusReversed          = "reversed"
+	PaymentStatusDisputed          = "disputed"
+)
+
 type Order struct {
 	ID          uint   `json:"id" gorm:"primaryKey"`
 	OrderNumber string `json:"order_number" gorm:"type:varchar(100);uniqueIndex;not null"`
@@ -21,42 +44,125 @@ type Order struct {
 	CustomerPhone   string `json:"customer_phone" gorm:"type:varchar(50)"`
 	ShippingAddress string `json:"shipping_address" gorm:"type:text"`
 	BillingAddress  string `json:"billing_address" gorm:"type:text"`
-	Status          string `json:"status" gorm:"type:varchar(50);default:'pending'"`         // pending, paid, shipped, delivered, cancelled
-	PaymentStatus   string `json:"payment_status" gorm:"type:varchar(50);default:'pending'"` // pending, paid, failed, refunded
-	PaymentMethod   string `json:"payment_method" gorm:"type:varchar(50)"`                   // paypal, stripe, etc.
+	Status          string `json:"status" gorm:"type:varchar(50);default:'pending'"`         // pending, confirmed, processing, shipped, delivered, cancelled
+	PaymentStatus   string `json:"payment_status" gorm:"type:varchar(50);default:'pending'"` // pending, awaiting_payment, partially_paid, paid, failed, partially_refunded, refunded, reversed, disputed
+	PaymentMethod   string `json:"payment_method" gorm:"type:varchar(50)"`                   // paypal, stripe, bank_transfer, etc.
 	PaymentID       string `json:"payment_id" gorm:"type:varchar(255)"`                      // External payment ID
 
 	// Shipping
 	TrackingNumber     string      `json:"tracking_number" gorm:"type:varchar(255)"`
 	ShippingCarrier    string      `json:"shipping_carrier" gorm:"type:varchar(100)"`
-	ShippingCountry    string      `json:"shipping_country" gorm:"type:varchar(2)"` // ISO 3166-1 alpha-2
+	ShippingService    string      `json:"shipping_service" gorm:"type:varchar(50)"` // carrier service chosen at checkout, e.g. IP
+	ShippingCountry    string      `json:"shipping_country" gorm:"type:varchar(2)"`  // ISO 3166-1 alpha-2
 	ShippingFee        float64     `json:"shipping_fee" gorm:"default:0"`
 	ShippedAt          *time.Time  `json:"shipped_at"`
 	ShippedEmailSentAt *time.Time  `json:"shipped_email_sent_at"`
 	SubtotalAmount     float64     `json:"subtotal_amount" gorm:"not null"`             // Amount before discounts
 	DiscountAmount     float64     `json:"discount_amount" gorm:"default:0"`            // Total discount applied
 	TotalAmount        float64     `json:"total_amount" gorm:"not null"`                // Final amount after discounts
+	RefundedAmount     float64     `json:"refunded_amount" gorm:"default:0"`            // Sum of refunds/reversals (see PaymentTransaction)
 	CouponCode         string      `json:"coupon_code" gorm:"type:varchar(50)"`         // Applied coupon code
 	CouponID           *uint       `json:"coupon_id" gorm:"index"`                      // Applied coupon ID
 	Coupon             *Coupon     `json:"coupon,omitempty" gorm:"foreignKey:CouponID"` // Applied coupon details
 	Currency           string      `json:"currency" gorm:"type:varchar(10);default:'USD'"`
 	Notes              string      `json:"notes" gorm:"type:text"`
 	Items              []OrderItem `json:"items,omitempty" gorm:"foreignKey:OrderID"`
-	CreatedAt          time.Time   `json:"created_at"`
-	UpdatedAt          time.Time   `json:"updated_at"`
+
+	// Source quote when the order was created by accepting an RFQ
+	QuoteRequestID *uint `json:"quote_request_id" gorm:"index"`
+
+	// The amounts above are in Currency (what the customer pays); these are the same amounts
+	// in the base currency, converted at ExchangeRate (units of Currency per 1 BaseCurrency).
+	BaseCurrency       string  `json:"base_currency" gorm:"type:varchar(10);default:'USD'"`
+	ExchangeRate       float64 `json:"exchange_rate" gorm:"type:decimal(18,8);default:1"`
+	BaseSubtotalAmount float64 `json:"base_subtotal_amount" gorm:"default:0"`
+	BaseDiscountAmount float64 `json:"base_discount_amount" gorm:"default:0"`
+	BaseShippingFee    float64 `json:"base_shipping_fee" gorm:"default:0"`
+	BaseTotalAmount    float64 `json:"base_total_amount" gorm:"default:0"`
+
+	// Tax (see TaxRule / OrderTaxLine). TotalAmount = Subtotal - Discount + Shipping, plus
+	// TaxAmount unless TaxInclusive (the tax is then already contained in the prices).
+	TaxAmount     float64        `json:"tax_amount" gorm:"default:0"`
+	BaseTaxAmount float64        `json:"base_tax_amount" gorm:"default:0"`
+	TaxInclusive  bool           `json:"tax_inclusive" gorm:"default:false"`
+	ReverseCharge bool           `json:"reverse_charge" gorm:"default:false"`
+	VATNumber     string         `json:"vat_number" gorm:"type:varchar(20)"`
+	TaxLines      []OrderTaxLine `json:"tax_lines,omitempty" gorm:"foreignKey:OrderID"`
+
+	// Offline payment (bank transfer): the reference the customer quotes on the wire
+	PaymentReference string `json:"payment_reference" gorm:"type:varchar(50);index"`
+
+	// Unpaid order follow-up (see OrderExpirySetting)
+	PaymentReminderSentAt *time.Time `json:"payment_reminder_sent_at"`
+
+	// A payment was captured after the order had been cancelled; the money must be refunded
+	RefundDue bool `json:"refund_due" gorm:"default:false;index"`
+
+	// Shopper agreed to follow-up emails at checkout (abandoned cart recovery, see CartRecoverySetting)
+	MarketingConsent bool `json:"marketing_consent" gorm:"default:false"`
+
+	// Derived from shipped quantities: unfulfilled, partial, fulfilled (see Shipment)
+	FulfillmentStatus string     `json:"fulfillment_status" gorm:"type:varchar(20);default:'unfulfilled';index"`
+	Shipments         []Shipment `json:"shipments,omitempty" gorm:"foreignKey:OrderID"`
+
+	Transactions  []PaymentTransaction `json:"transactions,omitempty" gorm:"foreignKey:OrderID"`
+	StatusHistory []OrderStatusHistory `json:"status_history,omitempty" gorm:"foreignKey:OrderID"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type OrderItem struct {
-	ID         uint      `json:"id" gorm:"primaryKey"`
-	OrderID    uint      `json:"order_id" gorm:"not null;index"`
-	Order      *Order    `json:"order,omitempty" gorm:"foreignKey:OrderID"`
-	ProductID  uint      `json:"product_id" gorm:"not null;index"`
-	Product    *Product  `json:"product,omitempty" gorm:"foreignKey:ProductID"`
-	Quantity   int       `json:"quantity" gorm:"not null"`
-	UnitPrice  float64   `json:"unit_price" gorm:"not null"`
-	TotalPrice float64   `json:"total_price" gorm:"not null"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID         uint     `json:"id" gorm:"primaryKey"`
+	OrderID    uint     `json:"order_id" gorm:"not null;index"`
+	Order      *Order   `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+	ProductID  uint     `json:"product_id" gorm:"not null;index"`
+	Product    *Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Quantity   int      `json:"quantity" gorm:"not null"`
+	UnitPrice  float64  `json:"unit_price" gorm:"not null"`
+	TotalPrice float64  `json:"total_price" gorm:"not null"`
+
+	RefundedQuantity  int `json:"refunded_quantity" gorm:"default:0"`
+	RestockedQuantity int `json:"restocked_quantity" gorm:"default:0"` // returned to Product.StockQuantity (refund/cancel)
+	ShippedQuantity   int `json:"shipped_quantity" gorm:"default:0"`   // sum of ShipmentItem quantities
+
+	// Units ordered beyond available stock (Product.BackorderPolicy). They are neither reserved
+	// nor deducted and cannot ship until received stock is allocated to them.
+	BackorderedQuantity int        `json:"backordered_quantity" gorm:"default:0"`
+	ExpectedShipDate    *time.Time `json:"expected_ship_date"`
+	BackorderFilledAt   *time.Time `json:"backorder_filled_at"`
+
+	// Catalog price in the base currency; UnitPrice is in the order's Currency.
+	BaseUnitPrice float64 `json:"base_unit_price" gorm:"default:0"`
+
+	// Snapshot of the product at order time, so later catalog edits don't rewrite history.
+	ProductSKU  string `json:"product_sku" gorm:"type:varchar(100)"`
+	ProductName string `json:"product_name" gorm:"type:varchar(255)"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DisplayName returns the snapshot name, falling back to the live product name for legacy rows.
+func (it OrderItem) DisplayName() string {
+	if it.ProductName != "" {
+		return it.ProductName
+	}
+	if it.Product != nil {
+		return it.Product.Name
+	}
+	return ""
+}
+
+// DisplaySKU returns the snapshot SKU, falling back to the live product SKU for legacy rows.
+func (it OrderItem) DisplaySKU() string {
+	if it.ProductSKU != "" {
+		return it.ProductSKU
+	}
+	if it.Product != nil {
+		return it.Product.SKU
+	}
+	return ""
 }
 
 // PaymentTransaction represents a payment transaction
@@ -66,12 +172,32 @@ type PaymentTransaction struct {
 	Order         *Order    `json:"order,omitempty" gorm:"foreignKey:OrderID"`
 	TransactionID string    `json:"transaction_id" gorm:"type:varchar(255);uniqueIndex;not null"` // PayPal transaction ID
 	PaymentMethod string    `json:"payment_method" gorm:"type:varchar(50);not null"`              // paypal, stripe, etc.
-	Amount        float64   `json:"amount" gorm:"not null"`
+	Amount        float64   `json:"amount" gorm:"not null"`                                       // negative for refunds/reversals
 	Currency      string    `json:"currency" gorm:"type:varchar(10);default:'USD'"`
-	Status        string    `json:"status" gorm:"type:varchar(50);not null"` // pending, completed, failed, cancelled
+	Status        string    `json:"status" gorm:"type:varchar(50);not null"` // pending, completed, failed, cancelled, refunded, reversed, disputed
 	PayerID       string    `json:"payer_id" gorm:"type:varchar(255)"`
 	PayerEmail    string    `json:"payer_email" gorm:"type:varchar(255)"`
 	PaymentData   string    `json:"payment_data" gorm:"type:text"` // JSON data from payment provider
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 }
+
+// OrderStatusHistory is an audit row for every order/payment status change.
+type OrderStatusHistory struct {
+	ID      uint `json:"id" gorm:"primaryKey"`
+	OrderID uint `json:"order_id" gorm:"not null;index"`
+
+	// Field is "status", "payment_status", "fulfillment_status" or "event" (an action without a status change,
+	// e.g. a payment reminder; ToStatus then names the event).
+	Field      string `json:"field" gorm:"type:varchar(30);not null;default:'status'"`
+	FromStatus string `json:"from_status" gorm:"type:varchar(50)"`
+	ToStatus   string `json:"to_status" gorm:"type:varchar(50);not null"`
+
+	// ActorType: admin | customer | system | webhook
+	ActorType    string     `json:"actor_type" gorm:"type:varchar(20);not null;default:'system'"`
+	ActorAdminID *uint      `json:"actor_admin_id" gorm:"index"`
+	ActorAdmin   *AdminUser `json:"actor_admin,omitempty" gorm:"foreignKey:ActorAdminID"`
+
+	Note      string    `json:"note" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// OrderExpirySetting holds single-row (ID=1) configuration for cancelling unpaid orders.
+//
+// A pending order whose payment is still pending/failed ExpireAfterHours after it was placed
+// is cancelled (releasing its stock reservation and coupon usage). Orders awaiting a bank
+// transfer get BankTransferExpireAfterHours instead (0 never expires them); partially paid
+// transfers are never cancelled automatically. When ReminderEnabled, a "complete your
+// payment" email is sent ReminderBeforeHours before the deadline.
+type OrderExpirySetting struct {
+	ID                           uint       `gorm:"primaryKey" json:"id"`
+	Enabled                      bool       `gorm:"default:true" json:"enabled"`
+	ExpireAfterHours             int        `gorm:"default:72" json:"expire_after_hours"`
+	BankTransferExpireAfterHours int        `gorm:"default:168" json:"bank_transfer_expire_after_hours"`
+	ReminderEnabled              bool       `gorm:"default:true" json:"reminder_enabled"`
+	ReminderBeforeHours          int        `gorm:"default:24" json:"reminder_before_hours"`
+	LastRunAt                    *time.Time `json:"last_run_at"`
+	CreatedAt                    time.Time  `json:"created_at"`
+	UpdatedAt                    time.Time  `json:"updated_at"`
+}
+
+func (OrderExpirySetting) TableName() string {
+	return "order_expiry_settings"
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Order document types.
+const (
+	InvoiceTypeProforma = "proforma" // issued before payment (quotation/bank transfer instructions)
+	InvoiceTypeInvoice  = "invoice"  // final commercial invoice for a paid order
+)
+
+// OrderInvoice is an issued invoice document. The number is assigned once and never reused,
+// so re-downloading an order's invoice always shows the same number.
+type OrderInvoice struct {
+	ID      uint   `json:"id" gorm:"primaryKey"`
+	OrderID uint   `json:"order_id" gorm:"not null;uniqueIndex:idx_order_invoice_type"`
+	Order   *Order `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+	Type    string `json:"type" gorm:"type:varchar(20);not null;uniqueIndex:idx_order_invoice_type"`
+	Number  string `json:"number" gorm:"type:varchar(50);not null;uniqueIndex"`
+
+	// Totals at issue time (the order may be refunded/edited later).
+	Currency    string  `json:"currency" gorm:"type:varchar(10);default:'USD'"`
+	TotalAmount float64 `json:"total_amount" gorm:"type:decimal(12,2);default:0"`
+
+	IssuedAt  time.Time `json:"issued_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DocumentSequence is a named gap-free counter (e.g. "invoice-2026") for document numbers.
+type DocumentSequence struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"type:varchar(50);not null;uniqueIndex"`
+	LastValue int64     `json:"last_value" gorm:"not null;default:0"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
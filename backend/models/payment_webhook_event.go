@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// PaymentWebhookEvent records every inbound payment provider webhook.
+//
+// The (provider, event_id) unique index is what deduplicates redeliveries: providers retry
+// until they get a 2xx, and the same event must never create a second transaction.
+type PaymentWebhookEvent struct {
+	ID uint `json:"id" gorm:"primaryKey"`
+
+	Provider   string `json:"provider" gorm:"size:32;not null;uniqueIndex:idx_payment_webhook_provider_event"` // paypal, stripe
+	EventID    string `json:"event_id" gorm:"size:128;not null;uniqueIndex:idx_payment_webhook_provider_event"`
+	EventType  string `json:"event_type" gorm:"size:100;index"`
+	ResourceID string `json:"resource_id" gorm:"size:128;index"`
+	OrderID    *uint  `json:"order_id" gorm:"index"`
+
+	// Status: received | processed | ignored | failed
+	Status string `json:"status" gorm:"size:20;default:'received';index"`
+	Error  string `json:"error" gorm:"type:text"`
+
+	Payload string `json:"payload" gorm:"type:longtext"`
+
+	ProcessedAt *time.Time `json:"processed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
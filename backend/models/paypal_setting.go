@@ -5,8 +5,9 @@ import "time"
 // PayPalSetting stores PayPal configuration.
 //
 // This is a single-row table (ID=1) so the admin UI can update it easily.
-// We only store Client IDs here (not secrets). Client IDs are public by nature
-// and are required on the frontend to load the PayPal JS SDK.
+// Client IDs are public by nature and are required on the frontend to load the PayPal JS SDK.
+// Client secrets are only used server-side to verify captures; they are stored encrypted
+// (utils.EncryptSecret) and never returned via JSON.
 type PayPalSetting struct {
 	ID uint `json:"id" gorm:"primaryKey"`
 
@@ -19,6 +20,13 @@ type PayPalSetting struct {
 	ClientIDSandbox string `json:"client_id_sandbox" gorm:"size:255;default:''"`
 	ClientIDLive    string `json:"client_id_live" gorm:"size:255;default:''"`
 
+	ClientSecretSandboxEnc string `json:"-" gorm:"type:text"`
+	ClientSecretLiveEnc    string `json:"-" gorm:"type:text"`
+
+	// Webhook IDs (from the PayPal developer dashboard) are needed to verify webhook signatures.
+	WebhookIDSandbox string `json:"webhook_id_sandbox" gorm:"size:64;default:''"`
+	WebhookIDLive    string `json:"webhook_id_live" gorm:"size:64;default:''"`
+
 	Currency string `json:"currency" gorm:"size:10;default:'USD'"`
 
 	CreatedAt time.Time `json:"created_at"`
@@ -35,6 +43,43 @@ func (s *PayPalSetting) EffectiveClientID() string {
 	return s.ClientIDSandbox
 }
 
+// EffectiveClientSecretEnc returns the encrypted secret for the active mode.
+func (s *PayPalSetting) EffectiveClientSecretEnc() string {
+	if s == nil {
+		return ""
+	}
+	if s.Mode == "live" {
+		return s.ClientSecretLiveEnc
+	}
+	return s.ClientSecretSandboxEnc
+}
+
+// EffectiveWebhookID returns the webhook ID for the active mode.
+func (s *PayPalSetting) EffectiveWebhookID() string {
+	if s == nil {
+		return ""
+	}
+	if s.Mode == "live" {
+		return s.WebhookIDLive
+	}
+	return s.WebhookIDSandbox
+}
+
+// PayPalSettingResponse is the admin view; secrets are replaced by has_* flags.
+type PayPalSettingResponse struct {
+	PayPalSetting
+	HasClientSecretSandbox bool `json:"has_client_secret_sandbox"`
+	HasClientSecretLive    bool `json:"has_client_secret_live"`
+}
+
+func (s *PayPalSetting) ToResponse() PayPalSettingResponse {
+	return PayPalSettingResponse{
+		PayPalSetting:          *s,
+		HasClientSecretSandbox: s.ClientSecretSandboxEnc != "",
+		HasClientSecretLive:    s.ClientSecretLiveEnc != "",
+	}
+}
+
 type PayPalPublicConfig struct {
 	Enabled  bool   `json:"enabled"`
 	Mode     string `json:"mode"`
@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+// Quote request statuses.
+const (
+	QuoteStatusSubmitted = "submitted" // waiting for an admin to price it
+	QuoteStatusQuoted    = "quoted"    // priced and sent to the customer
+	QuoteStatusAccepted  = "accepted"  // converted into an order (see OrderID)
+	QuoteStatusRejected  = "rejected"  // declined by the customer
+	QuoteStatusExpired   = "expired"   // ValidUntil passed before acceptance
+	QuoteStatusCancelled = "cancelled" // withdrawn by an admin
+)
+
+// QuoteRequest is a request-for-quote (RFQ): a list of part numbers a customer or guest
+// wants priced before ordering. Guests access it through AccessToken (sent by email).
+type QuoteRequest struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	QuoteNumber string `json:"quote_number" gorm:"type:varchar(50);uniqueIndex;not null"`
+	AccessToken string `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+
+	// Customer reference (for registered customers)
+	CustomerID *uint     `json:"customer_id" gorm:"index"`
+	Customer   *Customer `json:"customer,omitempty" gorm:"foreignKey:CustomerID"`
+
+	CustomerEmail   string `json:"customer_email" gorm:"type:varchar(255);not null;index"`
+	CustomerName    string `json:"customer_name" gorm:"type:varchar(255);not null"`
+	CustomerPhone   string `json:"customer_phone" gorm:"type:varchar(50)"`
+	CompanyName     string `json:"company_name" gorm:"type:varchar(255)"`
+	VATNumber       string `json:"vat_number" gorm:"type:varchar(20)"`      // for reverse charge on the resulting order
+	ShippingCountry string `json:"shipping_country" gorm:"type:varchar(2)"` // ISO 3166-1 alpha-2
+	ShippingAddress string `json:"shipping_address" gorm:"type:text"`
+	Message         string `json:"message" gorm:"type:text"`
+
+	Status string `json:"status" gorm:"type:varchar(20);default:'submitted';index"`
+
+	// Quote (filled in by an admin)
+	Currency       string     `json:"currency" gorm:"type:varchar(10);default:'USD'"`
+	SubtotalAmount float64    `json:"subtotal_amount" gorm:"type:decimal(12,2);default:0"`
+	ShippingFee    float64    `json:"shipping_fee" gorm:"type:decimal(12,2);default:0"`
+	TotalAmount    float64    `json:"total_amount" gorm:"type:decimal(12,2);default:0"`
+	ValidUntil     *time.Time `json:"valid_until"`
+	QuoteNotes     string     `json:"quote_notes" gorm:"type:text"`           // shown to the customer
+	AdminNotes     string     `json:"admin_notes,omitempty" gorm:"type:text"` // internal
+	QuotedAt       *time.Time `json:"quoted_at"`
+	QuotedByID     *uint      `json:"quoted_by_id" gorm:"index"`
+	QuotedBy       *AdminUser `json:"quoted_by,omitempty" gorm:"foreignKey:QuotedByID"`
+
+	// Conversion
+	AcceptedAt *time.Time `json:"accepted_at"`
+	RejectedAt *time.Time `json:"rejected_at"`
+	OrderID    *uint      `json:"order_id" gorm:"index"`
+
+	Items []QuoteRequestItem `json:"items,omitempty" gorm:"foreignKey:QuoteRequestID"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// QuoteRequestItem is one requested part number. UnitPrice stays nil until quoted; lines an
+// admin cannot supply are left unpriced and are not part of the resulting order.
+type QuoteRequestItem struct {
+	ID             uint `json:"id" gorm:"primaryKey"`
+	QuoteRequestID uint `json:"quote_request_id" gorm:"not null;index"`
+
+	// Requested
+	SKU       string `json:"sku" gorm:"type:varchar(100);not null"`
+	Quantity  int    `json:"quantity" gorm:"not null;default:1"`
+	Condition string `json:"condition" gorm:"type:varchar(20);default:'any'"` // new, refurbished, used, any
+	Notes     string `json:"notes" gorm:"type:text"`
+
+	// Matched catalog product (set on submit by SKU, or by the admin when pricing)
+	ProductID   *uint    `json:"product_id" gorm:"index"`
+	Product     *Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	ProductName string   `json:"product_name" gorm:"type:varchar(255)"`
+
+	// Quoted
+	UnitPrice  *float64 `json:"unit_price" gorm:"type:decimal(12,2)"`
+	TotalPrice float64  `json:"total_price" gorm:"type:decimal(12,2);default:0"`
+	LeadTime   string   `json:"lead_time" gorm:"type:varchar(100)"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// IsQuoted reports whether the line has a price and can be ordered.
+func (it QuoteRequestItem) IsQuoted() bool {
+	return it.UnitPrice != nil && *it.UnitPrice > 0 && it.ProductID != nil
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Return (RMA) statuses. Allowed transitions live in services/return_request.go.
+const (
+	ReturnStatusRequested = "requested" // submitted by the customer, waiting for review
+	ReturnStatusApproved  = "approved"  // RMA number issued, customer may send the part back
+	ReturnStatusRejected  = "rejected"  // declined by an admin
+	ReturnStatusReceived  = "received"  // part arrived at the warehouse
+	ReturnStatusInspected = "inspected" // part checked, waiting for a resolution
+	ReturnStatusResolved  = "resolved"  // refunded, replaced or repaired (see Resolution)
+	ReturnStatusCancelled = "cancelled" // withdrawn by the customer before approval
+)
+
+// Return resolutions (ReturnRequest.Resolution).
+const (
+	ReturnResolutionRefund      = "refund"
+	ReturnResolutionReplacement = "replacement"
+	ReturnResolutionRepair      = "repair"
+)
+
+// ReturnRequest is a return merchandise authorization (RMA) for parts of a shipped order,
+// e.g. a warranty claim or a wrong part. The customer conversation runs in the linked Ticket.
+type ReturnRequest struct {
+	ID uint `json:"id" gorm:"primaryKey"`
+	// RMANumber is issued on approval; the customer must quote it on the returned parcel.
+	RMANumber *string `json:"rma_number" gorm:"type:varchar(50);uniqueIndex"`
+
+	OrderID    uint      `json:"order_id" gorm:"not null;index"`
+	Order      *Order    `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+	CustomerID uint      `json:"customer_id" gorm:"not null;index"`
+	Customer   *Customer `json:"customer,omitempty" gorm:"foreignKey:CustomerID"`
+	TicketID   *uint     `json:"ticket_id" gorm:"index"`
+	Ticket     *Ticket   `json:"ticket,omitempty" gorm:"foreignKey:TicketID"`
+
+	Reason      string `json:"reason" gorm:"type:varchar(30);not null"` // warranty, wrong_part, damaged, not_as_described, other
+	Description string `json:"description" gorm:"type:text"`
+	Status      string `json:"status" gorm:"type:varchar(20);default:'requested';index"`
+
+	// Review
+	ReturnInstructions string     `json:"return_instructions" gorm:"type:text"` // shown to the customer
+	RejectionReason    string     `json:"rejection_reason" gorm:"type:text"`
+	AdminNotes         string     `json:"admin_notes,omitempty" gorm:"type:text"` // internal
+	ReviewedAt         *time.Time `json:"reviewed_at"`
+	ReviewedByID       *uint      `json:"reviewed_by_id"`
+
+	// Inbound parcel and inspection
+	ReturnCarrier        string     `json:"return_carrier" gorm:"type:varchar(100)"`
+	ReturnTrackingNumber string     `json:"return_tracking_number" gorm:"type:varchar(255)"`
+	ReceivedAt           *time.Time `json:"received_at"`
+	InspectionPassed     *bool      `json:"inspection_passed"`
+	InspectionNotes      string     `json:"inspection_notes" gorm:"type:text"`
+	InspectedAt          *time.Time `json:"inspected_at"`
+
+	// Resolution. Refunds link the PaymentTransaction; replacements and repairs carry the
+	// outbound tracking of the part sent back to the customer.
+	Resolution             string     `json:"resolution" gorm:"type:varchar(20)"`
+	ResolutionNotes        string     `json:"resolution_notes" gorm:"type:text"`
+	RefundTransactionID    *uint      `json:"refund_transaction_id"`
+	RefundAmount           float64    `json:"refund_amount" gorm:"default:0"`
+	OutboundCarrier        string     `json:"outbound_carrier" gorm:"type:varchar(100)"`
+	OutboundTrackingNumber string     `json:"outbound_tracking_number" gorm:"type:varchar(255)"`
+	ResolvedAt             *time.Time `json:"resolved_at"`
+
+	Items  []ReturnRequestItem  `json:"items,omitempty" gorm:"foreignKey:ReturnRequestID"`
+	Photos []ReturnRequestPhoto `json:"photos,omitempty" gorm:"foreignKey:ReturnRequestID"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DisplayNumber is the RMA number once issued, otherwise a reference from the ID.
+func (r ReturnRequest) DisplayNumber() string {
+	if r.RMANumber != nil && *r.RMANumber != "" {
+		return *r.RMANumber
+	}
+	return fmt.Sprintf("RETURN-%d", r.ID)
+}
+
+// ReturnRequestItem is the quantity of one order line being returned.
+type ReturnRequestItem struct {
+	ID               uint       `json:"id" gorm:"primaryKey"`
+	ReturnRequestID  uint       `json:"return_request_id" gorm:"not null;index"`
+	OrderItemID      uint       `json:"order_item_id" gorm:"not null;index"`
+	OrderItem        *OrderItem `json:"order_item,omitempty" gorm:"foreignKey:OrderItemID"`
+	Quantity         int        `json:"quantity" gorm:"not null"`
+	ReceivedQuantity int        `json:"received_quantity" gorm:"default:0"`
+}
+
+// ReturnRequestPhoto is a customer photo of the returned part (label, damage, fault display).
+type ReturnRequestPhoto struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	ReturnRequestID uint      `json:"return_request_id" gorm:"not null;index"`
+	FileName        string    `json:"file_name" gorm:"size:255;not null"`
+	FileURL         string    `json:"file_url" gorm:"type:text;not null"`
+	FileSize        int64     `json:"file_size"`
+	FileType        string    `json:"file_type" gorm:"size:100"`
+	CreatedAt       time.Time `json:"created_at"`
+}
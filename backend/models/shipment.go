@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Fulfillment statuses (Order.FulfillmentStatus), derived from shipped quantities.
+const (
+	FulfillmentUnfulfilled = "unfulfilled"
+	FulfillmentPartial     = "partial"
+	FulfillmentFulfilled   = "fulfilled"
+)
+
+// Shipment is one parcel sent for an order. An order can ship in several shipments (e.g. the
+// board from stock now, the cable a week later); each carries its own carrier and tracking.
+// Order.TrackingNumber/ShippingCarrier mirror the latest shipment for older clients.
+type Shipment struct {
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	OrderID        uint           `json:"order_id" gorm:"not null;index"`
+	Carrier        string         `json:"carrier" gorm:"type:varchar(100)"`
+	TrackingNumber string         `json:"tracking_number" gorm:"type:varchar(255);index"`
+	ShippedAt      time.Time      `json:"shipped_at"`
+	Notes          string         `json:"notes" gorm:"type:text"`
+	EmailSentAt    *time.Time     `json:"email_sent_at"`
+	CreatedByID    *uint          `json:"created_by_id"` // admin user; nil for system-created shipments
+	Items          []ShipmentItem `json:"items,omitempty" gorm:"foreignKey:ShipmentID"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ShipmentItem is the quantity of one order line in a shipment.
+type ShipmentItem struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	ShipmentID  uint       `json:"shipment_id" gorm:"not null;index"`
+	OrderItemID uint       `json:"order_item_id" gorm:"not null;index"`
+	OrderItem   *OrderItem `json:"order_item,omitempty" gorm:"foreignKey:OrderItemID"`
+	Quantity    int        `json:"quantity" gorm:"not null"`
+}
@@ -14,6 +14,13 @@ type ShippingCarrierTemplate struct {
 	Currency    string `json:"currency" gorm:"size:10;not null;default:'USD'"`
 	IsActive    bool   `json:"is_active" gorm:"default:true"`
 
+	// Door-to-door transit estimate in business days shown at checkout (0 = unknown).
+	TransitDaysMin int `json:"transit_days_min" gorm:"default:0"`
+	TransitDaysMax int `json:"transit_days_max" gorm:"default:0"`
+
+	// cm³ per volumetric kg (FedEx/DHL express: 5000); 0 uses the default divisor.
+	VolumetricDivisor int `json:"volumetric_divisor" gorm:"default:0"`
+
 	WeightBrackets  []ShippingCarrierWeightBracket  `json:"weight_brackets,omitempty" gorm:"foreignKey:TemplateID"`
 	QuoteSurcharges []ShippingCarrierQuoteSurcharge `json:"quote_surcharges,omitempty" gorm:"foreignKey:TemplateID"`
 
@@ -12,6 +12,10 @@ type ShippingTemplate struct {
 	Currency    string `json:"currency" gorm:"size:10;not null;default:'USD'"`
 	IsActive    bool   `json:"is_active" gorm:"default:true"`
 
+	// Door-to-door transit estimate in business days shown at checkout (0 = unknown).
+	TransitDaysMin int `json:"transit_days_min" gorm:"default:0"`
+	TransitDaysMax int `json:"transit_days_max" gorm:"default:0"`
+
 	WeightBrackets  []ShippingWeightBracket  `json:"weight_brackets,omitempty" gorm:"foreignKey:TemplateID"`
 	QuoteSurcharges []ShippingQuoteSurcharge `json:"quote_surcharges,omitempty" gorm:"foreignKey:TemplateID"`
 
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Stock reservation statuses.
+const (
+	StockReservationActive    = "active"    // holding Product.ReservedQuantity
+	StockReservationConverted = "converted" // order paid, turned into a stock deduction
+	StockReservationReleased  = "released"  // order cancelled/expired/deleted, hold returned
+)
+
+// StockReservation holds inventory for an unpaid order line.
+//
+// While active, Quantity is counted in Product.ReservedQuantity so other buyers cannot
+// order the same units. On payment it is converted into a real StockQuantity deduction;
+// on cancellation or expiry it is released.
+type StockReservation struct {
+	ID          uint     `json:"id" gorm:"primaryKey"`
+	OrderID     uint     `json:"order_id" gorm:"not null;index"`
+	OrderItemID uint     `json:"order_item_id" gorm:"index"`
+	ProductID   uint     `json:"product_id" gorm:"not null;index"`
+	Product     *Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Quantity    int      `json:"quantity" gorm:"not null"`
+
+	Status string `json:"status" gorm:"size:20;default:'active';index"`
+
+	ConvertedAt *time.Time `json:"converted_at"`
+	ReleasedAt  *time.Time `json:"released_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Product backorder policies.
+const (
+	BackorderDeny     = "deny"      // out-of-stock units cannot be ordered
+	BackorderAllow    = "backorder" // orderable; ships after Product.LeadTime
+	BackorderPreorder = "preorder"  // orderable; ships when stock arrives (Product.ExpectedArrivalAt)
+)
+
+// AllowsBackorder reports whether the product can be ordered beyond its available stock.
+func (p *Product) AllowsBackorder() bool {
+	return p != nil && (p.BackorderPolicy == BackorderAllow || p.BackorderPolicy == BackorderPreorder)
+}
+
+// AvailableQuantity is the stock that can still be ordered (on hand minus reserved).
+func (p *Product) AvailableQuantity() int {
+	if p == nil {
+		return 0
+	}
+	return p.StockQuantity - p.ReservedQuantity
+}
@@ -0,0 +1,103 @@
+package models
+
+import "time"
+
+// StripeSetting stores Stripe (card payments) configuration.
+//
+// Like PayPalSetting this is a single-row table (ID=1). Publishable keys are public and are
+// needed by Stripe.js on the checkout page. Secret keys and webhook signing secrets are only
+// used server-side; they are stored encrypted (utils.EncryptSecret) and never returned via JSON.
+type StripeSetting struct {
+	ID uint `json:"id" gorm:"primaryKey"`
+
+	Enabled bool `json:"enabled" gorm:"default:false"`
+
+	// Mode selects which key set is used.
+	// Allowed: "test" | "live"
+	Mode string `json:"mode" gorm:"size:16;default:'test'"`
+
+	PublishableKeyTest string `json:"publishable_key_test" gorm:"size:255;default:''"`
+	PublishableKeyLive string `json:"publishable_key_live" gorm:"size:255;default:''"`
+
+	SecretKeyTestEnc string `json:"-" gorm:"type:text"`
+	SecretKeyLiveEnc string `json:"-" gorm:"type:text"`
+
+	// Webhook signing secrets (whsec_...) from the Stripe dashboard endpoint settings.
+	WebhookSecretTestEnc string `json:"-" gorm:"type:text"`
+	WebhookSecretLiveEnc string `json:"-" gorm:"type:text"`
+
+	// StatementDescriptor is the suffix shown on the card statement (max 22 chars, optional).
+	StatementDescriptor string `json:"statement_descriptor" gorm:"size:22;default:''"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (s *StripeSetting) EffectivePublishableKey() string {
+	if s == nil {
+		return ""
+	}
+	if s.Mode == "live" {
+		return s.PublishableKeyLive
+	}
+	return s.PublishableKeyTest
+}
+
+// EffectiveSecretKeyEnc returns the encrypted secret key for the active mode.
+func (s *StripeSetting) EffectiveSecretKeyEnc() string {
+	if s == nil {
+		return ""
+	}
+	if s.Mode == "live" {
+		return s.SecretKeyLiveEnc
+	}
+	return s.SecretKeyTestEnc
+}
+
+// EffectiveWebhookSecretEnc returns the encrypted webhook signing secret for the active mode.
+func (s *StripeSetting) EffectiveWebhookSecretEnc() string {
+	if s == nil {
+		return ""
+	}
+	if s.Mode == "live" {
+		return s.WebhookSecretLiveEnc
+	}
+	return s.WebhookSecretTestEnc
+}
+
+// StripeSettingResponse is the admin view; secrets are replaced by has_* flags.
+type StripeSettingResponse struct {
+	StripeSetting
+	HasSecretKeyTest     bool `json:"has_secret_key_test"`
+	HasSecretKeyLive     bool `json:"has_secret_key_live"`
+	HasWebhookSecretTest bool `json:"has_webhook_secret_test"`
+	HasWebhookSecretLive bool `json:"has_webhook_secret_live"`
+}
+
+func (s *StripeSetting) ToResponse() StripeSettingResponse {
+	return StripeSettingResponse{
+		StripeSetting:        *s,
+		HasSecretKeyTest:     s.SecretKeyTestEnc != "",
+		HasSecretKeyLive:     s.SecretKeyLiveEnc != "",
+		HasWebhookSecretTest: s.WebhookSecretTestEnc != "",
+		HasWebhookSecretLive: s.WebhookSecretLiveEnc != "",
+	}
+}
+
+type StripePublicConfig struct {
+	Enabled        bool   `json:"enabled"`
+	Mode           string `json:"mode"`
+	PublishableKey string `json:"publishable_key"`
+}
+
+func (s *StripeSetting) ToPublicConfig() StripePublicConfig {
+	mode := s.Mode
+	if mode != "live" {
+		mode = "test"
+	}
+	return StripePublicConfig{
+		Enabled:        s.Enabled,
+		Mode:           mode,
+		PublishableKey: s.EffectivePublishableKey(),
+	}
+}
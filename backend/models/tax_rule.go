@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// TaxRule is the sales tax / VAT applied to orders shipped to a country.
+// Countries without an active rule are not taxed (e.g. exports outside the EU/UK).
+type TaxRule struct {
+	ID          uint    `json:"id" gorm:"primaryKey"`
+	CountryCode string  `json:"country_code" gorm:"size:2;not null;uniqueIndex"`  // ISO 3166-1 alpha-2
+	Name        string  `json:"name" gorm:"size:50;default:'VAT'"`                // label on invoices, e.g. "VAT", "MwSt", "GST"
+	Rate        float64 `json:"rate" gorm:"type:decimal(6,3);not null;default:0"` // percent, e.g. 20 for 20%
+
+	// PricesIncludeTax: catalog prices are treated as gross for this country and the tax is
+	// extracted from them; otherwise tax is added on top of the net prices.
+	PricesIncludeTax bool `json:"prices_include_tax" gorm:"default:false"`
+	ShippingTaxable  bool `json:"shipping_taxable" gorm:"default:true"`
+
+	// ReverseChargeEnabled: business customers with a valid VAT number registered in this
+	// country are not charged tax (the buyer self-accounts for it).
+	ReverseChargeEnabled bool `json:"reverse_charge_enabled" gorm:"default:false"`
+
+	IsActive  bool      `json:"is_active" gorm:"default:true;index"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OrderTaxLine is one tax component of an order, in the order's currency.
+// For reverse-charged orders with tax-inclusive prices, TaxAmount is negative: the tax
+// contained in the prices is deducted from the total.
+type OrderTaxLine struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	OrderID       uint      `json:"order_id" gorm:"not null;index"`
+	Name          string    `json:"name" gorm:"size:100;not null"`
+	CountryCode   string    `json:"country_code" gorm:"size:2"`
+	Rate          float64   `json:"rate" gorm:"type:decimal(6,3);default:0"`
+	Component     string    `json:"component" gorm:"size:20"` // items, shipping
+	TaxableAmount float64   `json:"taxable_amount" gorm:"default:0"`
+	TaxAmount     float64   `json:"tax_amount" gorm:"default:0"`
+	BaseTaxAmount float64   `json:"base_tax_amount" gorm:"default:0"`
+	Inclusive     bool      `json:"inclusive" gorm:"default:false"`
+	ReverseCharge bool      `json:"reverse_charge" gorm:"default:false"`
+	CreatedAt     time.Time `json:"created_at"`
+}
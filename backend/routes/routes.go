@@ -41,8 +41,15 @@ func SetupRoutes(r *gin.Engine) {
 	cacheController := &controllers.CacheController{}
 	hotlinkController := &controllers.HotlinkController{}
 	payPalController := &controllers.PayPalController{}
+	stripeController := &controllers.StripeController{}
+	paymentController := &controllers.PaymentController{}
 	analyticsController := &controllers.AnalyticsController{}
 	newsController := &controllers.NewsController{}
+	quoteRequestController := &controllers.QuoteRequestController{}
+	returnRequestController := &controllers.ReturnRequestController{}
+	currencyController := &controllers.CurrencyController{}
+	taxRuleController := &controllers.TaxRuleController{}
+	cartController := &controllers.CartController{}
 
 	// Health check endpoint
 	r.GET("/health", func(c *gin.Context) {
@@ -76,8 +83,15 @@ func SetupRoutes(r *gin.Engine) {
 			// Shipping (public)
 			public.GET("/shipping/countries", shippingRateController.PublicCountries)
 			public.GET("/shipping/quote", shippingRateController.PublicQuote)
+			public.GET("/shipping/options", shippingRateController.PublicOptions)
 			public.GET("/shipping/free-countries", shippingRateController.PublicFreeShippingCountries)
 
+			// Currencies (public)
+			public.GET("/currencies", currencyController.PublicList)
+
+			// Tax / VAT (public)
+			public.GET("/tax/rate", taxRuleController.PublicRate)
+
 			// Product detail endpoints are also cached (same TTL as product list)
 			public.GET("/products/:id", middleware.CachePublicGET(middleware.CacheTTLProducts(), "cache:public:product:"), productController.GetProduct)
 			public.GET("/products/sku", middleware.CachePublicGET(middleware.CacheTTLProducts(), "cache:public:product_sku_query:"), productController.GetProductBySKUQuery) // query param: sku=...
@@ -102,6 +116,14 @@ func SetupRoutes(r *gin.Engine) {
 
 			// PayPal (public config)
 			public.GET("/paypal/config", payPalController.GetPublicConfig)
+			public.POST("/paypal/webhook", payPalController.Webhook) // signature-verified PayPal events
+
+			// Stripe card payments (public config + signed webhook)
+			public.GET("/stripe/config", stripeController.GetPublicConfig)
+			public.POST("/stripe/webhook", stripeController.Webhook)
+
+			// Payment methods enabled for checkout
+			public.GET("/payment-methods", paymentController.GetPaymentMethods)
 
 			// Email (public)
 			public.GET("/email/config", emailController.GetPublicConfig)
@@ -191,8 +213,10 @@ func SetupRoutes(r *gin.Engine) {
 			{
 				shippingRates.GET("", shippingRateController.AdminList)
 				shippingRates.GET("/import/template", shippingRateController.DownloadTemplate)
+				shippingRates.GET("/export/xlsx", shippingRateController.ExportXLSX)
 				shippingRates.POST("/import/xlsx", shippingRateController.ImportXLSX)
 				shippingRates.POST("/bulk-delete", middleware.AdminOnly(), shippingRateController.BulkDelete)
+				shippingRates.PUT("/:id", shippingRateController.AdminUpdate)
 				// Allowed countries whitelist
 				shippingRates.GET("/allowed-countries", shippingRateController.ListAllowedCountries)
 				shippingRates.POST("/allowed-countries", shippingRateController.AddAllowedCountry)
@@ -208,9 +232,18 @@ func SetupRoutes(r *gin.Engine) {
 			orders.Use(middleware.AdminOnly())
 			{
 				orders.GET("", orderController.GetOrders)
+				orders.GET("/export", orderController.ExportOrders)
+				orders.GET("/expiry-settings", orderController.GetExpirySettings)
+				orders.PUT("/expiry-settings", orderController.UpdateExpirySettings)
 				orders.GET("/:id", orderController.GetOrder)
 				orders.PUT("/:id", orderController.UpdateOrder)
 				orders.PUT("/:id/status", orderController.UpdateOrderStatus)
+				orders.POST("/:id/refunds", orderController.RefundOrder)
+				orders.PUT("/:id/items", orderController.UpdateOrderItems)
+				orders.POST("/:id/bank-payments", orderController.RecordBankPayment)
+				orders.GET("/:id/shipments", orderController.GetOrderShipments)
+				orders.POST("/:id/shipments", orderController.CreateShipment)
+				orders.GET("/:id/invoice", orderController.DownloadInvoice)
 				orders.DELETE("/:id", orderController.DeleteOrder)
 			}
 
@@ -290,6 +323,45 @@ func SetupRoutes(r *gin.Engine) {
 				hotlink.PUT("/settings", hotlinkController.UpdateSettings)
 			}
 
+			// Currencies and exchange rates (admin only)
+			currencies := admin.Group("/currencies")
+			currencies.Use(middleware.AdminOnly())
+			{
+				currencies.GET("", currencyController.AdminList)
+				currencies.POST("", currencyController.Create)
+				currencies.POST("/import", currencyController.ImportRates)
+				currencies.PUT("/:id", currencyController.Update)
+				currencies.DELETE("/:id", currencyController.Delete)
+			}
+
+			// Tax rules (admin only)
+			taxRules := admin.Group("/tax-rules")
+			taxRules.Use(middleware.AdminOnly())
+			{
+				taxRules.GET("", taxRuleController.AdminList)
+				taxRules.POST("", taxRuleController.Create)
+				taxRules.PUT("/:id", taxRuleController.Update)
+				taxRules.DELETE("/:id", taxRuleController.Delete)
+			}
+
+			// Shopper carts (admin only, read-only)
+			carts := admin.Group("/carts")
+			carts.Use(middleware.AdminOnly())
+			{
+				carts.GET("", cartController.AdminList)
+			}
+
+			// Abandoned cart recovery (admin only)
+			cartRecovery := admin.Group("/cart-recovery")
+			cartRecovery.Use(middleware.AdminOnly())
+			{
+				cartRecovery.GET("/settings", cartController.GetRecoverySettings)
+				cartRecovery.PUT("/settings", cartController.UpdateRecoverySettings)
+				cartRecovery.POST("/run", cartController.RunRecovery)
+				cartRecovery.GET("/report", cartController.RecoveryReport)
+				cartRecovery.GET("/emails", cartController.RecoveryEmails)
+			}
+
 			// PayPal (admin only)
 			paypal := admin.Group("/paypal")
 			paypal.Use(middleware.AdminOnly())
@@ -298,6 +370,14 @@ func SetupRoutes(r *gin.Engine) {
 				paypal.PUT("/settings", payPalController.UpdateSettings)
 			}
 
+			// Stripe (admin only)
+			stripe := admin.Group("/stripe")
+			stripe.Use(middleware.AdminOnly())
+			{
+				stripe.GET("/settings", stripeController.GetSettings)
+				stripe.PUT("/settings", stripeController.UpdateSettings)
+			}
+
 			// Visitor Analytics (editor/admin for reads, admin-only for cleanup)
 			analytics := admin.Group("/analytics")
 			analytics.Use(middleware.EditorOrAdmin())
@@ -409,11 +489,39 @@ func SetupRoutes(r *gin.Engine) {
 		publicOrders := v1.Group("/orders")
 		publicOrders.Use(middleware.OptionalCustomerAuth()) // Try to authenticate if token present
 		{
-			publicOrders.POST("", orderController.CreateOrder)
-			publicOrders.POST("/:id/payment", orderController.ProcessPayment)
+			// Idempotency-Key header makes retried submissions replay the first response
+			publicOrders.POST("", middleware.Idempotency("orders:create"), orderController.CreateOrder)
+			publicOrders.POST("/:id/payment-intent", middleware.Idempotency("orders:payment-intent"), orderController.CreatePaymentIntent)
+			publicOrders.POST("/:id/payment", middleware.Idempotency("orders:payment"), orderController.ProcessPayment)
 			publicOrders.GET("/track/:orderNumber", orderController.GetOrderByNumber) // Order tracking endpoint
 		}
 
+		// Public request-for-quote endpoints (guests use the emailed access token)
+		publicQuotes := v1.Group("/quotes")
+		publicQuotes.Use(middleware.OptionalCustomerAuth())
+		{
+			publicQuotes.POST("", quoteRequestController.CreateQuoteRequest)
+			publicQuotes.GET("/:number", quoteRequestController.GetQuoteRequest)
+			publicQuotes.POST("/:number/accept", quoteRequestController.AcceptQuoteRequest)
+			publicQuotes.POST("/:number/reject", quoteRequestController.RejectQuoteRequest)
+		}
+
+		// Server-side cart (customers by login, guests by X-Cart-Token)
+		cart := v1.Group("/cart")
+		cart.Use(middleware.OptionalCustomerAuth())
+		{
+			cart.GET("", cartController.GetCart)
+			cart.DELETE("", cartController.ClearCart)
+			cart.POST("/items", cartController.AddItem)
+			cart.PUT("/items/:itemId", cartController.UpdateItem)
+			cart.DELETE("/items/:itemId", cartController.RemoveItem)
+			cart.POST("/acknowledge-prices", cartController.AcknowledgePrices)
+			cart.GET("/shipping-options", cartController.ShippingOptions)
+			cart.POST("/merge", cartController.MergeCart)
+			cart.POST("/checkout-snapshot", cartController.SaveCheckoutSnapshot)
+			cart.POST("/restore", cartController.RestoreCart)
+		}
+
 		// Customer authentication routes (public)
 		customer := v1.Group("/customer")
 		{
@@ -434,15 +542,47 @@ func SetupRoutes(r *gin.Engine) {
 				// Customer orders
 				customerProtected.GET("/orders", orderController.GetMyOrders)
 				customerProtected.GET("/orders/:id", orderController.GetMyOrderDetails)
+				customerProtected.GET("/orders/:id/invoice", orderController.DownloadMyInvoice)
+				customerProtected.GET("/quotes", quoteRequestController.GetMyQuoteRequests)
 
 				// Ticket/Support system
 				customerProtected.POST("/tickets", ticketController.CreateTicket)
 				customerProtected.GET("/tickets", ticketController.GetMyTickets)
 				customerProtected.GET("/tickets/:id", ticketController.GetTicketDetails)
 				customerProtected.POST("/tickets/:id/reply", ticketController.ReplyToTicket)
+
+				// Returns (RMA)
+				customerProtected.POST("/returns", returnRequestController.CreateReturnRequest)
+				customerProtected.GET("/returns", returnRequestController.GetMyReturnRequests)
+				customerProtected.GET("/returns/:id", returnRequestController.GetMyReturnRequest)
+				customerProtected.POST("/returns/:id/cancel", returnRequestController.CancelMyReturnRequest)
+				customerProtected.POST("/returns/:id/photos", returnRequestController.UploadReturnPhotos)
 			}
 		}
 
+		// Admin quote (RFQ) management
+		adminQuotes := admin.Group("/quotes")
+		adminQuotes.Use(middleware.AdminOnly())
+		{
+			adminQuotes.GET("", quoteRequestController.GetQuoteRequests)
+			adminQuotes.GET("/:id", quoteRequestController.GetQuoteRequestAdmin)
+			adminQuotes.POST("/:id/quote", quoteRequestController.PriceQuoteRequest)
+			adminQuotes.PUT("/:id/cancel", quoteRequestController.CancelQuoteRequest)
+		}
+
+		// Admin return (RMA) management
+		adminReturns := admin.Group("/returns")
+		adminReturns.Use(middleware.AdminOnly())
+		{
+			adminReturns.GET("", returnRequestController.GetReturnRequests)
+			adminReturns.GET("/:id", returnRequestController.GetReturnRequestAdmin)
+			adminReturns.POST("/:id/approve", returnRequestController.ApproveReturnRequest)
+			adminReturns.POST("/:id/reject", returnRequestController.RejectReturnRequest)
+			adminReturns.POST("/:id/receive", returnRequestController.ReceiveReturnRequest)
+			adminReturns.POST("/:id/inspect", returnRequestController.InspectReturnRequest)
+			adminReturns.POST("/:id/resolve", returnRequestController.ResolveReturnRequest)
+		}
+
 		// Admin ticket management
 		adminTickets := admin.Group("/tickets")
 		adminTickets.Use(middleware.EditorOrAdmin())
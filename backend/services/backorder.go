@@ -0,0 +1,224 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// DefaultBackorderLeadDays is used when Product.LeadTime cannot be read ("Contact us", empty).
+const DefaultBackorderLeadDays = 14
+
+var leadTimePattern = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)(?:\s*(?:-|–|~|to)\s*(\d+(?:\.\d+)?))?\s*(business days?|working days?|days?|weeks?|wks?|months?)`)
+
+// LeadTimeDays reads a free-text lead time such as "3-7 days", "2-3 weeks" or "1 month" and
+// returns the upper bound in calendar days. Business days count as 7/5 of a day.
+func LeadTimeDays(leadTime string) int {
+	m := leadTimePattern.FindStringSubmatch(leadTime)
+	if m == nil {
+		return DefaultBackorderLeadDays
+	}
+	n, _ := strconv.ParseFloat(m[1], 64)
+	if m[2] != "" {
+		if hi, err := strconv.ParseFloat(m[2], 64); err == nil && hi > n {
+			n = hi
+		}
+	}
+	unit := strings.ToLower(m[3])
+	switch {
+	case strings.HasPrefix(unit, "business"), strings.HasPrefix(unit, "working"):
+		n = n * 7 / 5
+	case strings.HasPrefix(unit, "w"):
+		n *= 7
+	case strings.HasPrefix(unit, "month"):
+		n *= 30
+	}
+	if n <= 0 {
+		return DefaultBackorderLeadDays
+	}
+	return int(math.Ceil(n))
+}
+
+// BackorderShipDate estimates when backordered units of a product ship: the expected arrival
+// date for pre-orders (when still in the future), otherwise today plus the product's lead time.
+func BackorderShipDate(p *models.Product, now time.Time) time.Time {
+	if p.BackorderPolicy == models.BackorderPreorder && p.ExpectedArrivalAt != nil && p.ExpectedArrivalAt.After(now) {
+		return *p.ExpectedArrivalAt
+	}
+	return now.AddDate(0, 0, LeadTimeDays(p.LeadTime))
+}
+
+// ShippableQuantity is what can ship of a line now: the unshipped units that are in stock.
+func ShippableQuantity(item models.OrderItem) int {
+	return max(UnshippedQuantity(item)-item.BackorderedQuantity, 0)
+}
+
+// BackorderFill is a backordered order line that received stock.
+type BackorderFill struct {
+	OrderID     uint
+	OrderItemID uint
+	Quantity    int  // units allocated now
+	Complete    bool // nothing of the line is backordered any more
+}
+
+// AllocateBackorderedStock hands a product's available stock to its backordered order lines,
+// oldest order first. Paid orders have the units deducted from stock; unpaid orders get a
+// stock reservation, as if the units had been in stock when they ordered. Cancelled, delivered
+// and refunded orders are skipped. Call it whenever stock of the product is received.
+func AllocateBackorderedStock(db *gorm.DB, productID uint) ([]BackorderFill, error) {
+	var fills []BackorderFill
+	err := db.Transaction(func(tx *gorm.DB) error {
+		products, err := lockProducts(tx, []uint{productID})
+		if err != nil {
+			return err
+		}
+		p, ok := products[productID]
+		if !ok {
+			return gorm.ErrRecordNotFound
+		}
+		avail := p.AvailableQuantity()
+		if avail <= 0 {
+			return nil
+		}
+
+		type row struct {
+			models.OrderItem
+			PaymentStatus string
+		}
+		var rows []row
+		if err := tx.Table("order_items").
+			Select("order_items.*, orders.payment_status").
+			Joins("JOIN orders ON orders.id = order_items.order_id").
+			Where("order_items.product_id = ? AND order_items.backordered_quantity > 0", productID).
+			Where("orders.status NOT IN ?", []string{models.OrderStatusCancelled, models.OrderStatusDelivered}).
+			Where("orders.payment_status NOT IN ?", []string{models.PaymentStatusRefunded, models.PaymentStatusReversed}).
+			Order("orders.created_at ASC, order_items.id ASC").
+			Scan(&rows).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		deducted, reserved := 0, 0
+		for _, r := range rows {
+			if avail <= 0 {
+				break
+			}
+			take := min(avail, r.BackorderedQuantity)
+			avail -= take
+			if IsPaymentCaptured(r.PaymentStatus) {
+				deducted += take
+			} else {
+				reserved += take
+				if err := tx.Create(&models.StockReservation{
+					OrderID:     r.OrderID,
+					OrderItemID: r.ID,
+					ProductID:   productID,
+					Quantity:    take,
+					Status:      models.StockReservationActive,
+				}).Error; err != nil {
+					return err
+				}
+			}
+
+			fill := BackorderFill{OrderID: r.OrderID, OrderItemID: r.ID, Quantity: take, Complete: take == r.BackorderedQuantity}
+			updates := map[string]interface{}{"backordered_quantity": gorm.Expr("backordered_quantity - ?", take)}
+			if fill.Complete {
+				updates["backorder_filled_at"] = &now
+			}
+			if err := tx.Model(&models.OrderItem{}).Where("id = ?", r.ID).UpdateColumns(updates).Error; err != nil {
+				return err
+			}
+			note := fmt.Sprintf("%s x%d allocated from received stock", r.DisplaySKU(), take)
+			if left := r.BackorderedQuantity - take; left > 0 {
+				note += fmt.Sprintf(", %d still backordered", left)
+			}
+			if err := RecordOrderHistory(tx, r.OrderID, "event", "", "backorder_allocated", ActorSystem, note); err != nil {
+				return err
+			}
+			fills = append(fills, fill)
+		}
+
+		if deducted > 0 || reserved > 0 {
+			return tx.Model(&models.Product{}).Where("id = ?", productID).UpdateColumns(map[string]interface{}{
+				"stock_quantity":    gorm.Expr("stock_quantity - ?", deducted),
+				"reserved_quantity": gorm.Expr("reserved_quantity + ?", reserved),
+			}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fills, nil
+}
+
+// BuildBackorderFilledEmail tells the customer that backordered items of their order are in stock.
+func BuildBackorderFilledEmail(siteURL string, order models.Order, items []models.OrderItem) (subject, text, html string) {
+	orderNo := orderDisplayNumber(order)
+	link := orderTrackURL(siteURL, order)
+	subject = fmt.Sprintf("Back in stock: items for order %s", orderNo)
+
+	body := fmt.Sprintf("Hello %s,\n\nGood news: the backordered items of order %s have arrived in our warehouse and will ship with the next dispatch.\n\n",
+		fallbackStr(order.CustomerName, "there"), orderNo)
+	body += emailItemsText(items, nil) + "\n"
+	if !IsPaymentCaptured(order.PaymentStatus) {
+		body += "\nThe order is not paid yet. We hold the items for you, please complete the payment so we can ship them.\n"
+	}
+	body += optionalLine("View your order", link)
+	text = customerEmailText(body)
+
+	inner := fmt.Sprintf("<p style=\"margin:0 0 10px 0\">Hello %s,</p>", escapeHTML(fallbackStr(order.CustomerName, "there"))) +
+		fmt.Sprintf("<p style=\"margin:0 0 10px 0\">Good news: the backordered items of your order <b>%s</b> have arrived in our warehouse and will ship with the next dispatch.</p>", escapeHTML(orderNo))
+	if !IsPaymentCaptured(order.PaymentStatus) {
+		inner += "<p style=\"margin:0 0 10px 0\">The order is not paid yet. We hold the items for you, please complete the payment so we can ship them.</p>"
+	}
+	inner += emailItemsTableHTML("Back in stock", items, nil) + emailButtonHTML("View order", link)
+	html = customerEmailHTML("Backordered items in stock", inner)
+	return subject, text, html
+}
+
+// NotifyBackorderFilled emails each customer whose backordered lines were completely filled
+// (best-effort; failures are logged). Shipping notifications must be enabled.
+func NotifyBackorderFilled(db *gorm.DB, siteURL string, fills []BackorderFill) {
+	byOrder := map[uint][]uint{}
+	var orderIDs []uint
+	for _, f := range fills {
+		if !f.Complete {
+			continue
+		}
+		if _, ok := byOrder[f.OrderID]; !ok {
+			orderIDs = append(orderIDs, f.OrderID)
+		}
+		byOrder[f.OrderID] = append(byOrder[f.OrderID], f.OrderItemID)
+	}
+	if len(orderIDs) == 0 {
+		return
+	}
+	setting, err := GetOrCreateEmailSetting(db)
+	if err != nil || !setting.Enabled || !setting.ShippingNotificationsEnabled {
+		return
+	}
+	for _, id := range orderIDs {
+		var order models.Order
+		if err := db.First(&order, id).Error; err != nil || order.CustomerEmail == "" {
+			continue
+		}
+		var items []models.OrderItem
+		if err := db.Preload("Product").Where("id IN ?", byOrder[id]).Order("id ASC").Find(&items).Error; err != nil {
+			continue
+		}
+		subj, txt, html := BuildBackorderFilledEmail(siteURL, order, items)
+		ref := fmt.Sprintf("backorder:%s:%d", order.OrderNumber, items[len(items)-1].ID)
+		if err := SendEmail(db, EmailSendOptions{To: order.CustomerEmail, Subject: subj, Text: txt, HTML: html, Headers: map[string]string{"X-Entity-Ref-ID": ref}}); err != nil {
+			log.Printf("backorder email for order %s: %v", order.OrderNumber, err)
+		}
+	}
+}
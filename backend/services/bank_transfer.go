@@ -0,0 +1,260 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PaymentMethodBankTransfer is the offline T/T wire payment method.
+const PaymentMethodBankTransfer = "bank_transfer"
+
+// BankTransferError is returned for bank transfer requests that cannot be applied to the order.
+type BankTransferError struct {
+	Message string
+}
+
+func (e *BankTransferError) Error() string { return e.Message }
+
+// BankTransferReference returns the payment reference for an order: "TT" + zero-padded order
+// ID + two ISO 7064 mod 97-10 check digits, so typos in the wire reference are detectable.
+func BankTransferReference(orderID uint) string {
+	base := fmt.Sprintf("%08d", orderID)
+	rem := 0
+	for _, ch := range base + "00" {
+		rem = (rem*10 + int(ch-'0')) % 97
+	}
+	return fmt.Sprintf("TT%s%02d", base, 98-rem)
+}
+
+// BankTransferInstructions is what the customer needs to send the wire.
+type BankTransferInstructions struct {
+	Beneficiary string  `json:"beneficiary"`
+	BankDetails string  `json:"bank_details"`
+	Reference   string  `json:"reference"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+	AmountPaid  float64 `json:"amount_paid"`
+	Balance     float64 `json:"balance"`
+	Notes       string  `json:"notes,omitempty"`
+}
+
+// GetBankTransferInstructions builds the instructions from the company profile. The amount is
+// the outstanding balance once part of the transfer has been received.
+func GetBankTransferInstructions(db *gorm.DB, order *models.Order) (*BankTransferInstructions, error) {
+	company := LoadInvoiceCompanyProfile(db)
+	if strings.TrimSpace(company.BankDetails) == "" {
+		return nil, &BankTransferError{Message: "Bank transfer is not available, please choose another payment method"}
+	}
+	paid, _, err := OrderPaymentTotals(db, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	ref := order.PaymentReference
+	if ref == "" {
+		ref = BankTransferReference(order.ID)
+	}
+	return &BankTransferInstructions{
+		Beneficiary: fallbackStr(company.LegalName, company.CompanyName),
+		BankDetails: strings.TrimSpace(company.BankDetails),
+		Reference:   ref,
+		Amount:      round2(order.TotalAmount),
+		Currency:    fallbackStr(order.Currency, BaseCurrency),
+		AmountPaid:  paid,
+		Balance:     round2(max(order.TotalAmount-paid, 0)),
+		Notes:       fmt.Sprintf("Please quote %s as the payment reference. Bank charges are payable by the sender.", ref),
+	}, nil
+}
+
+// StartBankTransfer switches an unpaid order to bank transfer: it gets a payment reference and
+// waits in awaiting_payment (not auto-cancelled) until an admin records the money.
+func StartBankTransfer(db *gorm.DB, orderID uint, actor StatusActor) (*models.Order, *BankTransferInstructions, error) {
+	var order models.Order
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+			return err
+		}
+		if order.Status == models.OrderStatusCancelled {
+			return &BankTransferError{Message: "Order has been cancelled"}
+		}
+		switch order.PaymentStatus {
+		case models.PaymentStatusAwaitingPayment, models.PaymentStatusPartiallyPaid:
+			if order.PaymentMethod == PaymentMethodBankTransfer {
+				return nil // already waiting for the transfer: just repeat the instructions
+			}
+		case models.PaymentStatusPending, models.PaymentStatusFailed, "":
+		default:
+			return &BankTransferError{Message: "Order is already paid"}
+		}
+		if _, err := GetBankTransferInstructions(tx, &order); err != nil {
+			return err
+		}
+
+		order.PaymentMethod = PaymentMethodBankTransfer
+		order.PaymentReference = BankTransferReference(order.ID)
+		if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Updates(map[string]interface{}{
+			"payment_method":    order.PaymentMethod,
+			"payment_reference": order.PaymentReference,
+		}).Error; err != nil {
+			return err
+		}
+		return TransitionPaymentStatus(tx, &order, models.PaymentStatusAwaitingPayment, actor,
+			"bank transfer selected, reference "+order.PaymentReference)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	instr, err := GetBankTransferInstructions(db, &order)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &order, instr, nil
+}
+
+// BankPaymentInput is a wire received on the company account, entered by an admin.
+type BankPaymentInput struct {
+	Amount        float64
+	BankReference string // the bank's transaction reference; must be unique
+	PayerName     string
+	ReceivedAt    *time.Time
+	Note          string
+}
+
+// RecordBankPayment books a received transfer as a completed PaymentTransaction and moves the
+// order to partially_paid, or to paid (deducting stock and confirming it) once the received
+// total covers the order total.
+func RecordBankPayment(db *gorm.DB, orderID uint, in BankPaymentInput, actor StatusActor) (*models.PaymentTransaction, *models.Order, error) {
+	if in.Amount <= 0 {
+		return nil, nil, &BankTransferError{Message: "Amount must be greater than zero"}
+	}
+	receivedAt := time.Now()
+	if in.ReceivedAt != nil {
+		receivedAt = *in.ReceivedAt
+	}
+
+	var (
+		order       models.Order
+		transaction models.PaymentTransaction
+	)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+			return err
+		}
+		if order.Status == models.OrderStatusCancelled {
+			return &BankTransferError{Message: "Order has been cancelled; refund the transfer instead"}
+		}
+		switch order.PaymentStatus {
+		case models.PaymentStatusPending, models.PaymentStatusFailed, models.PaymentStatusAwaitingPayment, models.PaymentStatusPartiallyPaid, "":
+		default:
+			return &BankTransferError{Message: "Order is already paid"}
+		}
+
+		paid, _, err := OrderPaymentTotals(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		if balance := round2(order.TotalAmount - paid); in.Amount > balance+priceTolerance {
+			return &BankTransferError{Message: fmt.Sprintf("Amount exceeds the outstanding balance of %.2f %s", balance, fallbackStr(order.Currency, BaseCurrency))}
+		}
+
+		if order.PaymentStatus != models.PaymentStatusAwaitingPayment && order.PaymentStatus != models.PaymentStatusPartiallyPaid {
+			// Customer wired without choosing bank transfer at checkout.
+			order.PaymentMethod = PaymentMethodBankTransfer
+			order.PaymentReference = fallbackStr(order.PaymentReference, BankTransferReference(order.ID))
+			if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Updates(map[string]interface{}{
+				"payment_method":    order.PaymentMethod,
+				"payment_reference": order.PaymentReference,
+			}).Error; err != nil {
+				return err
+			}
+			if err := TransitionPaymentStatus(tx, &order, models.PaymentStatusAwaitingPayment, actor, "bank transfer received"); err != nil {
+				return err
+			}
+		}
+
+		ref := strings.TrimSpace(in.BankReference)
+		if ref == "" {
+			ref = fmt.Sprintf("%s-%d", fallbackStr(order.PaymentReference, BankTransferReference(order.ID)), time.Now().UnixNano())
+		}
+		var dup int64
+		tx.Model(&models.PaymentTransaction{}).Where("transaction_id = ?", ref).Count(&dup)
+		if dup > 0 {
+			return &BankTransferError{Message: "A payment with this bank reference has already been recorded"}
+		}
+		data, _ := json.Marshal(map[string]interface{}{
+			"payment_reference": order.PaymentReference,
+			"received_at":       receivedAt.UTC().Format(time.RFC3339),
+			"note":              strings.TrimSpace(in.Note),
+		})
+		transaction = models.PaymentTransaction{
+			OrderID:       order.ID,
+			TransactionID: ref,
+			PaymentMethod: PaymentMethodBankTransfer,
+			Amount:        round2(in.Amount),
+			Currency:      fallbackStr(order.Currency, BaseCurrency),
+			Status:        "completed",
+			PayerID:       strings.TrimSpace(in.PayerName),
+			PaymentData:   string(data),
+		}
+		if err := tx.Create(&transaction).Error; err != nil {
+			return err
+		}
+
+		note := fmt.Sprintf("bank transfer %s: %.2f %s received", ref, transaction.Amount, transaction.Currency)
+		if paid+transaction.Amount >= order.TotalAmount-priceTolerance {
+			order.PaymentID = ref
+			return MarkOrderPaid(tx, &order, actor, note)
+		}
+		if order.PaymentStatus == models.PaymentStatusPartiallyPaid {
+			return RecordOrderHistory(tx, order.ID, "event", order.PaymentStatus, "bank_payment_received", actor, note)
+		}
+		return TransitionPaymentStatus(tx, &order, models.PaymentStatusPartiallyPaid, actor, note)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &transaction, &order, nil
+}
+
+// BuildBankTransferEmail sends the customer the wire instructions for their order.
+func BuildBankTransferEmail(siteURL string, order models.Order, instr BankTransferInstructions) (subject, text, html string) {
+	orderNo := orderDisplayNumber(order)
+	amount := fmt.Sprintf("%.2f %s", instr.Balance, instr.Currency)
+	link := orderTrackURL(siteURL, order)
+
+	subject = fmt.Sprintf("Bank transfer details for order %s", orderNo)
+
+	body := fmt.Sprintf("Thank you for your order %s. Please transfer %s to the account below.\n", orderNo, amount)
+	body += fmt.Sprintf("\nBeneficiary: %s\n%s\n", instr.Beneficiary, instr.BankDetails)
+	body += fmt.Sprintf("\nPayment reference: %s\n", instr.Reference)
+	body += "\n" + instr.Notes + "\nWe will confirm your order as soon as the payment arrives.\n"
+	body += optionalLine("View your order", link)
+	text = customerEmailText(body)
+
+	bankHTML := strings.ReplaceAll(escapeHTML(instr.BankDetails), "\n", "<br>")
+	inner := fmt.Sprintf("<p style=\"margin:0 0 10px 0\">Thank you for your order <b>%s</b>. Please transfer the amount below to our bank account.</p>", escapeHTML(orderNo)) +
+		emailRowsHTML(
+			emailRowHTML("Amount", amount),
+			emailRowHTML("Payment reference", instr.Reference),
+			emailRowHTML("Beneficiary", instr.Beneficiary),
+		) +
+		"<div style=\"margin-top:10px;padding:12px;border:1px solid #e5e7eb;border-radius:10px;font-family:ui-monospace,SFMono-Regular,Menlo,Monaco,Consolas,monospace;font-size:12px\">" + bankHTML + "</div>" +
+		fmt.Sprintf("<p style=\"margin:14px 0 0 0;font-size:13px;color:#374151\">%s We will confirm your order as soon as the payment arrives.</p>", escapeHTML(instr.Notes)) +
+		emailButtonHTML("View order", link)
+	html = customerEmailHTML("Bank transfer instructions", inner)
+	return subject, text, html
+}
+
+// IsBankTransferPending reports whether the order is waiting for (the rest of) a bank transfer.
+func IsBankTransferPending(order *models.Order) bool {
+	if order == nil || order.PaymentMethod != PaymentMethodBankTransfer {
+		return false
+	}
+	return order.PaymentStatus == models.PaymentStatusAwaitingPayment || order.PaymentStatus == models.PaymentStatusPartiallyPaid
+}
@@ -0,0 +1,420 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CartError is returned for cart operations the shopper can fix (unknown product, bad quantity).
+type CartError struct {
+	Message string
+}
+
+func (e *CartError) Error() string { return e.Message }
+
+const (
+	maxCartLines    = 100
+	maxCartQuantity = 9999
+)
+
+func newCartToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func loadCart(db *gorm.DB, cart *models.Cart) error {
+	return db.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Items.Product").First(cart, cart.ID).Error
+}
+
+// FindCart returns the active cart of a customer, or of a guest token. It returns
+// gorm.ErrRecordNotFound when there is none.
+func FindCart(db *gorm.DB, customerID *uint, token string) (*models.Cart, error) {
+	var cart models.Cart
+	q := db.Where("status = ?", models.CartStatusActive)
+	switch {
+	case customerID != nil:
+		q = q.Where("customer_id = ?", *customerID).Order("last_activity_at DESC")
+	case token != "":
+		q = q.Where("token = ? AND customer_id IS NULL", token)
+	default:
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := q.First(&cart).Error; err != nil {
+		return nil, err
+	}
+	if err := loadCart(db, &cart); err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// GetOrCreateCart returns the shopper's active cart, creating an empty one when needed.
+func GetOrCreateCart(db *gorm.DB, customerID *uint, token string) (*models.Cart, error) {
+	cart, err := FindCart(db, customerID, token)
+	if err == nil {
+		return cart, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	newToken, err := newCartToken()
+	if err != nil {
+		return nil, err
+	}
+	cart = &models.Cart{Token: newToken, CustomerID: customerID, Status: models.CartStatusActive, Currency: BaseCurrency, LastActivityAt: time.Now()}
+	if err := db.Create(cart).Error; err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+func touchCart(db *gorm.DB, cart *models.Cart) error {
+	cart.LastActivityAt = time.Now()
+	return db.Model(&models.Cart{}).Where("id = ?", cart.ID).Update("last_activity_at", cart.LastActivityAt).Error
+}
+
+// orderableProduct checks that a product can be put in a cart and returns its base price.
+func orderableProduct(db *gorm.DB, productID uint) (*models.Product, float64, error) {
+	var p models.Product
+	if err := db.First(&p, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, &CartError{Message: fmt.Sprintf("Product with ID %d not found", productID)}
+		}
+		return nil, 0, err
+	}
+	if !p.IsActive {
+		return nil, 0, &CartError{Message: fmt.Sprintf("Product %s is no longer available", p.Name)}
+	}
+	price, err := ProductUnitPrice(&p)
+	if err != nil {
+		return nil, 0, &CartError{Message: fmt.Sprintf("Product %s is not available for online purchase, please request a quote", p.Name)}
+	}
+	return &p, price, nil
+}
+
+// AddCartItem adds quantity of a product, increasing the line if it is already in the cart.
+// Stock is checked but not reserved; reservation happens when the order is placed.
+func AddCartItem(db *gorm.DB, cart *models.Cart, productID uint, quantity int) error {
+	if quantity <= 0 || quantity > maxCartQuantity {
+		return &CartError{Message: "Invalid quantity"}
+	}
+	p, price, err := orderableProduct(db, productID)
+	if err != nil {
+		return err
+	}
+
+	var item models.CartItem
+	err = db.Where("cart_id = ? AND product_id = ?", cart.ID, productID).First(&item).Error
+	switch {
+	case err == nil:
+		quantity += item.Quantity
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		var lines int64
+		db.Model(&models.CartItem{}).Where("cart_id = ?", cart.ID).Count(&lines)
+		if lines >= maxCartLines {
+			return &CartError{Message: fmt.Sprintf("A cart can hold at most %d different products", maxCartLines)}
+		}
+		item = models.CartItem{CartID: cart.ID, ProductID: productID, PriceAtAdd: price}
+	default:
+		return err
+	}
+	if quantity > maxCartQuantity {
+		return &CartError{Message: "Invalid quantity"}
+	}
+	if p.AvailableQuantity() < quantity && !p.AllowsBackorder() {
+		return &CartError{Message: fmt.Sprintf("Only %d of %s in stock", max(p.AvailableQuantity(), 0), p.Name)}
+	}
+
+	item.Quantity = quantity
+	if item.ID == 0 {
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "cart_id"}, {Name: "product_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"quantity", "updated_at"}),
+		}).Create(&item).Error; err != nil {
+			return err
+		}
+	} else if err := db.Model(&item).Update("quantity", quantity).Error; err != nil {
+		return err
+	}
+	if err := touchCart(db, cart); err != nil {
+		return err
+	}
+	return loadCart(db, cart)
+}
+
+// UpdateCartItem sets a line's quantity; zero removes it.
+func UpdateCartItem(db *gorm.DB, cart *models.Cart, itemID uint, quantity int) error {
+	if quantity < 0 || quantity > maxCartQuantity {
+		return &CartError{Message: "Invalid quantity"}
+	}
+	var item models.CartItem
+	if err := db.Where("id = ? AND cart_id = ?", itemID, cart.ID).First(&item).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &CartError{Message: "Cart item not found"}
+		}
+		return err
+	}
+	if quantity == 0 {
+		if err := db.Delete(&item).Error; err != nil {
+			return err
+		}
+	} else {
+		p, _, err := orderableProduct(db, item.ProductID)
+		if err != nil {
+			return err
+		}
+		if p.AvailableQuantity() < quantity && !p.AllowsBackorder() {
+			return &CartError{Message: fmt.Sprintf("Only %d of %s in stock", max(p.AvailableQuantity(), 0), p.Name)}
+		}
+		if err := db.Model(&item).Update("quantity", quantity).Error; err != nil {
+			return err
+		}
+	}
+	if err := touchCart(db, cart); err != nil {
+		return err
+	}
+	return loadCart(db, cart)
+}
+
+// ClearCart removes every line.
+func ClearCart(db *gorm.DB, cart *models.Cart) error {
+	if err := db.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{}).Error; err != nil {
+		return err
+	}
+	cart.Items = nil
+	return touchCart(db, cart)
+}
+
+// MergeGuestCart folds a guest cart into the customer's cart (creating it if needed).
+// Quantities of products in both carts are added up, capped at available stock.
+func MergeGuestCart(db *gorm.DB, customerID uint, token string) (*models.Cart, error) {
+	var merged *models.Cart
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var guest models.Cart
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token = ? AND status = ?", token, models.CartStatusActive).First(&guest).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if guest.CustomerID != nil {
+			// Already a customer cart: nothing to merge (the customer id must match).
+			if *guest.CustomerID != customerID {
+				return &CartError{Message: "Cart belongs to another account"}
+			}
+			return nil
+		}
+
+		cart, err := GetOrCreateCart(tx, &customerID, "")
+		if err != nil {
+			return err
+		}
+		var guestItems []models.CartItem
+		if err := tx.Where("cart_id = ?", guest.ID).Find(&guestItems).Error; err != nil {
+			return err
+		}
+		existing := map[uint]models.CartItem{}
+		for _, it := range cart.Items {
+			existing[it.ProductID] = it
+		}
+		for _, gi := range guestItems {
+			var p models.Product
+			if err := tx.First(&p, gi.ProductID).Error; err != nil || !p.IsActive {
+				continue
+			}
+			qty := gi.Quantity
+			if cur, ok := existing[gi.ProductID]; ok {
+				qty += cur.Quantity
+			}
+			if avail := p.AvailableQuantity(); qty > avail && !p.AllowsBackorder() {
+				if avail <= 0 {
+					// Out of stock and not backorderable: leave the guest line behind.
+					continue
+				}
+				qty = avail
+			}
+			qty = min(qty, maxCartQuantity)
+			if cur, ok := existing[gi.ProductID]; ok {
+				if err := tx.Model(&models.CartItem{}).Where("id = ?", cur.ID).Update("quantity", qty).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if err := tx.Create(&models.CartItem{CartID: cart.ID, ProductID: gi.ProductID, Quantity: qty, PriceAtAdd: gi.PriceAtAdd}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.Cart{}).Where("id = ?", guest.ID).Update("status", models.CartStatusMerged).Error; err != nil {
+			return err
+		}
+		if err := touchCart(tx, cart); err != nil {
+			return err
+		}
+		merged = cart
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if merged == nil {
+		return FindCart(db, &customerID, "")
+	}
+	return merged, loadCart(db, merged)
+}
+
+// CartLineView is a cart line re-priced against the current catalog.
+type CartLineView struct {
+	ItemID       uint     `json:"item_id"`
+	ProductID    uint     `json:"product_id"`
+	SKU          string   `json:"sku"`
+	Name         string   `json:"name"`
+	Quantity     int      `json:"quantity"`
+	UnitPrice    float64  `json:"unit_price"`
+	TotalPrice   float64  `json:"total_price"`
+	Available    int      `json:"available_quantity"`
+	PriceChanged bool     `json:"price_changed"`
+	PreviousUnit *float64 `json:"previous_unit_price,omitempty"`
+	Issue        string   `json:"issue,omitempty"` // unavailable, no_price, insufficient_stock
+
+	// Units beyond available stock of a product that takes backorders, and when they would ship.
+	Backordered      int        `json:"backordered,omitempty"`
+	ExpectedShipDate *time.Time `json:"expected_ship_date,omitempty"`
+}
+
+// CartView is what the storefront renders: every line priced now, in Currency.
+type CartView struct {
+	ID             uint           `json:"id"`
+	Token          string         `json:"token"`
+	CustomerID     *uint          `json:"customer_id"`
+	Currency       string         `json:"currency"`
+	Items          []CartLineView `json:"items"`
+	ItemCount      int            `json:"item_count"`
+	Subtotal       float64        `json:"subtotal"`
+	BaseSubtotal   float64        `json:"base_subtotal"`
+	WeightKg       float64        `json:"weight_kg"`
+	VolumeCm3      float64        `json:"volume_cm3"` // packed volume, for volumetric shipping weight
+	CanCheckout    bool           `json:"can_checkout"`
+	LastActivityAt time.Time      `json:"last_activity_at"`
+}
+
+// PriceCart re-prices a loaded cart (Items.Product preloaded) in cur (nil = base currency).
+// Lines that cannot be bought are flagged but kept so the shopper can see and remove them.
+func PriceCart(cart *models.Cart, cur *models.Currency) CartView {
+	c := BaseCurrencyRecord()
+	if cur != nil {
+		c = *cur
+	}
+	view := CartView{
+		ID: cart.ID, Token: cart.Token, CustomerID: cart.CustomerID, Currency: c.Code,
+		Items: []CartLineView{}, CanCheckout: len(cart.Items) > 0, LastActivityAt: cart.LastActivityAt,
+	}
+	var parcel ShippingParcel
+	for _, it := range cart.Items {
+		line := CartLineView{ItemID: it.ID, ProductID: it.ProductID, Quantity: it.Quantity}
+		p := it.Product
+		if p == nil || !p.IsActive {
+			line.Issue = "unavailable"
+			view.CanCheckout = false
+			view.Items = append(view.Items, line)
+			continue
+		}
+		line.SKU, line.Name, line.Available = p.SKU, p.Name, p.AvailableQuantity()
+		base, err := ProductUnitPrice(p)
+		if err != nil {
+			line.Issue = "no_price"
+			view.CanCheckout = false
+			view.Items = append(view.Items, line)
+			continue
+		}
+		if line.Available < it.Quantity {
+			if p.AllowsBackorder() {
+				line.Backordered = it.Quantity - max(line.Available, 0)
+				shipDate := BackorderShipDate(p, time.Now())
+				line.ExpectedShipDate = &shipDate
+			} else {
+				line.Issue = "insufficient_stock"
+				view.CanCheckout = false
+			}
+		}
+		line.UnitPrice = ConvertFromBase(base, &c)
+		line.TotalPrice = roundCurrency(line.UnitPrice*float64(it.Quantity), c.Decimals)
+		if it.PriceAtAdd > 0 && math.Abs(it.PriceAtAdd-base) > priceTolerance {
+			prev := ConvertFromBase(it.PriceAtAdd, &c)
+			line.PriceChanged, line.PreviousUnit = true, &prev
+		}
+		view.Subtotal += line.TotalPrice
+		view.BaseSubtotal += base * float64(it.Quantity)
+		view.ItemCount += it.Quantity
+		parcel.Add(p, it.Quantity)
+		view.Items = append(view.Items, line)
+	}
+	view.WeightKg, view.VolumeCm3 = parcel.WeightKg, parcel.VolumeCm3
+	view.Subtotal = roundCurrency(view.Subtotal, c.Decimals)
+	view.BaseSubtotal = round2(view.BaseSubtotal)
+	return view
+}
+
+// AcknowledgeCartPrices records the current prices as seen, clearing price_changed flags.
+func AcknowledgeCartPrices(db *gorm.DB, cart *models.Cart) error {
+	for i, it := range cart.Items {
+		if it.Product == nil {
+			continue
+		}
+		base, err := ProductUnitPrice(it.Product)
+		if err != nil || math.Abs(it.PriceAtAdd-base) <= priceTolerance {
+			continue
+		}
+		if err := db.Model(&models.CartItem{}).Where("id = ?", it.ID).Update("price_at_add", base).Error; err != nil {
+			return err
+		}
+		cart.Items[i].PriceAtAdd = base
+	}
+	return nil
+}
+
+// CartOrderLines turns a cart into order lines for PriceOrderLines. The last price the
+// shopper acknowledged (PriceAtAdd, converted into cur) is passed as the client price, so a
+// price change that was not acknowledged comes back as a mismatch instead of being charged.
+func CartOrderLines(cart *models.Cart, cur *models.Currency) ([]OrderLineInput, error) {
+	if len(cart.Items) == 0 {
+		return nil, &CartError{Message: "Cart is empty"}
+	}
+	lines := make([]OrderLineInput, 0, len(cart.Items))
+	for _, it := range cart.Items {
+		line := OrderLineInput{ProductID: it.ProductID, Quantity: it.Quantity}
+		if it.PriceAtAdd > 0 {
+			seen := ConvertFromBase(it.PriceAtAdd, cur)
+			line.ClientUnitPrice = &seen
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// MarkCartConverted closes a cart once its order has been placed.
+func MarkCartConverted(tx *gorm.DB, cartID, orderID uint) error {
+	res := tx.Model(&models.Cart{}).Where("id = ? AND status = ?", cartID, models.CartStatusActive).
+		Updates(map[string]interface{}{"status": models.CartStatusConverted, "order_id": orderID})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return &CartError{Message: "Cart has already been checked out"}
+	}
+	return nil
+}
@@ -0,0 +1,595 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"fanuc-backend/config"
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// GetOrCreateCartRecoverySetting returns the single-row settings (ID=1), creating defaults if needed.
+func GetOrCreateCartRecoverySetting(db *gorm.DB) (*models.CartRecoverySetting, error) {
+	var s models.CartRecoverySetting
+	err := db.First(&s, 1).Error
+	if err == nil {
+		return &s, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	s = models.CartRecoverySetting{
+		ID:                  1,
+		Enabled:             false,
+		AbandonedAfterHours: 4,
+		MaxAgeHours:         72,
+		IncludeUnpaidOrders: true,
+		CouponType:          "percentage",
+		CouponValue:         5,
+		CouponValidDays:     7,
+		AttributionDays:     7,
+	}
+	if e := db.Create(&s).Error; e != nil {
+		return nil, e
+	}
+	return &s, nil
+}
+
+// CheckoutSnapshotInput is what the storefront knows when the shopper reaches checkout.
+type CheckoutSnapshotInput struct {
+	Email        string
+	Name         string
+	CustomerID   *uint
+	CartID       *uint
+	Currency     string
+	EmailConsent bool
+	Lines        []OrderLineInput
+}
+
+// SaveCheckoutSnapshot creates or refreshes the open snapshot for the shopper's email and
+// customer account (or guest cart), so one shopper cannot overwrite another's snapshot.
+// Products that cannot be bought online are left out; nothing is reserved.
+func SaveCheckoutSnapshot(db *gorm.DB, in CheckoutSnapshotInput) (*models.CheckoutSnapshot, error) {
+	email := strings.ToLower(strings.TrimSpace(in.Email))
+	if email == "" {
+		return nil, &CartError{Message: "Email is required"}
+	}
+	if in.CustomerID == nil && in.CartID == nil {
+		return nil, &CartError{Message: "Cart not found"}
+	}
+
+	items := make([]models.CheckoutSnapshotItem, 0, len(in.Lines))
+	subtotal := 0.0
+	for _, l := range in.Lines {
+		if l.Quantity <= 0 {
+			continue
+		}
+		p, price, err := orderableProduct(db, l.ProductID)
+		if err != nil {
+			var cartErr *CartError
+			if errors.As(err, &cartErr) {
+				continue
+			}
+			return nil, err
+		}
+		items = append(items, models.CheckoutSnapshotItem{
+			ProductID: p.ID, ProductSKU: p.SKU, ProductName: p.Name, Quantity: l.Quantity, UnitPrice: price,
+		})
+		subtotal += price * float64(l.Quantity)
+	}
+	if len(items) == 0 {
+		return nil, &CartError{Message: "Cart is empty"}
+	}
+
+	var snap models.CheckoutSnapshot
+	err := db.Transaction(func(tx *gorm.DB) error {
+		q := tx.Where("email = ? AND status = ?", email, models.CheckoutSnapshotOpen)
+		if in.CustomerID != nil {
+			q = q.Where("customer_id = ?", *in.CustomerID)
+		} else {
+			q = q.Where("cart_id = ? AND customer_id IS NULL", *in.CartID)
+		}
+		err := q.Order("id DESC").First(&snap).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		snap.Email = email
+		snap.Name = strings.TrimSpace(in.Name)
+		snap.CustomerID = in.CustomerID
+		snap.CartID = in.CartID
+		snap.Currency = fallbackStr(NormalizeCurrencyCode(in.Currency), BaseCurrency)
+		snap.Subtotal = round2(subtotal)
+		snap.Status = models.CheckoutSnapshotOpen
+		snap.EmailConsent = in.EmailConsent
+		snap.LastSeenAt = time.Now()
+		if err := tx.Save(&snap).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("snapshot_id = ?", snap.ID).Delete(&models.CheckoutSnapshotItem{}).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].SnapshotID = snap.ID
+		}
+		snap.Items = items
+		return tx.Create(&snap.Items).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// CartRecoveryResult summarizes one recovery pass.
+type CartRecoveryResult struct {
+	SnapshotEmails int `json:"snapshot_emails"`
+	OrderEmails    int `json:"order_emails"`
+	Recovered      int `json:"recovered"` // source orders paid since their email
+}
+
+// RunCartRecovery emails idle checkouts and unpaid orders of consenting shoppers, and
+// records source orders that were paid after their recovery email.
+func RunCartRecovery(db *gorm.DB, s *models.CartRecoverySetting, siteURL string) (*CartRecoveryResult, error) {
+	if db == nil || s == nil {
+		return nil, errors.New("invalid arguments")
+	}
+	out := &CartRecoveryResult{}
+	if s.AbandonedAfterHours <= 0 {
+		return out, nil
+	}
+	now := time.Now()
+	idleBefore := now.Add(-time.Duration(s.AbandonedAfterHours) * time.Hour)
+	maxAge := time.Duration(s.MaxAgeHours) * time.Hour
+	if maxAge <= 0 {
+		maxAge = 30 * 24 * time.Hour
+	}
+	notBefore := now.Add(-maxAge)
+
+	var snaps []models.CheckoutSnapshot
+	if err := db.Where("status = ? AND email_consent = ? AND recovery_email_sent_at IS NULL", models.CheckoutSnapshotOpen, true).
+		Where("last_seen_at < ? AND last_seen_at >= ?", idleBefore, notBefore).
+		// Several carts can share an email: follow up only the latest checkout.
+		Where("NOT EXISTS (SELECT 1 FROM checkout_snapshots s2 WHERE s2.email = checkout_snapshots.email AND s2.status = ? AND s2.id > checkout_snapshots.id)", models.CheckoutSnapshotOpen).
+		Preload("Items").Order("id ASC").Find(&snaps).Error; err != nil {
+		return out, err
+	}
+	for i := range snaps {
+		if err := sendSnapshotRecovery(db, s, siteURL, &snaps[i]); err != nil {
+			log.Printf("cart recovery: email for checkout snapshot %d failed: %v", snaps[i].ID, err)
+			continue
+		}
+		out.SnapshotEmails++
+	}
+
+	// Unpaid orders already get a payment reminder from the order expiry scheduler when it
+	// sends them; only chase them here when it does not.
+	expiry, err := GetOrCreateOrderExpirySetting(db)
+	if err != nil {
+		return out, err
+	}
+	if s.IncludeUnpaidOrders && !sendsPaymentReminders(expiry) {
+		var orders []models.Order
+		if err := unpaidPendingOrders(db).
+			Where("created_at < ? AND created_at >= ?", idleBefore, notBefore).
+			Where("payment_reminder_sent_at IS NULL").
+			Where("(marketing_consent = ? OR customer_id IN (SELECT id FROM customers WHERE marketing_consent = ?))", true, true).
+			Where("NOT EXISTS (SELECT 1 FROM cart_recovery_emails r WHERE r.source_order_id = orders.id)").
+			// The shopper already placed another order with the same email: don't chase this one.
+			Where("NOT EXISTS (SELECT 1 FROM orders o2 WHERE o2.customer_email = orders.customer_email AND o2.id > orders.id)").
+			Preload("Items").Order("id ASC").Find(&orders).Error; err != nil {
+			return out, err
+		}
+		for i := range orders {
+			if err := sendOrderRecovery(db, s, siteURL, &orders[i]); err != nil {
+				log.Printf("cart recovery: email for order %s failed: %v", orders[i].OrderNumber, err)
+				continue
+			}
+			out.OrderEmails++
+		}
+	}
+
+	n, err := syncPaidRecoveryOrders(db, s)
+	out.Recovered = n
+	return out, err
+}
+
+func sendSnapshotRecovery(db *gorm.DB, s *models.CartRecoverySetting, siteURL string, snap *models.CheckoutSnapshot) error {
+	if len(snap.Items) == 0 {
+		return errors.New("snapshot has no items")
+	}
+	cur, err := ResolveCurrency(db, snap.Currency)
+	if err != nil {
+		base := BaseCurrencyRecord()
+		cur = &base
+	}
+	items := make([]models.OrderItem, 0, len(snap.Items))
+	for _, it := range snap.Items {
+		items = append(items, models.OrderItem{
+			ProductID: it.ProductID, ProductSKU: it.ProductSKU, ProductName: it.ProductName, Quantity: it.Quantity,
+		})
+	}
+	rec := models.CartRecoveryEmail{
+		Source:     models.CartRecoverySourceSnapshot,
+		SnapshotID: &snap.ID,
+		Email:      snap.Email,
+		Amount:     snap.Subtotal,
+	}
+	total := fmt.Sprintf("%.*f %s", cur.Decimals, ConvertFromBase(snap.Subtotal, cur), cur.Code)
+	if err := sendRecoveryEmail(db, s, siteURL, &rec, snap.Name, items, total); err != nil {
+		return err
+	}
+	now := time.Now()
+	snap.RecoveryEmailSentAt = &now
+	return db.Model(&models.CheckoutSnapshot{}).Where("id = ?", snap.ID).Update("recovery_email_sent_at", &now).Error
+}
+
+func sendOrderRecovery(db *gorm.DB, s *models.CartRecoverySetting, siteURL string, order *models.Order) error {
+	rec := models.CartRecoveryEmail{
+		Source:        models.CartRecoverySourceOrder,
+		SourceOrderID: &order.ID,
+		Email:         strings.ToLower(strings.TrimSpace(order.CustomerEmail)),
+		Amount:        order.BaseTotalAmount,
+	}
+	total := fmt.Sprintf("%.2f %s", order.TotalAmount, fallbackStr(order.Currency, BaseCurrency))
+	if err := sendRecoveryEmail(db, s, siteURL, &rec, order.CustomerName, order.Items, total); err != nil {
+		return err
+	}
+	return RecordOrderHistory(db, order.ID, "event", order.Status, "cart_recovery_sent", ActorSystem,
+		"abandoned cart email sent to "+order.CustomerEmail)
+}
+
+// sendRecoveryEmail creates the recovery record (and coupon) and sends the email. The record
+// is only kept when the email went out.
+func sendRecoveryEmail(db *gorm.DB, s *models.CartRecoverySetting, siteURL string, rec *models.CartRecoveryEmail, name string, items []models.OrderItem, total string) error {
+	if rec.Email == "" {
+		return errors.New("no customer email")
+	}
+	token, err := newCartToken()
+	if err != nil {
+		return err
+	}
+	rec.Token = token
+	rec.SentAt = time.Now()
+
+	var coupon *models.Coupon
+	if s.CouponEnabled && s.CouponValue > 0 {
+		if coupon, err = createRecoveryCoupon(db, s); err != nil {
+			return err
+		}
+		rec.CouponID = &coupon.ID
+		rec.CouponCode = coupon.Code
+	}
+	if err := db.Create(rec).Error; err != nil {
+		return err
+	}
+
+	subj, txt, html := BuildCartRecoveryEmail(siteURL, name, items, total, cartRestoreURL(siteURL, token), coupon)
+	if err := SendEmail(db, EmailSendOptions{
+		To:      rec.Email,
+		Subject: subj,
+		Text:    txt,
+		HTML:    html,
+		Headers: map[string]string{"X-Entity-Ref-ID": fmt.Sprintf("cart-recovery:%d", rec.ID)},
+	}); err != nil {
+		db.Delete(rec)
+		if coupon != nil {
+			db.Delete(coupon)
+		}
+		return err
+	}
+	return nil
+}
+
+// createRecoveryCoupon creates a single-use coupon for one recovery email.
+func createRecoveryCoupon(db *gorm.DB, s *models.CartRecoverySetting) (*models.Coupon, error) {
+	token, err := newCartToken()
+	if err != nil {
+		return nil, err
+	}
+	one := 1
+	expires := time.Now().AddDate(0, 0, max(s.CouponValidDays, 1))
+	coupon := models.Coupon{
+		Code:           "COMEBACK-" + strings.ToUpper(token[:8]),
+		Name:           "Cart recovery",
+		Description:    "Single-use coupon sent with an abandoned cart email",
+		Type:           fallbackStr(s.CouponType, "percentage"),
+		Value:          s.CouponValue,
+		UsageLimit:     &one,
+		UserUsageLimit: &one,
+		IsActive:       true,
+		ExpiresAt:      &expires,
+	}
+	if err := db.Create(&coupon).Error; err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// cartRestoreURL is the storefront page that calls POST /api/v1/cart/restore with the token.
+func cartRestoreURL(siteURL, token string) string {
+	if strings.TrimSpace(siteURL) == "" {
+		return ""
+	}
+	return strings.TrimRight(siteURL, "/") + "/cart/restore?token=" + token
+}
+
+// BuildCartRecoveryEmail reminds the shopper of the items left at checkout.
+func BuildCartRecoveryEmail(siteURL, name string, items []models.OrderItem, total, link string, coupon *models.Coupon) (subject, text, html string) {
+	subject = "You left something in your cart"
+	greeting := "Hello,"
+	if n := strings.TrimSpace(name); n != "" {
+		greeting = fmt.Sprintf("Hello %s,", n)
+	}
+
+	var couponText, couponValue string
+	if coupon != nil {
+		if coupon.Type == "fixed_amount" {
+			couponValue = fmt.Sprintf("%.2f %s off", coupon.Value, BaseCurrency)
+		} else {
+			couponValue = fmt.Sprintf("%g%% off", coupon.Value)
+		}
+		couponText = fmt.Sprintf("Use code %s for %s your order", coupon.Code, couponValue)
+		if coupon.ExpiresAt != nil {
+			couponText += " until " + coupon.ExpiresAt.UTC().Format("2006-01-02")
+		}
+		couponText += " (single use)."
+	}
+
+	body := greeting + "\n\nYou were about to order the items below. We saved your cart so you can pick up where you left off.\n"
+	if list := emailItemsText(items, nil); list != "" {
+		body += "\n" + list + "\n"
+	}
+	body += fmt.Sprintf("\nTotal: %s\n", total)
+	if couponText != "" {
+		body += "\n" + couponText + "\n"
+	}
+	body += optionalLine("Restore your cart", link)
+	text = customerEmailText(body)
+
+	inner := fmt.Sprintf("<p style=\"margin:0 0 10px 0\">%s</p>", escapeHTML(greeting)) +
+		"<p style=\"margin:0 0 10px 0\">You were about to order the items below. We saved your cart so you can pick up where you left off.</p>" +
+		emailItemsTableHTML("Your cart", items, nil) +
+		emailRowsHTML(emailRowHTML("Total", total))
+	if coupon != nil {
+		inner += emailRowsHTML(emailRowHTML("Coupon", coupon.Code+" ("+couponValue+")"))
+		inner += fmt.Sprintf("<p style=\"margin:6px 0 0 0;font-size:13px;color:#374151\">%s</p>", escapeHTML(couponText))
+	}
+	inner += emailButtonHTML("Restore my cart", link)
+	html = customerEmailHTML("Your saved cart", inner)
+	return subject, text, html
+}
+
+// RestoreRecoveryCart rebuilds a cart from a recovery email link: into the customer's cart
+// when logged in, otherwise into a new guest cart. Items that can no longer be bought are skipped.
+func RestoreRecoveryCart(db *gorm.DB, token string, customerID *uint) (*models.Cart, *models.CartRecoveryEmail, error) {
+	var rec models.CartRecoveryEmail
+	if strings.TrimSpace(token) == "" {
+		return nil, nil, &CartError{Message: "This link is no longer valid"}
+	}
+	if err := db.Where("token = ?", strings.TrimSpace(token)).First(&rec).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, &CartError{Message: "This link is no longer valid"}
+		}
+		return nil, nil, err
+	}
+	if rec.ClickedAt == nil {
+		now := time.Now()
+		rec.ClickedAt = &now
+		db.Model(&rec).Update("clicked_at", &now)
+	}
+
+	var lines []OrderLineInput
+	switch {
+	case rec.SnapshotID != nil:
+		var items []models.CheckoutSnapshotItem
+		if err := db.Where("snapshot_id = ?", *rec.SnapshotID).Order("id ASC").Find(&items).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, it := range items {
+			lines = append(lines, OrderLineInput{ProductID: it.ProductID, Quantity: it.Quantity})
+		}
+	case rec.SourceOrderID != nil:
+		var items []models.OrderItem
+		if err := db.Where("order_id = ?", *rec.SourceOrderID).Order("id ASC").Find(&items).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, it := range items {
+			lines = append(lines, OrderLineInput{ProductID: it.ProductID, Quantity: it.Quantity})
+		}
+	}
+
+	cart, err := GetOrCreateCart(db, customerID, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	inCart := map[uint]bool{}
+	for _, it := range cart.Items {
+		inCart[it.ProductID] = true
+	}
+	for _, l := range lines {
+		if inCart[l.ProductID] {
+			continue
+		}
+		if err := AddCartItem(db, cart, l.ProductID, l.Quantity); err != nil {
+			var cartErr *CartError
+			if errors.As(err, &cartErr) {
+				continue
+			}
+			return nil, nil, err
+		}
+	}
+	if err := db.Model(&models.Cart{}).Where("id = ?", cart.ID).Update("recovery_id", rec.ID).Error; err != nil {
+		return nil, nil, err
+	}
+	cart.RecoveryID = &rec.ID
+	if err := loadCart(db, cart); err != nil {
+		return nil, nil, err
+	}
+	return cart, &rec, nil
+}
+
+// AttributeRecoveredOrder closes the shopper's open checkout snapshots and credits the order
+// to a recovery email: the one whose link rebuilt the cart, whose coupon was used, or the
+// latest one sent to the same email within the attribution window.
+func AttributeRecoveredOrder(db *gorm.DB, order *models.Order, recoveryID *uint) error {
+	email := strings.ToLower(strings.TrimSpace(order.CustomerEmail))
+	if err := db.Model(&models.CheckoutSnapshot{}).
+		Where("email = ? AND status = ?", email, models.CheckoutSnapshotOpen).
+		Updates(map[string]interface{}{"status": models.CheckoutSnapshotOrdered, "order_id": order.ID}).Error; err != nil {
+		return err
+	}
+
+	s, err := GetOrCreateCartRecoverySetting(db)
+	if err != nil {
+		return err
+	}
+	q := db.Where("recovered_order_id IS NULL")
+	switch {
+	case recoveryID != nil:
+		q = q.Where("id = ?", *recoveryID)
+	case order.CouponCode != "" && strings.HasPrefix(strings.ToUpper(order.CouponCode), "COMEBACK-"):
+		q = q.Where("coupon_code = ?", strings.ToUpper(order.CouponCode))
+	default:
+		q = q.Where("email = ? AND sent_at >= ?", email, time.Now().AddDate(0, 0, -max(s.AttributionDays, 1)))
+	}
+	var rec models.CartRecoveryEmail
+	if err := q.Order("sent_at DESC").First(&rec).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	return markRecovered(db, &rec, order)
+}
+
+func markRecovered(db *gorm.DB, rec *models.CartRecoveryEmail, order *models.Order) error {
+	now := time.Now()
+	return db.Model(&models.CartRecoveryEmail{}).Where("id = ? AND recovered_order_id IS NULL", rec.ID).
+		Updates(map[string]interface{}{"recovered_order_id": order.ID, "recovered_at": &now, "recovered_amount": order.BaseTotalAmount}).Error
+}
+
+// syncPaidRecoveryOrders credits unpaid-order emails whose source order has since been paid.
+func syncPaidRecoveryOrders(db *gorm.DB, s *models.CartRecoverySetting) (int, error) {
+	var recs []models.CartRecoveryEmail
+	if err := db.Where("source = ? AND recovered_order_id IS NULL AND sent_at >= ?",
+		models.CartRecoverySourceOrder, time.Now().AddDate(0, 0, -max(s.AttributionDays, 1))).
+		Find(&recs).Error; err != nil {
+		return 0, err
+	}
+	n := 0
+	for i := range recs {
+		if recs[i].SourceOrderID == nil {
+			continue
+		}
+		var order models.Order
+		if err := db.First(&order, *recs[i].SourceOrderID).Error; err != nil {
+			continue
+		}
+		if !IsPaymentCaptured(order.PaymentStatus) {
+			continue
+		}
+		if err := markRecovered(db, &recs[i], &order); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// CartRecoveryStats are recovery email totals for one source (or all).
+type CartRecoveryStats struct {
+	Source          string  `json:"source"`
+	Sent            int64   `json:"sent"`
+	Clicked         int64   `json:"clicked"`
+	Recovered       int64   `json:"recovered"`
+	Paid            int64   `json:"paid"`
+	AbandonedAmount float64 `json:"abandoned_amount"`
+	RecoveredAmount float64 `json:"recovered_amount"`
+	ConversionRate  float64 `json:"conversion_rate"` // recovered / sent, percent
+}
+
+// CartRecoveryReport summarizes emails sent since the given time. Amounts are in the base currency.
+func CartRecoveryReport(db *gorm.DB, since time.Time) ([]CartRecoveryStats, error) {
+	var rows []CartRecoveryStats
+	err := db.Model(&models.CartRecoveryEmail{}).
+		Select("cart_recovery_emails.source AS source, COUNT(*) AS sent, "+
+			"SUM(CASE WHEN cart_recovery_emails.clicked_at IS NOT NULL THEN 1 ELSE 0 END) AS clicked, "+
+			"SUM(CASE WHEN cart_recovery_emails.recovered_order_id IS NOT NULL THEN 1 ELSE 0 END) AS recovered, "+
+			"SUM(CASE WHEN orders.payment_status IN ? THEN 1 ELSE 0 END) AS paid, "+
+			"COALESCE(SUM(cart_recovery_emails.amount), 0) AS abandoned_amount, "+
+			"COALESCE(SUM(cart_recovery_emails.recovered_amount), 0) AS recovered_amount",
+			[]string{models.PaymentStatusPaid, models.PaymentStatusPartiallyRefunded}).
+		Joins("LEFT JOIN orders ON orders.id = cart_recovery_emails.recovered_order_id").
+		Where("cart_recovery_emails.sent_at >= ?", since).
+		Group("cart_recovery_emails.source").
+		Order("cart_recovery_emails.source ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	total := CartRecoveryStats{Source: "all"}
+	for i := range rows {
+		rows[i].AbandonedAmount = round2(rows[i].AbandonedAmount)
+		rows[i].RecoveredAmount = round2(rows[i].RecoveredAmount)
+		if rows[i].Sent > 0 {
+			rows[i].ConversionRate = round2(float64(rows[i].Recovered) * 100 / float64(rows[i].Sent))
+		}
+		total.Sent += rows[i].Sent
+		total.Clicked += rows[i].Clicked
+		total.Recovered += rows[i].Recovered
+		total.Paid += rows[i].Paid
+		total.AbandonedAmount += rows[i].AbandonedAmount
+		total.RecoveredAmount += rows[i].RecoveredAmount
+	}
+	total.AbandonedAmount = round2(total.AbandonedAmount)
+	total.RecoveredAmount = round2(total.RecoveredAmount)
+	if total.Sent > 0 {
+		total.ConversionRate = round2(float64(total.Recovered) * 100 / float64(total.Sent))
+	}
+	return append([]CartRecoveryStats{total}, rows...), nil
+}
+
+// StartCartRecoveryScheduler runs a background goroutine that sends abandoned cart emails
+// based on the cart recovery settings. Checks every 15 minutes.
+func StartCartRecoveryScheduler() {
+	db := config.GetDB()
+	if db == nil {
+		return
+	}
+
+	go func() {
+		t := time.NewTicker(15 * time.Minute)
+		defer t.Stop()
+
+		for range t.C {
+			s, err := GetOrCreateCartRecoverySetting(db)
+			if err != nil {
+				log.Printf("cart recovery: settings load failed: %v", err)
+				continue
+			}
+			if !s.Enabled {
+				continue
+			}
+
+			res, err := RunCartRecovery(db, s, os.Getenv("SITE_URL"))
+			if err != nil {
+				log.Printf("cart recovery: run failed: %v", err)
+				continue
+			}
+
+			now := time.Now().UTC()
+			_ = db.Model(&models.CartRecoverySetting{}).Where("id = ?", 1).Update("last_run_at", &now).Error
+			if res.SnapshotEmails > 0 || res.OrderEmails > 0 || res.Recovered > 0 {
+				log.Printf("cart recovery: sent %d checkout and %d unpaid order emails, %d orders recovered",
+					res.SnapshotEmails, res.OrderEmails, res.Recovered)
+			}
+		}
+	}()
+}
@@ -0,0 +1,305 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"fanuc-backend/models"
+
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// BaseCurrency is the currency catalog prices, coupons and reports are kept in.
+const BaseCurrency = "USD"
+
+// CurrencyError is returned when a requested currency cannot be used.
+type CurrencyError struct {
+	Code    string
+	Message string
+}
+
+func (e *CurrencyError) Error() string { return e.Message }
+
+func NormalizeCurrencyCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// BaseCurrencyRecord is the built-in base currency row (also used before the table is seeded).
+func BaseCurrencyRecord() models.Currency {
+	return models.Currency{Code: BaseCurrency, Name: "US Dollar", Symbol: "$", Rate: 1, Decimals: 2, IsActive: true}
+}
+
+// ListActiveCurrencies returns the currencies shoppers can choose, base currency first.
+func ListActiveCurrencies(db *gorm.DB) ([]models.Currency, error) {
+	var list []models.Currency
+	if err := db.Where("is_active = ?", true).
+		Order(fmt.Sprintf("CASE WHEN code = '%s' THEN 0 ELSE 1 END, sort_order ASC, code ASC", BaseCurrency)).
+		Find(&list).Error; err != nil {
+		return nil, err
+	}
+	if len(list) == 0 || list[0].Code != BaseCurrency {
+		list = append([]models.Currency{BaseCurrencyRecord()}, list...)
+	}
+	return list, nil
+}
+
+// ResolveCurrency returns the active currency for code; an empty code means the base currency.
+func ResolveCurrency(db *gorm.DB, code string) (*models.Currency, error) {
+	code = NormalizeCurrencyCode(code)
+	if code == "" {
+		code = BaseCurrency
+	}
+	var cur models.Currency
+	err := db.Where("code = ?", code).First(&cur).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) && code == BaseCurrency {
+		cur = BaseCurrencyRecord()
+		return &cur, nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !cur.IsActive) {
+		return nil, &CurrencyError{Code: code, Message: fmt.Sprintf("Currency %s is not supported", code)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cur.Code == BaseCurrency {
+		cur.Rate = 1
+	}
+	if cur.Rate <= 0 {
+		return nil, &CurrencyError{Code: code, Message: fmt.Sprintf("Currency %s has no exchange rate", code)}
+	}
+	return &cur, nil
+}
+
+// roundCurrency rounds to the currency's minor unit (2 decimals unless configured otherwise).
+func roundCurrency(v float64, decimals int) float64 {
+	if decimals < 0 || decimals > 4 {
+		decimals = 2
+	}
+	p := math.Pow(10, float64(decimals))
+	return math.Round(v*p) / p
+}
+
+// ConvertFromBase converts a base-currency amount into cur, rounded to cur's minor unit.
+func ConvertFromBase(amount float64, cur *models.Currency) float64 {
+	if cur == nil || cur.Code == BaseCurrency || cur.Rate <= 0 {
+		return round2(amount)
+	}
+	return roundCurrency(amount*cur.Rate, cur.Decimals)
+}
+
+// ConvertToBase converts an amount in cur back into the base currency.
+func ConvertToBase(amount float64, cur *models.Currency) float64 {
+	if cur == nil || cur.Code == BaseCurrency || cur.Rate <= 0 {
+		return round2(amount)
+	}
+	return round2(amount / cur.Rate)
+}
+
+// ConvertShippingQuote re-expresses a quote (priced in its template currency) in target.
+// Template currencies other than the base must exist in the currency table (active or not).
+func ConvertShippingQuote(db *gorm.DB, q ShippingQuoteResult, target *models.Currency) (ShippingQuoteResult, error) {
+	from := NormalizeCurrencyCode(q.Currency)
+	if from == "" {
+		from = BaseCurrency
+	}
+	to := BaseCurrencyRecord()
+	if target != nil {
+		to = *target
+	}
+	if from == to.Code {
+		q.Currency = from
+		return q, nil
+	}
+
+	toBase := func(v float64) float64 { return v }
+	if from != BaseCurrency {
+		var src models.Currency
+		if err := db.Where("code = ?", from).First(&src).Error; err != nil || src.Rate <= 0 {
+			return q, &CurrencyError{Code: from, Message: fmt.Sprintf("No exchange rate for shipping template currency %s", from)}
+		}
+		toBase = func(v float64) float64 { return v / src.Rate }
+	}
+	conv := func(v float64) float64 { return ConvertFromBase(toBase(v), &to) }
+
+	q.RatePerKg = conv(q.RatePerKg)
+	q.BaseQuote = conv(q.BaseQuote)
+	q.AdditionalFee = conv(q.AdditionalFee)
+	q.ShippingFee = conv(q.ShippingFee)
+	q.Currency = to.Code
+	return q, nil
+}
+
+// CurrencyImportItem is the outcome of one row of a rate import.
+type CurrencyImportItem struct {
+	RowNumber int     `json:"row_number"`
+	Code      string  `json:"code"`
+	Rate      float64 `json:"rate,omitempty"`
+	Action    string  `json:"action"` // created | updated | skipped | failed
+	Message   string  `json:"message,omitempty"`
+}
+
+type CurrencyImportResult struct {
+	TotalRows int                  `json:"total_rows"`
+	Created   int                  `json:"created"`
+	Updated   int                  `json:"updated"`
+	Skipped   int                  `json:"skipped"`
+	Failed    int                  `json:"failed"`
+	Items     []CurrencyImportItem `json:"items"`
+}
+
+// ImportCurrencyRates reads a CSV or XLSX file with a header row containing at least
+// "code" and "rate" (units per 1 USD); "name", "symbol" and "decimals" are optional.
+// Known currencies get their rate updated; unknown codes are added as inactive so an admin
+// can review them before they are offered to shoppers.
+func ImportCurrencyRates(db *gorm.DB, filename string, r io.Reader) (CurrencyImportResult, error) {
+	var res CurrencyImportResult
+	if db == nil {
+		return res, errors.New("db is nil")
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return res, err
+	}
+
+	var rows [][]string
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == ".xlsx" || bytes.HasPrefix(data, []byte("PK")) {
+		f, err := excelize.OpenReader(bytes.NewReader(data))
+		if err != nil {
+			return res, fmt.Errorf("invalid xlsx: %w", err)
+		}
+		defer f.Close()
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return res, errors.New("xlsx has no sheets")
+		}
+		if rows, err = f.GetRows(sheets[0]); err != nil {
+			return res, err
+		}
+	} else {
+		cr := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+		cr.FieldsPerRecord = -1
+		cr.TrimLeadingSpace = true
+		if rows, err = cr.ReadAll(); err != nil {
+			return res, fmt.Errorf("invalid csv: %w", err)
+		}
+	}
+	if len(rows) < 2 {
+		return res, errors.New("file has no data rows")
+	}
+
+	col := map[string]int{}
+	for i, h := range rows[0] {
+		h = strings.ToLower(strings.TrimSpace(h))
+		switch h {
+		case "currency", "currency_code":
+			h = "code"
+		case "exchange_rate", "rate_to_usd":
+			h = "rate"
+		}
+		if _, dup := col[h]; !dup {
+			col[h] = i
+		}
+	}
+	if _, ok := col["code"]; !ok {
+		return res, errors.New(`missing "code" column`)
+	}
+	if _, ok := col["rate"]; !ok {
+		return res, errors.New(`missing "rate" column`)
+	}
+	cell := func(row []string, name string) string {
+		i, ok := col[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	now := time.Now()
+	for i, row := range rows[1:] {
+		item := CurrencyImportItem{RowNumber: i + 2, Code: NormalizeCurrencyCode(cell(row, "code"))}
+		if item.Code == "" && cell(row, "rate") == "" {
+			continue // blank line
+		}
+		res.TotalRows++
+		fail := func(msg string) {
+			item.Action, item.Message = "failed", msg
+			res.Failed++
+			res.Items = append(res.Items, item)
+		}
+		if len(item.Code) != 3 {
+			fail("code must be a 3-letter ISO 4217 code")
+			continue
+		}
+		rate, err := strconv.ParseFloat(strings.ReplaceAll(cell(row, "rate"), ",", ""), 64)
+		if err != nil || rate <= 0 {
+			fail("rate must be a positive number")
+			continue
+		}
+		item.Rate = rate
+		if item.Code == BaseCurrency {
+			item.Action, item.Message = "skipped", "base currency rate is always 1"
+			res.Skipped++
+			res.Items = append(res.Items, item)
+			continue
+		}
+
+		updates := map[string]interface{}{"rate": rate, "rate_source": "import", "rate_updated_at": &now}
+		if v := cell(row, "name"); v != "" {
+			updates["name"] = v
+		}
+		if v := cell(row, "symbol"); v != "" {
+			updates["symbol"] = v
+		}
+		if v := cell(row, "decimals"); v != "" {
+			d, err := strconv.Atoi(v)
+			if err != nil || d < 0 || d > 4 {
+				fail("decimals must be between 0 and 4")
+				continue
+			}
+			updates["decimals"] = d
+		}
+
+		var existing models.Currency
+		err = db.Where("code = ?", item.Code).First(&existing).Error
+		switch {
+		case err == nil:
+			if err := db.Model(&existing).Updates(updates).Error; err != nil {
+				fail(err.Error())
+				continue
+			}
+			item.Action = "updated"
+			res.Updated++
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			cur := models.Currency{Code: item.Code, Name: cell(row, "name"), Symbol: cell(row, "symbol"), Rate: rate, Decimals: 2, RateSource: "import", RateUpdatedAt: &now}
+			if d, ok := updates["decimals"].(int); ok {
+				cur.Decimals = d
+			}
+			// Create, then switch off: a zero-value IsActive would be replaced by the column default.
+			if err := db.Create(&cur).Error; err != nil {
+				fail(err.Error())
+				continue
+			}
+			if err := db.Model(&cur).Update("is_active", false).Error; err != nil {
+				fail(err.Error())
+				continue
+			}
+			item.Action, item.Message = "created", "added as inactive"
+			res.Created++
+		default:
+			fail(err.Error())
+			continue
+		}
+		res.Items = append(res.Items, item)
+	}
+	return res, nil
+}
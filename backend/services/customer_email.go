@@ -0,0 +1,122 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"fanuc-backend/models"
+)
+
+// customerEmailHTML wraps body HTML in the branded shell used by customer-facing emails
+// (same header/card look as the shipment notification).
+func customerEmailHTML(subtitle, bodyHTML string) string {
+	return "<div style=\"font-family:Arial,Helvetica,sans-serif;max-width:640px;margin:0 auto;line-height:1.6;color:#111827\">" +
+		"<div style=\"padding:18px 20px;background:linear-gradient(135deg,#f59e0b,#fbbf24);border-radius:14px 14px 0 0;\">" +
+		"<div style=\"font-size:18px;font-weight:800\">Vcocnc Spare Parts</div>" +
+		"<div style=\"font-size:13px;opacity:0.9;margin-top:4px\">" + escapeHTML(subtitle) + "</div>" +
+		"</div>" +
+		"<div style=\"border:1px solid #e5e7eb;border-top:none;border-radius:0 0 14px 14px;padding:18px 20px;background:#fff\">" +
+		bodyHTML +
+		"<p style=\"margin:14px 0 0 0;font-size:12px;color:#6b7280\">If you have any questions, reply to this email.</p>" +
+		"</div>" +
+		"</div>"
+}
+
+// customerEmailText appends the standard signature to a plain-text body.
+func customerEmailText(body string) string {
+	return "Vcocnc\n\n" + strings.TrimRight(body, "\n") + "\n\nIf you have any questions, reply to this email.\n\n--\nVcocnc Spare Parts\n"
+}
+
+// emailRowHTML renders a label/value row for the key-value tables in customer emails.
+func emailRowHTML(label, value string) string {
+	return fmt.Sprintf("<tr><td style=\"width:160px;color:#6b7280;font-size:13px\">%s</td><td style=\"font-size:14px;font-weight:700\">%s</td></tr>", escapeHTML(label), escapeHTML(value))
+}
+
+func emailRowsHTML(rows ...string) string {
+	return "<table role=\"presentation\" cellpadding=\"0\" cellspacing=\"0\" style=\"width:100%;border-collapse:separate;border-spacing:0 8px\">" +
+		strings.Join(rows, "") +
+		"</table>"
+}
+
+func emailButtonHTML(label, href string) string {
+	if strings.TrimSpace(href) == "" {
+		return ""
+	}
+	return fmt.Sprintf("<p style=\"margin:14px 0 0 0\"><a href=\"%s\" style=\"display:inline-block;background:#111827;color:#fff;text-decoration:none;font-weight:800;font-size:13px;padding:10px 12px;border-radius:10px\">%s</a></p>", escapeAttr(href), escapeHTML(label))
+}
+
+// emailItemsTableHTML renders SKU/Item/Qty rows; qty overrides per item ID when non-nil.
+func emailItemsTableHTML(title string, items []models.OrderItem, qty map[uint]int) string {
+	rows := make([]string, 0, len(items))
+	for _, it := range items {
+		q := it.Quantity
+		if qty != nil {
+			v, ok := qty[it.ID]
+			if !ok {
+				continue
+			}
+			q = v
+		}
+		sku := fallbackStr(it.DisplaySKU(), fmt.Sprintf("PID-%d", it.ProductID))
+		name := fallbackStr(it.DisplayName(), "Product")
+		rows = append(rows,
+			"<tr>"+
+				"<td style=\"padding:8px 10px;border-top:1px solid #e5e7eb;font-family:ui-monospace,SFMono-Regular,Menlo,Monaco,Consolas,monospace;font-size:12px;color:#111827;\">"+escapeHTML(sku)+"</td>"+
+				"<td style=\"padding:8px 10px;border-top:1px solid #e5e7eb;font-family:Arial,Helvetica,sans-serif;font-size:13px;color:#111827;\">"+escapeHTML(name)+"</td>"+
+				fmt.Sprintf("<td style=\"padding:8px 10px;border-top:1px solid #e5e7eb;font-family:Arial,Helvetica,sans-serif;font-size:13px;color:#111827;text-align:right;\">%d</td>", q)+
+				"</tr>")
+	}
+	if len(rows) == 0 {
+		return ""
+	}
+	th := "padding:8px 10px;background:#f9fafb;border-bottom:1px solid #e5e7eb;font-family:Arial,Helvetica,sans-serif;font-size:11px;color:#6b7280;text-transform:uppercase;letter-spacing:0.04em"
+	return "<div style=\"margin-top:14px\">" +
+		"<div style=\"font-family:Arial,Helvetica,sans-serif;font-size:13px;font-weight:800;color:#111827;margin:0 0 8px 0\">" + escapeHTML(title) + "</div>" +
+		"<table role=\"presentation\" cellpadding=\"0\" cellspacing=\"0\" style=\"width:100%;border:1px solid #e5e7eb;border-radius:10px;border-collapse:separate;border-spacing:0;overflow:hidden\">" +
+		"<tr>" +
+		"<th align=\"left\" style=\"" + th + "\">SKU</th>" +
+		"<th align=\"left\" style=\"" + th + "\">Item</th>" +
+		"<th align=\"right\" style=\"" + th + "\">Qty</th>" +
+		"</tr>" +
+		strings.Join(rows, "") +
+		"</table>" +
+		"</div>"
+}
+
+// emailItemsText renders "- SKU | Name xQty" lines; qty works as in emailItemsTableHTML.
+func emailItemsText(items []models.OrderItem, qty map[uint]int) string {
+	lines := make([]string, 0, len(items))
+	for _, it := range items {
+		q := it.Quantity
+		if qty != nil {
+			v, ok := qty[it.ID]
+			if !ok {
+				continue
+			}
+			q = v
+		}
+		sku := fallbackStr(it.DisplaySKU(), fmt.Sprintf("PID-%d", it.ProductID))
+		name := fallbackStr(it.DisplayName(), "Product")
+		lines = append(lines, fmt.Sprintf("- %s | %s x%d", sku, name, q))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// orderTrackURL returns the signed public tracking page for an order, or "" without a site URL.
+func orderTrackURL(siteURL string, order models.Order) string {
+	if strings.TrimSpace(siteURL) == "" || order.OrderNumber == "" {
+		return ""
+	}
+	link := strings.TrimRight(siteURL, "/") + "/orders/track/" + order.OrderNumber
+	if token := OrderTrackingToken(order.OrderNumber); token != "" {
+		link += "?token=" + token
+	}
+	return link
+}
+
+func orderDisplayNumber(order models.Order) string {
+	if order.OrderNumber != "" {
+		return order.OrderNumber
+	}
+	return fmt.Sprintf("ORDER-%d", order.ID)
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"fanuc-backend/config"
+	"fanuc-backend/models"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// IdempotencyTTL is how long a completed response is replayed for the same key.
+	IdempotencyTTL = 24 * time.Hour
+	// idempotencyLockTTL bounds a processing claim, so a request that died mid-way does not
+	// block its key forever. It must outlast the slowest guarded handler (payment capture: 45s).
+	idempotencyLockTTL = 2 * time.Minute
+
+	redisPrefixIdempotency = "idem:"
+)
+
+// IdempotencyRecord is the state stored for one idempotency key.
+type IdempotencyRecord struct {
+	RequestHash    string `json:"request_hash"`
+	Status         string `json:"status"` // models.IdempotencyProcessing | models.IdempotencyCompleted
+	ResponseStatus int    `json:"response_status,omitempty"`
+	ContentType    string `json:"content_type,omitempty"`
+	Body           []byte `json:"body,omitempty"`
+}
+
+// IdempotencyStore persists idempotency keys.
+type IdempotencyStore interface {
+	// Claim reserves key for a new request. When the key already exists it returns the
+	// existing record and claimed=false.
+	Claim(ctx context.Context, key, requestHash string) (existing *IdempotencyRecord, claimed bool, err error)
+	// Complete stores the response for replay.
+	Complete(ctx context.Context, key string, rec IdempotencyRecord) error
+	// Release drops a claim so the request can be retried (used after server errors).
+	Release(ctx context.Context, key string) error
+}
+
+// GetIdempotencyStore returns the Redis store when Redis is configured, the database otherwise.
+func GetIdempotencyStore() IdempotencyStore {
+	if rdb := config.GetRedis(); rdb != nil {
+		return &redisIdempotencyStore{rdb: rdb}
+	}
+	return &dbIdempotencyStore{db: config.GetDB()}
+}
+
+type redisIdempotencyStore struct {
+	rdb *redis.Client
+}
+
+func (s *redisIdempotencyStore) Claim(ctx context.Context, key, requestHash string) (*IdempotencyRecord, bool, error) {
+	claim, _ := json.Marshal(IdempotencyRecord{RequestHash: requestHash, Status: models.IdempotencyProcessing})
+	ok, err := s.rdb.SetNX(ctx, redisPrefixIdempotency+key, claim, idempotencyLockTTL).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if ok {
+		return nil, true, nil
+	}
+	raw, err := s.rdb.Get(ctx, redisPrefixIdempotency+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// Expired between SETNX and GET: try once more.
+		ok, err = s.rdb.SetNX(ctx, redisPrefixIdempotency+key, claim, idempotencyLockTTL).Result()
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			return nil, true, nil
+		}
+		raw, err = s.rdb.Get(ctx, redisPrefixIdempotency+key).Bytes()
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var rec IdempotencyRecord
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return nil, false, err
+	}
+	return &rec, false, nil
+}
+
+func (s *redisIdempotencyStore) Complete(ctx context.Context, key string, rec IdempotencyRecord) error {
+	rec.Status = models.IdempotencyCompleted
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.rdb.Set(ctx, redisPrefixIdempotency+key, raw, IdempotencyTTL).Err()
+}
+
+func (s *redisIdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.rdb.Del(ctx, redisPrefixIdempotency+key).Err()
+}
+
+type dbIdempotencyStore struct {
+	db *gorm.DB
+}
+
+func (s *dbIdempotencyStore) Claim(ctx context.Context, key, requestHash string) (*IdempotencyRecord, bool, error) {
+	if s.db == nil {
+		return nil, false, errors.New("db is nil")
+	}
+	db := s.db.WithContext(ctx)
+	now := time.Now()
+	row := models.IdempotencyKey{
+		Key:         key,
+		RequestHash: requestHash,
+		Status:      models.IdempotencyProcessing,
+		ExpiresAt:   now.Add(idempotencyLockTTL),
+	}
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+	if res.Error != nil {
+		return nil, false, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return nil, true, nil
+	}
+
+	// The unique index rejected the insert: the key exists.
+	var existing models.IdempotencyKey
+	if err := db.Where("idempotency_key = ?", key).First(&existing).Error; err != nil {
+		return nil, false, err
+	}
+	if existing.ExpiresAt.Before(now) {
+		// Stale claim or expired replay window: take the key over, unless another request just did.
+		res = db.Model(&models.IdempotencyKey{}).
+			Where("id = ? AND expires_at = ?", existing.ID, existing.ExpiresAt).
+			Updates(map[string]interface{}{
+				"request_hash":    requestHash,
+				"status":          models.IdempotencyProcessing,
+				"response_status": 0,
+				"content_type":    "",
+				"response_body":   "",
+				"expires_at":      row.ExpiresAt,
+			})
+		if res.Error != nil {
+			return nil, false, res.Error
+		}
+		if res.RowsAffected == 1 {
+			return nil, true, nil
+		}
+		if err := db.Where("idempotency_key = ?", key).First(&existing).Error; err != nil {
+			return nil, false, err
+		}
+	}
+	return &IdempotencyRecord{
+		RequestHash:    existing.RequestHash,
+		Status:         existing.Status,
+		ResponseStatus: existing.ResponseStatus,
+		ContentType:    existing.ContentType,
+		Body:           []byte(existing.ResponseBody),
+	}, false, nil
+}
+
+func (s *dbIdempotencyStore) Complete(ctx context.Context, key string, rec IdempotencyRecord) error {
+	return s.db.WithContext(ctx).Model(&models.IdempotencyKey{}).Where("idempotency_key = ?", key).Updates(map[string]interface{}{
+		"status":          models.IdempotencyCompleted,
+		"response_status": rec.ResponseStatus,
+		"content_type":    rec.ContentType,
+		"response_body":   string(rec.Body),
+		"expires_at":      time.Now().Add(IdempotencyTTL),
+	}).Error
+}
+
+func (s *dbIdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Where("idempotency_key = ? AND status = ?", key, models.IdempotencyProcessing).
+		Delete(&models.IdempotencyKey{}).Error
+}
+
+// StartIdempotencyCleanupScheduler deletes expired database idempotency keys once an hour.
+// (Redis keys expire on their own.)
+func StartIdempotencyCleanupScheduler() {
+	db := config.GetDB()
+	if db == nil {
+		return
+	}
+
+	go func() {
+		t := time.NewTicker(1 * time.Hour)
+		defer t.Stop()
+
+		for range t.C {
+			result := db.Where("expires_at < ?", time.Now()).Delete(&models.IdempotencyKey{})
+			if result.Error != nil {
+				log.Printf("idempotency cleanup: delete failed: %v", result.Error)
+				continue
+			}
+			if result.RowsAffected > 0 {
+				log.Printf("idempotency cleanup: deleted %d expired keys", result.RowsAffected)
+			}
+		}
+	}()
+}
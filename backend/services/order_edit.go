@@ -0,0 +1,307 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrderEditError is returned when an order's lines cannot be changed.
+type OrderEditError struct {
+	Message string
+}
+
+func (e *OrderEditError) Error() string { return e.Message }
+
+// OrderEditLine is one wanted line of an edited order.
+type OrderEditLine struct {
+	ProductID uint
+	Quantity  int
+}
+
+// OrderEditInput replaces the lines of an order. Items is the complete new list: lines that
+// are left out are removed, new products are added, others get the new quantity.
+type OrderEditInput struct {
+	Items        []OrderEditLine
+	RemoveCoupon bool   // drop a coupon that no longer applies instead of refusing the edit
+	Note         string // why the order was changed, e.g. "customer phoned to add a spare"
+	AdminID      *uint
+}
+
+// CouponChecker validates a coupon code against a base-currency subtotal without recording
+// usage (CouponController.CheckCoupon).
+type CouponChecker func(db *gorm.DB, code string, baseSubtotal float64, customerEmail string) (*models.CouponValidateResponse, error)
+
+// CheckOrderEditable reports why an order's lines can no longer be changed, or nil.
+// Only unpaid, unshipped and unrefunded orders are editable.
+func CheckOrderEditable(order *models.Order) error {
+	switch order.Status {
+	case models.OrderStatusPending, models.OrderStatusConfirmed:
+	default:
+		return &OrderEditError{Message: fmt.Sprintf("Order is %s and its items can no longer be changed", order.Status)}
+	}
+	switch fallbackStr(order.PaymentStatus, models.PaymentStatusPending) {
+	case models.PaymentStatusPending, models.PaymentStatusAwaitingPayment, models.PaymentStatusFailed:
+	default:
+		return &OrderEditError{Message: fmt.Sprintf("Payment is %s, only unpaid orders can be edited", strings.ReplaceAll(order.PaymentStatus, "_", " "))}
+	}
+	if order.FulfillmentStatus != "" && order.FulfillmentStatus != models.FulfillmentUnfulfilled {
+		return &OrderEditError{Message: "Order has shipments and its items can no longer be changed"}
+	}
+	if order.RefundedAmount > 0 {
+		return &OrderEditError{Message: "Order has refunds and its items can no longer be changed"}
+	}
+	return nil
+}
+
+// EditOrderItems replaces an unpaid order's lines and recalculates everything derived from them,
+// in one transaction: lines are re-priced at the catalog price (in the order currency at the
+// order's exchange rate), the stock reservation is rebuilt (an *OrderLineError means not enough
+// stock), shipping is re-quoted for the new weight, the coupon is re-validated against the new
+// subtotal and tax is recalculated. The change is recorded in the order history.
+func EditOrderItems(db *gorm.DB, orderID uint, in OrderEditInput, checkCoupon CouponChecker) (*models.Order, error) {
+	// Merge repeated products so each product is one line.
+	var lines []OrderLineInput
+	index := map[uint]int{}
+	for _, l := range in.Items {
+		if l.ProductID == 0 || l.Quantity <= 0 {
+			return nil, &OrderEditError{Message: "Every line needs a product and a quantity of at least 1"}
+		}
+		if i, ok := index[l.ProductID]; ok {
+			lines[i].Quantity += l.Quantity
+			continue
+		}
+		index[l.ProductID] = len(lines)
+		lines = append(lines, OrderLineInput{ProductID: l.ProductID, Quantity: l.Quantity})
+	}
+	if len(lines) == 0 {
+		return nil, &OrderEditError{Message: "Order must contain at least one item, cancel the order instead"}
+	}
+
+	var order models.Order
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+			First(&order, orderID).Error; err != nil {
+			return err
+		}
+		if err := CheckOrderEditable(&order); err != nil {
+			return err
+		}
+
+		// Give the order's own hold back first, so its units count as available when re-priced.
+		if _, err := ReleaseOrderReservations(tx, order.ID); err != nil {
+			return err
+		}
+
+		cur, err := ResolveCurrency(tx, fallbackStr(order.Currency, BaseCurrency))
+		if err != nil {
+			var curErr *CurrencyError
+			if errors.As(err, &curErr) {
+				return &OrderEditError{Message: curErr.Message}
+			}
+			return err
+		}
+		// Keep the rate the order was placed at, so unchanged lines keep their price.
+		if order.ExchangeRate > 0 {
+			cur.Rate = order.ExchangeRate
+		}
+		priced, err := PriceOrderLines(tx, lines, cur)
+		if err != nil {
+			return err
+		}
+
+		// Update lines in place (their IDs stay stable), add new ones, delete the rest.
+		existing := map[uint]models.OrderItem{}
+		var changes []string
+		for _, it := range order.Items {
+			if _, dup := existing[it.ProductID]; dup {
+				if err := tx.Delete(&models.OrderItem{}, it.ID).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			existing[it.ProductID] = it
+		}
+		for _, it := range priced.Items {
+			old, ok := existing[it.ProductID]
+			if !ok {
+				it.OrderID = order.ID
+				if err := tx.Create(&it).Error; err != nil {
+					return err
+				}
+				changes = append(changes, fmt.Sprintf("added %s x%d", it.ProductSKU, it.Quantity))
+				continue
+			}
+			delete(existing, it.ProductID)
+			if err := tx.Model(&models.OrderItem{}).Where("id = ?", old.ID).Updates(map[string]interface{}{
+				"quantity":        it.Quantity,
+				"unit_price":      it.UnitPrice,
+				"total_price":     it.TotalPrice,
+				"base_unit_price": it.BaseUnitPrice,
+				"product_sku":     it.ProductSKU,
+				"product_name":    it.ProductName,
+			}).Error; err != nil {
+				return err
+			}
+			if old.Quantity != it.Quantity {
+				changes = append(changes, fmt.Sprintf("%s x%d -> x%d", it.ProductSKU, old.Quantity, it.Quantity))
+			}
+		}
+		for _, old := range existing {
+			if err := tx.Delete(&models.OrderItem{}, old.ID).Error; err != nil {
+				return err
+			}
+			changes = append(changes, fmt.Sprintf("removed %s x%d", old.DisplaySKU(), old.Quantity))
+		}
+
+		// Keep the shipping option the customer chose at checkout.
+		var baseShippingFee float64
+		if order.ShippingCarrier != "" {
+			var opt ShippingOption
+			if opt, err = QuoteShippingOption(tx, order.ShippingCountry, priced.Parcel, order.ShippingCarrier, order.ShippingService); err == nil {
+				baseShippingFee = opt.ShippingFee
+			}
+		} else {
+			baseShippingFee, err = OrderShippingFee(tx, order.ShippingCountry, priced.Parcel)
+		}
+		if err != nil {
+			var choiceErr *ShippingChoiceError
+			if errors.As(err, &choiceErr) {
+				return &OrderEditError{Message: choiceErr.Message}
+			}
+			var curErr *CurrencyError
+			if errors.As(err, &curErr) {
+				return &OrderEditError{Message: "Shipping fee could not be converted: " + curErr.Message}
+			}
+			return &OrderEditError{Message: "Shipping template not configured for country/weight: " + err.Error()}
+		}
+
+		// Re-validate the coupon as if this order had never used it.
+		baseDiscount := 0.0
+		couponCode, couponID := order.CouponCode, order.CouponID
+		if couponCode != "" {
+			if _, err := ReleaseCouponUsage(tx, order.ID); err != nil {
+				return err
+			}
+			if in.RemoveCoupon {
+				changes = append(changes, "coupon "+couponCode+" removed")
+				couponCode, couponID = "", nil
+			} else {
+				res, err := checkCoupon(tx, couponCode, priced.BaseSubtotal, order.CustomerEmail)
+				if err != nil {
+					return err
+				}
+				if res == nil || !res.Valid {
+					msg := "is no longer valid"
+					if res != nil && res.Message != "" {
+						msg = "no longer applies: " + res.Message
+					}
+					return &OrderEditError{Message: fmt.Sprintf("Coupon %s %s. Remove the coupon to save this change", couponCode, msg)}
+				}
+				baseDiscount = res.DiscountAmount
+				couponID = &res.CouponID
+				if err := tx.Create(&models.CouponUsage{
+					CouponID:       res.CouponID,
+					OrderID:        order.ID,
+					CustomerEmail:  order.CustomerEmail,
+					DiscountAmount: res.DiscountAmount,
+				}).Error; err != nil {
+					return err
+				}
+				if err := tx.Model(&models.Coupon{}).Where("id = ?", res.CouponID).
+					UpdateColumn("used_count", gorm.Expr("used_count + ?", 1)).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		shippingFee := ConvertFromBase(baseShippingFee, cur)
+		discount := ConvertFromBase(baseDiscount, cur)
+		if discount > priced.Subtotal {
+			discount = priced.Subtotal
+		}
+		tax, err := CalculateOrderTax(tx, TaxInput{
+			CountryCode:  order.ShippingCountry,
+			VATNumber:    order.VATNumber,
+			Currency:     cur,
+			Subtotal:     priced.Subtotal,
+			Discount:     discount,
+			Shipping:     shippingFee,
+			BaseSubtotal: priced.BaseSubtotal,
+			BaseDiscount: baseDiscount,
+			BaseShipping: baseShippingFee,
+		})
+		if err != nil {
+			var vatErr *VATNumberError
+			if errors.As(err, &vatErr) {
+				return &OrderEditError{Message: vatErr.Message}
+			}
+			return err
+		}
+
+		oldTotal := order.TotalAmount
+		order.SubtotalAmount = priced.Subtotal
+		order.DiscountAmount = discount
+		order.ShippingFee = shippingFee
+		order.TotalAmount = priced.Subtotal - discount + shippingFee
+		order.BaseSubtotalAmount = priced.BaseSubtotal
+		order.BaseDiscountAmount = baseDiscount
+		order.BaseShippingFee = baseShippingFee
+		order.BaseTotalAmount = priced.BaseSubtotal - baseDiscount + baseShippingFee
+		order.CouponCode, order.CouponID = couponCode, couponID
+		ApplyOrderTax(&order, tax)
+
+		if err := tx.Where("order_id = ?", order.ID).Delete(&models.OrderTaxLine{}).Error; err != nil {
+			return err
+		}
+		for i := range order.TaxLines {
+			order.TaxLines[i].OrderID = order.ID
+			if err := tx.Create(&order.TaxLines[i]).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Updates(map[string]interface{}{
+			"subtotal_amount":      order.SubtotalAmount,
+			"discount_amount":      order.DiscountAmount,
+			"shipping_fee":         order.ShippingFee,
+			"tax_amount":           order.TaxAmount,
+			"tax_inclusive":        order.TaxInclusive,
+			"reverse_charge":       order.ReverseCharge,
+			"total_amount":         order.TotalAmount,
+			"base_subtotal_amount": order.BaseSubtotalAmount,
+			"base_discount_amount": order.BaseDiscountAmount,
+			"base_shipping_fee":    order.BaseShippingFee,
+			"base_tax_amount":      order.BaseTaxAmount,
+			"base_total_amount":    order.BaseTotalAmount,
+			"coupon_code":          order.CouponCode,
+			"coupon_id":            order.CouponID,
+		}).Error; err != nil {
+			return err
+		}
+
+		// Hold stock for the new lines; fails if another order took the units meanwhile.
+		order.Items = nil
+		if err := ReserveOrderStock(tx, &order); err != nil {
+			return err
+		}
+
+		if len(changes) == 0 {
+			changes = append(changes, "prices refreshed")
+		}
+		note := strings.Join(changes, "; ") + fmt.Sprintf("; total %.2f -> %.2f %s", oldTotal, order.TotalAmount, cur.Code)
+		if n := strings.TrimSpace(in.Note); n != "" {
+			note = n + " (" + note + ")"
+		}
+		return RecordOrderHistory(tx, order.ID, "event", "", "items_edited", AdminActor(in.AdminID), note)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"fanuc-backend/config"
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetOrCreateOrderExpirySetting returns the single-row settings (ID=1), creating defaults if needed.
+func GetOrCreateOrderExpirySetting(db *gorm.DB) (*models.OrderExpirySetting, error) {
+	var s models.OrderExpirySetting
+	err := db.First(&s, 1).Error
+	if err == nil {
+		return &s, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	s = models.OrderExpirySetting{
+		ID:                           1,
+		Enabled:                      true,
+		ExpireAfterHours:             72,
+		BankTransferExpireAfterHours: 168,
+		ReminderEnabled:              true,
+		ReminderBeforeHours:          24,
+	}
+	if e := db.Create(&s).Error; e != nil {
+		return nil, e
+	}
+	return &s, nil
+}
+
+// OrderExpiryResult summarizes one expiry pass.
+type OrderExpiryResult struct {
+	Reminded  int `json:"reminded"`
+	Cancelled int `json:"cancelled"`
+}
+
+// unpaidPendingOrders selects pending orders whose payment is in one of paymentStatuses
+// (pending or failed when none are given).
+func unpaidPendingOrders(db *gorm.DB, paymentStatuses ...string) *gorm.DB {
+	if len(paymentStatuses) == 0 {
+		paymentStatuses = []string{models.PaymentStatusPending, models.PaymentStatusFailed}
+	}
+	return db.Model(&models.Order{}).
+		Where("status = ? AND payment_status IN ?", models.OrderStatusPending, paymentStatuses)
+}
+
+// RunOrderExpiry sends due payment reminders and cancels unpaid orders past the expiry window:
+// ExpireAfterHours for online payments, BankTransferExpireAfterHours for orders awaiting a transfer.
+func RunOrderExpiry(db *gorm.DB, s *models.OrderExpirySetting, siteURL string) (*OrderExpiryResult, error) {
+	if db == nil || s == nil {
+		return nil, errors.New("invalid arguments")
+	}
+	out := &OrderExpiryResult{}
+	if err := expireUnpaidOrders(db, s, siteURL, s.ExpireAfterHours, out,
+		models.PaymentStatusPending, models.PaymentStatusFailed); err != nil {
+		return out, err
+	}
+	err := expireUnpaidOrders(db, s, siteURL, s.BankTransferExpireAfterHours, out, models.PaymentStatusAwaitingPayment)
+	return out, err
+}
+
+// expireUnpaidOrders reminds and cancels pending orders in paymentStatuses placed more than
+// hours ago (nothing when hours is 0).
+func expireUnpaidOrders(db *gorm.DB, s *models.OrderExpirySetting, siteURL string, hours int, out *OrderExpiryResult, paymentStatuses ...string) error {
+	if hours <= 0 {
+		return nil
+	}
+	window := time.Duration(hours) * time.Hour
+	now := time.Now()
+
+	if s.ReminderEnabled && s.ReminderBeforeHours > 0 && s.ReminderBeforeHours < hours {
+		remindAt := now.Add(-(window - time.Duration(s.ReminderBeforeHours)*time.Hour))
+		var due []models.Order
+		if err := unpaidPendingOrders(db, paymentStatuses...).
+			Where("created_at < ? AND created_at >= ? AND payment_reminder_sent_at IS NULL", remindAt, now.Add(-window)).
+			Preload("Items").Order("id ASC").Find(&due).Error; err != nil {
+			return err
+		}
+		for i := range due {
+			if err := sendPaymentReminder(db, siteURL, &due[i], due[i].CreatedAt.Add(window)); err != nil {
+				log.Printf("order expiry: reminder for %s failed: %v", due[i].OrderNumber, err)
+				continue
+			}
+			out.Reminded++
+		}
+	}
+
+	var expired []models.Order
+	if err := unpaidPendingOrders(db, paymentStatuses...).Where("created_at < ?", now.Add(-window)).
+		Order("id ASC").Find(&expired).Error; err != nil {
+		return err
+	}
+	note := fmt.Sprintf("unpaid for %d hours, cancelled automatically", hours)
+	for i := range expired {
+		order := expired[i]
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Re-check under a row lock: a payment may have landed since the query, and one
+			// arriving now waits for this transaction (MarkOrderPaid locks the same row).
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, order.ID).Error; err != nil {
+				return err
+			}
+			if order.Status != models.OrderStatusPending || !slices.Contains(paymentStatuses, order.PaymentStatus) {
+				return nil
+			}
+			return TransitionOrderStatus(tx, &order, models.OrderStatusCancelled, ActorSystem, note)
+		})
+		if err != nil {
+			log.Printf("order expiry: cancel %s failed: %v", order.OrderNumber, err)
+			continue
+		}
+		if order.Status == models.OrderStatusCancelled {
+			out.Cancelled++
+		}
+	}
+	return nil
+}
+
+// sendsPaymentReminders reports whether RunOrderExpiry emails unpaid orders before cancelling them.
+func sendsPaymentReminders(s *models.OrderExpirySetting) bool {
+	return s.Enabled && s.ReminderEnabled && s.ReminderBeforeHours > 0
+}
+
+func sendPaymentReminder(db *gorm.DB, siteURL string, order *models.Order, expiresAt time.Time) error {
+	if strings.TrimSpace(order.CustomerEmail) == "" {
+		return errors.New("order has no customer email")
+	}
+	subj, txt, html := BuildPaymentReminderEmail(siteURL, *order, expiresAt)
+	if err := SendEmail(db, EmailSendOptions{
+		To:      order.CustomerEmail,
+		Subject: subj,
+		Text:    txt,
+		HTML:    html,
+		Headers: map[string]string{"X-Entity-Ref-ID": "payment-reminder:" + order.OrderNumber},
+	}); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Update("payment_reminder_sent_at", &now).Error; err != nil {
+			return err
+		}
+		order.PaymentReminderSentAt = &now
+		return RecordOrderHistory(tx, order.ID, "event", order.Status, "payment_reminder_sent", ActorSystem,
+			"payment reminder sent to "+order.CustomerEmail)
+	})
+}
+
+// BuildPaymentReminderEmail asks the customer to complete payment before the order is cancelled.
+func BuildPaymentReminderEmail(siteURL string, order models.Order, expiresAt time.Time) (subject, text, html string) {
+	orderNo := orderDisplayNumber(order)
+	currency := fallbackStr(order.Currency, "USD")
+	total := fmt.Sprintf("%.2f %s", order.TotalAmount, currency)
+	deadline := expiresAt.UTC().Format("2006-01-02 15:04 UTC")
+	link := orderTrackURL(siteURL, order)
+
+	subject = fmt.Sprintf("Complete your payment for order %s", orderNo)
+
+	body := fmt.Sprintf("We have not yet received payment for your order %s (%s).\n", orderNo, total)
+	body += fmt.Sprintf("\nThe items are reserved for you until %s; after that the order will be cancelled automatically.\n", deadline)
+	if items := emailItemsText(order.Items, nil); items != "" {
+		body += "\nItems:\n" + items + "\n"
+	}
+	body += optionalLine("Complete your payment", link)
+	text = customerEmailText(body)
+
+	inner := fmt.Sprintf("<p style=\"margin:0 0 10px 0\">We have not yet received payment for your order <b>%s</b>.</p>", escapeHTML(orderNo)) +
+		emailRowsHTML(
+			emailRowHTML("Order", orderNo),
+			emailRowHTML("Total", total),
+			emailRowHTML("Reserved until", deadline),
+		) +
+		emailItemsTableHTML("Items", order.Items, nil) +
+		"<p style=\"margin:14px 0 0 0;font-size:13px;color:#374151\">After this time the order will be cancelled automatically and the items released.</p>" +
+		emailButtonHTML("Complete payment", link)
+	html = customerEmailHTML("Payment reminder", inner)
+	return subject, text, html
+}
+
+// StartOrderExpiryScheduler runs a background goroutine that reminds and cancels unpaid
+// orders based on the order expiry settings. Checks every 15 minutes.
+func StartOrderExpiryScheduler() {
+	db := config.GetDB()
+	if db == nil {
+		return
+	}
+
+	go func() {
+		t := time.NewTicker(15 * time.Minute)
+		defer t.Stop()
+
+		for range t.C {
+			s, err := GetOrCreateOrderExpirySetting(db)
+			if err != nil {
+				log.Printf("order expiry: settings load failed: %v", err)
+				continue
+			}
+			if !s.Enabled {
+				continue
+			}
+
+			res, err := RunOrderExpiry(db, s, os.Getenv("SITE_URL"))
+			if err != nil {
+				log.Printf("order expiry: run failed: %v", err)
+				continue
+			}
+
+			now := time.Now().UTC()
+			_ = db.Model(&models.OrderExpirySetting{}).Where("id = ?", 1).Update("last_run_at", &now).Error
+			if res.Reminded > 0 || res.Cancelled > 0 {
+				log.Printf("order expiry: sent %d reminders, cancelled %d orders", res.Reminded, res.Cancelled)
+			}
+		}
+	}()
+}
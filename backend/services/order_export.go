@@ -0,0 +1,246 @@
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"fanuc-backend/models"
+
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// OrderExportFilter selects the orders of an accounting export. Zero values do not filter.
+type OrderExportFilter struct {
+	DateFrom      *time.Time // created_at >= DateFrom
+	DateTo        *time.Time // created_at <= DateTo
+	Status        string
+	PaymentStatus string
+	Country       string // ISO 3166-1 alpha-2 shipping country
+}
+
+// Export sheets. CSV holds one sheet per file, so the caller picks which.
+const (
+	OrderExportSheetOrders = "orders"
+	OrderExportSheetItems  = "items"
+)
+
+const orderExportBatchSize = 200
+
+const exportTimeLayout = "2006-01-02 15:04:05"
+
+var orderExportHeaders = []string{
+	"Order number", "Created at (UTC)", "Status", "Payment status", "Fulfillment status", "Payment method",
+	"Customer name", "Customer email", "Shipping country", "VAT number", "Reverse charge", "Currency",
+	"Subtotal", "Discount", "Coupon code", "Shipping fee", "Tax", "Tax inclusive", "Total", "Refunded",
+	"Exchange rate", "Base currency", "Base total", "Payment reference",
+	"Payment transaction IDs", "Refund transaction IDs", "Shipping carrier", "Tracking number", "Shipped at (UTC)",
+}
+
+var orderItemExportHeaders = []string{
+	"Order number", "Created at (UTC)", "Currency", "SKU", "Product name",
+	"Quantity", "Unit price", "Line total", "Base unit price", "Refunded quantity", "Shipped quantity",
+}
+
+func exportTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(exportTimeLayout)
+}
+
+func exportBool(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// orderExportRow renders one order. Transaction IDs are split into money in (completed
+// payments) and money out (refunds/reversals) so they can be matched to bank statements.
+func orderExportRow(o models.Order) []interface{} {
+	var payments, refunds []string
+	for _, t := range o.Transactions {
+		switch {
+		case t.Amount < 0:
+			refunds = append(refunds, t.TransactionID)
+		case t.Status == "completed":
+			payments = append(payments, t.TransactionID)
+		}
+	}
+	created := o.CreatedAt
+	return []interface{}{
+		o.OrderNumber, exportTime(&created), o.Status, o.PaymentStatus, o.FulfillmentStatus, o.PaymentMethod,
+		o.CustomerName, o.CustomerEmail, o.ShippingCountry, o.VATNumber, exportBool(o.ReverseCharge), fallbackStr(o.Currency, BaseCurrency),
+		round2(o.SubtotalAmount), round2(o.DiscountAmount), o.CouponCode, round2(o.ShippingFee), round2(o.TaxAmount), exportBool(o.TaxInclusive), round2(o.TotalAmount), round2(o.RefundedAmount),
+		o.ExchangeRate, fallbackStr(o.BaseCurrency, BaseCurrency), round2(o.BaseTotalAmount), o.PaymentReference,
+		strings.Join(payments, "; "), strings.Join(refunds, "; "), o.ShippingCarrier, o.TrackingNumber, exportTime(o.ShippedAt),
+	}
+}
+
+func orderItemExportRows(o models.Order) [][]interface{} {
+	created := o.CreatedAt
+	rows := make([][]interface{}, 0, len(o.Items))
+	for _, it := range o.Items {
+		rows = append(rows, []interface{}{
+			o.OrderNumber, exportTime(&created), fallbackStr(o.Currency, BaseCurrency), it.DisplaySKU(), it.DisplayName(),
+			it.Quantity, round2(it.UnitPrice), round2(it.TotalPrice), round2(it.BaseUnitPrice), it.RefundedQuantity, it.ShippedQuantity,
+		})
+	}
+	return rows
+}
+
+// orderExportQuery applies the filter; batches walk it in ID order.
+func orderExportQuery(db *gorm.DB, f OrderExportFilter) *gorm.DB {
+	q := db.Model(&models.Order{})
+	if f.DateFrom != nil {
+		q = q.Where("created_at >= ?", *f.DateFrom)
+	}
+	if f.DateTo != nil {
+		q = q.Where("created_at <= ?", *f.DateTo)
+	}
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.PaymentStatus != "" {
+		q = q.Where("payment_status = ?", f.PaymentStatus)
+	}
+	if c := NormalizeCountryCode(f.Country); c != "" {
+		q = q.Where("shipping_country = ?", c)
+	}
+	return q
+}
+
+// eachExportedOrder loads matching orders in batches (with items and transactions) and calls fn per order.
+func eachExportedOrder(db *gorm.DB, f OrderExportFilter, fn func(models.Order) error) error {
+	var batch []models.Order
+	res := orderExportQuery(db, f).
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Transactions", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		FindInBatches(&batch, orderExportBatchSize, func(_ *gorm.DB, _ int) error {
+			for _, o := range batch {
+				if err := fn(o); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	return res.Error
+}
+
+// WriteOrdersCSV streams one export sheet (orders or items) as CSV. A UTF-8 BOM is written
+// first so Excel opens names with accents correctly.
+func WriteOrdersCSV(db *gorm.DB, w io.Writer, f OrderExportFilter, sheet string) error {
+	if _, err := io.WriteString(w, "\xef\xbb\xbf"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	headers := orderExportHeaders
+	if sheet == OrderExportSheetItems {
+		headers = orderItemExportHeaders
+	}
+	if err := cw.Write(headers); err != nil {
+		return err
+	}
+	writeRow := func(row []interface{}) error {
+		rec := make([]string, len(row))
+		for i, v := range row {
+			if s, ok := v.(string); ok {
+				rec[i] = csvText(s)
+				continue
+			}
+			rec[i] = fmt.Sprint(v)
+		}
+		return cw.Write(rec)
+	}
+	err := eachExportedOrder(db, f, func(o models.Order) error {
+		if sheet == OrderExportSheetItems {
+			for _, row := range orderItemExportRows(o) {
+				if err := writeRow(row); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return writeRow(orderExportRow(o))
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvText keeps Excel from evaluating text cells (customer names, emails, VAT numbers,
+// product names) as formulas by prefixing those that start like one with a quote.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// WriteOrdersXLSX streams a workbook with an "Orders" and an "Items" sheet. Rows go through
+// excelize stream writers, so large exports are spooled to disk instead of held in memory.
+func WriteOrdersXLSX(db *gorm.DB, w io.Writer, f OrderExportFilter) error {
+	x := excelize.NewFile()
+	defer x.Close()
+	if err := x.SetSheetName("Sheet1", "Orders"); err != nil {
+		return err
+	}
+	if _, err := x.NewSheet("Items"); err != nil {
+		return err
+	}
+	headerStyle, _ := x.NewStyle(&excelize.Style{
+		Font:   &excelize.Font{Bold: true, Color: "#111827"},
+		Fill:   excelize.Fill{Type: "pattern", Color: []string{"#F3F4F6"}, Pattern: 1},
+		Border: []excelize.Border{{Type: "bottom", Color: "#E5E7EB", Style: 1}},
+	})
+
+	// A workbook takes one stream writer at a time: write Orders, flush, then walk the orders again for Items.
+	sheets := []struct {
+		name    string
+		headers []string
+		rows    func(models.Order) [][]interface{}
+	}{
+		{"Orders", orderExportHeaders, func(o models.Order) [][]interface{} { return [][]interface{}{orderExportRow(o)} }},
+		{"Items", orderItemExportHeaders, orderItemExportRows},
+	}
+	for _, s := range sheets {
+		sw, err := x.NewStreamWriter(s.name)
+		if err != nil {
+			return err
+		}
+		if err := sw.SetPanes(&excelize.Panes{Freeze: true, Split: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+			return err
+		}
+		header := make([]interface{}, len(s.headers))
+		for i, h := range s.headers {
+			header[i] = excelize.Cell{StyleID: headerStyle, Value: h}
+		}
+		if err := sw.SetRow("A1", header); err != nil {
+			return err
+		}
+		rowNum := 2
+		err = eachExportedOrder(db, f, func(o models.Order) error {
+			for _, row := range s.rows(o) {
+				cell, _ := excelize.CoordinatesToCellName(1, rowNum)
+				if err := sw.SetRow(cell, row); err != nil {
+					return err
+				}
+				rowNum++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if err := sw.Flush(); err != nil {
+			return err
+		}
+	}
+	return x.Write(w)
+}
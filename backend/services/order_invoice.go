@@ -0,0 +1,368 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InvoiceError is returned when a document cannot be issued for the order's current state.
+type InvoiceError struct {
+	Message string
+}
+
+func (e *InvoiceError) Error() string { return e.Message }
+
+// NextDocumentNumber increments and returns the named sequence. It must run inside a
+// transaction: the row lock keeps numbers gap-free and unique under concurrent requests.
+func NextDocumentNumber(tx *gorm.DB, name string) (int64, error) {
+	var seq models.DocumentSequence
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("name = ?", name).First(&seq).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		seq = models.DocumentSequence{Name: name}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&seq).Error; err != nil {
+			return 0, err
+		}
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("name = ?", name).First(&seq).Error
+	}
+	if err != nil {
+		return 0, err
+	}
+	seq.LastValue++
+	if err := tx.Model(&models.DocumentSequence{}).Where("id = ?", seq.ID).Update("last_value", seq.LastValue).Error; err != nil {
+		return 0, err
+	}
+	return seq.LastValue, nil
+}
+
+// DefaultInvoiceType is the document a customer would expect for the order right now.
+func DefaultInvoiceType(order models.Order) string {
+	if IsPaymentCaptured(order.PaymentStatus) || order.PaymentStatus == models.PaymentStatusRefunded {
+		return models.InvoiceTypeInvoice
+	}
+	return models.InvoiceTypeProforma
+}
+
+// IssueOrderInvoice returns the order's document of the given type, assigning the next
+// sequential number the first time it is requested.
+//   - proforma: any order that is not cancelled
+//   - invoice: orders whose payment was captured
+func IssueOrderInvoice(db *gorm.DB, order *models.Order, docType string) (*models.OrderInvoice, error) {
+	if db == nil || order == nil {
+		return nil, errors.New("invalid arguments")
+	}
+	var prefix string
+	switch docType {
+	case models.InvoiceTypeProforma:
+		prefix = "PF"
+	case models.InvoiceTypeInvoice:
+		prefix = "INV"
+	default:
+		return nil, &InvoiceError{Message: fmt.Sprintf("Unknown document type %q", docType)}
+	}
+
+	var inv models.OrderInvoice
+	err := db.Where("order_id = ? AND type = ?", order.ID, docType).First(&inv).Error
+	if err == nil {
+		return &inv, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	switch docType {
+	case models.InvoiceTypeProforma:
+		if order.Status == models.OrderStatusCancelled {
+			return nil, &InvoiceError{Message: "Order has been cancelled"}
+		}
+	case models.InvoiceTypeInvoice:
+		if !IsPaymentCaptured(order.PaymentStatus) {
+			return nil, &InvoiceError{Message: "An invoice is available once the order has been paid, download the proforma instead"}
+		}
+	}
+
+	now := time.Now()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		n, err := NextDocumentNumber(tx, fmt.Sprintf("%s-%d", docType, now.Year()))
+		if err != nil {
+			return err
+		}
+		inv = models.OrderInvoice{
+			OrderID:     order.ID,
+			Type:        docType,
+			Number:      fmt.Sprintf("%s-%d-%06d", prefix, now.Year(), n),
+			Currency:    fallbackStr(order.Currency, "USD"),
+			TotalAmount: round2(order.TotalAmount),
+			IssuedAt:    now,
+		}
+		return tx.Create(&inv).Error
+	})
+	if err != nil {
+		// Lost a race with a concurrent request for the same order: use its number.
+		var existing models.OrderInvoice
+		if e := db.Where("order_id = ? AND type = ?", order.ID, docType).First(&existing).Error; e == nil {
+			return &existing, nil
+		}
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// LoadInvoiceCompanyProfile returns the company profile, or an empty one when none is configured.
+func LoadInvoiceCompanyProfile(db *gorm.DB) *models.CompanyProfile {
+	var p models.CompanyProfile
+	if db == nil || db.First(&p).Error != nil {
+		return &models.CompanyProfile{CompanyName: "Vcocnc Spare Parts"}
+	}
+	return &p
+}
+
+// OrderInvoiceFilename is the download name for an issued document.
+func OrderInvoiceFilename(inv *models.OrderInvoice) string {
+	return strings.ToLower(inv.Type) + "-" + inv.Number + ".pdf"
+}
+
+const (
+	invoiceMarginX = 42.0
+	invoiceBottom  = pdfPageHeight - 60
+)
+
+// RenderOrderInvoicePDF draws a proforma or commercial invoice for the order.
+// order.Items must be loaded.
+func RenderOrderInvoicePDF(inv *models.OrderInvoice, order models.Order, company *models.CompanyProfile) ([]byte, error) {
+	if inv == nil || company == nil {
+		return nil, errors.New("invalid arguments")
+	}
+	d := newPDFDocument()
+	right := pdfPageWidth - invoiceMarginX
+	currency := fallbackStr(inv.Currency, fallbackStr(order.Currency, "USD"))
+	money := func(v float64) string { return fmt.Sprintf("%.2f %s", v, currency) }
+
+	title := "COMMERCIAL INVOICE"
+	if inv.Type == models.InvoiceTypeProforma {
+		title = "PROFORMA INVOICE"
+	}
+
+	// Header band
+	d.FillRect(0, 0, pdfPageWidth, 8, 0.961, 0.620, 0.043)
+
+	// Seller block (left)
+	y := 52.0
+	seller := fallbackStr(company.LegalName, company.CompanyName)
+	d.SetTextColor(0.067, 0.094, 0.153)
+	d.Text(invoiceMarginX, y, 16, true, seller)
+	y += 16
+	d.SetTextColor(0.29, 0.33, 0.39)
+	var sellerLines []string
+	if company.LegalName != "" && company.CompanyName != "" && company.CompanyName != company.LegalName {
+		sellerLines = append(sellerLines, company.CompanyName)
+	}
+	sellerLines = append(sellerLines, pdfWrapText(fallbackStr(company.Address, company.Location), 260, 9, false)...)
+	if company.Phone != "" {
+		sellerLines = append(sellerLines, "Tel: "+company.Phone)
+	}
+	if company.Email != "" {
+		sellerLines = append(sellerLines, "Email: "+company.Email)
+	}
+	if company.TaxID != "" {
+		sellerLines = append(sellerLines, "Tax ID: "+company.TaxID)
+	}
+	for _, l := range sellerLines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		d.Text(invoiceMarginX, y, 9, false, l)
+		y += 12
+	}
+
+	// Document block (right)
+	d.SetTextColor(0.067, 0.094, 0.153)
+	d.TextRight(right, 52, 18, true, title)
+	meta := [][2]string{
+		{"Number", inv.Number},
+		{"Date", inv.IssuedAt.Format("2006-01-02")},
+		{"Order", orderDisplayNumber(order)},
+		{"Order date", order.CreatedAt.Format("2006-01-02")},
+	}
+	if inv.Type == models.InvoiceTypeInvoice {
+		meta = append(meta, [2]string{"Payment", strings.TrimSpace(fallbackStr(order.PaymentMethod, "paid") + " " + order.PaymentID)})
+	}
+	my := 72.0
+	for _, m := range meta {
+		d.SetTextColor(0.42, 0.45, 0.50)
+		d.TextRight(right-130, my, 9, false, m[0])
+		d.SetTextColor(0.067, 0.094, 0.153)
+		d.TextRight(right, my, 9, true, m[1])
+		my += 13
+	}
+	if my > y {
+		y = my
+	}
+	y += 14
+
+	// Bill to / Ship to
+	colW := (right - invoiceMarginX - 20) / 2
+	billing := fallbackStr(strings.TrimSpace(order.BillingAddress), order.ShippingAddress)
+	billTo := append([]string{order.CustomerName}, pdfWrapText(billing, colW, 9, false)...)
+	billTo = append(billTo, order.CustomerEmail)
+	if order.VATNumber != "" {
+		billTo = append(billTo, "VAT: "+order.VATNumber)
+	}
+	if order.CustomerPhone != "" {
+		billTo = append(billTo, order.CustomerPhone)
+	}
+	shipTo := append([]string{order.CustomerName}, pdfWrapText(order.ShippingAddress, colW, 9, false)...)
+	if order.ShippingCountry != "" {
+		shipTo = append(shipTo, order.ShippingCountry)
+	}
+	d.SetTextColor(0.42, 0.45, 0.50)
+	d.Text(invoiceMarginX, y, 8, true, "BILL TO")
+	d.Text(invoiceMarginX+colW+20, y, 8, true, "SHIP TO")
+	y += 13
+	d.SetTextColor(0.067, 0.094, 0.153)
+	by, sy := y, y
+	for _, l := range billTo {
+		if strings.TrimSpace(l) != "" {
+			d.Text(invoiceMarginX, by, 9, false, l)
+			by += 12
+		}
+	}
+	for _, l := range shipTo {
+		if strings.TrimSpace(l) != "" {
+			d.Text(invoiceMarginX+colW+20, sy, 9, false, l)
+			sy += 12
+		}
+	}
+	if sy > by {
+		by = sy
+	}
+	y = by + 16
+
+	// Items table
+	cols := struct{ no, sku, desc, qty, unit, amount float64 }{
+		no: invoiceMarginX + 4, sku: invoiceMarginX + 26, desc: invoiceMarginX + 140,
+		qty: right - 170, unit: right - 90, amount: right - 4,
+	}
+	descW := cols.qty - 40 - cols.desc
+	tableHeader := func() {
+		d.FillRect(invoiceMarginX, y, right-invoiceMarginX, 18, 0.976, 0.980, 0.984)
+		d.SetTextColor(0.42, 0.45, 0.50)
+		d.Text(cols.no, y+12, 8, true, "#")
+		d.Text(cols.sku, y+12, 8, true, "PART NUMBER")
+		d.Text(cols.desc, y+12, 8, true, "DESCRIPTION")
+		d.TextRight(cols.qty, y+12, 8, true, "QTY")
+		d.TextRight(cols.unit, y+12, 8, true, "UNIT PRICE")
+		d.TextRight(cols.amount, y+12, 8, true, "AMOUNT")
+		d.SetTextColor(0.067, 0.094, 0.153)
+		y += 18
+	}
+	tableHeader()
+	for i, it := range order.Items {
+		desc := pdfWrapText(fallbackStr(it.DisplayName(), "Product"), descW, 9, false)
+		sku := pdfWrapText(fallbackStr(it.DisplaySKU(), fmt.Sprintf("PID-%d", it.ProductID)), cols.desc-cols.sku-8, 9, false)
+		rows := len(desc)
+		if len(sku) > rows {
+			rows = len(sku)
+		}
+		h := float64(rows)*11 + 8
+		if y+h > invoiceBottom {
+			d.AddPage()
+			y = 48
+			tableHeader()
+		}
+		d.Text(cols.no, y+12, 9, false, fmt.Sprintf("%d", i+1))
+		for j, l := range sku {
+			d.Text(cols.sku, y+12+float64(j)*11, 9, false, l)
+		}
+		for j, l := range desc {
+			d.Text(cols.desc, y+12+float64(j)*11, 9, false, l)
+		}
+		d.TextRight(cols.qty, y+12, 9, false, fmt.Sprintf("%d", it.Quantity))
+		d.TextRight(cols.unit, y+12, 9, false, fmt.Sprintf("%.2f", it.UnitPrice))
+		d.TextRight(cols.amount, y+12, 9, false, fmt.Sprintf("%.2f", it.TotalPrice))
+		y += h
+		d.Line(invoiceMarginX, y, right, y, 0.5, 0.85)
+	}
+
+	// Totals
+	totals := [][2]string{{"Subtotal", money(order.SubtotalAmount)}}
+	if order.DiscountAmount > 0 {
+		label := "Discount"
+		if order.CouponCode != "" {
+			label += " (" + order.CouponCode + ")"
+		}
+		totals = append(totals, [2]string{label, "-" + money(order.DiscountAmount)})
+	}
+	totals = append(totals, [2]string{"Shipping", money(order.ShippingFee)})
+	for _, tl := range order.TaxLines {
+		totals = append(totals, [2]string{tl.Name, money(tl.TaxAmount)})
+	}
+	if y+float64(len(totals)+2)*16 > invoiceBottom {
+		d.AddPage()
+		y = 48
+	}
+	y += 18
+	for _, t := range totals {
+		d.SetTextColor(0.29, 0.33, 0.39)
+		d.TextRight(right-110, y, 9, false, t[0])
+		d.SetTextColor(0.067, 0.094, 0.153)
+		d.TextRight(right, y, 9, false, t[1])
+		y += 14
+	}
+	d.Line(right-230, y-6, right, y-6, 0.8, 0.2)
+	y += 8
+	totalLabel := "Total"
+	if inv.Type == models.InvoiceTypeProforma {
+		totalLabel = "Amount due"
+	}
+	d.TextRight(right-110, y, 11, true, totalLabel)
+	d.TextRight(right, y, 11, true, money(order.TotalAmount))
+	y += 16
+	if inv.Type == models.InvoiceTypeInvoice && order.RefundedAmount > 0 {
+		d.SetTextColor(0.29, 0.33, 0.39)
+		d.TextRight(right-110, y, 9, false, "Refunded")
+		d.TextRight(right, y, 9, false, "-"+money(order.RefundedAmount))
+		y += 14
+	}
+	y += 16
+
+	// Payment instructions / notes
+	var notes []string
+	if order.ReverseCharge {
+		notes = append(notes, ReverseChargeNote, "")
+	}
+	if inv.Type == models.InvoiceTypeProforma {
+		notes = append(notes, "This proforma invoice is issued for payment purposes. Goods are shipped once payment has been received.")
+		if strings.TrimSpace(company.BankDetails) != "" {
+			notes = append(notes, "", "Bank details:")
+			notes = append(notes, strings.Split(strings.TrimSpace(company.BankDetails), "\n")...)
+			notes = append(notes, fmt.Sprintf("Please quote %s as payment reference.", inv.Number))
+		}
+	} else {
+		notes = append(notes, "Thank you for your business. This invoice confirms payment has been received.")
+	}
+	if strings.TrimSpace(company.InvoiceNotes) != "" {
+		notes = append(notes, "")
+		notes = append(notes, strings.Split(strings.TrimSpace(company.InvoiceNotes), "\n")...)
+	}
+	d.SetTextColor(0.29, 0.33, 0.39)
+	for _, n := range notes {
+		for _, l := range pdfWrapText(n, right-invoiceMarginX, 9, false) {
+			if y > invoiceBottom {
+				d.AddPage()
+				y = 48
+				d.SetTextColor(0.29, 0.33, 0.39)
+			}
+			d.Text(invoiceMarginX, y, 9, false, l)
+			y += 12
+		}
+	}
+
+	return d.Bytes()
+}
@@ -72,7 +72,7 @@ func BuildOrderNotificationEmail(siteURL string, order models.Order, event strin
 	base := strings.TrimSpace(siteURL)
 	if base != "" {
 		base = strings.TrimRight(base, "/")
-		trackPage = base + "/orders/track/" + orderNo
+		trackPage = orderTrackURL(base, order)
 		adminOrderPage = base + "/admin/orders/" + fmt.Sprintf("%d", order.ID)
 	}
 
@@ -90,12 +90,8 @@ func BuildOrderNotificationEmail(siteURL string, order models.Order, event strin
 	// Items
 	itemLines := make([]string, 0)
 	for _, it := range order.Items {
-		sku := ""
-		name := ""
-		if it.Product != nil {
-			sku = it.Product.SKU
-			name = it.Product.Name
-		}
+		sku := it.DisplaySKU()
+		name := it.DisplayName()
 		if strings.TrimSpace(sku) == "" {
 			sku = fmt.Sprintf("PID-%d", it.ProductID)
 		}
@@ -105,13 +101,28 @@ func BuildOrderNotificationEmail(siteURL string, order models.Order, event strin
 		itemLines = append(itemLines, fmt.Sprintf("- %s | %s x%d | %s", sku, name, it.Quantity, formatMoney(it.TotalPrice)))
 	}
 
+	// Tax lines (and the buyer's VAT number for B2B orders)
+	taxText := ""
+	taxRows := ""
+	for _, tl := range order.TaxLines {
+		taxText += fmt.Sprintf("%s: %s\n", tl.Name, formatMoney(tl.TaxAmount))
+		taxRows += fmt.Sprintf("<tr><td style=\"width:180px;color:#6b7280;font-size:13px\">%s</td><td style=\"font-size:14px\">%s</td></tr>", escapeHTML(tl.Name), escapeHTML(formatMoney(tl.TaxAmount)))
+	}
+	if order.VATNumber != "" {
+		taxText += fmt.Sprintf("VAT number: %s\n", order.VATNumber)
+		taxRows += fmt.Sprintf("<tr><td style=\"width:180px;color:#6b7280;font-size:13px\">VAT number</td><td style=\"font-size:14px\">%s</td></tr>", escapeHTML(order.VATNumber))
+	}
+	if order.ReverseCharge {
+		taxText += ReverseChargeNote + "\n"
+	}
+
 	itemsText := ""
 	if len(itemLines) > 0 {
 		itemsText = "\nItems:\n" + strings.Join(itemLines, "\n") + "\n"
 	}
 
 	text = fmt.Sprintf(
-		"Order notification (%s)\n\nOrder: %s\nCreated at: %s\nPayment status: %s\nPayment method: %s\nTotal: %s\nSubtotal: %s\nDiscount: %s\n\nCustomer: %s\nEmail: %s\nPhone: %s\n\nShipping address:\n%s\n\nBilling address:\n%s\n\nNotes:\n%s\n%s\nAdmin: %s\nTrack: %s\n",
+		"Order notification (%s)\n\nOrder: %s\nCreated at: %s\nPayment status: %s\nPayment method: %s\nTotal: %s\nSubtotal: %s\nDiscount: %s\nShipping: %s\n%s\nCustomer: %s\nEmail: %s\nPhone: %s\n\nShipping address:\n%s\n\nBilling address:\n%s\n\nNotes:\n%s\n%s\nAdmin: %s\nTrack: %s\n",
 		eventTitle,
 		orderNo,
 		fallbackStr(createdAt, "-"),
@@ -120,6 +131,8 @@ func BuildOrderNotificationEmail(siteURL string, order models.Order, event strin
 		formatMoney(order.TotalAmount),
 		formatMoney(order.SubtotalAmount),
 		formatMoney(order.DiscountAmount),
+		formatMoney(order.ShippingFee),
+		taxText,
 		fallbackStr(strings.TrimSpace(order.CustomerName), "-"),
 		fallbackStr(strings.TrimSpace(order.CustomerEmail), "-"),
 		fallbackStr(strings.TrimSpace(order.CustomerPhone), "-"),
@@ -145,12 +158,8 @@ func BuildOrderNotificationEmail(siteURL string, order models.Order, event strin
 	if len(order.Items) > 0 {
 		rows := make([]string, 0, len(order.Items))
 		for _, it := range order.Items {
-			sku := ""
-			name := ""
-			if it.Product != nil {
-				sku = it.Product.SKU
-				name = it.Product.Name
-			}
+			sku := it.DisplaySKU()
+			name := it.DisplayName()
 			if strings.TrimSpace(sku) == "" {
 				sku = fmt.Sprintf("PID-%d", it.ProductID)
 			}
@@ -211,6 +220,8 @@ func BuildOrderNotificationEmail(siteURL string, order models.Order, event strin
 		fmt.Sprintf("<tr><td style=\"width:180px;color:#6b7280;font-size:13px\">Total</td><td style=\"font-size:14px;font-weight:800\">%s</td></tr>", escapeHTML(formatMoney(order.TotalAmount))) +
 		fmt.Sprintf("<tr><td style=\"width:180px;color:#6b7280;font-size:13px\">Subtotal</td><td style=\"font-size:14px\">%s</td></tr>", escapeHTML(formatMoney(order.SubtotalAmount))) +
 		fmt.Sprintf("<tr><td style=\"width:180px;color:#6b7280;font-size:13px\">Discount</td><td style=\"font-size:14px\">%s</td></tr>", escapeHTML(formatMoney(order.DiscountAmount))) +
+		fmt.Sprintf("<tr><td style=\"width:180px;color:#6b7280;font-size:13px\">Shipping</td><td style=\"font-size:14px\">%s</td></tr>", escapeHTML(formatMoney(order.ShippingFee))) +
+		taxRows +
 		"</table>" +
 		"<div style=\"margin-top:14px\">" +
 		"<div style=\"font-family:Arial,Helvetica,sans-serif;font-size:13px;font-weight:800;color:#111827;margin:0 0 8px 0\">Customer</div>" +
@@ -288,7 +299,7 @@ func notifyAdminOrderEvent(db *gorm.DB, siteURL string, orderID uint, event stri
 	}
 
 	var order models.Order
-	if err := db.Preload("Items.Product").First(&order, orderID).Error; err != nil {
+	if err := db.Preload("Items.Product").Preload("TaxLines").First(&order, orderID).Error; err != nil {
 		return err
 	}
 
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// MarkOrderPaid records the payment method/ID and moves the order to paid (which deducts stock
+// and confirms a pending order). It must run inside the same DB transaction that records the
+// PaymentTransaction.
+//
+// A capture that completes after the order was cancelled (e.g. by unpaid order expiry) is
+// kept rather than failing the transaction: the order stays cancelled and is flagged
+// RefundDue so the money can be returned. Callers check order.RefundDue afterwards.
+func MarkOrderPaid(tx *gorm.DB, order *models.Order, actor StatusActor, note string) error {
+	if tx == nil || order == nil {
+		return errors.New("invalid arguments")
+	}
+	if err := lockOrderState(tx, order); err != nil {
+		return err
+	}
+	updates := map[string]interface{}{
+		"payment_method": order.PaymentMethod,
+		"payment_id":     order.PaymentID,
+	}
+	if order.Status == models.OrderStatusCancelled {
+		updates["refund_due"] = true
+		if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		order.RefundDue = true
+		return RecordOrderHistory(tx, order.ID, "event", order.PaymentStatus, "payment_on_cancelled_order", actor,
+			strings.TrimSpace(note+"; order was already cancelled, refund due"))
+	}
+	if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Updates(updates).Error; err != nil {
+		return err
+	}
+	return TransitionPaymentStatus(tx, order, models.PaymentStatusPaid, actor, note)
+}
+
+// OrderPaymentTotals sums money received and returned for an order from its transactions.
+func OrderPaymentTotals(db *gorm.DB, orderID uint) (paid float64, refunded float64, err error) {
+	var txs []models.PaymentTransaction
+	if err = db.Where("order_id = ?", orderID).Find(&txs).Error; err != nil {
+		return 0, 0, err
+	}
+	for _, t := range txs {
+		switch {
+		case t.Amount > 0 && t.Status == "completed":
+			paid += t.Amount
+		case t.Amount < 0 && (t.Status == "refunded" || t.Status == "reversed"):
+			refunded += -t.Amount
+		}
+	}
+	return round2(paid), round2(refunded), nil
+}
+
+// RefreshOrderRefundStatus recomputes refunded_amount and moves payment_status to
+// refunded / partially_refunded from the transaction ledger.
+func RefreshOrderRefundStatus(tx *gorm.DB, order *models.Order, actor StatusActor, note string) error {
+	paid, refunded, err := OrderPaymentTotals(tx, order.ID)
+	if err != nil {
+		return err
+	}
+	order.RefundedAmount = refunded
+	if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Update("refunded_amount", refunded).Error; err != nil {
+		return err
+	}
+	if refunded <= 0 {
+		return nil
+	}
+	if order.RefundDue {
+		// Captured after cancellation: the payment status never became paid, so only the flag moves.
+		if refunded < paid-0.005 {
+			return nil
+		}
+		if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Update("refund_due", false).Error; err != nil {
+			return err
+		}
+		order.RefundDue = false
+		return RecordOrderHistory(tx, order.ID, "event", order.PaymentStatus, "refund_due_settled", actor, note)
+	}
+	if paid <= 0 {
+		// Orders paid before the ledger was reliable: fall back to the order total.
+		paid = round2(order.TotalAmount)
+	}
+	status := models.PaymentStatusPartiallyRefunded
+	if refunded >= paid-0.005 {
+		status = models.PaymentStatusRefunded
+	}
+	if status == models.PaymentStatusPartiallyRefunded && order.PaymentStatus == models.PaymentStatusPartiallyPaid {
+		// Part of a partial wire returned: the order was never paid, refunded_amount records it.
+		return RecordOrderHistory(tx, order.ID, "event", order.PaymentStatus, "partial_refund", actor, note)
+	}
+	return TransitionPaymentStatus(tx, order, status, actor, note)
+}
+
+// ReleaseCouponUsage removes the coupon usage recorded for an order and gives the use back
+// to the coupon. It returns how many usages were released.
+func ReleaseCouponUsage(tx *gorm.DB, orderID uint) (int, error) {
+	var usages []models.CouponUsage
+	if err := tx.Where("order_id = ?", orderID).Find(&usages).Error; err != nil {
+		return 0, err
+	}
+	for _, u := range usages {
+		if err := tx.Delete(&models.CouponUsage{}, u.ID).Error; err != nil {
+			return 0, err
+		}
+		if err := tx.Model(&models.Coupon{}).Where("id = ? AND used_count > 0", u.CouponID).
+			UpdateColumn("used_count", gorm.Expr("used_count - 1")).Error; err != nil {
+			return 0, err
+		}
+	}
+	return len(usages), nil
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// priceTolerance is the largest difference between the client-displayed unit price
+// and the server price that is still treated as "the same price" (rounding noise).
+const priceTolerance = 0.005
+
+// OrderLineInput is a requested order line before server-side pricing.
+// ClientUnitPrice is what the shopper saw; it is never used as the charged price.
+type OrderLineInput struct {
+	ProductID       uint
+	Quantity        int
+	ClientUnitPrice *float64
+}
+
+// PriceMismatch describes a line whose client-displayed price differs from the server price.
+type PriceMismatch struct {
+	ProductID       uint    `json:"product_id"`
+	SKU             string  `json:"sku"`
+	Name            string  `json:"name"`
+	ClientUnitPrice float64 `json:"client_unit_price"`
+	UnitPrice       float64 `json:"unit_price"`
+}
+
+// PricedOrderLines is the server-authoritative pricing result for a set of lines.
+// Item prices and Subtotal are in Currency; BaseSubtotal is the same in the base currency.
+type PricedOrderLines struct {
+	Items        []models.OrderItem
+	Products     map[uint]models.Product
+	Currency     models.Currency
+	Subtotal     float64
+	BaseSubtotal float64
+	Parcel       ShippingParcel // weight and packed volume of all lines, for shipping quotes
+	Mismatches   []PriceMismatch
+}
+
+// OrderLineError is returned when a requested line cannot be sold (missing, inactive, unpriced, no stock).
+type OrderLineError struct {
+	ProductID uint
+	Message   string
+}
+
+func (e *OrderLineError) Error() string { return e.Message }
+
+// ProductUnitPrice returns the price a product is sold at.
+// Price is the selling (sale) price; ComparePrice is only the struck-through "was" price
+// shown in the storefront and is never charged. Products without a positive price are
+// "price on request" and cannot be ordered online.
+func ProductUnitPrice(p *models.Product) (float64, error) {
+	if p == nil {
+		return 0, errors.New("product is nil")
+	}
+	price := round2(p.Price)
+	if price <= 0 {
+		return 0, fmt.Errorf("product %s has no online price", p.SKU)
+	}
+	return price, nil
+}
+
+// PriceOrderLines loads every product, validates availability and builds OrderItems with
+// server-side prices and a snapshot of the product name/SKU. Prices are converted into cur
+// (nil means the base currency); client prices are compared in that currency.
+func PriceOrderLines(db *gorm.DB, lines []OrderLineInput, cur *models.Currency) (*PricedOrderLines, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("no order lines")
+	}
+
+	out := &PricedOrderLines{Products: map[uint]models.Product{}, Currency: BaseCurrencyRecord()}
+	if cur != nil {
+		out.Currency = *cur
+	}
+	for _, line := range lines {
+		if line.Quantity <= 0 {
+			return nil, &OrderLineError{ProductID: line.ProductID, Message: fmt.Sprintf("Invalid quantity for product ID %d", line.ProductID)}
+		}
+
+		product, ok := out.Products[line.ProductID]
+		if !ok {
+			if err := db.First(&product, line.ProductID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, &OrderLineError{ProductID: line.ProductID, Message: fmt.Sprintf("Product with ID %d not found", line.ProductID)}
+				}
+				return nil, err
+			}
+			out.Products[line.ProductID] = product
+		}
+
+		if !product.IsActive {
+			return nil, &OrderLineError{ProductID: product.ID, Message: fmt.Sprintf("Product %s is no longer available", product.Name)}
+		}
+		// Advisory only: the authoritative check happens under row lock in ReserveOrderStock.
+		if product.AvailableQuantity() < line.Quantity && !product.AllowsBackorder() {
+			return nil, &OrderLineError{ProductID: product.ID, Message: fmt.Sprintf("Insufficient stock for product %s", product.Name)}
+		}
+		basePrice, err := ProductUnitPrice(&product)
+		if err != nil {
+			return nil, &OrderLineError{ProductID: product.ID, Message: fmt.Sprintf("Product %s is not available for online purchase, please request a quote", product.Name)}
+		}
+		unitPrice := ConvertFromBase(basePrice, &out.Currency)
+
+		if line.ClientUnitPrice != nil && *line.ClientUnitPrice > 0 && math.Abs(*line.ClientUnitPrice-unitPrice) > priceTolerance {
+			out.Mismatches = append(out.Mismatches, PriceMismatch{
+				ProductID:       product.ID,
+				SKU:             product.SKU,
+				Name:            product.Name,
+				ClientUnitPrice: *line.ClientUnitPrice,
+				UnitPrice:       unitPrice,
+			})
+		}
+
+		lineTotal := roundCurrency(unitPrice*float64(line.Quantity), out.Currency.Decimals)
+		out.Subtotal += lineTotal
+		out.BaseSubtotal += basePrice * float64(line.Quantity)
+		out.Parcel.Add(&product, line.Quantity)
+
+		out.Items = append(out.Items, models.OrderItem{
+			ProductID:     product.ID,
+			ProductSKU:    product.SKU,
+			ProductName:   product.Name,
+			Quantity:      line.Quantity,
+			UnitPrice:     unitPrice,
+			TotalPrice:    lineTotal,
+			BaseUnitPrice: basePrice,
+		})
+	}
+	out.Subtotal = roundCurrency(out.Subtotal, out.Currency.Decimals)
+	out.BaseSubtotal = round2(out.BaseSubtotal)
+	return out, nil
+}
+
+// OrderShippingFee quotes the shipping fee of an order in the base currency. Free-shipping
+// countries cost nothing; otherwise the default template is used, falling back to the first
+// active carrier template for the country (FEDEX, then DHL). A *CurrencyError means the
+// template's currency has no exchange rate.
+func OrderShippingFee(db *gorm.DB, countryCode string, parcel ShippingParcel) (float64, error) {
+	cc := NormalizeCountryCode(countryCode)
+	if IsFreeShippingCountry(db, cc) || parcel.ChargeableWeightKg(DefaultVolumetricDivisor) <= 0 {
+		return 0, nil
+	}
+	quote, err := CalculateShippingQuote(db, cc, parcel)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		type pair struct {
+			Carrier     string
+			ServiceCode string
+		}
+		var pairs []pair
+		qe := db.Model(&models.ShippingCarrierTemplate{}).
+			Select("carrier, service_code").
+			Where("country_code = ? AND is_active = ?", cc, true).
+			Group("carrier, service_code").
+			Order("CASE WHEN carrier = 'FEDEX' THEN 0 WHEN carrier = 'DHL' THEN 1 ELSE 9 END, carrier ASC, service_code ASC").
+			Scan(&pairs).Error
+		if qe == nil && len(pairs) > 0 {
+			quote, err = CalculateCarrierShippingQuote(db, pairs[0].Carrier, pairs[0].ServiceCode, cc, parcel)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	// Templates may be priced in another currency; shipping is carried in base amounts.
+	if quote, err = ConvertShippingQuote(db, quote, nil); err != nil {
+		return 0, err
+	}
+	return quote.ShippingFee, nil
+}
@@ -0,0 +1,378 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RefundLineInput refunds a quantity of one order line.
+type RefundLineInput struct {
+	OrderItemID uint `json:"order_item_id"`
+	Quantity    int  `json:"quantity"`
+}
+
+// RefundInput describes an admin refund.
+//
+//   - Full refunds everything still refundable (all remaining lines + shipping).
+//   - Lines refunds specific quantities; the amount is their discounted line value
+//     (plus shipping when IncludeShipping) unless Amount overrides it.
+//   - Amount alone refunds an arbitrary sum without touching lines.
+type RefundInput struct {
+	Full            bool
+	Lines           []RefundLineInput
+	Amount          *float64
+	IncludeShipping bool
+	Restock         bool
+	ReverseCoupon   bool
+	Reason          string
+
+	// ViaProvider sends the money back through the payment provider (PayPal capture or Stripe charge refund).
+	// When false the refund is only recorded (e.g. paid back by bank transfer).
+	ViaProvider bool
+
+	ActorAdminID *uint
+}
+
+// RefundError is a validation error the admin can fix (bad amount/line/status).
+type RefundError struct {
+	Message string
+}
+
+func (e *RefundError) Error() string { return e.Message }
+
+// RefundResult is what CreateOrderRefund recorded.
+type RefundResult struct {
+	Transaction   models.PaymentTransaction `json:"transaction"`
+	Amount        float64                   `json:"amount"`
+	TaxAmount     float64                   `json:"tax_amount"` // tax included in Amount for line refunds of tax-exclusive orders
+	Lines         map[uint]int              `json:"lines"`      // order item ID -> refunded quantity
+	Restocked     bool                      `json:"restocked"`
+	CouponRelease int                       `json:"coupon_usages_released"`
+}
+
+// refundableStatuses are payment statuses that can still be refunded. Partially paid bank
+// transfers are refunded up to what was received (the balance comes from the ledger).
+var refundableStatuses = map[string]bool{
+	models.PaymentStatusPartiallyPaid:     true,
+	models.PaymentStatusPaid:              true,
+	models.PaymentStatusPartiallyRefunded: true,
+	models.PaymentStatusDisputed:          true,
+}
+
+// CreateOrderRefund validates and records a refund as a negative PaymentTransaction,
+// optionally refunding through the payment provider, restocking lines and reversing coupon usage.
+func CreateOrderRefund(ctx context.Context, db *gorm.DB, orderID uint, in RefundInput) (*RefundResult, *models.Order, error) {
+	if db == nil {
+		return nil, nil, errors.New("db is nil")
+	}
+
+	var (
+		order  models.Order
+		result *RefundResult
+		issued string // provider refund ID once the money has moved
+	)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = refundOrderLocked(ctx, tx, orderID, in, &order, &issued)
+		return err
+	})
+	if err != nil {
+		if issued != "" {
+			// Money already moved at the provider; the webhook will still record it.
+			return nil, nil, fmt.Errorf("refund %s was issued at %s but could not be recorded: %w", issued, PaymentMethodLabel(order.PaymentMethod), err)
+		}
+		return nil, nil, err
+	}
+
+	_ = db.Preload("Items").First(&order, order.ID).Error
+	return result, &order, nil
+}
+
+// refundOrderLocked does the work of CreateOrderRefund with the order row locked from the
+// balance check until the refund is recorded (provider call included), so concurrent refunds
+// of one order run one after the other and each sees the refunds recorded before it.
+func refundOrderLocked(ctx context.Context, tx *gorm.DB, orderID uint, in RefundInput, order *models.Order, issued *string) (*RefundResult, error) {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Order{}, orderID).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Preload("Items").Preload("TaxLines").First(order, orderID).Error; err != nil {
+		return nil, err
+	}
+	if !refundableStatuses[order.PaymentStatus] && !order.RefundDue {
+		return nil, &RefundError{Message: fmt.Sprintf("Order payment status is %s, nothing to refund", order.PaymentStatus)}
+	}
+	if order.RefundDue {
+		// Paid after cancellation: the cancel already released stock and coupon usage.
+		in.Restock, in.ReverseCoupon = false, false
+	}
+	if order.PaymentStatus == models.PaymentStatusPartiallyPaid {
+		// Stock is only reserved until the order is fully paid; nothing to put back.
+		in.Restock = false
+	}
+
+	paid, refunded, err := OrderPaymentTotals(tx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	if paid <= 0 && order.PaymentStatus != models.PaymentStatusPartiallyPaid {
+		paid = round2(order.TotalAmount)
+	}
+	remaining := round2(paid - refunded)
+	if remaining <= 0 {
+		return nil, &RefundError{Message: "Order has already been fully refunded"}
+	}
+
+	// Resolve refunded quantities per line.
+	itemsByID := map[uint]models.OrderItem{}
+	for _, it := range order.Items {
+		itemsByID[it.ID] = it
+	}
+	lines := map[uint]int{}
+	if in.Full {
+		for _, it := range order.Items {
+			if left := it.Quantity - it.RefundedQuantity; left > 0 {
+				lines[it.ID] = left
+			}
+		}
+	} else {
+		for _, l := range in.Lines {
+			it, ok := itemsByID[l.OrderItemID]
+			if !ok {
+				return nil, &RefundError{Message: fmt.Sprintf("Order item %d does not belong to this order", l.OrderItemID)}
+			}
+			if l.Quantity <= 0 {
+				return nil, &RefundError{Message: "Refund quantity must be positive"}
+			}
+			lines[it.ID] += l.Quantity
+			if left := it.Quantity - it.RefundedQuantity; lines[it.ID] > left {
+				return nil, &RefundError{Message: fmt.Sprintf("Only %d of %s can still be refunded", left, fallbackStr(it.DisplaySKU(), "this item"))}
+			}
+		}
+	}
+
+	// Resolve the amount.
+	var amount, taxAmount float64
+	switch {
+	case in.Full:
+		amount = remaining
+	case in.Amount != nil:
+		amount = round2(*in.Amount)
+	case len(lines) > 0:
+		// Line value net of the order-level coupon discount.
+		factor := 1.0
+		if order.SubtotalAmount > 0 && order.DiscountAmount > 0 {
+			factor = (order.SubtotalAmount - order.DiscountAmount) / order.SubtotalAmount
+		}
+		var itemsNet float64
+		for id, q := range lines {
+			itemsNet += itemsByID[id].UnitPrice * float64(q) * factor
+		}
+		amount = itemsNet
+		if in.IncludeShipping {
+			amount += order.ShippingFee
+		}
+		if !order.TaxInclusive {
+			// Tax was added on top of the prices, so it is refunded with them.
+			taxAmount = round2(refundTaxShare(*order, itemsNet, in.IncludeShipping))
+			amount += taxAmount
+		}
+		amount = round2(amount)
+	default:
+		return nil, &RefundError{Message: "Specify full, items or amount"}
+	}
+	if amount <= 0 {
+		return nil, &RefundError{Message: "Refund amount must be positive"}
+	}
+	if amount > remaining+0.005 {
+		return nil, &RefundError{Message: fmt.Sprintf("Refund amount %.2f exceeds refundable balance %.2f", amount, remaining)}
+	}
+
+	currency := fallbackStr(order.Currency, "USD")
+	txID := fmt.Sprintf("REFUND-%s-%d", orderDisplayNumber(*order), time.Now().UnixNano())
+	providerData := ""
+
+	if in.ViaProvider {
+		if !IsOnlinePaymentMethod(order.PaymentMethod) {
+			return nil, &RefundError{Message: fmt.Sprintf("Provider refunds are not supported for payment method %q, record it manually instead", order.PaymentMethod)}
+		}
+		label := PaymentMethodLabel(order.PaymentMethod)
+		var capture models.PaymentTransaction
+		if err := tx.Where("order_id = ? AND payment_method = ? AND amount > 0 AND status = ?", order.ID, order.PaymentMethod, "completed").
+			Order("id DESC").First(&capture).Error; err != nil {
+			return nil, &RefundError{Message: fmt.Sprintf("No completed %s payment found for this order", label)}
+		}
+		provider, err := GetPaymentProvider(tx, order.PaymentMethod)
+		if err != nil {
+			return nil, err
+		}
+		providerRefund, err := provider.Refund(ctx, &capture, amount, currency, in.Reason)
+		if err != nil {
+			return nil, err
+		}
+		txID = providerRefund.ID
+		providerData = string(providerRefund.Raw)
+		*issued = txID
+	}
+
+	meta := map[string]interface{}{
+		"reason":         strings.TrimSpace(in.Reason),
+		"lines":          lines,
+		"restock":        in.Restock,
+		"reverse_coupon": in.ReverseCoupon,
+		"via_provider":   in.ViaProvider,
+		"admin_id":       in.ActorAdminID,
+	}
+	if providerData != "" {
+		meta["provider_response"] = json.RawMessage(providerData)
+	}
+	metaJSON, _ := json.Marshal(meta)
+
+	result := &RefundResult{Amount: amount, TaxAmount: taxAmount, Lines: lines}
+	result.Transaction = models.PaymentTransaction{
+		OrderID:       order.ID,
+		TransactionID: txID,
+		PaymentMethod: fallbackStr(order.PaymentMethod, "manual"),
+		Amount:        -amount,
+		Currency:      currency,
+		Status:        "refunded",
+		PaymentData:   string(metaJSON),
+	}
+	if err := tx.Create(&result.Transaction).Error; err != nil {
+		return nil, err
+	}
+
+	for id, q := range lines {
+		it := itemsByID[id]
+		updates := map[string]interface{}{"refunded_quantity": gorm.Expr("refunded_quantity + ?", q)}
+		// Refunded units come off the backorder first: they were never taken from stock.
+		fromBackorder := min(q, it.BackorderedQuantity)
+		if fromBackorder > 0 {
+			updates["backordered_quantity"] = gorm.Expr("backordered_quantity - ?", fromBackorder)
+		}
+		// Never restock more than was sold (e.g. lines already restocked by a cancellation).
+		restock := q - fromBackorder
+		if left := it.Quantity - it.BackorderedQuantity - it.RestockedQuantity; restock > left {
+			restock = left
+		}
+		if in.Restock && restock > 0 {
+			if err := tx.Model(&models.Product{}).Where("id = ?", it.ProductID).
+				UpdateColumn("stock_quantity", gorm.Expr("stock_quantity + ?", restock)).Error; err != nil {
+				return nil, err
+			}
+			updates["restocked_quantity"] = gorm.Expr("restocked_quantity + ?", restock)
+			result.Restocked = true
+		}
+		if err := tx.Model(&models.OrderItem{}).Where("id = ?", id).UpdateColumns(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if in.ReverseCoupon && order.CouponID != nil {
+		n, err := ReleaseCouponUsage(tx, order.ID)
+		if err != nil {
+			return nil, err
+		}
+		result.CouponRelease = n
+	}
+
+	note := fmt.Sprintf("Refund %.2f %s (%s)", amount, currency, txID)
+	if r := strings.TrimSpace(in.Reason); r != "" {
+		note += ": " + r
+	}
+
+	// Refunded units no longer have to ship, which can complete a partial fulfillment.
+	if len(lines) > 0 {
+		var items []models.OrderItem
+		if err := tx.Where("order_id = ?", order.ID).Find(&items).Error; err != nil {
+			return nil, err
+		}
+		if err := refreshFulfillmentStatus(tx, order, items, AdminActor(in.ActorAdminID), note); err != nil {
+			return nil, err
+		}
+	}
+	if err := RefreshOrderRefundStatus(tx, order, AdminActor(in.ActorAdminID), note); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// refundTaxShare is the tax charged on the refunded part of an order: the items tax line in
+// proportion to the refunded item value, and the whole shipping tax line with the shipping.
+func refundTaxShare(order models.Order, itemsNet float64, shipping bool) float64 {
+	var tax float64
+	for _, tl := range order.TaxLines {
+		switch tl.Component {
+		case "items":
+			if tl.TaxableAmount > 0 {
+				tax += tl.TaxAmount * math.Min(itemsNet/tl.TaxableAmount, 1)
+			}
+		case "shipping":
+			if shipping {
+				tax += tl.TaxAmount
+			}
+		}
+	}
+	return tax
+}
+
+// BuildRefundNotificationEmail tells the customer how much is being refunded and for what.
+func BuildRefundNotificationEmail(siteURL string, order models.Order, refund RefundResult, reason string) (subject, text, html string) {
+	orderNo := orderDisplayNumber(order)
+	currency := fallbackStr(order.Currency, "USD")
+	amount := fmt.Sprintf("%.2f %s", refund.Amount, currency)
+	full := order.PaymentStatus == "refunded"
+
+	subject = fmt.Sprintf("Refund issued for order %s", orderNo)
+	kind := "A partial refund"
+	if full {
+		kind = "A full refund"
+	}
+
+	itemsText := emailItemsText(order.Items, refund.Lines)
+	if len(refund.Lines) == 0 {
+		itemsText = ""
+	}
+	body := fmt.Sprintf("%s of %s has been issued for your order %s.\n", kind, amount, orderNo)
+	if refund.TaxAmount != 0 {
+		body += fmt.Sprintf("This includes %.2f %s tax.\n", refund.TaxAmount, currency)
+	}
+	if strings.TrimSpace(reason) != "" {
+		body += fmt.Sprintf("\nReason: %s\n", strings.TrimSpace(reason))
+	}
+	if itemsText != "" {
+		body += "\nRefunded items:\n" + itemsText + "\n"
+	}
+	body += "\nDepending on your payment provider, it may take 3-10 business days for the funds to appear.\n"
+	body += optionalLine("View your order", orderTrackURL(siteURL, order))
+	text = customerEmailText(body)
+
+	rows := []string{
+		emailRowHTML("Order", orderNo),
+		emailRowHTML("Refund amount", amount),
+	}
+	if refund.TaxAmount != 0 {
+		rows = append(rows, emailRowHTML("Including tax", fmt.Sprintf("%.2f %s", refund.TaxAmount, currency)))
+	}
+	if strings.TrimSpace(reason) != "" {
+		rows = append(rows, emailRowHTML("Reason", strings.TrimSpace(reason)))
+	}
+	inner := fmt.Sprintf("<p style=\"margin:0 0 10px 0\">%s has been issued for your order <b>%s</b>.</p>", kind, escapeHTML(orderNo)) +
+		emailRowsHTML(rows...)
+	if len(refund.Lines) > 0 {
+		inner += emailItemsTableHTML("Refunded items", order.Items, refund.Lines)
+	}
+	inner += "<p style=\"margin:14px 0 0 0;font-size:13px;color:#374151\">Depending on your payment provider, it may take 3-10 business days for the funds to appear.</p>" +
+		emailButtonHTML("View order", orderTrackURL(siteURL, order))
+	html = customerEmailHTML("Refund issued", inner)
+	return subject, text, html
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// orderTransitions lists, per order status, the statuses an order may move to.
+var orderTransitions = map[string][]string{
+	models.OrderStatusPending:    {models.OrderStatusConfirmed, models.OrderStatusProcessing, models.OrderStatusCancelled},
+	models.OrderStatusConfirmed:  {models.OrderStatusProcessing, models.OrderStatusShipped, models.OrderStatusCancelled},
+	models.OrderStatusProcessing: {models.OrderStatusShipped, models.OrderStatusCancelled},
+	models.OrderStatusShipped:    {models.OrderStatusDelivered},
+	models.OrderStatusDelivered:  {},
+	models.OrderStatusCancelled:  {},
+}
+
+// paymentTransitions lists, per payment status, the statuses a payment may move to.
+var paymentTransitions = map[string][]string{
+	models.PaymentStatusPending:           {models.PaymentStatusAwaitingPayment, models.PaymentStatusPaid, models.PaymentStatusFailed},
+	models.PaymentStatusAwaitingPayment:   {models.PaymentStatusPending, models.PaymentStatusPartiallyPaid, models.PaymentStatusPaid, models.PaymentStatusFailed},
+	models.PaymentStatusPartiallyPaid:     {models.PaymentStatusPaid, models.PaymentStatusRefunded},
+	models.PaymentStatusFailed:            {models.PaymentStatusPending, models.PaymentStatusAwaitingPayment, models.PaymentStatusPaid},
+	models.PaymentStatusPaid:              {models.PaymentStatusPartiallyRefunded, models.PaymentStatusRefunded, models.PaymentStatusReversed, models.PaymentStatusDisputed},
+	models.PaymentStatusPartiallyRefunded: {models.PaymentStatusRefunded, models.PaymentStatusReversed, models.PaymentStatusDisputed},
+	models.PaymentStatusDisputed:          {models.PaymentStatusPaid, models.PaymentStatusPartiallyRefunded, models.PaymentStatusRefunded, models.PaymentStatusReversed},
+	models.PaymentStatusReversed:          {models.PaymentStatusPaid},
+	models.PaymentStatusRefunded:          {},
+}
+
+// OrderStatuses returns every valid order status in lifecycle order.
+func OrderStatuses() []string {
+	return []string{models.OrderStatusPending, models.OrderStatusConfirmed, models.OrderStatusProcessing, models.OrderStatusShipped, models.OrderStatusDelivered, models.OrderStatusCancelled}
+}
+
+// PaymentStatuses returns every valid payment status.
+func PaymentStatuses() []string {
+	return []string{models.PaymentStatusPending, models.PaymentStatusAwaitingPayment, models.PaymentStatusPartiallyPaid, models.PaymentStatusPaid, models.PaymentStatusFailed, models.PaymentStatusPartiallyRefunded, models.PaymentStatusRefunded, models.PaymentStatusReversed, models.PaymentStatusDisputed}
+}
+
+// AllowedOrderTransitions returns the statuses reachable from the given order status.
+func AllowedOrderTransitions(from string) []string {
+	return append([]string(nil), orderTransitions[from]...)
+}
+
+// AllowedPaymentTransitions returns the statuses reachable from the given payment status.
+func AllowedPaymentTransitions(from string) []string {
+	return append([]string(nil), paymentTransitions[from]...)
+}
+
+// IsPaymentCaptured reports whether money was taken for the order (stock has been deducted).
+func IsPaymentCaptured(paymentStatus string) bool {
+	switch paymentStatus {
+	case models.PaymentStatusPaid, models.PaymentStatusPartiallyRefunded, models.PaymentStatusDisputed:
+		return true
+	}
+	return false
+}
+
+// StatusActor identifies who changed a status.
+type StatusActor struct {
+	Type    string // admin | customer | system | webhook
+	AdminID *uint
+
+	// Force skips the transition table. Only for facts reported by a payment provider
+	// (e.g. a reversal on an order we never saw captured); the change is still logged.
+	Force bool
+}
+
+var (
+	ActorSystem   = StatusActor{Type: "system"}
+	ActorCustomer = StatusActor{Type: "customer"}
+	ActorWebhook  = StatusActor{Type: "webhook", Force: true}
+)
+
+// AdminActor returns an actor for an admin user ID (nil when unknown).
+func AdminActor(adminID *uint) StatusActor {
+	return StatusActor{Type: "admin", AdminID: adminID}
+}
+
+// StatusTransitionError is returned for a transition the state machine does not allow.
+type StatusTransitionError struct {
+	Field  string
+	From   string
+	To     string
+	Reason string
+}
+
+func (e *StatusTransitionError) Error() string {
+	if e.Reason != "" {
+		return e.Reason
+	}
+	return fmt.Sprintf("cannot change %s from %s to %s", strings.ReplaceAll(e.Field, "_", " "), e.From, e.To)
+}
+
+func containsStatus(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordOrderHistory appends an audit row.
+func RecordOrderHistory(tx *gorm.DB, orderID uint, field, from, to string, actor StatusActor, note string) error {
+	h := models.OrderStatusHistory{
+		OrderID:      orderID,
+		Field:        field,
+		FromStatus:   from,
+		ToStatus:     to,
+		ActorType:    fallbackStr(actor.Type, "system"),
+		ActorAdminID: actor.AdminID,
+		Note:         strings.TrimSpace(note),
+		CreatedAt:    time.Now(),
+	}
+	return tx.Create(&h).Error
+}
+
+// lockOrderState locks the order row until the transaction ends and refreshes the state the
+// transitions check, so a concurrent payment, cancellation or expiry is seen instead of the
+// caller's copy. Other in-memory changes of the caller are kept.
+func lockOrderState(tx *gorm.DB, order *models.Order) error {
+	var cur models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "status", "payment_status", "refund_due").First(&cur, order.ID).Error; err != nil {
+		return err
+	}
+	order.Status, order.PaymentStatus, order.RefundDue = cur.Status, cur.PaymentStatus, cur.RefundDue
+	return nil
+}
+
+// TransitionOrderStatus validates and applies an order status change with its side effects:
+//   - shipped requires a tracking number and stamps shipped_at
+//   - cancelled puts captured stock back, or releases the stock reservation and coupon usage of unpaid orders
+func TransitionOrderStatus(tx *gorm.DB, order *models.Order, to string, actor StatusActor, note string) error {
+	if tx == nil || order == nil {
+		return errors.New("invalid arguments")
+	}
+	if err := lockOrderState(tx, order); err != nil {
+		return err
+	}
+	from := fallbackStr(order.Status, models.OrderStatusPending)
+	if _, ok := orderTransitions[to]; !ok {
+		return &StatusTransitionError{Field: "status", From: from, To: to, Reason: fmt.Sprintf("invalid status %q", to)}
+	}
+	if from == to {
+		return nil
+	}
+	if !actor.Force && !containsStatus(orderTransitions[from], to) {
+		return &StatusTransitionError{Field: "status", From: from, To: to}
+	}
+
+	updates := map[string]interface{}{"status": to}
+	switch to {
+	case models.OrderStatusShipped:
+		if strings.TrimSpace(order.TrackingNumber) == "" {
+			return &StatusTransitionError{Field: "status", From: from, To: to, Reason: "a tracking number is required before marking the order shipped"}
+		}
+		if order.ShippedAt == nil {
+			now := time.Now()
+			order.ShippedAt = &now
+			updates["shipped_at"] = &now
+		}
+	case models.OrderStatusCancelled:
+		if IsPaymentCaptured(order.PaymentStatus) {
+			if err := RestockOrderItems(tx, order.ID); err != nil {
+				return err
+			}
+			break
+		}
+		if _, err := ReleaseOrderReservations(tx, order.ID); err != nil {
+			return err
+		}
+		if _, err := ReleaseCouponUsage(tx, order.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Updates(updates).Error; err != nil {
+		return err
+	}
+	order.Status = to
+	return RecordOrderHistory(tx, order.ID, "status", from, to, actor, note)
+}
+
+// TransitionPaymentStatus validates and applies a payment status change.
+// Moving to paid converts the stock reservation into a deduction and confirms a pending order (see MarkOrderPaid).
+func TransitionPaymentStatus(tx *gorm.DB, order *models.Order, to string, actor StatusActor, note string) error {
+	if tx == nil || order == nil {
+		return errors.New("invalid arguments")
+	}
+	if err := lockOrderState(tx, order); err != nil {
+		return err
+	}
+	from := fallbackStr(order.PaymentStatus, models.PaymentStatusPending)
+	if _, ok := paymentTransitions[to]; !ok {
+		return &StatusTransitionError{Field: "payment_status", From: from, To: to, Reason: fmt.Sprintf("invalid payment status %q", to)}
+	}
+	if from == to {
+		return nil
+	}
+	if !actor.Force && !containsStatus(paymentTransitions[from], to) {
+		return &StatusTransitionError{Field: "payment_status", From: from, To: to}
+	}
+	if to == models.PaymentStatusPaid && order.Status == models.OrderStatusCancelled {
+		return &StatusTransitionError{Field: "payment_status", From: from, To: to, Reason: "cannot mark a cancelled order as paid"}
+	}
+
+	if to == models.PaymentStatusPaid && !IsPaymentCaptured(from) {
+		if err := ConvertOrderReservations(tx, order); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Update("payment_status", to).Error; err != nil {
+		return err
+	}
+	order.PaymentStatus = to
+	if err := RecordOrderHistory(tx, order.ID, "payment_status", from, to, actor, note); err != nil {
+		return err
+	}
+
+	if to == models.PaymentStatusPaid && order.Status == models.OrderStatusPending {
+		return TransitionOrderStatus(tx, order, models.OrderStatusConfirmed, actor, "payment received")
+	}
+	return nil
+}
+
+func loadOrderItems(tx *gorm.DB, order *models.Order) ([]models.OrderItem, error) {
+	if len(order.Items) > 0 {
+		return order.Items, nil
+	}
+	var items []models.OrderItem
+	err := tx.Where("order_id = ?", order.ID).Find(&items).Error
+	return items, err
+}
+
+// RestockOrderItems returns every not-yet-restocked unit of an order to stock. Backordered
+// units were never deducted and are skipped.
+func RestockOrderItems(tx *gorm.DB, orderID uint) error {
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ?", orderID).Find(&items).Error; err != nil {
+		return err
+	}
+	for _, item := range items {
+		left := item.Quantity - item.BackorderedQuantity - item.RestockedQuantity
+		if left <= 0 {
+			continue
+		}
+		if err := tx.Model(&models.Product{}).Where("id = ?", item.ProductID).
+			UpdateColumn("stock_quantity", gorm.Expr("stock_quantity + ?", left)).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.OrderItem{}).Where("id = ?", item.ID).
+			Update("restocked_quantity", item.Quantity).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// orderNumberAlphabet is Crockford base32: no I, L, O or U, so numbers read out on the
+// phone are not mistaken for each other.
+const orderNumberAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewOrderNumber returns an unused order number "ORD-YYMMDD-XXXXXXXX" with 40 random bits,
+// so numbers can no longer be guessed from the order time.
+func NewOrderNumber(db *gorm.DB) (string, error) {
+	for i := 0; i < 5; i++ {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		suffix := make([]byte, len(b))
+		for j, v := range b {
+			suffix[j] = orderNumberAlphabet[v&31]
+		}
+		number := "ORD-" + time.Now().UTC().Format("060102") + "-" + string(suffix)
+		var n int64
+		if err := db.Model(&models.Order{}).Where("order_number = ?", number).Count(&n).Error; err != nil {
+			return "", err
+		}
+		if n == 0 {
+			return number, nil
+		}
+	}
+	return "", errors.New("could not generate a unique order number")
+}
+
+// orderTrackingSecret signs guest tracking links. ORDER_TRACKING_SECRET can be set to rotate
+// links independently of logins; otherwise JWT_SECRET is used.
+func orderTrackingSecret() []byte {
+	if s := strings.TrimSpace(os.Getenv("ORDER_TRACKING_SECRET")); s != "" {
+		return []byte(s)
+	}
+	return []byte(strings.TrimSpace(os.Getenv("JWT_SECRET")))
+}
+
+// OrderTrackingToken is the HMAC token that lets a guest view an order's tracking page.
+// It does not expire: it is printed in every order email. Empty without a signing secret.
+func OrderTrackingToken(orderNumber string) string {
+	secret := orderTrackingSecret()
+	if len(secret) == 0 || orderNumber == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("order-track:" + orderNumber))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// VerifyOrderTrackingToken checks a tracking token in constant time.
+func VerifyOrderTrackingToken(orderNumber, token string) bool {
+	expected := OrderTrackingToken(orderNumber)
+	token = strings.TrimSpace(token)
+	if expected == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(token)) == 1
+}
+
+// CanTrackOrder reports whether the caller proved they may see the order: a valid
+// tracking token, or the email address the order was placed with.
+func CanTrackOrder(order models.Order, token, email string) bool {
+	if VerifyOrderTrackingToken(order.OrderNumber, token) {
+		return true
+	}
+	email = strings.TrimSpace(email)
+	return email != "" && strings.EqualFold(email, strings.TrimSpace(order.CustomerEmail))
+}
+
+// PublicOrderItem is an order line on the guest tracking page.
+type PublicOrderItem struct {
+	SKU             string  `json:"sku"`
+	Name            string  `json:"name"`
+	Quantity        int     `json:"quantity"`
+	UnitPrice       float64 `json:"unit_price"`
+	TotalPrice      float64 `json:"total_price"`
+	ShippedQuantity int     `json:"shipped_quantity"`
+}
+
+// PublicShipment is a parcel on the guest tracking page.
+type PublicShipment struct {
+	Carrier        string    `json:"carrier"`
+	TrackingNumber string    `json:"tracking_number"`
+	TrackingURL    string    `json:"tracking_url,omitempty"`
+	ShippedAt      time.Time `json:"shipped_at"`
+	Items          []struct {
+		SKU      string `json:"sku"`
+		Quantity int    `json:"quantity"`
+	} `json:"items"`
+}
+
+// PublicOrderView is what guest order tracking returns: status, amounts, lines and parcels,
+// without the customer's name, contact details or addresses.
+type PublicOrderView struct {
+	OrderNumber       string            `json:"order_number"`
+	Status            string            `json:"status"`
+	PaymentStatus     string            `json:"payment_status"`
+	PaymentMethod     string            `json:"payment_method"`
+	FulfillmentStatus string            `json:"fulfillment_status"`
+	Currency          string            `json:"currency"`
+	SubtotalAmount    float64           `json:"subtotal_amount"`
+	DiscountAmount    float64           `json:"discount_amount"`
+	ShippingFee       float64           `json:"shipping_fee"`
+	TaxAmount         float64           `json:"tax_amount"`
+	TotalAmount       float64           `json:"total_amount"`
+	RefundedAmount    float64           `json:"refunded_amount"`
+	ShippingCountry   string            `json:"shipping_country"`
+	ShippingCarrier   string            `json:"shipping_carrier"`
+	TrackingNumber    string            `json:"tracking_number"`
+	ShippedAt         *time.Time        `json:"shipped_at"`
+	Items             []PublicOrderItem `json:"items"`
+	Shipments         []PublicShipment  `json:"shipments"`
+	CreatedAt         time.Time         `json:"created_at"`
+}
+
+// NewPublicOrderView reduces an order (with Items and Shipments.Items loaded) to its public view.
+func NewPublicOrderView(order models.Order) PublicOrderView {
+	v := PublicOrderView{
+		OrderNumber:       order.OrderNumber,
+		Status:            order.Status,
+		PaymentStatus:     order.PaymentStatus,
+		PaymentMethod:     order.PaymentMethod,
+		FulfillmentStatus: order.FulfillmentStatus,
+		Currency:          fallbackStr(order.Currency, BaseCurrency),
+		SubtotalAmount:    order.SubtotalAmount,
+		DiscountAmount:    order.DiscountAmount,
+		ShippingFee:       order.ShippingFee,
+		TaxAmount:         order.TaxAmount,
+		TotalAmount:       order.TotalAmount,
+		RefundedAmount:    order.RefundedAmount,
+		ShippingCountry:   order.ShippingCountry,
+		ShippingCarrier:   order.ShippingCarrier,
+		TrackingNumber:    order.TrackingNumber,
+		ShippedAt:         order.ShippedAt,
+		Items:             make([]PublicOrderItem, 0, len(order.Items)),
+		Shipments:         make([]PublicShipment, 0, len(order.Shipments)),
+		CreatedAt:         order.CreatedAt,
+	}
+	skuByItem := map[uint]string{}
+	for _, it := range order.Items {
+		skuByItem[it.ID] = it.DisplaySKU()
+		v.Items = append(v.Items, PublicOrderItem{
+			SKU:             it.DisplaySKU(),
+			Name:            it.DisplayName(),
+			Quantity:        it.Quantity,
+			UnitPrice:       it.UnitPrice,
+			TotalPrice:      it.TotalPrice,
+			ShippedQuantity: it.ShippedQuantity,
+		})
+	}
+	for _, s := range order.Shipments {
+		ps := PublicShipment{
+			Carrier:        s.Carrier,
+			TrackingNumber: s.TrackingNumber,
+			TrackingURL:    carrierTrackingURL(s.Carrier, s.TrackingNumber),
+			ShippedAt:      s.ShippedAt,
+		}
+		for _, si := range s.Items {
+			ps.Items = append(ps.Items, struct {
+				SKU      string `json:"sku"`
+				Quantity int    `json:"quantity"`
+			}{SKU: skuByItem[si.OrderItemID], Quantity: si.Quantity})
+		}
+		v.Shipments = append(v.Shipments, ps)
+	}
+	return v
+}
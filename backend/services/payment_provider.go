@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// Online payment methods, stored in Order.PaymentMethod and PaymentTransaction.PaymentMethod.
+const (
+	PaymentMethodPayPal = "paypal"
+	PaymentMethodStripe = "stripe" // card payments via Stripe PaymentIntents
+)
+
+// PaymentProvider is an online payment method the checkout can offer. Implementations talk to
+// the provider's API; everything that touches orders and the transaction ledger stays in the
+// shared code (ProcessPayment, CreateOrderRefund, HandlePaymentWebhookEvent).
+type PaymentProvider interface {
+	// Name is the payment method key ("paypal", "stripe").
+	Name() string
+	// CreateIntent starts a payment for the order total and returns what the browser needs
+	// to complete it (PayPal order ID, Stripe client secret).
+	CreateIntent(ctx context.Context, order *models.Order) (*PaymentIntent, error)
+	// Capture confirms a payment server-side from the data the browser sends back after the
+	// buyer approved it, and checks it against the order. Mismatches return *PaymentVerificationError.
+	Capture(ctx context.Context, order *models.Order, data map[string]interface{}) (*CapturedPayment, error)
+	// Refund returns (part of) a completed capture transaction.
+	Refund(ctx context.Context, capture *models.PaymentTransaction, amount float64, currency, reason string) (*ProviderRefund, error)
+	// ParseWebhook verifies the webhook signature and normalizes the event.
+	ParseWebhook(ctx context.Context, headers http.Header, body []byte) (*ProviderWebhookEvent, error)
+}
+
+// PaymentIntent is a payment started with a provider, as handed to the checkout page.
+type PaymentIntent struct {
+	Provider     string  `json:"provider"`
+	IntentID     string  `json:"intent_id"`               // PayPal order ID / Stripe PaymentIntent ID
+	ClientSecret string  `json:"client_secret,omitempty"` // Stripe only
+	Status       string  `json:"status"`
+	Amount       float64 `json:"amount"`
+	Currency     string  `json:"currency"`
+}
+
+// CapturedPayment is a payment confirmed directly with the provider.
+type CapturedPayment struct {
+	Provider      string
+	PaymentID     string // provider-side payment (PayPal order / PaymentIntent), stored in Order.PaymentID
+	TransactionID string // capture / charge ID, stored in PaymentTransaction.TransactionID
+	Pending       bool   // provider accepted the payment but has not settled it yet
+	Amount        float64
+	Currency      string
+	PayerID       string
+	PayerEmail    string
+	Raw           json.RawMessage
+}
+
+// ProviderRefund is a refund issued at the provider.
+type ProviderRefund struct {
+	ID      string
+	Pending bool
+	Raw     json.RawMessage
+}
+
+// PaymentVerificationError means the provider answered but the payment does not match our order.
+type PaymentVerificationError struct {
+	Provider string
+	Reason   string
+}
+
+func (e *PaymentVerificationError) Error() string {
+	return fmt.Sprintf("%s verification failed: %s", e.Provider, e.Reason)
+}
+
+// PaymentWebhookPayloadError means a webhook body could not be understood.
+type PaymentWebhookPayloadError struct {
+	Reason string
+}
+
+func (e *PaymentWebhookPayloadError) Error() string { return "invalid webhook payload: " + e.Reason }
+
+var (
+	// ErrUnknownPaymentProvider is returned for payment methods without an online provider.
+	ErrUnknownPaymentProvider = errors.New("unknown payment provider")
+	// ErrPaymentProviderNotConfigured wraps the provider's own "missing credentials" error.
+	ErrPaymentProviderNotConfigured = errors.New("payment provider not configured")
+	// ErrPaymentWebhookSignature means a webhook could not be authenticated.
+	ErrPaymentWebhookSignature = errors.New("payment webhook signature verification failed")
+)
+
+// IsOnlinePaymentMethod reports whether money for the method moves through a PaymentProvider
+// (so refunds can be issued at the provider).
+func IsOnlinePaymentMethod(method string) bool {
+	return method == PaymentMethodPayPal || method == PaymentMethodStripe
+}
+
+// PaymentMethodLabel is the human name of a payment method for notes and the checkout.
+func PaymentMethodLabel(method string) string {
+	switch method {
+	case PaymentMethodPayPal:
+		return "PayPal"
+	case PaymentMethodStripe:
+		return "Stripe"
+	case PaymentMethodBankTransfer:
+		return "Bank transfer"
+	}
+	return method
+}
+
+// GetPaymentProvider builds the provider for a payment method from its admin settings.
+// It does not check Enabled: captures, refunds and webhooks for existing payments must keep
+// working after a method is switched off for new checkouts.
+func GetPaymentProvider(db *gorm.DB, method string) (PaymentProvider, error) {
+	switch strings.ToLower(strings.TrimSpace(method)) {
+	case PaymentMethodPayPal:
+		s, err := GetOrCreatePayPalSetting(db)
+		if err != nil {
+			return nil, err
+		}
+		client, err := NewPayPalClientFromSetting(s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPaymentProviderNotConfigured, err)
+		}
+		return &PayPalProvider{Client: client, Setting: s}, nil
+	case PaymentMethodStripe:
+		s, err := GetOrCreateStripeSetting(db)
+		if err != nil {
+			return nil, err
+		}
+		client, err := NewStripeClientFromSetting(s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPaymentProviderNotConfigured, err)
+		}
+		return &StripeProvider{Client: client, Setting: s}, nil
+	}
+	return nil, ErrUnknownPaymentProvider
+}
+
+// CheckoutPaymentMethod is one payment option offered at checkout.
+type CheckoutPaymentMethod struct {
+	Method string      `json:"method"`
+	Label  string      `json:"label"`
+	Config interface{} `json:"config,omitempty"` // public provider config (client ID / publishable key)
+}
+
+// CheckoutPaymentMethods lists the payment methods enabled in the admin panel, in display order.
+func CheckoutPaymentMethods(db *gorm.DB) ([]CheckoutPaymentMethod, error) {
+	var out []CheckoutPaymentMethod
+	pp, err := GetOrCreatePayPalSetting(db)
+	if err != nil {
+		return nil, err
+	}
+	if pp.Enabled && pp.EffectiveClientID() != "" {
+		out = append(out, CheckoutPaymentMethod{Method: PaymentMethodPayPal, Label: "PayPal", Config: pp.ToPublicConfig()})
+	}
+	st, err := GetOrCreateStripeSetting(db)
+	if err != nil {
+		return nil, err
+	}
+	if st.Enabled && st.EffectivePublishableKey() != "" {
+		out = append(out, CheckoutPaymentMethod{Method: PaymentMethodStripe, Label: "Credit / debit card", Config: st.ToPublicConfig()})
+	}
+	if strings.TrimSpace(LoadInvoiceCompanyProfile(db).BankDetails) != "" {
+		out = append(out, CheckoutPaymentMethod{Method: PaymentMethodBankTransfer, Label: "Bank transfer (T/T)"})
+	}
+	return out, nil
+}
+
+// IsCheckoutPaymentMethodEnabled reports whether new payments may be started with the method.
+func IsCheckoutPaymentMethodEnabled(db *gorm.DB, method string) (bool, error) {
+	methods, err := CheckoutPaymentMethods(db)
+	if err != nil {
+		return false, err
+	}
+	for _, m := range methods {
+		if m.Method == method {
+			return true, nil
+		}
+	}
+	return false, nil
+}
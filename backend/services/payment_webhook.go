@@ -0,0 +1,271 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// Normalized webhook event kinds (ProviderWebhookEvent.Kind).
+const (
+	PaymentEventCompleted = "payment.completed" // a capture/charge settled
+	PaymentEventRefunded  = "payment.refunded"  // money returned to the buyer
+	PaymentEventReversed  = "payment.reversed"  // chargeback: money taken back by the card network/PayPal
+	PaymentEventDisputed  = "payment.disputed"  // buyer opened a dispute; no money moved yet
+)
+
+// ProviderWebhookEvent is a verified provider webhook mapped onto the handful of events we act on.
+type ProviderWebhookEvent struct {
+	Provider  string
+	EventID   string
+	EventType string // provider event type, e.g. PAYMENT.CAPTURE.COMPLETED, payment_intent.succeeded
+	Kind      string // one of the PaymentEvent* kinds; empty for events we only acknowledge
+
+	ResourceID      string // capture / refund / dispute ID the event is about
+	CaptureID       string // the original capture/charge (for refunds and disputes)
+	OrderNumber     string // our order number, when the provider echoes it back
+	ProviderOrderID string // PayPal order / Stripe PaymentIntent ID (Order.PaymentID)
+	Amount          float64
+	Currency        string
+	Reason          string
+
+	Resource json.RawMessage // provider resource, stored with the transaction
+}
+
+// PaymentWebhookResult tells the caller what happened to an event.
+type PaymentWebhookResult struct {
+	OrderID    *uint
+	ResourceID string
+	Status     string // processed | ignored
+	Note       string
+	OrderPaid  bool // order transitioned to paid by this event
+}
+
+// findOrderForPayment resolves our order from a capture ID, our order number or the provider
+// order ID stored in Order.PaymentID.
+func findOrderForPayment(db *gorm.DB, captureID, orderNumber, providerOrderID string) (*models.Order, error) {
+	var order models.Order
+	if captureID != "" {
+		var t models.PaymentTransaction
+		if err := db.Where("transaction_id = ?", captureID).First(&t).Error; err == nil {
+			if err := db.Preload("Items").First(&order, t.OrderID).Error; err == nil {
+				return &order, nil
+			}
+		}
+	}
+	if orderNumber != "" {
+		if err := db.Preload("Items").Where("order_number = ?", orderNumber).First(&order).Error; err == nil {
+			return &order, nil
+		}
+	}
+	if providerOrderID != "" {
+		if err := db.Preload("Items").Where("payment_id = ?", providerOrderID).First(&order).Error; err == nil {
+			return &order, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// HandlePaymentWebhookEvent applies a verified provider webhook event to orders and the transaction ledger.
+// Deduplication by event ID is the caller's job; this function is additionally idempotent on
+// PaymentTransaction.TransactionID so a replayed event never double-books money.
+func HandlePaymentWebhookEvent(db *gorm.DB, ev *ProviderWebhookEvent) (*PaymentWebhookResult, error) {
+	if db == nil || ev == nil {
+		return nil, errors.New("invalid arguments")
+	}
+	switch ev.Kind {
+	case PaymentEventCompleted:
+		return handlePaymentCompleted(db, ev)
+	case PaymentEventRefunded, PaymentEventReversed:
+		return handlePaymentRefunded(db, ev)
+	case PaymentEventDisputed:
+		return handlePaymentDisputed(db, ev)
+	default:
+		return &PaymentWebhookResult{ResourceID: ev.ResourceID, Status: "ignored", Note: "unhandled event type"}, nil
+	}
+}
+
+func handlePaymentCompleted(db *gorm.DB, ev *ProviderWebhookEvent) (*PaymentWebhookResult, error) {
+	label := PaymentMethodLabel(ev.Provider)
+	out := &PaymentWebhookResult{ResourceID: ev.ResourceID}
+	order, err := findOrderForPayment(db, ev.ResourceID, ev.OrderNumber, ev.ProviderOrderID)
+	if err != nil {
+		out.Status, out.Note = "ignored", "order not found"
+		return out, nil
+	}
+	out.OrderID = &order.ID
+
+	amount, currency := round2(math.Abs(ev.Amount)), strings.ToUpper(ev.Currency)
+	if !strings.EqualFold(currency, fallbackStr(order.Currency, "USD")) || math.Abs(amount-round2(order.TotalAmount)) > priceTolerance {
+		out.Status = "ignored"
+		out.Note = fmt.Sprintf("capture %.2f %s does not match order total %.2f %s", amount, currency, order.TotalAmount, order.Currency)
+		return out, nil
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var t models.PaymentTransaction
+		e := tx.Where("transaction_id = ?", ev.ResourceID).First(&t).Error
+		switch {
+		case e == nil:
+			if t.Status != "completed" {
+				if err := tx.Model(&t).Updates(map[string]interface{}{"status": "completed", "payment_data": string(ev.Resource)}).Error; err != nil {
+					return err
+				}
+			}
+		case errors.Is(e, gorm.ErrRecordNotFound):
+			t = models.PaymentTransaction{
+				OrderID:       order.ID,
+				TransactionID: ev.ResourceID,
+				PaymentMethod: ev.Provider,
+				Amount:        amount,
+				Currency:      currency,
+				Status:        "completed",
+				PaymentData:   string(ev.Resource),
+			}
+			if err := tx.Create(&t).Error; err != nil {
+				return err
+			}
+		default:
+			return e
+		}
+
+		if IsPaymentCaptured(order.PaymentStatus) {
+			return nil
+		}
+		order.PaymentMethod = ev.Provider
+		if order.PaymentID == "" {
+			order.PaymentID = ev.ProviderOrderID
+		}
+		if order.RefundDue {
+			return nil
+		}
+		if err := MarkOrderPaid(tx, order, ActorWebhook, label+" capture "+ev.ResourceID+" completed"); err != nil {
+			return err
+		}
+		out.OrderPaid = !order.RefundDue
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	out.Status = "processed"
+	if order.RefundDue {
+		out.Note = "order was cancelled before the payment completed, refund due"
+	}
+	return out, nil
+}
+
+func handlePaymentRefunded(db *gorm.DB, ev *ProviderWebhookEvent) (*PaymentWebhookResult, error) {
+	label := PaymentMethodLabel(ev.Provider)
+	out := &PaymentWebhookResult{ResourceID: ev.ResourceID}
+	captureID := fallbackStr(ev.CaptureID, ev.ResourceID)
+	order, err := findOrderForPayment(db, captureID, ev.OrderNumber, ev.ProviderOrderID)
+	if err != nil {
+		out.Status, out.Note = "ignored", "order not found"
+		return out, nil
+	}
+	out.OrderID = &order.ID
+
+	amount := round2(math.Abs(ev.Amount))
+	status := "refunded"
+	if ev.Kind == PaymentEventReversed {
+		status = "reversed"
+	}
+	// A capture-shaped payload reuses the capture ID; keep refund rows unique.
+	txID := ev.ResourceID
+	if txID == captureID {
+		txID = status + ":" + captureID
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Waits for an admin refund of this order in progress, which records the same refund ID.
+		if err := lockOrderState(tx, order); err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.PaymentTransaction{}).Where("transaction_id = ?", txID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			t := models.PaymentTransaction{
+				OrderID:       order.ID,
+				TransactionID: txID,
+				PaymentMethod: ev.Provider,
+				Amount:        -amount,
+				Currency:      fallbackStr(strings.ToUpper(ev.Currency), order.Currency),
+				Status:        status,
+				PaymentData:   string(ev.Resource),
+			}
+			if err := tx.Create(&t).Error; err != nil {
+				return err
+			}
+		}
+		if status == "reversed" {
+			if err := RefreshOrderRefundStatus(tx, order, ActorWebhook, label+" reversal "+txID); err != nil {
+				return err
+			}
+			return TransitionPaymentStatus(tx, order, models.PaymentStatusReversed, ActorWebhook, label+" capture "+captureID+" reversed")
+		}
+		return RefreshOrderRefundStatus(tx, order, ActorWebhook, fmt.Sprintf("%s refund %s (%.2f)", label, txID, amount))
+	})
+	if err != nil {
+		return nil, err
+	}
+	out.Status = "processed"
+	return out, nil
+}
+
+func handlePaymentDisputed(db *gorm.DB, ev *ProviderWebhookEvent) (*PaymentWebhookResult, error) {
+	out := &PaymentWebhookResult{ResourceID: ev.ResourceID}
+	order, err := findOrderForPayment(db, ev.CaptureID, ev.OrderNumber, ev.ProviderOrderID)
+	if err != nil {
+		out.Status, out.Note = "ignored", "order not found"
+		return out, nil
+	}
+	out.OrderID = &order.ID
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		txID := "dispute:" + ev.ResourceID
+		if err := tx.Model(&models.PaymentTransaction{}).Where("transaction_id = ?", txID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			// No money has moved yet: the row records the dispute (amount lives in payment_data).
+			t := models.PaymentTransaction{
+				OrderID:       order.ID,
+				TransactionID: txID,
+				PaymentMethod: ev.Provider,
+				Amount:        0,
+				Currency:      fallbackStr(strings.ToUpper(ev.Currency), order.Currency),
+				Status:        "disputed",
+				PaymentData:   string(ev.Resource),
+			}
+			if err := tx.Create(&t).Error; err != nil {
+				return err
+			}
+		}
+		return TransitionPaymentStatus(tx, order, models.PaymentStatusDisputed, ActorWebhook, PaymentMethodLabel(ev.Provider)+" dispute "+ev.ResourceID+": "+ev.Reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+	out.Status = "processed"
+	out.Note = ev.Reason
+	return out, nil
+}
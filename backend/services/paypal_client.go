@@ -0,0 +1,471 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fanuc-backend/models"
+	"fanuc-backend/utils"
+)
+
+const (
+	payPalSandboxBase = "https://api-m.sandbox.paypal.com"
+	payPalLiveBase    = "https://api-m.paypal.com"
+)
+
+// PayPalAPIBase returns the REST API base URL for a mode.
+// PAYPAL_API_BASE overrides it (e.g. a local fake PayPal server in tests).
+func PayPalAPIBase(mode string) string {
+	if v := strings.TrimRight(strings.TrimSpace(os.Getenv("PAYPAL_API_BASE")), "/"); v != "" {
+		return v
+	}
+	if mode == "live" {
+		return payPalLiveBase
+	}
+	return payPalSandboxBase
+}
+
+// PayPalClient is a minimal PayPal REST client (OAuth2 client credentials + Orders v2).
+// HTTP and BaseURL are exported so callers/tests can inject their own transport or server.
+type PayPalClient struct {
+	HTTP         *http.Client
+	BaseURL      string
+	ClientID     string
+	ClientSecret string
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+func NewPayPalClient(mode, clientID, clientSecret string) *PayPalClient {
+	return &PayPalClient{
+		HTTP:         &http.Client{Timeout: 20 * time.Second},
+		BaseURL:      PayPalAPIBase(mode),
+		ClientID:     strings.TrimSpace(clientID),
+		ClientSecret: strings.TrimSpace(clientSecret),
+	}
+}
+
+// ErrPayPalNotConfigured is returned when the active mode has no client ID/secret.
+var ErrPayPalNotConfigured = errors.New("paypal client id/secret not configured")
+
+// NewPayPalClientFromSetting builds a client for the active mode, decrypting the stored secret.
+func NewPayPalClientFromSetting(s *models.PayPalSetting) (*PayPalClient, error) {
+	if s == nil {
+		return nil, ErrPayPalNotConfigured
+	}
+	clientID := strings.TrimSpace(s.EffectiveClientID())
+	enc := strings.TrimSpace(s.EffectiveClientSecretEnc())
+	if clientID == "" || enc == "" {
+		return nil, ErrPayPalNotConfigured
+	}
+	secret, err := utils.DecryptSecret(enc)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt paypal secret: %w", err)
+	}
+	return NewPayPalClient(s.Mode, clientID, secret), nil
+}
+
+// PayPalAPIError is a non-2xx response from the PayPal REST API.
+type PayPalAPIError struct {
+	StatusCode int
+	Name       string `json:"name"`
+	Message    string `json:"message"`
+	Details    []struct {
+		Issue       string `json:"issue"`
+		Description string `json:"description"`
+	} `json:"details"`
+}
+
+func (e *PayPalAPIError) Error() string {
+	msg := e.Name
+	if e.Message != "" {
+		msg = strings.TrimSpace(msg + ": " + e.Message)
+	}
+	if len(e.Details) > 0 && e.Details[0].Issue != "" {
+		msg += " (" + e.Details[0].Issue + ")"
+	}
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	return fmt.Sprintf("paypal api error %d: %s", e.StatusCode, msg)
+}
+
+// HasIssue reports whether the error carries the given PayPal issue code (e.g. ORDER_ALREADY_CAPTURED).
+func (e *PayPalAPIError) HasIssue(issue string) bool {
+	for _, d := range e.Details {
+		if d.Issue == issue {
+			return true
+		}
+	}
+	return false
+}
+
+type PayPalMoney struct {
+	CurrencyCode string `json:"currency_code"`
+	Value        string `json:"value"`
+}
+
+type PayPalCapture struct {
+	ID         string      `json:"id"`
+	Status     string      `json:"status"`
+	Amount     PayPalMoney `json:"amount"`
+	InvoiceID  string      `json:"invoice_id"`
+	CustomID   string      `json:"custom_id"`
+	CreateTime string      `json:"create_time"`
+}
+
+type PayPalPurchaseUnit struct {
+	ReferenceID string      `json:"reference_id"`
+	InvoiceID   string      `json:"invoice_id"`
+	CustomID    string      `json:"custom_id"`
+	Amount      PayPalMoney `json:"amount"`
+	Payments    struct {
+		Captures []PayPalCapture `json:"captures"`
+	} `json:"payments"`
+}
+
+// PayPalOrder is the subset of the Orders v2 order resource we rely on.
+type PayPalOrder struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Payer  struct {
+		PayerID      string `json:"payer_id"`
+		EmailAddress string `json:"email_address"`
+	} `json:"payer"`
+	PurchaseUnits []PayPalPurchaseUnit `json:"purchase_units"`
+
+	// Raw is the full JSON body returned by PayPal.
+	Raw json.RawMessage `json:"-"`
+}
+
+func (c *PayPalClient) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.tokenExpiry) {
+		return c.token, nil
+	}
+	if c.ClientID == "" || c.ClientSecret == "" {
+		return "", ErrPayPalNotConfigured
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/v1/oauth2/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(c.ClientID, c.ClientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("paypal oauth failed: %s", strings.TrimSpace(string(body)))
+	}
+
+	var out struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return "", err
+	}
+	if out.AccessToken == "" {
+		return "", errors.New("paypal oauth: empty access token")
+	}
+	c.token = out.AccessToken
+	// Refresh a minute early so we never send an expiring token.
+	c.tokenExpiry = time.Now().Add(time.Duration(out.ExpiresIn)*time.Second - time.Minute)
+	return c.token, nil
+}
+
+// do sends an authenticated JSON request and returns the raw response body.
+func (c *PayPalClient) do(ctx context.Context, method, path string, payload any) ([]byte, error) {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var rdr io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		rdr = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, rdr)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	if payload != nil || method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		apiErr := &PayPalAPIError{StatusCode: resp.StatusCode}
+		_ = json.Unmarshal(body, apiErr)
+		return nil, apiErr
+	}
+	return body, nil
+}
+
+func decodePayPalOrder(body []byte) (*PayPalOrder, error) {
+	var o PayPalOrder
+	if err := json.Unmarshal(body, &o); err != nil {
+		return nil, err
+	}
+	o.Raw = json.RawMessage(body)
+	return &o, nil
+}
+
+// GetOrder fetches an order: GET /v2/checkout/orders/{id}
+func (c *PayPalClient) GetOrder(ctx context.Context, id string) (*PayPalOrder, error) {
+	body, err := c.do(ctx, http.MethodGet, "/v2/checkout/orders/"+url.PathEscape(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodePayPalOrder(body)
+}
+
+// CreateOrder creates a CAPTURE order for one purchase unit: POST /v2/checkout/orders
+// customID carries our order number so the capture can be matched back to the order.
+func (c *PayPalClient) CreateOrder(ctx context.Context, customID string, amount float64, currency, description string) (*PayPalOrder, error) {
+	unit := map[string]any{
+		"reference_id": customID,
+		"custom_id":    customID,
+		"amount":       PayPalMoney{CurrencyCode: strings.ToUpper(currency), Value: paypalAmountValue(amount, currency)},
+	}
+	if description = strings.TrimSpace(description); description != "" {
+		if len(description) > 127 {
+			description = description[:127]
+		}
+		unit["description"] = description
+	}
+	payload := map[string]any{
+		"intent":         "CAPTURE",
+		"purchase_units": []any{unit},
+	}
+	body, err := c.do(ctx, http.MethodPost, "/v2/checkout/orders", payload)
+	if err != nil {
+		return nil, err
+	}
+	return decodePayPalOrder(body)
+}
+
+// CaptureOrder captures an approved order: POST /v2/checkout/orders/{id}/capture
+func (c *PayPalClient) CaptureOrder(ctx context.Context, id string) (*PayPalOrder, error) {
+	body, err := c.do(ctx, http.MethodPost, "/v2/checkout/orders/"+url.PathEscape(id)+"/capture", nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodePayPalOrder(body)
+}
+
+// PayPalVerifiedPayment is a capture confirmed directly with PayPal.
+type PayPalVerifiedPayment struct {
+	PayPalOrderID string
+	CaptureID     string
+	CaptureStatus string // COMPLETED or PENDING
+	Amount        float64
+	Currency      string
+	PayerID       string
+	PayerEmail    string
+	Raw           json.RawMessage
+}
+
+// VerifyPayPalOrderPayment loads the PayPal order server-side (capturing it if the buyer only
+// approved it) and checks the capture status, amount and currency against our order.
+// A PENDING capture is returned without error; callers must not mark the order paid for it.
+func VerifyPayPalOrderPayment(ctx context.Context, client *PayPalClient, paypalOrderID string, order *models.Order) (*PayPalVerifiedPayment, error) {
+	if client == nil {
+		return nil, ErrPayPalNotConfigured
+	}
+	if order == nil {
+		return nil, errors.New("order is nil")
+	}
+	paypalOrderID = strings.TrimSpace(paypalOrderID)
+	if paypalOrderID == "" {
+		return nil, &PaymentVerificationError{Provider: PaymentMethodPayPal, Reason: "missing PayPal order ID"}
+	}
+
+	ppOrder, err := client.GetOrder(ctx, paypalOrderID)
+	if err != nil {
+		return nil, err
+	}
+	if ppOrder.Status == "APPROVED" {
+		captured, err := client.CaptureOrder(ctx, paypalOrderID)
+		if err != nil {
+			var apiErr *PayPalAPIError
+			if !errors.As(err, &apiErr) || !apiErr.HasIssue("ORDER_ALREADY_CAPTURED") {
+				return nil, err
+			}
+			// Captured concurrently (e.g. by the browser); reload the final state.
+			if captured, err = client.GetOrder(ctx, paypalOrderID); err != nil {
+				return nil, err
+			}
+		}
+		ppOrder = captured
+	}
+	if ppOrder.Status != "COMPLETED" {
+		return nil, &PaymentVerificationError{Provider: PaymentMethodPayPal, Reason: fmt.Sprintf("PayPal order status is %s", ppOrder.Status)}
+	}
+	if len(ppOrder.PurchaseUnits) == 0 || len(ppOrder.PurchaseUnits[0].Payments.Captures) == 0 {
+		return nil, &PaymentVerificationError{Provider: PaymentMethodPayPal, Reason: "PayPal order has no captures"}
+	}
+
+	unit := ppOrder.PurchaseUnits[0]
+	// If the buyer-side order carries our reference, it must be this order.
+	for _, ref := range []string{unit.InvoiceID, unit.CustomID} {
+		if ref != "" && ref != order.OrderNumber {
+			return nil, &PaymentVerificationError{Provider: PaymentMethodPayPal, Reason: "PayPal order belongs to a different order"}
+		}
+	}
+
+	capture := unit.Payments.Captures[0]
+	if capture.Status != "COMPLETED" && capture.Status != "PENDING" {
+		return nil, &PaymentVerificationError{Provider: PaymentMethodPayPal, Reason: fmt.Sprintf("capture status is %s", capture.Status)}
+	}
+
+	expectedCurrency := strings.ToUpper(strings.TrimSpace(order.Currency))
+	if expectedCurrency == "" {
+		expectedCurrency = "USD"
+	}
+	if !strings.EqualFold(capture.Amount.CurrencyCode, expectedCurrency) {
+		return nil, &PaymentVerificationError{Provider: PaymentMethodPayPal, Reason: fmt.Sprintf("currency %s does not match order currency %s", capture.Amount.CurrencyCode, expectedCurrency)}
+	}
+	amount, err := strconv.ParseFloat(strings.TrimSpace(capture.Amount.Value), 64)
+	if err != nil {
+		return nil, &PaymentVerificationError{Provider: PaymentMethodPayPal, Reason: "invalid capture amount"}
+	}
+	if math.Abs(amount-round2(order.TotalAmount)) > priceTolerance {
+		return nil, &PaymentVerificationError{Provider: PaymentMethodPayPal, Reason: fmt.Sprintf("captured amount %.2f does not match order total %.2f", amount, order.TotalAmount)}
+	}
+
+	return &PayPalVerifiedPayment{
+		PayPalOrderID: ppOrder.ID,
+		CaptureID:     capture.ID,
+		CaptureStatus: capture.Status,
+		Amount:        amount,
+		Currency:      strings.ToUpper(capture.Amount.CurrencyCode),
+		PayerID:       ppOrder.Payer.PayerID,
+		PayerEmail:    ppOrder.Payer.EmailAddress,
+		Raw:           ppOrder.Raw,
+	}, nil
+}
+
+// ErrPayPalWebhookSignature means PayPal did not confirm the webhook transmission signature.
+var ErrPayPalWebhookSignature = errors.New("paypal webhook signature verification failed")
+
+// VerifyWebhookSignature asks PayPal to verify a webhook transmission:
+// POST /v1/notifications/verify-webhook-signature
+// body must be the raw request body exactly as received.
+func (c *PayPalClient) VerifyWebhookSignature(ctx context.Context, webhookID string, headers http.Header, body []byte) error {
+	webhookID = strings.TrimSpace(webhookID)
+	if webhookID == "" {
+		return errors.New("paypal webhook id not configured")
+	}
+	if !json.Valid(body) {
+		return errors.New("invalid webhook body")
+	}
+	payload := map[string]any{
+		"auth_algo":         headers.Get("PAYPAL-AUTH-ALGO"),
+		"cert_url":          headers.Get("PAYPAL-CERT-URL"),
+		"transmission_id":   headers.Get("PAYPAL-TRANSMISSION-ID"),
+		"transmission_sig":  headers.Get("PAYPAL-TRANSMISSION-SIG"),
+		"transmission_time": headers.Get("PAYPAL-TRANSMISSION-TIME"),
+		"webhook_id":        webhookID,
+		"webhook_event":     json.RawMessage(body),
+	}
+	for _, k := range []string{"auth_algo", "cert_url", "transmission_id", "transmission_sig", "transmission_time"} {
+		if payload[k] == "" {
+			return ErrPayPalWebhookSignature
+		}
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, "/v1/notifications/verify-webhook-signature", payload)
+	if err != nil {
+		return err
+	}
+	var out struct {
+		VerificationStatus string `json:"verification_status"`
+	}
+	if err := json.Unmarshal(resp, &out); err != nil {
+		return err
+	}
+	if out.VerificationStatus != "SUCCESS" {
+		return ErrPayPalWebhookSignature
+	}
+	return nil
+}
+
+// PayPalRefund is the subset of the Payments v2 refund resource we store.
+type PayPalRefund struct {
+	ID     string      `json:"id"`
+	Status string      `json:"status"`
+	Amount PayPalMoney `json:"amount"`
+
+	Raw json.RawMessage `json:"-"`
+}
+
+// paypalAmountValue formats an amount the way PayPal expects: currencies without a minor
+// unit (JPY, HUF, TWD) are rejected when sent with decimals.
+func paypalAmountValue(amount float64, currency string) string {
+	switch strings.ToUpper(strings.TrimSpace(currency)) {
+	case "JPY", "HUF", "TWD":
+		return strconv.FormatFloat(math.Round(amount), 'f', 0, 64)
+	}
+	return strconv.FormatFloat(round2(amount), 'f', 2, 64)
+}
+
+// RefundCapture refunds (part of) a capture: POST /v2/payments/captures/{id}/refund
+func (c *PayPalClient) RefundCapture(ctx context.Context, captureID string, amount float64, currency, note string) (*PayPalRefund, error) {
+	payload := map[string]any{
+		"amount": PayPalMoney{CurrencyCode: strings.ToUpper(currency), Value: paypalAmountValue(amount, currency)},
+	}
+	if note = strings.TrimSpace(note); note != "" {
+		if len(note) > 255 {
+			note = note[:255]
+		}
+		payload["note_to_payer"] = note
+	}
+	body, err := c.do(ctx, http.MethodPost, "/v2/payments/captures/"+url.PathEscape(captureID)+"/refund", payload)
+	if err != nil {
+		return nil, err
+	}
+	var r PayPalRefund
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, err
+	}
+	r.Raw = json.RawMessage(body)
+	return &r, nil
+}
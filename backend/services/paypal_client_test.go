@@ -0,0 +1,285 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"fanuc-backend/models"
+)
+
+// fakePayPal is a minimal PayPal REST server: orders are CREATED, APPROVED by the test
+// (which can change their references or amount) and COMPLETED by a capture.
+type fakePayPal struct {
+	t  *testing.T
+	mu sync.Mutex
+
+	orders          map[string]*fakePayPalOrder
+	created         map[string]any // last create-order payload
+	captureCalls    int
+	verifyStatus    string // verification_status returned by verify-webhook-signature
+	alreadyCaptured bool   // capture answers 422 ORDER_ALREADY_CAPTURED after completing the order
+}
+
+type fakePayPalOrder struct {
+	status    string
+	customID  string
+	invoiceID string
+	amount    PayPalMoney
+}
+
+func newFakePayPal(t *testing.T) (*fakePayPal, *PayPalClient) {
+	t.Helper()
+	f := &fakePayPal{t: t, orders: map[string]*fakePayPalOrder{}, verifyStatus: "SUCCESS"}
+	srv := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(srv.Close)
+	t.Setenv("PAYPAL_API_BASE", srv.URL+"/")
+	return f, NewPayPalClient("sandbox", "client-id", "client-secret")
+}
+
+func (f *fakePayPal) orderJSON(id string, o *fakePayPalOrder) map[string]any {
+	unit := map[string]any{
+		"reference_id": o.customID,
+		"custom_id":    o.customID,
+		"invoice_id":   o.invoiceID,
+		"amount":       o.amount,
+	}
+	if o.status == "COMPLETED" {
+		unit["payments"] = map[string]any{"captures": []any{map[string]any{
+			"id": "CAP-" + id, "status": "COMPLETED", "amount": o.amount, "custom_id": o.customID,
+		}}}
+	}
+	return map[string]any{
+		"id":             id,
+		"status":         o.status,
+		"payer":          map[string]any{"payer_id": "PAYER1", "email_address": "buyer@example.com"},
+		"purchase_units": []any{unit},
+	}
+}
+
+func (f *fakePayPal) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	writeJSON := func(status int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(v)
+	}
+
+	if r.URL.Path == "/v1/oauth2/token" {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "client-id" || pass != "client-secret" {
+			writeJSON(http.StatusUnauthorized, map[string]any{"error": "invalid_client"})
+			return
+		}
+		writeJSON(http.StatusOK, map[string]any{"access_token": "token-1", "expires_in": 3600})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer token-1" {
+		writeJSON(http.StatusUnauthorized, map[string]any{"name": "AUTHENTICATION_FAILURE"})
+		return
+	}
+
+	path := r.URL.Path
+	switch {
+	case r.Method == http.MethodPost && path == "/v2/checkout/orders":
+		var payload map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			f.t.Errorf("create order: decode body: %v", err)
+		}
+		f.created = payload
+		unit := payload["purchase_units"].([]any)[0].(map[string]any)
+		amount := unit["amount"].(map[string]any)
+		id := fmt.Sprintf("PP%d", len(f.orders)+1)
+		o := &fakePayPalOrder{
+			status:   "CREATED",
+			customID: fmt.Sprint(unit["custom_id"]),
+			amount:   PayPalMoney{CurrencyCode: fmt.Sprint(amount["currency_code"]), Value: fmt.Sprint(amount["value"])},
+		}
+		f.orders[id] = o
+		writeJSON(http.StatusCreated, f.orderJSON(id, o))
+
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/capture"):
+		id := strings.TrimSuffix(strings.TrimPrefix(path, "/v2/checkout/orders/"), "/capture")
+		o, ok := f.orders[id]
+		if !ok {
+			writeJSON(http.StatusNotFound, map[string]any{"name": "RESOURCE_NOT_FOUND"})
+			return
+		}
+		f.captureCalls++
+		o.status = "COMPLETED"
+		if f.alreadyCaptured {
+			writeJSON(http.StatusUnprocessableEntity, map[string]any{
+				"name": "UNPROCESSABLE_ENTITY", "details": []any{map[string]any{"issue": "ORDER_ALREADY_CAPTURED"}},
+			})
+			return
+		}
+		writeJSON(http.StatusCreated, f.orderJSON(id, o))
+
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/v2/checkout/orders/"):
+		id := strings.TrimPrefix(path, "/v2/checkout/orders/")
+		o, ok := f.orders[id]
+		if !ok {
+			writeJSON(http.StatusNotFound, map[string]any{"name": "RESOURCE_NOT_FOUND"})
+			return
+		}
+		writeJSON(http.StatusOK, f.orderJSON(id, o))
+
+	case r.Method == http.MethodPost && path == "/v1/notifications/verify-webhook-signature":
+		var payload map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		if payload["webhook_id"] != "WH-1" {
+			f.t.Errorf("verify signature: webhook_id = %v", payload["webhook_id"])
+		}
+		writeJSON(http.StatusOK, map[string]any{"verification_status": f.verifyStatus})
+
+	default:
+		writeJSON(http.StatusNotFound, map[string]any{"name": "RESOURCE_NOT_FOUND"})
+	}
+}
+
+// approve stands in for the buyer approving the order in the PayPal popup.
+func (f *fakePayPal) approve(id string, change func(o *fakePayPalOrder)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	o := f.orders[id]
+	o.status = "APPROVED"
+	if change != nil {
+		change(o)
+	}
+}
+
+func TestPayPalCreateOrder(t *testing.T) {
+	f, client := newFakePayPal(t)
+	pp, err := client.CreateOrder(context.Background(), "FA-1001", 125.5, "usd", "FANUC parts")
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	if pp.ID == "" || pp.Status != "CREATED" {
+		t.Fatalf("CreateOrder returned %+v", pp)
+	}
+	if f.created["intent"] != "CAPTURE" {
+		t.Errorf("intent = %v, want CAPTURE", f.created["intent"])
+	}
+	unit := f.created["purchase_units"].([]any)[0].(map[string]any)
+	if unit["custom_id"] != "FA-1001" {
+		t.Errorf("custom_id = %v, want FA-1001", unit["custom_id"])
+	}
+	amount := unit["amount"].(map[string]any)
+	if amount["currency_code"] != "USD" || amount["value"] != "125.50" {
+		t.Errorf("amount = %v, want 125.50 USD", amount)
+	}
+
+	if _, err := client.CreateOrder(context.Background(), "FA-1002", 1500.4, "JPY", ""); err != nil {
+		t.Fatalf("CreateOrder JPY: %v", err)
+	}
+	amount = f.created["purchase_units"].([]any)[0].(map[string]any)["amount"].(map[string]any)
+	if amount["value"] != "1500" {
+		t.Errorf("JPY amount value = %v, want 1500", amount["value"])
+	}
+}
+
+func TestVerifyPayPalOrderPayment(t *testing.T) {
+	tests := []struct {
+		name            string
+		total           float64
+		currency        string
+		change          func(o *fakePayPalOrder)
+		alreadyCaptured bool
+		wantReason      string // empty: verification succeeds
+	}{
+		{name: "approved order is captured", total: 125.5},
+		{name: "captured concurrently", total: 125.5, alreadyCaptured: true},
+		{name: "amount within tolerance", total: 125.504},
+		{name: "amount mismatch", total: 125.52, wantReason: "does not match order total"},
+		{name: "currency mismatch", total: 125.5, currency: "EUR", wantReason: "does not match order currency"},
+		{name: "custom_id of another order", total: 125.5, change: func(o *fakePayPalOrder) { o.customID = "FA-9999" }, wantReason: "different order"},
+		{name: "invoice_id of another order", total: 125.5, change: func(o *fakePayPalOrder) { o.invoiceID = "FA-9999" }, wantReason: "different order"},
+		{name: "matching invoice_id", total: 125.5, change: func(o *fakePayPalOrder) { o.invoiceID = "FA-1001" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, client := newFakePayPal(t)
+			f.alreadyCaptured = tt.alreadyCaptured
+			pp, err := client.CreateOrder(context.Background(), "FA-1001", 125.5, "USD", "")
+			if err != nil {
+				t.Fatalf("CreateOrder: %v", err)
+			}
+			f.approve(pp.ID, tt.change)
+
+			order := &models.Order{OrderNumber: "FA-1001", TotalAmount: tt.total, Currency: tt.currency}
+			got, err := VerifyPayPalOrderPayment(context.Background(), client, pp.ID, order)
+			if f.captureCalls != 1 {
+				t.Errorf("capture calls = %d, want 1", f.captureCalls)
+			}
+			if tt.wantReason != "" {
+				var verr *PaymentVerificationError
+				if !errors.As(err, &verr) || !strings.Contains(verr.Reason, tt.wantReason) {
+					t.Fatalf("err = %v, want verification error containing %q", err, tt.wantReason)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyPayPalOrderPayment: %v", err)
+			}
+			if got.CaptureID != "CAP-"+pp.ID || got.CaptureStatus != "COMPLETED" || got.Amount != 125.5 || got.Currency != "USD" {
+				t.Errorf("verified payment = %+v", got)
+			}
+			if got.PayerID != "PAYER1" {
+				t.Errorf("payer = %q, want PAYER1", got.PayerID)
+			}
+		})
+	}
+}
+
+func TestVerifyPayPalOrderPaymentNotApproved(t *testing.T) {
+	f, client := newFakePayPal(t)
+	pp, err := client.CreateOrder(context.Background(), "FA-1001", 10, "USD", "")
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	_, err = VerifyPayPalOrderPayment(context.Background(), client, pp.ID, &models.Order{OrderNumber: "FA-1001", TotalAmount: 10})
+	var verr *PaymentVerificationError
+	if !errors.As(err, &verr) || !strings.Contains(verr.Reason, "CREATED") {
+		t.Fatalf("err = %v, want verification error for status CREATED", err)
+	}
+	if f.captureCalls != 0 {
+		t.Errorf("capture calls = %d, want 0", f.captureCalls)
+	}
+
+	_, err = VerifyPayPalOrderPayment(context.Background(), client, "missing", &models.Order{OrderNumber: "FA-1001", TotalAmount: 10})
+	var apiErr *PayPalAPIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("err = %v, want PayPal 404", err)
+	}
+}
+
+func TestPayPalVerifyWebhookSignature(t *testing.T) {
+	f, client := newFakePayPal(t)
+	headers := http.Header{}
+	for _, h := range []string{"PAYPAL-AUTH-ALGO", "PAYPAL-CERT-URL", "PAYPAL-TRANSMISSION-ID", "PAYPAL-TRANSMISSION-SIG", "PAYPAL-TRANSMISSION-TIME"} {
+		headers.Set(h, "x")
+	}
+	body := []byte(`{"id":"WH-EVENT-1","event_type":"PAYMENT.CAPTURE.COMPLETED"}`)
+
+	if err := client.VerifyWebhookSignature(context.Background(), "WH-1", headers, body); err != nil {
+		t.Fatalf("VerifyWebhookSignature: %v", err)
+	}
+
+	f.mu.Lock()
+	f.verifyStatus = "FAILURE"
+	f.mu.Unlock()
+	if err := client.VerifyWebhookSignature(context.Background(), "WH-1", headers, body); !errors.Is(err, ErrPayPalWebhookSignature) {
+		t.Fatalf("err = %v, want ErrPayPalWebhookSignature", err)
+	}
+
+	headers.Del("PAYPAL-TRANSMISSION-SIG")
+	if err := client.VerifyWebhookSignature(context.Background(), "WH-1", headers, body); !errors.Is(err, ErrPayPalWebhookSignature) {
+		t.Fatalf("missing header: err = %v, want ErrPayPalWebhookSignature", err)
+	}
+}
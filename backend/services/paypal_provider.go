@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"fanuc-backend/models"
+)
+
+// PayPalProvider is the PaymentProvider for PayPal Orders v2 (JS SDK buttons on the checkout).
+type PayPalProvider struct {
+	Client  *PayPalClient
+	Setting *models.PayPalSetting
+}
+
+func (p *PayPalProvider) Name() string { return PaymentMethodPayPal }
+
+// CreateIntent creates the PayPal order the JS SDK buttons approve (createOrder callback).
+func (p *PayPalProvider) CreateIntent(ctx context.Context, order *models.Order) (*PaymentIntent, error) {
+	currency := fallbackStr(strings.ToUpper(order.Currency), "USD")
+	ppOrder, err := p.Client.CreateOrder(ctx, order.OrderNumber, order.TotalAmount, currency, "Order "+orderDisplayNumber(*order))
+	if err != nil {
+		return nil, err
+	}
+	return &PaymentIntent{
+		Provider: PaymentMethodPayPal,
+		IntentID: ppOrder.ID,
+		Status:   ppOrder.Status,
+		Amount:   round2(order.TotalAmount),
+		Currency: currency,
+	}, nil
+}
+
+// payPalOrderIDFromData extracts the PayPal order ID from what the checkout posts back.
+// Frontend sends: { orderID, payerID, details, paymentSource }; older clients sent the
+// PayPal order object itself.
+func payPalOrderIDFromData(data map[string]interface{}) string {
+	id, _ := data["orderID"].(string)
+	if id == "" {
+		if details, ok := data["details"].(map[string]interface{}); ok {
+			id, _ = details["id"].(string)
+		} else {
+			id, _ = data["id"].(string)
+		}
+	}
+	return strings.TrimSpace(id)
+}
+
+// Capture verifies (and if only approved, captures) the PayPal order with PayPal.
+func (p *PayPalProvider) Capture(ctx context.Context, order *models.Order, data map[string]interface{}) (*CapturedPayment, error) {
+	verified, err := VerifyPayPalOrderPayment(ctx, p.Client, payPalOrderIDFromData(data), order)
+	if err != nil {
+		return nil, err
+	}
+	return &CapturedPayment{
+		Provider:      PaymentMethodPayPal,
+		PaymentID:     verified.PayPalOrderID,
+		TransactionID: verified.CaptureID,
+		Pending:       verified.CaptureStatus != "COMPLETED",
+		Amount:        verified.Amount,
+		Currency:      verified.Currency,
+		PayerID:       verified.PayerID,
+		PayerEmail:    verified.PayerEmail,
+		Raw:           verified.Raw,
+	}, nil
+}
+
+func (p *PayPalProvider) Refund(ctx context.Context, capture *models.PaymentTransaction, amount float64, currency, reason string) (*ProviderRefund, error) {
+	r, err := p.Client.RefundCapture(ctx, capture.TransactionID, amount, currency, reason)
+	if err != nil {
+		return nil, err
+	}
+	if r.Status != "COMPLETED" && r.Status != "PENDING" {
+		return nil, fmt.Errorf("paypal refund status %s", r.Status)
+	}
+	return &ProviderRefund{ID: r.ID, Pending: r.Status == "PENDING", Raw: r.Raw}, nil
+}
+
+// PayPalWebhookEvent is the envelope PayPal posts to our webhook endpoint.
+type PayPalWebhookEvent struct {
+	ID           string          `json:"id"`
+	EventType    string          `json:"event_type"`
+	ResourceType string          `json:"resource_type"`
+	Summary      string          `json:"summary"`
+	Resource     json.RawMessage `json:"resource"`
+}
+
+// payPalPaymentResource covers capture and refund resources.
+type payPalPaymentResource struct {
+	ID                string      `json:"id"`
+	Status            string      `json:"status"`
+	Amount            PayPalMoney `json:"amount"`
+	InvoiceID         string      `json:"invoice_id"`
+	CustomID          string      `json:"custom_id"`
+	SupplementaryData struct {
+		RelatedIDs struct {
+			OrderID string `json:"order_id"`
+		} `json:"related_ids"`
+	} `json:"supplementary_data"`
+	Links []struct {
+		Href string `json:"href"`
+		Rel  string `json:"rel"`
+	} `json:"links"`
+}
+
+// captureIDFromLinks returns the parent capture ID of a refund ("up" link .../captures/{id}).
+func (r payPalPaymentResource) captureIDFromLinks() string {
+	for _, l := range r.Links {
+		if l.Rel != "up" {
+			continue
+		}
+		if i := strings.Index(l.Href, "/captures/"); i >= 0 {
+			return strings.Trim(l.Href[i+len("/captures/"):], "/")
+		}
+	}
+	return ""
+}
+
+type payPalDisputeResource struct {
+	DisputeID            string      `json:"dispute_id"`
+	Reason               string      `json:"reason"`
+	Status               string      `json:"status"`
+	DisputeAmount        PayPalMoney `json:"dispute_amount"`
+	DisputedTransactions []struct {
+		SellerTransactionID string `json:"seller_transaction_id"`
+		InvoiceNumber       string `json:"invoice_number"`
+	} `json:"disputed_transactions"`
+}
+
+func parsePayPalAmount(m PayPalMoney) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(m.Value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", m.Value)
+	}
+	return round2(math.Abs(v)), nil
+}
+
+// ParseWebhook has PayPal verify the transmission signature, then maps capture, refund,
+// reversal and dispute events.
+func (p *PayPalProvider) ParseWebhook(ctx context.Context, headers http.Header, body []byte) (*ProviderWebhookEvent, error) {
+	var env PayPalWebhookEvent
+	if err := json.Unmarshal(body, &env); err != nil || strings.TrimSpace(env.ID) == "" {
+		return nil, &PaymentWebhookPayloadError{Reason: "invalid webhook event"}
+	}
+	if err := p.Client.VerifyWebhookSignature(ctx, p.Setting.EffectiveWebhookID(), headers, body); err != nil {
+		if errors.Is(err, ErrPayPalWebhookSignature) {
+			return nil, ErrPaymentWebhookSignature
+		}
+		return nil, err
+	}
+
+	ev := &ProviderWebhookEvent{
+		Provider:  PaymentMethodPayPal,
+		EventID:   env.ID,
+		EventType: env.EventType,
+		Resource:  env.Resource,
+	}
+	switch env.EventType {
+	case "PAYMENT.CAPTURE.COMPLETED", "PAYMENT.CAPTURE.REFUNDED", "PAYMENT.CAPTURE.REVERSED":
+		var res payPalPaymentResource
+		if err := json.Unmarshal(env.Resource, &res); err != nil {
+			return nil, &PaymentWebhookPayloadError{Reason: err.Error()}
+		}
+		amount, err := parsePayPalAmount(res.Amount)
+		if err != nil {
+			return nil, &PaymentWebhookPayloadError{Reason: err.Error()}
+		}
+		ev.ResourceID = res.ID
+		ev.OrderNumber = firstNonEmpty(res.InvoiceID, res.CustomID)
+		ev.Amount = amount
+		ev.Currency = strings.ToUpper(res.Amount.CurrencyCode)
+		switch env.EventType {
+		case "PAYMENT.CAPTURE.COMPLETED":
+			ev.Kind = PaymentEventCompleted
+			ev.ProviderOrderID = res.SupplementaryData.RelatedIDs.OrderID
+		case "PAYMENT.CAPTURE.REFUNDED":
+			ev.Kind = PaymentEventRefunded
+		default:
+			ev.Kind = PaymentEventReversed
+		}
+		if ev.Kind != PaymentEventCompleted {
+			// Some payloads carry the capture itself (status REFUNDED/REVERSED).
+			ev.CaptureID = fallbackStr(res.captureIDFromLinks(), res.ID)
+		}
+	case "CUSTOMER.DISPUTE.CREATED":
+		var res payPalDisputeResource
+		if err := json.Unmarshal(env.Resource, &res); err != nil {
+			return nil, &PaymentWebhookPayloadError{Reason: err.Error()}
+		}
+		ev.Kind = PaymentEventDisputed
+		ev.ResourceID = res.DisputeID
+		ev.Currency = strings.ToUpper(res.DisputeAmount.CurrencyCode)
+		ev.Reason = res.Reason
+		if len(res.DisputedTransactions) > 0 {
+			ev.CaptureID = res.DisputedTransactions[0].SellerTransactionID
+			ev.OrderNumber = res.DisputedTransactions[0].InvoiceNumber
+		}
+	}
+	return ev, nil
+}
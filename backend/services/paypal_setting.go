@@ -0,0 +1,28 @@
+package services
+
+import (
+	"errors"
+
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// GetOrCreatePayPalSetting loads the single-row PayPal settings (ID=1), creating defaults if missing.
+func GetOrCreatePayPalSetting(db *gorm.DB) (*models.PayPalSetting, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	var s models.PayPalSetting
+	if err := db.First(&s, 1).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			s = models.PayPalSetting{ID: 1, Enabled: false, Mode: "sandbox", Currency: "USD"}
+			if e := db.Create(&s).Error; e != nil {
+				return nil, e
+			}
+		} else {
+			return nil, err
+		}
+	}
+	return &s, nil
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Minimal PDF writer for generated documents (invoices etc.).
+//
+// Only the standard Helvetica/Helvetica-Bold fonts are used, so nothing has to be embedded
+// and no external binaries are needed. Text is encoded as WinAnsi (Windows-1252); characters
+// outside that set are replaced with '?'. Coordinates are in points with the origin at the
+// top-left corner (the writer flips them to PDF's bottom-left origin).
+
+const (
+	pdfPageWidth  = 595.28 // A4
+	pdfPageHeight = 841.89
+)
+
+// Advance widths (1/1000 em) for WinAnsi 32..126.
+var pdfHelveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var pdfHelveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+type pdfDocument struct {
+	pages []*bytes.Buffer
+	cur   *bytes.Buffer
+}
+
+func newPDFDocument() *pdfDocument {
+	d := &pdfDocument{}
+	d.AddPage()
+	return d
+}
+
+// AddPage starts a new A4 page; subsequent drawing goes to it.
+func (d *pdfDocument) AddPage() {
+	d.cur = &bytes.Buffer{}
+	d.pages = append(d.pages, d.cur)
+}
+
+// pdfEncodeText converts a UTF-8 string to WinAnsi bytes.
+func pdfEncodeText(s string) []byte {
+	enc := charmap.Windows1252.NewEncoder()
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r == '\t' || r == '\n' || r == '\r' {
+			r = ' '
+		}
+		b, err := enc.Bytes([]byte(string(r)))
+		if err != nil || len(b) != 1 {
+			out = append(out, '?')
+			continue
+		}
+		out = append(out, b[0])
+	}
+	return out
+}
+
+func pdfEscape(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		switch c {
+		case '(', ')', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// pdfTextWidth returns the rendered width of s in points.
+func pdfTextWidth(s string, size float64, bold bool) float64 {
+	widths := &pdfHelveticaWidths
+	if bold {
+		widths = &pdfHelveticaBoldWidths
+	}
+	total := 0
+	for _, c := range pdfEncodeText(s) {
+		if c >= 32 && c <= 126 {
+			total += widths[c-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// pdfWrapText splits s into lines no wider than width (explicit newlines are kept).
+func pdfWrapText(s string, width, size float64, bold bool) []string {
+	var lines []string
+	for _, para := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		words := strings.Fields(para)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		line := ""
+		for _, w := range words {
+			// Hard-break words that do not fit on a line by themselves (long part numbers/URLs).
+			for pdfTextWidth(w, size, bold) > width && len([]rune(w)) > 1 {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				r := []rune(w)
+				n := len(r) - 1
+				for n > 1 && pdfTextWidth(string(r[:n]), size, bold) > width {
+					n--
+				}
+				lines = append(lines, string(r[:n]))
+				w = string(r[n:])
+			}
+			candidate := w
+			if line != "" {
+				candidate = line + " " + w
+			}
+			if line != "" && pdfTextWidth(candidate, size, bold) > width {
+				lines = append(lines, line)
+				line = w
+				continue
+			}
+			line = candidate
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Text draws s with its baseline at (x, y).
+func (d *pdfDocument) Text(x, y, size float64, bold bool, s string) {
+	if s == "" {
+		return
+	}
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.cur, "BT /%s %.2f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, pdfPageHeight-y, pdfEscape(pdfEncodeText(s)))
+}
+
+// TextRight draws s right-aligned so it ends at x.
+func (d *pdfDocument) TextRight(x, y, size float64, bold bool, s string) {
+	d.Text(x-pdfTextWidth(s, size, bold), y, size, bold, s)
+}
+
+// SetTextColor sets the fill color (0..1 RGB) used for text and filled shapes.
+func (d *pdfDocument) SetTextColor(r, g, b float64) {
+	fmt.Fprintf(d.cur, "%.3f %.3f %.3f rg\n", r, g, b)
+}
+
+// Line draws a stroke from (x1, y1) to (x2, y2) in the given gray level.
+func (d *pdfDocument) Line(x1, y1, x2, y2, width, gray float64) {
+	fmt.Fprintf(d.cur, "%.3f G %.2f w %.2f %.2f m %.2f %.2f l S\n", gray, width, x1, pdfPageHeight-y1, x2, pdfPageHeight-y2)
+}
+
+// FillRect fills a rectangle whose top-left corner is (x, y).
+func (d *pdfDocument) FillRect(x, y, w, h, r, g, b float64) {
+	fmt.Fprintf(d.cur, "q %.3f %.3f %.3f rg %.2f %.2f %.2f %.2f re f Q\n", r, g, b, x, pdfPageHeight-y-h, w, h)
+}
+
+// Bytes serializes the document.
+func (d *pdfDocument) Bytes() ([]byte, error) {
+	var out bytes.Buffer
+	var offsets []int
+	obj := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// 1: catalog, 2: page tree, 3-4: fonts, then (page, content) pairs.
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+i*2)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range d.pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 6+i*2))
+
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		if _, err := zw.Write(page.Bytes()); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", len(offsets), z.Len())
+		out.Write(z.Bytes())
+		out.WriteString("\nendstream\nendobj\n")
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes(), nil
+}
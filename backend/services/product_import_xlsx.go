@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"strconv"
 	"strings"
 
@@ -50,6 +51,10 @@ type ProductImportResult struct {
 	Template   string              `json:"template"` // template identifier
 	Overwrite  bool                `json:"overwrite"`
 	CreatedNew bool                `json:"create_missing"`
+
+	// Backordered order lines that received imported stock (see AllocateBackorderedStock).
+	BackordersAllocated int             `json:"backorders_allocated"`
+	BackorderFills      []BackorderFill `json:"-"`
 }
 
 func GenerateProductImportTemplateXLSX(brand string) ([]byte, error) {
@@ -162,6 +167,7 @@ func ImportProductsFromXLSX(ctx context.Context, db *gorm.DB, r io.Reader, opts
 		defaultCategoryID = cats[0].ID
 	}
 
+	var restocked []uint
 	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		for _, row := range rows {
 			model := normalizeModel(row.Model)
@@ -193,6 +199,9 @@ func ImportProductsFromXLSX(ctx context.Context, db *gorm.DB, r io.Reader, opts
 			}
 
 			if found {
+				if row.Quantity > product.StockQuantity {
+					restocked = append(restocked, product.ID)
+				}
 				updates := map[string]any{}
 				updates["price"] = row.Price
 				updates["stock_quantity"] = row.Quantity
@@ -301,6 +310,17 @@ func ImportProductsFromXLSX(ctx context.Context, db *gorm.DB, r io.Reader, opts
 		return res, err
 	}
 	res.Failed = countAction(res.Items, "failed")
+
+	// Received stock goes to waiting backorders first.
+	for _, id := range restocked {
+		fills, err := AllocateBackorderedStock(db, id)
+		if err != nil {
+			log.Printf("product import: allocate backorders for product %d: %v", id, err)
+			continue
+		}
+		res.BackorderFills = append(res.BackorderFills, fills...)
+	}
+	res.BackordersAllocated = len(res.BackorderFills)
 	return res, nil
 }
 
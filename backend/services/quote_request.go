@@ -0,0 +1,529 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// QuoteError is a validation/state error the caller can show as-is.
+type QuoteError struct {
+	Message string
+}
+
+func (e *QuoteError) Error() string { return e.Message }
+
+// QuoteLineInput is one requested part number.
+type QuoteLineInput struct {
+	SKU       string `json:"sku"`
+	Quantity  int    `json:"quantity"`
+	Condition string `json:"condition"`
+	Notes     string `json:"notes"`
+}
+
+// QuoteRequestInput is a new RFQ from a customer or guest.
+type QuoteRequestInput struct {
+	CustomerID      *uint
+	CustomerEmail   string
+	CustomerName    string
+	CustomerPhone   string
+	CompanyName     string
+	VATNumber       string
+	ShippingCountry string
+	ShippingAddress string
+	Message         string
+	Lines           []QuoteLineInput
+}
+
+// QuotePriceLineInput prices one RFQ line. A nil UnitPrice marks the line as not available.
+type QuotePriceLineInput struct {
+	ItemID    uint     `json:"item_id"`
+	ProductID *uint    `json:"product_id"`
+	UnitPrice *float64 `json:"unit_price"`
+	LeadTime  string   `json:"lead_time"`
+}
+
+// QuotePriceInput is an admin's quote for an RFQ.
+type QuotePriceInput struct {
+	Lines       []QuotePriceLineInput
+	ShippingFee float64
+	Currency    string
+	ValidUntil  time.Time
+	QuoteNotes  string
+	AdminNotes  string
+	AdminID     *uint
+}
+
+const maxQuoteLines = 200
+
+var quoteConditions = map[string]bool{"new": true, "refurbished": true, "used": true, "any": true}
+
+func newQuoteAccessToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// CreateQuoteRequest validates and stores an RFQ, matching part numbers to catalog products.
+func CreateQuoteRequest(db *gorm.DB, in QuoteRequestInput) (*models.QuoteRequest, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	in.CustomerEmail = strings.ToLower(strings.TrimSpace(in.CustomerEmail))
+	in.CustomerName = strings.TrimSpace(in.CustomerName)
+	if in.CustomerEmail == "" || !strings.Contains(in.CustomerEmail, "@") {
+		return nil, &QuoteError{Message: "A valid email address is required"}
+	}
+	if in.CustomerName == "" {
+		return nil, &QuoteError{Message: "Name is required"}
+	}
+	if len(in.Lines) == 0 {
+		return nil, &QuoteError{Message: "Add at least one part number"}
+	}
+	if len(in.Lines) > maxQuoteLines {
+		return nil, &QuoteError{Message: fmt.Sprintf("A quote request can contain at most %d lines", maxQuoteLines)}
+	}
+	if strings.TrimSpace(in.VATNumber) != "" {
+		number, _, err := ValidateVATNumber(in.VATNumber)
+		if err != nil {
+			return nil, &QuoteError{Message: err.Error()}
+		}
+		in.VATNumber = number
+	}
+
+	token, err := newQuoteAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	q := models.QuoteRequest{
+		QuoteNumber:     fmt.Sprintf("RFQ-%d-%s", time.Now().Unix(), strings.ToUpper(token[:4])),
+		AccessToken:     token,
+		CustomerID:      in.CustomerID,
+		CustomerEmail:   in.CustomerEmail,
+		CustomerName:    in.CustomerName,
+		CustomerPhone:   strings.TrimSpace(in.CustomerPhone),
+		CompanyName:     strings.TrimSpace(in.CompanyName),
+		VATNumber:       in.VATNumber,
+		ShippingCountry: NormalizeCountryCode(in.ShippingCountry),
+		ShippingAddress: strings.TrimSpace(in.ShippingAddress),
+		Message:         strings.TrimSpace(in.Message),
+		Status:          models.QuoteStatusSubmitted,
+		Currency:        "USD",
+	}
+	for i, l := range in.Lines {
+		sku := strings.TrimSpace(l.SKU)
+		if sku == "" {
+			return nil, &QuoteError{Message: fmt.Sprintf("Line %d: part number is required", i+1)}
+		}
+		if l.Quantity <= 0 {
+			return nil, &QuoteError{Message: fmt.Sprintf("Line %d: quantity must be positive", i+1)}
+		}
+		cond := strings.ToLower(strings.TrimSpace(l.Condition))
+		if cond == "" {
+			cond = "any"
+		}
+		if !quoteConditions[cond] {
+			return nil, &QuoteError{Message: fmt.Sprintf("Line %d: condition must be new, refurbished, used or any", i+1)}
+		}
+		item := models.QuoteRequestItem{SKU: sku, Quantity: l.Quantity, Condition: cond, Notes: strings.TrimSpace(l.Notes)}
+		var p models.Product
+		if err := db.Select("id", "sku", "name").Where("sku = ?", sku).First(&p).Error; err == nil {
+			item.ProductID = &p.ID
+			item.ProductName = p.Name
+		}
+		q.Items = append(q.Items, item)
+	}
+
+	if err := db.Create(&q).Error; err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+// ExpireQuoteIfDue marks a quoted RFQ expired once its validity date has passed.
+func ExpireQuoteIfDue(db *gorm.DB, q *models.QuoteRequest) error {
+	if q.Status != models.QuoteStatusQuoted || q.ValidUntil == nil || time.Now().Before(*q.ValidUntil) {
+		return nil
+	}
+	if err := db.Model(&models.QuoteRequest{}).Where("id = ? AND status = ?", q.ID, models.QuoteStatusQuoted).
+		Update("status", models.QuoteStatusExpired).Error; err != nil {
+		return err
+	}
+	q.Status = models.QuoteStatusExpired
+	return nil
+}
+
+// PriceQuoteRequest records an admin's prices/lead times and marks the RFQ quoted.
+// Re-quoting an already quoted (or expired) RFQ replaces the previous quote.
+func PriceQuoteRequest(db *gorm.DB, quoteID uint, in QuotePriceInput) (*models.QuoteRequest, error) {
+	var q models.QuoteRequest
+	if err := db.Preload("Items").First(&q, quoteID).Error; err != nil {
+		return nil, err
+	}
+	switch q.Status {
+	case models.QuoteStatusSubmitted, models.QuoteStatusQuoted, models.QuoteStatusExpired:
+	default:
+		return nil, &QuoteError{Message: fmt.Sprintf("Quote is %s and can no longer be changed", q.Status)}
+	}
+	if !in.ValidUntil.After(time.Now()) {
+		return nil, &QuoteError{Message: "valid_until must be in the future"}
+	}
+	if in.ShippingFee < 0 {
+		return nil, &QuoteError{Message: "shipping_fee cannot be negative"}
+	}
+
+	byID := map[uint]*models.QuoteRequestItem{}
+	for i := range q.Items {
+		byID[q.Items[i].ID] = &q.Items[i]
+	}
+	for _, l := range in.Lines {
+		it, ok := byID[l.ItemID]
+		if !ok {
+			return nil, &QuoteError{Message: fmt.Sprintf("Item %d does not belong to this quote", l.ItemID)}
+		}
+		if l.ProductID != nil {
+			var p models.Product
+			if err := db.Select("id", "name").First(&p, *l.ProductID).Error; err != nil {
+				return nil, &QuoteError{Message: fmt.Sprintf("Product %d not found", *l.ProductID)}
+			}
+			it.ProductID = &p.ID
+			it.ProductName = p.Name
+		}
+		it.LeadTime = strings.TrimSpace(l.LeadTime)
+		it.UnitPrice = nil
+		it.TotalPrice = 0
+		if l.UnitPrice != nil && *l.UnitPrice > 0 {
+			if it.ProductID == nil {
+				return nil, &QuoteError{Message: fmt.Sprintf("Link %s to a catalog product before pricing it", it.SKU)}
+			}
+			price := round2(*l.UnitPrice)
+			it.UnitPrice = &price
+			it.TotalPrice = round2(price * float64(it.Quantity))
+		}
+	}
+
+	subtotal := 0.0
+	quoted := 0
+	for _, it := range q.Items {
+		if it.IsQuoted() {
+			subtotal += it.TotalPrice
+			quoted++
+		}
+	}
+	if quoted == 0 {
+		return nil, &QuoteError{Message: "Price at least one line"}
+	}
+
+	now := time.Now()
+	validUntil := in.ValidUntil
+	q.SubtotalAmount = round2(subtotal)
+	q.ShippingFee = round2(in.ShippingFee)
+	q.TotalAmount = round2(q.SubtotalAmount + q.ShippingFee)
+	cur, err := ResolveCurrency(db, fallbackStr(strings.TrimSpace(in.Currency), fallbackStr(q.Currency, BaseCurrency)))
+	if err != nil {
+		var curErr *CurrencyError
+		if errors.As(err, &curErr) {
+			return nil, &QuoteError{Message: curErr.Message}
+		}
+		return nil, err
+	}
+	q.Currency = cur.Code
+	q.ValidUntil = &validUntil
+	q.QuoteNotes = strings.TrimSpace(in.QuoteNotes)
+	q.AdminNotes = strings.TrimSpace(in.AdminNotes)
+	q.QuotedAt = &now
+	q.QuotedByID = in.AdminID
+	q.Status = models.QuoteStatusQuoted
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, it := range q.Items {
+			if err := tx.Model(&models.QuoteRequestItem{}).Where("id = ?", it.ID).Updates(map[string]interface{}{
+				"product_id":   it.ProductID,
+				"product_name": it.ProductName,
+				"unit_price":   it.UnitPrice,
+				"total_price":  it.TotalPrice,
+				"lead_time":    it.LeadTime,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Omit("Items").Save(&q).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+// AcceptQuoteRequest converts a quoted RFQ into a pending order at the quoted prices.
+// Unpriced lines are dropped. Quoted lines may carry a lead time, so no stock is reserved;
+// stock is deducted when the order is paid. Tax is added for the shipping country as at
+// checkout; vatNumber (empty = the one given with the request) may make it reverse charge.
+func AcceptQuoteRequest(db *gorm.DB, quoteID uint, vatNumber string, actor StatusActor) (*models.Order, error) {
+	var order models.Order
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the quote so a double click cannot create two orders.
+		var q models.QuoteRequest
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").First(&q, quoteID).Error; err != nil {
+			return err
+		}
+		if err := ExpireQuoteIfDue(tx, &q); err != nil {
+			return err
+		}
+		if q.Status != models.QuoteStatusQuoted {
+			return &QuoteError{Message: fmt.Sprintf("Quote is %s and cannot be accepted", q.Status)}
+		}
+
+		// Quoted prices are fixed in the quote currency; base amounts use today's rate.
+		cur, err := ResolveCurrency(tx, fallbackStr(q.Currency, BaseCurrency))
+		if err != nil {
+			var curErr *CurrencyError
+			if errors.As(err, &curErr) {
+				return &QuoteError{Message: curErr.Message + ", please ask us for a new quote"}
+			}
+			return err
+		}
+
+		for _, it := range q.Items {
+			if !it.IsQuoted() {
+				continue
+			}
+			order.Items = append(order.Items, models.OrderItem{
+				ProductID:     *it.ProductID,
+				ProductSKU:    it.SKU,
+				ProductName:   fallbackStr(it.ProductName, it.SKU),
+				Quantity:      it.Quantity,
+				UnitPrice:     *it.UnitPrice,
+				TotalPrice:    it.TotalPrice,
+				BaseUnitPrice: ConvertToBase(*it.UnitPrice, cur),
+			})
+		}
+		if len(order.Items) == 0 {
+			return &QuoteError{Message: "Quote has no priced lines"}
+		}
+
+		notes := "Quote " + q.QuoteNumber
+		if q.QuoteNotes != "" {
+			notes += "\n" + q.QuoteNotes
+		}
+		number, err := NewOrderNumber(tx)
+		if err != nil {
+			return err
+		}
+		order.OrderNumber = number
+		order.CustomerID = q.CustomerID
+		order.CustomerEmail = q.CustomerEmail
+		order.CustomerName = q.CustomerName
+		order.CustomerPhone = q.CustomerPhone
+		order.ShippingAddress = q.ShippingAddress
+		order.ShippingCountry = q.ShippingCountry
+		order.ShippingFee = q.ShippingFee
+		order.Status = models.OrderStatusPending
+		order.PaymentStatus = models.PaymentStatusPending
+		order.SubtotalAmount = q.SubtotalAmount
+		order.TotalAmount = q.TotalAmount
+		order.Currency = cur.Code
+		order.BaseCurrency = BaseCurrency
+		order.ExchangeRate = cur.Rate
+		order.BaseSubtotalAmount = ConvertToBase(q.SubtotalAmount, cur)
+		order.BaseShippingFee = ConvertToBase(q.ShippingFee, cur)
+		order.BaseTotalAmount = ConvertToBase(q.TotalAmount, cur)
+		order.Notes = notes
+		order.QuoteRequestID = &q.ID
+
+		tax, err := CalculateOrderTax(tx, TaxInput{
+			CountryCode:  q.ShippingCountry,
+			VATNumber:    fallbackStr(strings.TrimSpace(vatNumber), q.VATNumber),
+			Currency:     cur,
+			Subtotal:     order.SubtotalAmount,
+			Shipping:     order.ShippingFee,
+			BaseSubtotal: order.BaseSubtotalAmount,
+			BaseShipping: order.BaseShippingFee,
+		})
+		if err != nil {
+			var vatErr *VATNumberError
+			if errors.As(err, &vatErr) {
+				return &QuoteError{Message: vatErr.Message}
+			}
+			return err
+		}
+		ApplyOrderTax(&order, tax)
+
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		if err := RecordOrderHistory(tx, order.ID, "status", "", order.Status, actor, "order placed from quote "+q.QuoteNumber); err != nil {
+			return err
+		}
+		now := time.Now()
+		return tx.Model(&models.QuoteRequest{}).Where("id = ?", q.ID).Updates(map[string]interface{}{
+			"status":      models.QuoteStatusAccepted,
+			"accepted_at": &now,
+			"order_id":    order.ID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// RejectQuoteRequest records that the customer declined a quote.
+func RejectQuoteRequest(db *gorm.DB, q *models.QuoteRequest) error {
+	if err := ExpireQuoteIfDue(db, q); err != nil {
+		return err
+	}
+	if q.Status != models.QuoteStatusQuoted && q.Status != models.QuoteStatusSubmitted {
+		return &QuoteError{Message: fmt.Sprintf("Quote is %s and cannot be rejected", q.Status)}
+	}
+	now := time.Now()
+	if err := db.Model(&models.QuoteRequest{}).Where("id = ?", q.ID).Updates(map[string]interface{}{
+		"status":      models.QuoteStatusRejected,
+		"rejected_at": &now,
+	}).Error; err != nil {
+		return err
+	}
+	q.Status = models.QuoteStatusRejected
+	q.RejectedAt = &now
+	return nil
+}
+
+// QuoteViewURL is the customer link to view/accept a quote (works for guests).
+func QuoteViewURL(siteURL string, q models.QuoteRequest) string {
+	if strings.TrimSpace(siteURL) == "" {
+		return ""
+	}
+	return strings.TrimRight(siteURL, "/") + "/quotes/" + q.QuoteNumber + "?token=" + q.AccessToken
+}
+
+// BuildQuoteReadyEmail tells the customer their quote is priced and how to accept it.
+func BuildQuoteReadyEmail(siteURL string, q models.QuoteRequest) (subject, text, html string) {
+	link := QuoteViewURL(siteURL, q)
+	currency := fallbackStr(q.Currency, "USD")
+	validUntil := ""
+	if q.ValidUntil != nil {
+		validUntil = q.ValidUntil.UTC().Format("2006-01-02")
+	}
+
+	subject = fmt.Sprintf("Your quote %s is ready", q.QuoteNumber)
+
+	var lines []string
+	for _, it := range q.Items {
+		if it.IsQuoted() {
+			l := fmt.Sprintf("- %s | %s x%d @ %.2f %s", it.SKU, fallbackStr(it.ProductName, "-"), it.Quantity, *it.UnitPrice, currency)
+			if it.LeadTime != "" {
+				l += " (lead time " + it.LeadTime + ")"
+			}
+			lines = append(lines, l)
+		} else {
+			lines = append(lines, fmt.Sprintf("- %s x%d: not available", it.SKU, it.Quantity))
+		}
+	}
+	body := fmt.Sprintf("Hello %s,\n\nThank you for your request. Here is our quote %s:\n\n%s\n", fallbackStr(q.CustomerName, "there"), q.QuoteNumber, strings.Join(lines, "\n"))
+	body += fmt.Sprintf("\nSubtotal: %.2f %s\nShipping: %.2f %s\nTotal: %.2f %s\n", q.SubtotalAmount, currency, q.ShippingFee, currency, q.TotalAmount, currency)
+	if validUntil != "" {
+		body += "Valid until: " + validUntil + "\n"
+	}
+	if q.QuoteNotes != "" {
+		body += "\n" + q.QuoteNotes + "\n"
+	}
+	body += optionalLine("\nView and accept the quote", link)
+	text = customerEmailText(body)
+
+	th := "padding:8px 10px;background:#f9fafb;border-bottom:1px solid #e5e7eb;font-size:11px;color:#6b7280;text-transform:uppercase;letter-spacing:0.04em"
+	td := "padding:8px 10px;border-top:1px solid #e5e7eb;font-size:13px;color:#111827"
+	rows := ""
+	for _, it := range q.Items {
+		price, lead := "Not available", ""
+		if it.IsQuoted() {
+			price = fmt.Sprintf("%.2f", *it.UnitPrice)
+			lead = it.LeadTime
+		}
+		rows += "<tr>" +
+			"<td style=\"" + td + ";font-family:ui-monospace,Menlo,Consolas,monospace;font-size:12px\">" + escapeHTML(it.SKU) + "</td>" +
+			fmt.Sprintf("<td style=\"%s;text-align:right\">%d</td>", td, it.Quantity) +
+			"<td style=\"" + td + ";text-align:right\">" + escapeHTML(price) + "</td>" +
+			"<td style=\"" + td + "\">" + escapeHTML(lead) + "</td>" +
+			"</tr>"
+	}
+	table := "<table role=\"presentation\" cellpadding=\"0\" cellspacing=\"0\" style=\"width:100%;margin-top:14px;border:1px solid #e5e7eb;border-radius:10px;border-collapse:separate;border-spacing:0;overflow:hidden;font-family:Arial,Helvetica,sans-serif\">" +
+		"<tr><th align=\"left\" style=\"" + th + "\">Part number</th><th align=\"right\" style=\"" + th + "\">Qty</th>" +
+		"<th align=\"right\" style=\"" + th + "\">Unit price (" + escapeHTML(currency) + ")</th><th align=\"left\" style=\"" + th + "\">Lead time</th></tr>" +
+		rows + "</table>"
+
+	summary := []string{
+		emailRowHTML("Quote", q.QuoteNumber),
+		emailRowHTML("Subtotal", fmt.Sprintf("%.2f %s", q.SubtotalAmount, currency)),
+		emailRowHTML("Shipping", fmt.Sprintf("%.2f %s", q.ShippingFee, currency)),
+		emailRowHTML("Total", fmt.Sprintf("%.2f %s", q.TotalAmount, currency)),
+	}
+	if validUntil != "" {
+		summary = append(summary, emailRowHTML("Valid until", validUntil))
+	}
+	inner := fmt.Sprintf("<p style=\"margin:0 0 10px 0\">Thank you for your request. Here is our quote <b>%s</b>.</p>", escapeHTML(q.QuoteNumber)) +
+		table + emailRowsHTML(summary...)
+	if q.QuoteNotes != "" {
+		inner += "<p style=\"margin:14px 0 0 0;font-size:13px;color:#374151;white-space:pre-line\">" + escapeHTML(q.QuoteNotes) + "</p>"
+	}
+	inner += emailButtonHTML("View and accept quote", link)
+	html = customerEmailHTML("Your quote is ready", inner)
+	return subject, text, html
+}
+
+// SendQuoteReadyEmail emails the priced quote to the customer.
+func SendQuoteReadyEmail(db *gorm.DB, siteURL string, q models.QuoteRequest) error {
+	subj, txt, html := BuildQuoteReadyEmail(siteURL, q)
+	return SendEmail(db, EmailSendOptions{
+		To:      q.CustomerEmail,
+		Subject: subj,
+		Text:    txt,
+		HTML:    html,
+		Headers: map[string]string{"X-Entity-Ref-ID": "quote:" + q.QuoteNumber},
+	})
+}
+
+// NotifyAdminQuoteRequested tells the order notification recipients about a new RFQ.
+func NotifyAdminQuoteRequested(db *gorm.DB, siteURL string, q models.QuoteRequest) error {
+	s, err := GetOrCreateEmailSetting(db)
+	if err != nil {
+		return err
+	}
+	if !s.Enabled || !(s.OrderCreatedNotificationsEnabled || s.OrderNotificationsEnabled) {
+		return nil
+	}
+	_, recipients, err := NormalizeEmailRecipients(s.OrderNotificationEmails)
+	if err != nil || len(recipients) == 0 {
+		return err
+	}
+
+	lines := make([]string, 0, len(q.Items))
+	for _, it := range q.Items {
+		lines = append(lines, fmt.Sprintf("- %s x%d (%s)", it.SKU, it.Quantity, it.Condition))
+	}
+	subj := fmt.Sprintf("New quote request %s from %s", q.QuoteNumber, fallbackStr(q.CompanyName, q.CustomerName))
+	txt := fmt.Sprintf("Quote request: %s\nCustomer: %s <%s>\nCompany: %s\nCountry: %s\n\n%s\n", q.QuoteNumber, q.CustomerName, q.CustomerEmail, q.CompanyName, q.ShippingCountry, strings.Join(lines, "\n"))
+	if q.Message != "" {
+		txt += "\nMessage:\n" + q.Message + "\n"
+	}
+	if siteURL != "" {
+		txt += optionalLine("\nAdmin", strings.TrimRight(siteURL, "/")+"/admin/quotes/"+fmt.Sprintf("%d", q.ID))
+	}
+
+	var lastErr error
+	for _, to := range recipients {
+		if e := SendEmail(db, EmailSendOptions{To: to, Subject: subj, Text: txt, Headers: map[string]string{"X-Entity-Ref-ID": "admin-quote:" + q.QuoteNumber}}); e != nil {
+			lastErr = e
+		}
+	}
+	return lastErr
+}
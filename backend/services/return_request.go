@@ -0,0 +1,803 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReturnError is a validation/state error the caller can show as-is.
+type ReturnError struct {
+	Message string
+}
+
+func (e *ReturnError) Error() string { return e.Message }
+
+// ReturnLineInput returns a quantity of one order line.
+type ReturnLineInput struct {
+	OrderItemID uint `json:"order_item_id"`
+	Quantity    int  `json:"quantity"`
+}
+
+// ReturnRequestInput is a customer's return request for one order.
+type ReturnRequestInput struct {
+	CustomerID    uint
+	CustomerEmail string
+	OrderID       uint
+	Reason        string
+	Description   string
+	Lines         []ReturnLineInput
+}
+
+// ReturnApproveInput issues the RMA number and tells the customer how to send the part back.
+type ReturnApproveInput struct {
+	RMANumber          string // optional; generated from the RMA sequence when empty
+	ReturnInstructions string
+	AdminNotes         string
+	AdminID            *uint
+}
+
+// ReturnReceiveInput books the returned parcel. With no Lines every requested unit arrived.
+type ReturnReceiveInput struct {
+	Lines          []ReturnLineInput
+	Carrier        string
+	TrackingNumber string
+	Note           string
+	AdminID        *uint
+}
+
+// ReturnResolveInput closes an inspected return.
+//   - refund: refunds the received units through CreateOrderRefund (Amount overrides the line value)
+//   - replacement: takes the replacement units out of stock and records the outbound parcel
+//   - repair: records the outbound parcel of the repaired part
+type ReturnResolveInput struct {
+	Resolution      string
+	Notes           string
+	Amount          *float64
+	IncludeShipping bool
+	Restock         bool
+	ViaProvider     bool
+	OutboundCarrier string
+	OutboundTrack   string
+	AdminID         *uint
+}
+
+var returnReasons = map[string]bool{"warranty": true, "wrong_part": true, "damaged": true, "not_as_described": true, "other": true}
+
+// returnTransitions lists, per return status, the statuses a return may move to.
+var returnTransitions = map[string][]string{
+	models.ReturnStatusRequested: {models.ReturnStatusApproved, models.ReturnStatusRejected, models.ReturnStatusCancelled},
+	models.ReturnStatusApproved:  {models.ReturnStatusReceived, models.ReturnStatusCancelled},
+	models.ReturnStatusReceived:  {models.ReturnStatusInspected},
+	models.ReturnStatusInspected: {models.ReturnStatusResolved},
+	models.ReturnStatusRejected:  {},
+	models.ReturnStatusResolved:  {},
+	models.ReturnStatusCancelled: {},
+}
+
+// returnActiveStatuses are returns whose units count against what can still be returned.
+var returnActiveStatuses = []string{models.ReturnStatusRequested, models.ReturnStatusApproved, models.ReturnStatusReceived, models.ReturnStatusInspected, models.ReturnStatusResolved}
+
+// AllowedReturnTransitions returns the statuses reachable from the given return status.
+func AllowedReturnTransitions(from string) []string {
+	return append([]string(nil), returnTransitions[from]...)
+}
+
+func checkReturnTransition(r *models.ReturnRequest, to string) error {
+	if !containsStatus(returnTransitions[r.Status], to) {
+		return &ReturnError{Message: fmt.Sprintf("Return %s is %s and cannot be %s", r.DisplayNumber(), r.Status, to)}
+	}
+	return nil
+}
+
+// shippedUnits is how many units of a line reached the customer. Orders shipped before
+// shipments existed have no ShippedQuantity; their whole quantity counts once shipped.
+func shippedUnits(order models.Order, item models.OrderItem) int {
+	if item.ShippedQuantity > 0 || (order.FulfillmentStatus != models.FulfillmentUnfulfilled && order.FulfillmentStatus != "") {
+		return item.ShippedQuantity
+	}
+	if order.Status == models.OrderStatusShipped || order.Status == models.OrderStatusDelivered {
+		return item.Quantity
+	}
+	return 0
+}
+
+// ReturnableQuantities returns, per order item ID, the shipped units not already in a return.
+func ReturnableQuantities(db *gorm.DB, order models.Order) (map[uint]int, error) {
+	var rows []struct {
+		OrderItemID uint
+		Qty         int
+	}
+	if err := db.Table("return_request_items").
+		Select("return_request_items.order_item_id, SUM(return_request_items.quantity) AS qty").
+		Joins("JOIN return_requests ON return_requests.id = return_request_items.return_request_id").
+		Where("return_requests.order_id = ? AND return_requests.status IN ?", order.ID, returnActiveStatuses).
+		Group("return_request_items.order_item_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	pending := map[uint]int{}
+	for _, r := range rows {
+		pending[r.OrderItemID] = r.Qty
+	}
+	out := map[uint]int{}
+	for _, it := range order.Items {
+		out[it.ID] = max(shippedUnits(order, it)-pending[it.ID], 0)
+	}
+	return out, nil
+}
+
+// CreateReturnRequest validates and stores a customer's return request and opens a support
+// ticket for it, so questions about the return stay in one conversation.
+func CreateReturnRequest(db *gorm.DB, in ReturnRequestInput) (*models.ReturnRequest, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	reason := strings.ToLower(strings.TrimSpace(in.Reason))
+	if !returnReasons[reason] {
+		return nil, &ReturnError{Message: "Reason must be warranty, wrong_part, damaged, not_as_described or other"}
+	}
+	if len(in.Lines) == 0 {
+		return nil, &ReturnError{Message: "Select at least one item to return"}
+	}
+
+	var r models.ReturnRequest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the order so two concurrent requests cannot return the same units.
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").First(&order, in.OrderID).Error; err != nil {
+			return err
+		}
+		if (order.CustomerID == nil || *order.CustomerID != in.CustomerID) && !strings.EqualFold(order.CustomerEmail, in.CustomerEmail) {
+			return gorm.ErrRecordNotFound
+		}
+		returnable, err := ReturnableQuantities(tx, order)
+		if err != nil {
+			return err
+		}
+		itemsByID := map[uint]models.OrderItem{}
+		for _, it := range order.Items {
+			itemsByID[it.ID] = it
+		}
+
+		r = models.ReturnRequest{
+			OrderID:     order.ID,
+			CustomerID:  in.CustomerID,
+			Reason:      reason,
+			Description: strings.TrimSpace(in.Description),
+			Status:      models.ReturnStatusRequested,
+		}
+		lines := map[uint]int{}
+		for _, l := range in.Lines {
+			it, ok := itemsByID[l.OrderItemID]
+			if !ok {
+				return &ReturnError{Message: fmt.Sprintf("Order item %d does not belong to this order", l.OrderItemID)}
+			}
+			if l.Quantity <= 0 {
+				return &ReturnError{Message: "Return quantity must be positive"}
+			}
+			lines[it.ID] += l.Quantity
+			if left := returnable[it.ID]; lines[it.ID] > left {
+				return &ReturnError{Message: fmt.Sprintf("Only %d of %s can be returned", left, fallbackStr(it.DisplaySKU(), "this item"))}
+			}
+		}
+		for _, it := range order.Items {
+			if q := lines[it.ID]; q > 0 {
+				r.Items = append(r.Items, models.ReturnRequestItem{OrderItemID: it.ID, Quantity: q})
+			}
+		}
+		if err := tx.Create(&r).Error; err != nil {
+			return err
+		}
+
+		ticket := models.Ticket{
+			TicketNumber: fmt.Sprintf("TKT-RMA-%d", r.ID),
+			CustomerID:   in.CustomerID,
+			Subject:      fmt.Sprintf("Return request for order %s", orderDisplayNumber(order)),
+			Message:      returnTicketMessage(r, order),
+			Category:     "return",
+			Priority:     "normal",
+			Status:       "open",
+			OrderID:      &order.ID,
+			OrderNumber:  order.OrderNumber,
+		}
+		if err := tx.Create(&ticket).Error; err != nil {
+			return err
+		}
+		r.TicketID = &ticket.ID
+		if err := tx.Model(&models.ReturnRequest{}).Where("id = ?", r.ID).Update("ticket_id", ticket.ID).Error; err != nil {
+			return err
+		}
+		return RecordOrderHistory(tx, order.ID, "event", "", "return_requested", ActorCustomer, fmt.Sprintf("%s: %s", r.DisplayNumber(), reason))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func returnTicketMessage(r models.ReturnRequest, order models.Order) string {
+	qty := map[uint]int{}
+	for _, it := range r.Items {
+		qty[it.OrderItemID] = it.Quantity
+	}
+	msg := fmt.Sprintf("Return %s\nReason: %s\n\nItems:\n%s\n", r.DisplayNumber(), strings.ReplaceAll(r.Reason, "_", " "), emailItemsText(order.Items, qty))
+	if r.Description != "" {
+		msg += "\n" + r.Description + "\n"
+	}
+	return msg
+}
+
+// loadReturnForUpdate locks a return for a status change.
+func loadReturnForUpdate(tx *gorm.DB, id uint) (*models.ReturnRequest, error) {
+	var r models.ReturnRequest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").First(&r, id).Error; err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// addReturnTicketReply posts a staff reply on the return's ticket and moves the ticket along.
+func addReturnTicketReply(tx *gorm.DB, r *models.ReturnRequest, adminID *uint, message string) error {
+	if r.TicketID == nil {
+		return nil
+	}
+	reply := models.TicketReply{TicketID: *r.TicketID, AdminUserID: adminID, Message: message, IsStaff: true}
+	if err := tx.Create(&reply).Error; err != nil {
+		return err
+	}
+	updates := map[string]interface{}{"status": "in-progress"}
+	switch r.Status {
+	case models.ReturnStatusResolved, models.ReturnStatusRejected, models.ReturnStatusCancelled:
+		now := time.Now()
+		updates["status"] = "resolved"
+		updates["resolved_at"] = &now
+	}
+	return tx.Model(&models.Ticket{}).Where("id = ?", *r.TicketID).Updates(updates).Error
+}
+
+// ApproveReturnRequest issues the RMA number and return instructions.
+func ApproveReturnRequest(db *gorm.DB, id uint, in ReturnApproveInput) (*models.ReturnRequest, error) {
+	var r *models.ReturnRequest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if r, err = loadReturnForUpdate(tx, id); err != nil {
+			return err
+		}
+		if err := checkReturnTransition(r, models.ReturnStatusApproved); err != nil {
+			return err
+		}
+		number := strings.TrimSpace(in.RMANumber)
+		if number == "" {
+			n, err := NextDocumentNumber(tx, "rma")
+			if err != nil {
+				return err
+			}
+			number = fmt.Sprintf("RMA-%d-%05d", time.Now().Year(), n)
+		} else {
+			var dup int64
+			tx.Model(&models.ReturnRequest{}).Where("rma_number = ? AND id <> ?", number, r.ID).Count(&dup)
+			if dup > 0 {
+				return &ReturnError{Message: fmt.Sprintf("RMA number %s is already in use", number)}
+			}
+		}
+
+		now := time.Now()
+		r.RMANumber = &number
+		r.Status = models.ReturnStatusApproved
+		r.ReturnInstructions = strings.TrimSpace(in.ReturnInstructions)
+		r.AdminNotes = strings.TrimSpace(in.AdminNotes)
+		r.ReviewedAt = &now
+		r.ReviewedByID = in.AdminID
+		if err := tx.Omit("Items", "Photos").Save(r).Error; err != nil {
+			return err
+		}
+		if err := RecordOrderHistory(tx, r.OrderID, "event", "", "return_approved", AdminActor(in.AdminID), number); err != nil {
+			return err
+		}
+		msg := fmt.Sprintf("Your return has been approved. RMA number: %s", number)
+		if r.ReturnInstructions != "" {
+			msg += "\n\n" + r.ReturnInstructions
+		}
+		return addReturnTicketReply(tx, r, in.AdminID, msg)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// RejectReturnRequest declines a return request.
+func RejectReturnRequest(db *gorm.DB, id uint, reason string, adminID *uint) (*models.ReturnRequest, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, &ReturnError{Message: "Please give the customer a reason"}
+	}
+	var r *models.ReturnRequest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if r, err = loadReturnForUpdate(tx, id); err != nil {
+			return err
+		}
+		if err := checkReturnTransition(r, models.ReturnStatusRejected); err != nil {
+			return err
+		}
+		now := time.Now()
+		r.Status = models.ReturnStatusRejected
+		r.RejectionReason = reason
+		r.ReviewedAt = &now
+		r.ReviewedByID = adminID
+		if err := tx.Omit("Items", "Photos").Save(r).Error; err != nil {
+			return err
+		}
+		if err := RecordOrderHistory(tx, r.OrderID, "event", "", "return_rejected", AdminActor(adminID), reason); err != nil {
+			return err
+		}
+		return addReturnTicketReply(tx, r, adminID, "Your return request was declined: "+reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// CancelReturnRequest withdraws a return the customer has not shipped yet.
+func CancelReturnRequest(db *gorm.DB, id uint, customerID uint) (*models.ReturnRequest, error) {
+	var r *models.ReturnRequest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if r, err = loadReturnForUpdate(tx, id); err != nil {
+			return err
+		}
+		if r.CustomerID != customerID {
+			return gorm.ErrRecordNotFound
+		}
+		if err := checkReturnTransition(r, models.ReturnStatusCancelled); err != nil {
+			return err
+		}
+		r.Status = models.ReturnStatusCancelled
+		if err := tx.Model(&models.ReturnRequest{}).Where("id = ?", r.ID).Update("status", r.Status).Error; err != nil {
+			return err
+		}
+		if err := RecordOrderHistory(tx, r.OrderID, "event", "", "return_cancelled", ActorCustomer, r.DisplayNumber()); err != nil {
+			return err
+		}
+		return addReturnTicketReply(tx, r, nil, "Return cancelled by the customer.")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ReceiveReturnRequest books the returned parcel at the warehouse.
+func ReceiveReturnRequest(db *gorm.DB, id uint, in ReturnReceiveInput) (*models.ReturnRequest, error) {
+	var r *models.ReturnRequest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if r, err = loadReturnForUpdate(tx, id); err != nil {
+			return err
+		}
+		if err := checkReturnTransition(r, models.ReturnStatusReceived); err != nil {
+			return err
+		}
+
+		received := map[uint]int{}
+		if len(in.Lines) == 0 {
+			for _, it := range r.Items {
+				received[it.OrderItemID] = it.Quantity
+			}
+		} else {
+			requested := map[uint]int{}
+			for _, it := range r.Items {
+				requested[it.OrderItemID] = it.Quantity
+			}
+			for _, l := range in.Lines {
+				want, ok := requested[l.OrderItemID]
+				if !ok {
+					return &ReturnError{Message: fmt.Sprintf("Order item %d is not part of this return", l.OrderItemID)}
+				}
+				if l.Quantity < 0 {
+					return &ReturnError{Message: "Received quantity cannot be negative"}
+				}
+				received[l.OrderItemID] += l.Quantity
+				if received[l.OrderItemID] > want {
+					return &ReturnError{Message: fmt.Sprintf("Only %d units of order item %d were authorized", want, l.OrderItemID)}
+				}
+			}
+		}
+		total := 0
+		for i := range r.Items {
+			q := received[r.Items[i].OrderItemID]
+			total += q
+			if err := tx.Model(&models.ReturnRequestItem{}).Where("id = ?", r.Items[i].ID).Update("received_quantity", q).Error; err != nil {
+				return err
+			}
+			r.Items[i].ReceivedQuantity = q
+		}
+		if total == 0 {
+			return &ReturnError{Message: "Nothing was received"}
+		}
+
+		now := time.Now()
+		r.Status = models.ReturnStatusReceived
+		r.ReceivedAt = &now
+		if v := strings.TrimSpace(in.Carrier); v != "" {
+			r.ReturnCarrier = v
+		}
+		if v := strings.TrimSpace(in.TrackingNumber); v != "" {
+			r.ReturnTrackingNumber = v
+		}
+		if note := strings.TrimSpace(in.Note); note != "" {
+			r.AdminNotes = strings.TrimSpace(r.AdminNotes + "\n" + note)
+		}
+		if err := tx.Omit("Items", "Photos").Save(r).Error; err != nil {
+			return err
+		}
+		if err := RecordOrderHistory(tx, r.OrderID, "event", "", "return_received", AdminActor(in.AdminID), r.DisplayNumber()); err != nil {
+			return err
+		}
+		return addReturnTicketReply(tx, r, in.AdminID, "We have received your returned part and will inspect it shortly.")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// InspectReturnRequest records the inspection result of the received part.
+func InspectReturnRequest(db *gorm.DB, id uint, passed bool, notes string, adminID *uint) (*models.ReturnRequest, error) {
+	var r *models.ReturnRequest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if r, err = loadReturnForUpdate(tx, id); err != nil {
+			return err
+		}
+		if err := checkReturnTransition(r, models.ReturnStatusInspected); err != nil {
+			return err
+		}
+		now := time.Now()
+		r.Status = models.ReturnStatusInspected
+		r.InspectionPassed = &passed
+		r.InspectionNotes = strings.TrimSpace(notes)
+		r.InspectedAt = &now
+		if err := tx.Omit("Items", "Photos").Save(r).Error; err != nil {
+			return err
+		}
+		result := "passed"
+		if !passed {
+			result = "failed"
+		}
+		if err := RecordOrderHistory(tx, r.OrderID, "event", "", "return_inspected", AdminActor(adminID), r.DisplayNumber()+": inspection "+result); err != nil {
+			return err
+		}
+		msg := "Inspection of your returned part is complete."
+		if r.InspectionNotes != "" {
+			msg += "\n\n" + r.InspectionNotes
+		}
+		return addReturnTicketReply(tx, r, adminID, msg)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ResolveReturnRequest closes an inspected return as a refund, replacement or repair.
+func ResolveReturnRequest(ctx context.Context, db *gorm.DB, id uint, in ReturnResolveInput) (*models.ReturnRequest, *RefundResult, error) {
+	resolution := strings.ToLower(strings.TrimSpace(in.Resolution))
+	switch resolution {
+	case models.ReturnResolutionRefund, models.ReturnResolutionReplacement, models.ReturnResolutionRepair:
+	default:
+		return nil, nil, &ReturnError{Message: "Resolution must be refund, replacement or repair"}
+	}
+
+	var r models.ReturnRequest
+	if err := db.Preload("Items").First(&r, id).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := checkReturnTransition(&r, models.ReturnStatusResolved); err != nil {
+		return nil, nil, err
+	}
+
+	// The refund may go through the payment provider, so it runs before (and outside) the
+	// transaction that closes the return, like any admin refund. The return is claimed first
+	// (resolution set while still inspected) so a concurrent resolve cannot refund it twice.
+	var refund *RefundResult
+	if resolution == models.ReturnResolutionRefund {
+		claim := db.Model(&models.ReturnRequest{}).
+			Where("id = ? AND status = ? AND (resolution = '' OR resolution IS NULL)", r.ID, models.ReturnStatusInspected).
+			Update("resolution", resolution)
+		if claim.Error != nil {
+			return nil, nil, claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			return nil, nil, &ReturnError{Message: fmt.Sprintf("Return %s is already being resolved", r.DisplayNumber())}
+		}
+		release := func() {
+			db.Model(&models.ReturnRequest{}).Where("id = ? AND status = ?", r.ID, models.ReturnStatusInspected).Update("resolution", "")
+		}
+
+		lines := make([]RefundLineInput, 0, len(r.Items))
+		for _, it := range r.Items {
+			if it.ReceivedQuantity > 0 {
+				lines = append(lines, RefundLineInput{OrderItemID: it.OrderItemID, Quantity: it.ReceivedQuantity})
+			}
+		}
+		var err error
+		refund, _, err = CreateOrderRefund(ctx, db, r.OrderID, RefundInput{
+			Lines:           lines,
+			Amount:          in.Amount,
+			IncludeShipping: in.IncludeShipping,
+			Restock:         in.Restock,
+			Reason:          fmt.Sprintf("Return %s", r.DisplayNumber()),
+			ViaProvider:     in.ViaProvider,
+			ActorAdminID:    in.AdminID,
+		})
+		if err != nil {
+			release()
+			var refundErr *RefundError
+			if errors.As(err, &refundErr) {
+				return nil, nil, &ReturnError{Message: refundErr.Message}
+			}
+			return nil, nil, err
+		}
+	}
+
+	var out *models.ReturnRequest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if out, err = loadReturnForUpdate(tx, id); err != nil {
+			return err
+		}
+		if err := checkReturnTransition(out, models.ReturnStatusResolved); err != nil {
+			return err
+		}
+		if refund == nil && out.Resolution != "" {
+			// A refund resolution claimed the return in the meantime.
+			return &ReturnError{Message: fmt.Sprintf("Return %s is already being resolved", out.DisplayNumber())}
+		}
+
+		if resolution == models.ReturnResolutionReplacement {
+			for _, it := range out.Items {
+				if it.ReceivedQuantity <= 0 {
+					continue
+				}
+				var item models.OrderItem
+				if err := tx.First(&item, it.OrderItemID).Error; err != nil {
+					return err
+				}
+				res := tx.Model(&models.Product{}).Where("id = ? AND stock_quantity >= ?", item.ProductID, it.ReceivedQuantity).
+					UpdateColumn("stock_quantity", gorm.Expr("stock_quantity - ?", it.ReceivedQuantity))
+				if res.Error != nil {
+					return res.Error
+				}
+				if res.RowsAffected == 0 {
+					return &ReturnError{Message: fmt.Sprintf("Not enough stock of %s for a replacement", fallbackStr(item.DisplaySKU(), "this item"))}
+				}
+			}
+		}
+
+		now := time.Now()
+		out.Status = models.ReturnStatusResolved
+		out.Resolution = resolution
+		out.ResolutionNotes = strings.TrimSpace(in.Notes)
+		out.OutboundCarrier = strings.TrimSpace(in.OutboundCarrier)
+		out.OutboundTrackingNumber = strings.TrimSpace(in.OutboundTrack)
+		out.ResolvedAt = &now
+		if refund != nil {
+			out.RefundTransactionID = &refund.Transaction.ID
+			out.RefundAmount = refund.Amount
+		}
+		if err := tx.Omit("Items", "Photos").Save(out).Error; err != nil {
+			return err
+		}
+		if err := RecordOrderHistory(tx, out.OrderID, "event", "", "return_resolved", AdminActor(in.AdminID), out.DisplayNumber()+": "+resolution); err != nil {
+			return err
+		}
+		currency := ""
+		if refund != nil {
+			currency = refund.Transaction.Currency
+		}
+		return addReturnTicketReply(tx, out, in.AdminID, returnResolutionSummary(*out, currency))
+	})
+	if err != nil {
+		if refund != nil {
+			return nil, nil, fmt.Errorf("refund %s was recorded but the return could not be closed: %w", refund.Transaction.TransactionID, err)
+		}
+		return nil, nil, err
+	}
+	return out, refund, nil
+}
+
+// returnResolutionSummary describes the outcome of a resolved return in one paragraph.
+func returnResolutionSummary(r models.ReturnRequest, currency string) string {
+	var msg string
+	switch r.Resolution {
+	case models.ReturnResolutionRefund:
+		msg = "Your return has been refunded."
+		if r.RefundAmount > 0 && currency != "" {
+			msg = fmt.Sprintf("Your return has been refunded: %.2f %s.", r.RefundAmount, currency)
+		}
+	case models.ReturnResolutionReplacement:
+		msg = "A replacement part is on its way."
+	case models.ReturnResolutionRepair:
+		msg = "Your part has been repaired and is on its way back."
+	}
+	if r.OutboundTrackingNumber != "" {
+		msg += fmt.Sprintf(" Carrier: %s, tracking number: %s.", fallbackStr(r.OutboundCarrier, "-"), r.OutboundTrackingNumber)
+	}
+	if r.ResolutionNotes != "" {
+		msg += "\n\n" + r.ResolutionNotes
+	}
+	return msg
+}
+
+// returnAccountURL is the customer's order page, where returns are listed.
+func returnAccountURL(siteURL string, r models.ReturnRequest) string {
+	if strings.TrimSpace(siteURL) == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/account/orders/%d", strings.TrimRight(siteURL, "/"), r.OrderID)
+}
+
+// BuildReturnStatusEmail tells the customer where their return stands. r needs Items.OrderItem
+// and Order loaded.
+func BuildReturnStatusEmail(siteURL string, r models.ReturnRequest) (subject, text, html string) {
+	number := r.DisplayNumber()
+	orderNo := ""
+	currency := "USD"
+	if r.Order != nil {
+		orderNo = orderDisplayNumber(*r.Order)
+		currency = fallbackStr(r.Order.Currency, currency)
+	}
+
+	var headline, details string
+	switch r.Status {
+	case models.ReturnStatusRequested:
+		subject = fmt.Sprintf("We received your return request for order %s", orderNo)
+		headline = "We received your return request and will review it shortly."
+	case models.ReturnStatusApproved:
+		subject = fmt.Sprintf("Return %s approved", number)
+		headline = fmt.Sprintf("Your return has been approved. Please write RMA number %s clearly on the parcel.", number)
+		details = r.ReturnInstructions
+	case models.ReturnStatusRejected:
+		subject = fmt.Sprintf("Update on your return request for order %s", orderNo)
+		headline = "Unfortunately we cannot accept this return."
+		details = r.RejectionReason
+	case models.ReturnStatusReceived:
+		subject = fmt.Sprintf("Return %s received", number)
+		headline = "We have received your returned part and will inspect it shortly."
+	case models.ReturnStatusInspected:
+		subject = fmt.Sprintf("Return %s inspected", number)
+		headline = "Inspection of your returned part is complete."
+		details = r.InspectionNotes
+	case models.ReturnStatusResolved:
+		subject = fmt.Sprintf("Return %s completed", number)
+		headline = returnResolutionSummary(models.ReturnRequest{Resolution: r.Resolution, RefundAmount: r.RefundAmount}, currency)
+		details = r.ResolutionNotes
+	default:
+		subject = fmt.Sprintf("Return %s %s", number, r.Status)
+		headline = fmt.Sprintf("Your return is now %s.", r.Status)
+	}
+
+	items := make([]models.OrderItem, 0, len(r.Items))
+	qty := map[uint]int{}
+	for _, it := range r.Items {
+		if it.OrderItem != nil {
+			items = append(items, *it.OrderItem)
+			qty[it.OrderItemID] = it.Quantity
+		}
+	}
+	link := returnAccountURL(siteURL, r)
+
+	body := headline + "\n\n" + fmt.Sprintf("Return: %s\nOrder: %s\n", number, orderNo)
+	if r.Status == models.ReturnStatusResolved && r.OutboundTrackingNumber != "" {
+		body += fmt.Sprintf("Carrier: %s\nTracking number: %s\n", fallbackStr(r.OutboundCarrier, "-"), r.OutboundTrackingNumber)
+	}
+	if len(items) > 0 {
+		body += "\nItems:\n" + emailItemsText(items, qty) + "\n"
+	}
+	if details != "" {
+		body += "\n" + details + "\n"
+	}
+	body += optionalLine("\nView your order", link)
+	text = customerEmailText(body)
+
+	rows := []string{emailRowHTML("Return", number), emailRowHTML("Order", orderNo)}
+	if r.Status == models.ReturnStatusResolved && r.OutboundTrackingNumber != "" {
+		rows = append(rows, emailRowHTML("Carrier", fallbackStr(r.OutboundCarrier, "-")), emailRowHTML("Tracking number", r.OutboundTrackingNumber))
+	}
+	inner := "<p style=\"margin:0 0 10px 0\">" + escapeHTML(headline) + "</p>" + emailRowsHTML(rows...)
+	if len(items) > 0 {
+		inner += emailItemsTableHTML("Items in this return", items, qty)
+	}
+	if details != "" {
+		inner += "<p style=\"margin:14px 0 0 0;font-size:13px;color:#374151;white-space:pre-line\">" + escapeHTML(details) + "</p>"
+	}
+	inner += emailButtonHTML("View order", link)
+	html = customerEmailHTML("Return update", inner)
+	return subject, text, html
+}
+
+// SendReturnStatusEmail loads what the email needs and sends it to the customer.
+func SendReturnStatusEmail(db *gorm.DB, siteURL string, returnID uint) error {
+	var r models.ReturnRequest
+	if err := db.Preload("Items.OrderItem").Preload("Order").Preload("Customer").First(&r, returnID).Error; err != nil {
+		return err
+	}
+	to := ""
+	if r.Order != nil {
+		to = r.Order.CustomerEmail
+	}
+	if r.Customer != nil && r.Customer.Email != "" {
+		to = r.Customer.Email
+	}
+	if to == "" {
+		return nil
+	}
+	subj, txt, html := BuildReturnStatusEmail(siteURL, r)
+	return SendEmail(db, EmailSendOptions{
+		To:      to,
+		Subject: subj,
+		Text:    txt,
+		HTML:    html,
+		Headers: map[string]string{"X-Entity-Ref-ID": fmt.Sprintf("return:%d:%s", r.ID, r.Status)},
+	})
+}
+
+// NotifyAdminReturnRequested tells the order notification recipients about a new return request.
+func NotifyAdminReturnRequested(db *gorm.DB, siteURL string, returnID uint) error {
+	s, err := GetOrCreateEmailSetting(db)
+	if err != nil {
+		return err
+	}
+	if !s.Enabled || !s.OrderNotificationsEnabled {
+		return nil
+	}
+	_, recipients, err := NormalizeEmailRecipients(s.OrderNotificationEmails)
+	if err != nil || len(recipients) == 0 {
+		return err
+	}
+
+	var r models.ReturnRequest
+	if err := db.Preload("Items.OrderItem").Preload("Order").Preload("Photos").First(&r, returnID).Error; err != nil {
+		return err
+	}
+	orderNo := ""
+	customer := ""
+	if r.Order != nil {
+		orderNo = orderDisplayNumber(*r.Order)
+		customer = fmt.Sprintf("%s <%s>", r.Order.CustomerName, r.Order.CustomerEmail)
+	}
+	lines := make([]string, 0, len(r.Items))
+	for _, it := range r.Items {
+		sku := fmt.Sprintf("item %d", it.OrderItemID)
+		if it.OrderItem != nil {
+			sku = fallbackStr(it.OrderItem.DisplaySKU(), sku)
+		}
+		lines = append(lines, fmt.Sprintf("- %s x%d", sku, it.Quantity))
+	}
+	subj := fmt.Sprintf("New return request for order %s (%s)", orderNo, strings.ReplaceAll(r.Reason, "_", " "))
+	txt := fmt.Sprintf("Return: %s\nOrder: %s\nCustomer: %s\nReason: %s\n\n%s\n", r.DisplayNumber(), orderNo, customer, r.Reason, strings.Join(lines, "\n"))
+	if r.Description != "" {
+		txt += "\nDescription:\n" + r.Description + "\n"
+	}
+	if siteURL != "" {
+		txt += optionalLine("\nAdmin", strings.TrimRight(siteURL, "/")+"/admin/returns/"+fmt.Sprintf("%d", r.ID))
+	}
+
+	var lastErr error
+	for _, to := range recipients {
+		if e := SendEmail(db, EmailSendOptions{To: to, Subject: subj, Text: txt, Headers: map[string]string{"X-Entity-Ref-ID": fmt.Sprintf("admin-return:%d", r.ID)}}); e != nil {
+			lastErr = e
+		}
+	}
+	return lastErr
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ShipmentError is a shipment request the admin can fix (bad line/quantity/order status).
+type ShipmentError struct {
+	Message string
+}
+
+func (e *ShipmentError) Error() string { return e.Message }
+
+// ShipmentLineInput ships a quantity of one order line.
+type ShipmentLineInput struct {
+	OrderItemID uint `json:"order_item_id"`
+	Quantity    int  `json:"quantity"`
+}
+
+// ShipmentInput describes a parcel handed to a carrier. With no Lines, everything still
+// unshipped goes into the shipment.
+type ShipmentInput struct {
+	Carrier        string
+	TrackingNumber string
+	ShippedAt      *time.Time
+	Notes          string
+	Lines          []ShipmentLineInput
+	ActorAdminID   *uint
+}
+
+// UnshippedQuantity is how many units of a line still have to ship (refunded units never ship).
+func UnshippedQuantity(item models.OrderItem) int {
+	return max(item.Quantity-item.RefundedQuantity-item.ShippedQuantity, 0)
+}
+
+// OrderFulfillmentStatus derives the fulfillment status from the lines' shipped quantities.
+func OrderFulfillmentStatus(items []models.OrderItem) string {
+	shipped, open := 0, 0
+	for _, it := range items {
+		shipped += it.ShippedQuantity
+		open += UnshippedQuantity(it)
+	}
+	switch {
+	case shipped == 0:
+		return models.FulfillmentUnfulfilled
+	case open > 0:
+		return models.FulfillmentPartial
+	default:
+		return models.FulfillmentFulfilled
+	}
+}
+
+// CreateShipment records a shipment for a confirmed order, adds its quantities to the lines,
+// mirrors carrier/tracking onto the order and refreshes the fulfillment status. The first
+// partial shipment moves the order to processing; the one completing it moves it to shipped.
+func CreateShipment(db *gorm.DB, orderID uint, in ShipmentInput) (*models.Shipment, *models.Order, error) {
+	carrier := strings.TrimSpace(in.Carrier)
+	tracking := strings.TrimSpace(in.TrackingNumber)
+	if tracking == "" {
+		return nil, nil, &ShipmentError{Message: "Tracking number is required"}
+	}
+	shippedAt := time.Now()
+	if in.ShippedAt != nil {
+		shippedAt = *in.ShippedAt
+	}
+
+	var (
+		order    models.Order
+		shipment models.Shipment
+	)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+			return err
+		}
+		switch order.Status {
+		case models.OrderStatusConfirmed, models.OrderStatusProcessing, models.OrderStatusShipped:
+		case models.OrderStatusCancelled:
+			return &ShipmentError{Message: "Order has been cancelled"}
+		case models.OrderStatusDelivered:
+			return &ShipmentError{Message: "Order has already been delivered"}
+		default:
+			return &ShipmentError{Message: "Order must be confirmed before it can ship"}
+		}
+
+		var items []models.OrderItem
+		if err := tx.Where("order_id = ?", order.ID).Order("id ASC").Find(&items).Error; err != nil {
+			return err
+		}
+		itemsByID := map[uint]models.OrderItem{}
+		for _, it := range items {
+			itemsByID[it.ID] = it
+		}
+
+		lines := map[uint]int{}
+		if len(in.Lines) == 0 {
+			for _, it := range items {
+				if left := ShippableQuantity(it); left > 0 {
+					lines[it.ID] = left
+				}
+			}
+		} else {
+			for _, l := range in.Lines {
+				it, ok := itemsByID[l.OrderItemID]
+				if !ok {
+					return &ShipmentError{Message: fmt.Sprintf("Order item %d does not belong to this order", l.OrderItemID)}
+				}
+				if l.Quantity <= 0 {
+					return &ShipmentError{Message: "Shipment quantity must be positive"}
+				}
+				lines[it.ID] += l.Quantity
+				if left := ShippableQuantity(it); lines[it.ID] > left {
+					if it.BackorderedQuantity > 0 {
+						return &ShipmentError{Message: fmt.Sprintf("Only %d of %s can ship now, %d are backordered", left, fallbackStr(it.DisplaySKU(), "this item"), it.BackorderedQuantity)}
+					}
+					return &ShipmentError{Message: fmt.Sprintf("Only %d of %s still has to ship", left, fallbackStr(it.DisplaySKU(), "this item"))}
+				}
+			}
+		}
+		if len(lines) == 0 {
+			return &ShipmentError{Message: "Nothing left to ship on this order"}
+		}
+
+		ids := make([]uint, 0, len(lines))
+		for id := range lines {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+		shipment = models.Shipment{
+			OrderID:        order.ID,
+			Carrier:        carrier,
+			TrackingNumber: tracking,
+			ShippedAt:      shippedAt,
+			Notes:          strings.TrimSpace(in.Notes),
+			CreatedByID:    in.ActorAdminID,
+		}
+		for _, id := range ids {
+			shipment.Items = append(shipment.Items, models.ShipmentItem{OrderItemID: id, Quantity: lines[id]})
+		}
+		if err := tx.Create(&shipment).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			q := lines[items[i].ID]
+			if q == 0 {
+				continue
+			}
+			if err := tx.Model(&models.OrderItem{}).Where("id = ?", items[i].ID).
+				UpdateColumn("shipped_quantity", gorm.Expr("shipped_quantity + ?", q)).Error; err != nil {
+				return err
+			}
+			items[i].ShippedQuantity += q
+		}
+
+		// Older clients read the order-level fields; keep them on the latest parcel.
+		order.TrackingNumber = tracking
+		order.ShippingCarrier = carrier
+		if order.ShippedAt == nil {
+			order.ShippedAt = &shippedAt
+		}
+		if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Updates(map[string]interface{}{
+			"tracking_number":  order.TrackingNumber,
+			"shipping_carrier": order.ShippingCarrier,
+			"shipped_at":       order.ShippedAt,
+		}).Error; err != nil {
+			return err
+		}
+
+		actor := AdminActor(in.ActorAdminID)
+		note := fmt.Sprintf("shipment #%d: %s %s", shipment.ID, fallbackStr(carrier, "carrier not specified"), tracking)
+		if err := refreshFulfillmentStatus(tx, &order, items, actor, note); err != nil {
+			return err
+		}
+		switch {
+		case order.FulfillmentStatus == models.FulfillmentFulfilled && order.Status != models.OrderStatusShipped:
+			return TransitionOrderStatus(tx, &order, models.OrderStatusShipped, actor, note)
+		case order.Status == models.OrderStatusConfirmed:
+			return TransitionOrderStatus(tx, &order, models.OrderStatusProcessing, actor, note)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_ = db.Preload("Items.OrderItem").First(&shipment, shipment.ID).Error
+	_ = db.Preload("Items").First(&order, order.ID).Error
+	return &shipment, &order, nil
+}
+
+// refreshFulfillmentStatus stores the fulfillment status derived from items and logs a change.
+func refreshFulfillmentStatus(tx *gorm.DB, order *models.Order, items []models.OrderItem, actor StatusActor, note string) error {
+	from := fallbackStr(order.FulfillmentStatus, models.FulfillmentUnfulfilled)
+	to := OrderFulfillmentStatus(items)
+	if from == to {
+		return nil
+	}
+	if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Update("fulfillment_status", to).Error; err != nil {
+		return err
+	}
+	order.FulfillmentStatus = to
+	return RecordOrderHistory(tx, order.ID, "fulfillment_status", from, to, actor, note)
+}
+
+// ListOrderShipments returns an order's shipments, oldest first, with their lines.
+func ListOrderShipments(db *gorm.DB, orderID uint) ([]models.Shipment, error) {
+	var shipments []models.Shipment
+	err := db.Where("order_id = ?", orderID).
+		Preload("Items.OrderItem").
+		Order("shipped_at ASC, id ASC").
+		Find(&shipments).Error
+	return shipments, err
+}
+
+// MarkShipmentEmailSent stamps the shipment (and the order, for older clients) as notified.
+func MarkShipmentEmailSent(db *gorm.DB, shipment *models.Shipment) error {
+	now := time.Now()
+	if err := db.Model(&models.Shipment{}).Where("id = ?", shipment.ID).Update("email_sent_at", &now).Error; err != nil {
+		return err
+	}
+	shipment.EmailSentAt = &now
+	return db.Model(&models.Order{}).Where("id = ?", shipment.OrderID).Update("shipped_email_sent_at", &now).Error
+}
+
+// LegacyShipment wraps the order-level tracking fields as a shipment of the whole order, for
+// orders shipped by editing the order instead of creating a Shipment.
+func LegacyShipment(order models.Order) models.Shipment {
+	s := models.Shipment{
+		OrderID:        order.ID,
+		Carrier:        order.ShippingCarrier,
+		TrackingNumber: order.TrackingNumber,
+	}
+	if order.ShippedAt != nil {
+		s.ShippedAt = *order.ShippedAt
+	}
+	return s
+}
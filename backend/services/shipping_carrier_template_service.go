@@ -7,6 +7,7 @@ import (
 	"io"
 	"math"
 	"sort"
+	"strconv"
 	"strings"
 
 	"fanuc-backend/models"
@@ -109,7 +110,9 @@ func ListActiveCarrierShippingCountries(db *gorm.DB, carrier string, serviceCode
 	return out, nil
 }
 
-func CalculateCarrierShippingQuote(db *gorm.DB, carrier string, serviceCode string, countryCode string, weightKg float64) (ShippingQuoteResult, error) {
+// CalculateCarrierShippingQuote prices a parcel with a carrier service template, billed on the
+// chargeable weight with the template's volumetric divisor.
+func CalculateCarrierShippingQuote(db *gorm.DB, carrier string, serviceCode string, countryCode string, parcel ShippingParcel) (ShippingQuoteResult, error) {
 	if db == nil {
 		return ShippingQuoteResult{}, errors.New("db is nil")
 	}
@@ -123,10 +126,7 @@ func CalculateCarrierShippingQuote(db *gorm.DB, carrier string, serviceCode stri
 		return ShippingQuoteResult{}, errors.New("carrier is empty")
 	}
 
-	if weightKg < 0 {
-		weightKg = 0
-	}
-	if weightKg == 0 {
+	if parcel.ChargeableWeightKg(DefaultVolumetricDivisor) == 0 {
 		return ShippingQuoteResult{CountryCode: cc, Currency: "USD", WeightKg: 0, BillingWeight: 0, RatePerKg: 0, BaseQuote: 0, AdditionalFee: 0, ShippingFee: 0, Source: "carrier", Carrier: carrier, ServiceCode: serviceCode}, nil
 	}
 
@@ -164,6 +164,9 @@ func CalculateCarrierShippingQuote(db *gorm.DB, carrier string, serviceCode stri
 		return ShippingQuoteResult{}, errors.New("no weight brackets configured")
 	}
 
+	actualKg := math.Max(parcel.WeightKg, 0)
+	volumetricKg := parcel.VolumetricWeightKg(tpl.VolumetricDivisor)
+	weightKg := parcel.ChargeableWeightKg(tpl.VolumetricDivisor)
 	billingWeightKg := weightKg
 	if weightKg > 0 && weightKg < 21 {
 		// If template provides fixed-fee rows (min=max) under 21kg, round up to the nearest available row.
@@ -238,17 +241,22 @@ func CalculateCarrierShippingQuote(db *gorm.DB, carrier string, serviceCode stri
 	shippingFee := round2(baseQuote + extra)
 
 	return ShippingQuoteResult{
-		CountryCode:   cc,
-		Currency:      cur,
-		WeightKg:      round3(weightKg),
-		BillingWeight: round3(billingWeightKg),
-		RatePerKg:     round3(ratePerKg),
-		BaseQuote:     baseQuote,
-		AdditionalFee: round2(extra),
-		ShippingFee:   shippingFee,
-		Source:        "carrier",
-		Carrier:       tpl.Carrier,
-		ServiceCode:   tpl.ServiceCode,
+		CountryCode:      cc,
+		Currency:         cur,
+		WeightKg:         round3(actualKg),
+		VolumetricWeight: round3(volumetricKg),
+		ChargeableWeight: round3(weightKg),
+		BillingWeight:    round3(billingWeightKg),
+		RatePerKg:        round3(ratePerKg),
+		BaseQuote:        baseQuote,
+		AdditionalFee:    round2(extra),
+		ShippingFee:      shippingFee,
+		Source:           "carrier",
+		Carrier:          tpl.Carrier,
+		ServiceCode:      tpl.ServiceCode,
+
+		TransitDaysMin: tpl.TransitDaysMin,
+		TransitDaysMax: tpl.TransitDaysMax,
 	}, nil
 }
 
@@ -346,13 +354,20 @@ func GenerateCarrierZoneTemplateXLSX(opts CarrierZoneImportOptions) ([]byte, err
 	_ = f.SetCellValue(sMeta, "B2", serviceCode)
 	_ = f.SetCellValue(sMeta, "A3", "Currency")
 	_ = f.SetCellValue(sMeta, "B3", currency)
-	_ = f.SetCellValue(sMeta, "A5", "Notes")
-	_ = f.SetCellValue(sMeta, "B5", strings.Join([]string{
+	_ = f.SetCellValue(sMeta, "A4", "TransitDays")
+	_ = f.SetCellValue(sMeta, "B4", "")
+	_ = f.SetCellValue(sMeta, "A5", "VolumetricDivisor")
+	_ = f.SetCellValue(sMeta, "B5", DefaultVolumetricDivisor)
+	_ = f.SetCellValue(sMeta, "A6", "Notes")
+	_ = f.SetCellValue(sMeta, "B6", strings.Join([]string{
 		"1) Fill CountryZones: ISO2 country_code + zone.",
 		"2) Under21Kg_Zones: weights 0.5..20.5 (0.5 step) => FINAL shipping fee for that billed weight.",
 		"3) Over21Kg_Zones: >=21kg brackets => FINAL rate per kg.",
 		"4) If you are using the provided FedEx workbook (Fedex价格表2025上ebay.xlsx), you can keep its combined sheet \"加过利润的所有运费（含旺季附加费）\" as the rate source and ONLY add CountryZones, then upload.",
 		"5) US has zone 1/2 in some sheets; this system is country-level (no ZIP/state). Pick one zone or extend to region logic.",
+		"6) TransitDays (optional): business days shown to customers at checkout, e.g. 3-5.",
+		"7) VolumetricDivisor (optional): cm3 per kg of dimensional weight (FedEx/DHL: 5000). Parcels are billed on max(actual, L*W*H/divisor).",
+		"8) Optional: CountryZones columns currency / transit_days / volumetric_divisor override the values above for one country; a QuoteSurcharge sheet (country_code, country_name, quote_amount, additional_fee, currency) adds surcharges per country.",
 	}, "\n"))
 
 	// Country-zone map
@@ -457,6 +472,9 @@ type zoneRates struct {
 	Over21  map[string][]models.ShippingCarrierWeightBracket
 }
 
+// ImportCarrierZoneTemplatesFromXLSX upserts one carrier service's per-country templates from
+// the CountryZones mapping and the zone rate sheets. With replace, the brackets and surcharges
+// of each listed country are rebuilt from the file; countries not in CountryZones are untouched.
 func ImportCarrierZoneTemplatesFromXLSX(ctx context.Context, db *gorm.DB, r io.Reader, replace bool, override CarrierZoneImportOptions) (ShippingTemplateImportResult, error) {
 	if db == nil {
 		return ShippingTemplateImportResult{}, errors.New("db is nil")
@@ -467,18 +485,18 @@ func ImportCarrierZoneTemplatesFromXLSX(ctx context.Context, db *gorm.DB, r io.R
 	}
 	defer func() { _ = f.Close() }()
 
-	metaCarrier, metaService, metaCurrency := readCarrierMeta(f)
+	meta := readCarrierMeta(f)
 	carrier := NormalizeCarrier(override.Carrier)
 	service := NormalizeServiceCode(override.ServiceCode)
 	currency := strings.TrimSpace(strings.ToUpper(override.Currency))
 	if carrier == "" {
-		carrier = NormalizeCarrier(metaCarrier)
+		carrier = NormalizeCarrier(meta.Carrier)
 	}
 	if service == "" {
-		service = NormalizeServiceCode(metaService)
+		service = NormalizeServiceCode(meta.Service)
 	}
 	if currency == "" {
-		currency = strings.TrimSpace(strings.ToUpper(metaCurrency))
+		currency = strings.TrimSpace(strings.ToUpper(meta.Currency))
 	}
 	if currency == "" {
 		currency = "USD"
@@ -486,13 +504,28 @@ func ImportCarrierZoneTemplatesFromXLSX(ctx context.Context, db *gorm.DB, r io.R
 	if carrier == "" {
 		return ShippingTemplateImportResult{}, errors.New("missing carrier (set CarrierMeta or pass carrier param)")
 	}
+	transitMin, transitMax, transitOK := parseTransitDays(meta.Transit)
+	if meta.Transit != "" && !transitOK {
+		return ShippingTemplateImportResult{}, fmt.Errorf("invalid TransitDays %q (use e.g. 3-5)", meta.Transit)
+	}
+	divisor, divisorOK := parseVolumetricDivisor(meta.VolumetricDivisor)
+	if meta.VolumetricDivisor != "" && !divisorOK {
+		return ShippingTemplateImportResult{}, fmt.Errorf("invalid VolumetricDivisor %q (use e.g. 5000)", meta.VolumetricDivisor)
+	}
 
 	cz, czErrs := parseCountryZonesSheet(f, "CountryZones")
 	zr, zrErrs := parseZoneRatesSheets(f, "Under21Kg_Zones", "Over21Kg_Zones")
+	// Optional per-country surcharges, same layout as the default templates' QuoteSurcharge sheet.
+	quotes, qErrs := parseQuoteSheet(f)
+	surcharges := map[string][]quoteRow{}
+	for _, q := range quotes {
+		surcharges[q.CountryCode] = append(surcharges[q.CountryCode], q)
+	}
 
 	res := ShippingTemplateImportResult{Errors: []string{}}
 	res.Errors = append(res.Errors, czErrs...)
 	res.Errors = append(res.Errors, zrErrs...)
+	res.Errors = append(res.Errors, qErrs...)
 	if len(res.Errors) > 0 {
 		res.Failed = len(res.Errors)
 		return res, errors.New("invalid xlsx")
@@ -535,23 +568,54 @@ func ImportCarrierZoneTemplatesFromXLSX(ctx context.Context, db *gorm.DB, r io.R
 			name = cc
 		}
 
+		// Optional CountryZones columns override the CarrierMeta values for one country.
+		rowCurrency := currency
+		if v := strings.ToUpper(row.Currency); v != "" {
+			rowCurrency = v
+		}
+		rowTransitMin, rowTransitMax, rowTransitOK := transitMin, transitMax, transitOK
+		if row.TransitDays != "" {
+			if rowTransitMin, rowTransitMax, rowTransitOK = parseTransitDays(row.TransitDays); !rowTransitOK {
+				failed++
+				res.Errors = append(res.Errors, fmt.Sprintf("%s: invalid transit_days %q (use e.g. 3-5)", cc, row.TransitDays))
+				continue
+			}
+		}
+		rowDivisor, rowDivisorOK := divisor, divisorOK
+		if row.VolumetricDivisor != "" {
+			if rowDivisor, rowDivisorOK = parseVolumetricDivisor(row.VolumetricDivisor); !rowDivisorOK {
+				failed++
+				res.Errors = append(res.Errors, fmt.Sprintf("%s: invalid volumetric_divisor %q (use e.g. 5000)", cc, row.VolumetricDivisor))
+				continue
+			}
+		}
+
 		err := db.Transaction(func(tx *gorm.DB) error {
+			fresh := replace
 			var tpl models.ShippingCarrierTemplate
 			e := tx.Where("carrier = ? AND service_code = ? AND country_code = ?", carrier, service, cc).First(&tpl).Error
 			if e != nil {
 				if !errors.Is(e, gorm.ErrRecordNotFound) {
 					return e
 				}
-				tpl = models.ShippingCarrierTemplate{Carrier: carrier, ServiceCode: service, CountryCode: cc, CountryName: name, Currency: currency, IsActive: true}
+				tpl = models.ShippingCarrierTemplate{Carrier: carrier, ServiceCode: service, CountryCode: cc, CountryName: name, Currency: rowCurrency, IsActive: true,
+					TransitDaysMin: rowTransitMin, TransitDaysMax: rowTransitMax, VolumetricDivisor: rowDivisor}
 				if ce := tx.Create(&tpl).Error; ce != nil {
 					return ce
 				}
 				created++
+				fresh = true
 			} else {
 				// Update basic fields
 				tpl.CountryName = name
-				tpl.Currency = currency
+				tpl.Currency = rowCurrency
 				tpl.IsActive = true
+				if rowTransitOK {
+					tpl.TransitDaysMin, tpl.TransitDaysMax = rowTransitMin, rowTransitMax
+				}
+				if rowDivisorOK {
+					tpl.VolumetricDivisor = rowDivisor
+				}
 				if ue := tx.Save(&tpl).Error; ue != nil {
 					return ue
 				}
@@ -576,9 +640,46 @@ func ImportCarrierZoneTemplatesFromXLSX(ctx context.Context, db *gorm.DB, r io.R
 				}
 				ins = append(ins, models.ShippingCarrierWeightBracket{TemplateID: tpl.ID, MinKg: round3(b.MinKg), MaxKg: round3(b.MaxKg), RatePerKg: round3(b.RatePerKg)})
 			}
-			if len(ins) > 0 {
-				if ie := tx.Create(&ins).Error; ie != nil {
-					return ie
+			if fresh {
+				if len(ins) > 0 {
+					if ie := tx.Create(&ins).Error; ie != nil {
+						return ie
+					}
+				}
+			} else {
+				// Re-uploading without replace: update rates in place instead of duplicating rows.
+				for _, b := range ins {
+					var ex models.ShippingCarrierWeightBracket
+					e := tx.Where("template_id = ? AND min_kg = ? AND max_kg = ?", tpl.ID, b.MinKg, b.MaxKg).First(&ex).Error
+					if e == nil {
+						if ue := tx.Model(&ex).Update("rate_per_kg", b.RatePerKg).Error; ue != nil {
+							return ue
+						}
+						continue
+					}
+					if !errors.Is(e, gorm.ErrRecordNotFound) {
+						return e
+					}
+					if ce := tx.Create(&b).Error; ce != nil {
+						return ce
+					}
+				}
+			}
+
+			for _, q := range surcharges[cc] {
+				var ex models.ShippingCarrierQuoteSurcharge
+				e := tx.Where("template_id = ? AND quote_amount = ?", tpl.ID, q.QuoteAmount).First(&ex).Error
+				if e == nil {
+					if ue := tx.Model(&ex).Update("additional_fee", q.AdditionalFee).Error; ue != nil {
+						return ue
+					}
+					continue
+				}
+				if !errors.Is(e, gorm.ErrRecordNotFound) {
+					return e
+				}
+				if ce := tx.Create(&models.ShippingCarrierQuoteSurcharge{TemplateID: tpl.ID, QuoteAmount: q.QuoteAmount, AdditionalFee: q.AdditionalFee}).Error; ce != nil {
+					return ce
 				}
 			}
 			return nil
@@ -600,12 +701,26 @@ type countryZoneRow struct {
 	CountryCode string
 	CountryName string
 	Zone        string
+
+	// Optional per-country overrides of CarrierMeta (empty = use the meta value).
+	Currency          string
+	TransitDays       string
+	VolumetricDivisor string
+}
+
+// carrierMeta holds the key/value rows of the CarrierMeta sheet, as written.
+type carrierMeta struct {
+	Carrier           string
+	Service           string
+	Currency          string
+	Transit           string
+	VolumetricDivisor string
 }
 
-func readCarrierMeta(f *excelize.File) (carrier string, service string, currency string) {
+func readCarrierMeta(f *excelize.File) (meta carrierMeta) {
 	rows, err := f.GetRows("CarrierMeta")
 	if err != nil {
-		return "", "", ""
+		return meta
 	}
 	for _, r := range rows {
 		if len(r) < 2 {
@@ -615,14 +730,54 @@ func readCarrierMeta(f *excelize.File) (carrier string, service string, currency
 		v := strings.TrimSpace(r[1])
 		switch strings.ToLower(k) {
 		case "carrier":
-			carrier = v
+			meta.Carrier = v
 		case "servicecode", "service", "service_code":
-			service = v
+			meta.Service = v
 		case "currency":
-			currency = v
+			meta.Currency = v
+		case "transitdays", "transit_days", "transit":
+			meta.Transit = v
+		case "volumetricdivisor", "volumetric_divisor", "divisor":
+			meta.VolumetricDivisor = v
 		}
 	}
-	return carrier, service, currency
+	return meta
+}
+
+// parseTransitDays reads "3-5" or "4" business days; "0" clears the estimate.
+func parseTransitDays(s string) (minDays int, maxDays int, ok bool) {
+	s = strings.TrimSpace(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "days"))
+	if s == "" {
+		return 0, 0, false
+	}
+	if s == "0" {
+		return 0, 0, true
+	}
+	lo, hi, found := strings.Cut(s, "-")
+	a, err := strconv.Atoi(strings.TrimSpace(lo))
+	if err != nil || a <= 0 {
+		return 0, 0, false
+	}
+	b := a
+	if found {
+		if b, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil || b < a {
+			return 0, 0, false
+		}
+	}
+	return a, b, true
+}
+
+// parseVolumetricDivisor reads a divisor such as "5000"; "0" means the default divisor.
+func parseVolumetricDivisor(s string) (int, bool) {
+	s = strings.TrimSpace(strings.ReplaceAll(s, ",", ""))
+	if s == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 || v != math.Trunc(v) {
+		return 0, false
+	}
+	return int(v), true
 }
 
 func parseCountryZonesSheet(f *excelize.File, name string) ([]countryZoneRow, []string) {
@@ -636,6 +791,7 @@ func parseCountryZonesSheet(f *excelize.File, name string) ([]countryZoneRow, []
 
 	head := rows[0]
 	colCC, colName, colZone := -1, -1, -1
+	colCur, colTransit, colDivisor := -1, -1, -1
 	for i, h := range head {
 		k := strings.ToLower(strings.TrimSpace(h))
 		k = strings.ReplaceAll(k, " ", "")
@@ -646,6 +802,12 @@ func parseCountryZonesSheet(f *excelize.File, name string) ([]countryZoneRow, []
 			colName = i
 		case "zone", "region", "area":
 			colZone = i
+		case "currency":
+			colCur = i
+		case "transit_days", "transitdays", "transit":
+			colTransit = i
+		case "volumetric_divisor", "volumetricdivisor", "divisor":
+			colDivisor = i
 		case "\u56fd\u5bb6\u4ee3\u7801", "\u56fd\u5bb6\u7801", "\u56fd\u522b":
 			colCC = i
 		case "\u56fd\u5bb6\u540d\u79f0", "\u56fd\u5bb6", "\u540d\u79f0":
@@ -687,7 +849,8 @@ func parseCountryZonesSheet(f *excelize.File, name string) ([]countryZoneRow, []
 		if cc == "" {
 			continue
 		}
-		out = append(out, countryZoneRow{CountryCode: cc, CountryName: get(r, colName), Zone: get(r, colZone)})
+		out = append(out, countryZoneRow{CountryCode: cc, CountryName: get(r, colName), Zone: get(r, colZone),
+			Currency: get(r, colCur), TransitDays: get(r, colTransit), VolumetricDivisor: get(r, colDivisor)})
 	}
 	if len(out) == 0 {
 		return nil, []string{"CountryZones: no rows"}
@@ -717,69 +880,71 @@ func parseZoneRatesSheets(f *excelize.File, under21Name string, over21Name strin
 	if err != nil {
 		return out, append(errStrs, err.Error())
 	}
-	if len(rows) <= 1 {
-		return out, []string{under21Name + " sheet has no data"}
-	}
-	head := rows[0]
-	if len(head) < 2 {
-		return out, []string{under21Name + " header is too short"}
-	}
-	zones := make([]struct {
-		z   string
-		col int
-	}, 0)
-	for i := 1; i < len(head); i++ {
-		z := strings.TrimSpace(head[i])
-		if z == "" {
-			continue
-		}
-		zones = append(zones, struct {
-			z   string
-			col int
-		}{z: z, col: i})
-		if out.Under21[z] == nil {
-			out.Under21[z] = map[float64]float64{}
-		}
-	}
-	if len(zones) == 0 {
-		return out, []string{under21Name + ": no zone columns detected"}
-	}
-
 	get := func(r []string, idx int) string {
 		if idx < 0 || idx >= len(r) {
 			return ""
 		}
 		return strings.TrimSpace(r[idx])
 	}
-	for i := 1; i < len(rows); i++ {
-		r := rows[i]
-		wStr := get(r, 0)
-		if wStr == "" {
-			continue
+	// Either sheet may hold only its header when a carrier has no rates on that side of 21kg
+	// (exports write it that way); at least one of them must have data.
+	hasUnder21 := len(rows) > 1
+	if hasUnder21 {
+		head := rows[0]
+		if len(head) < 2 {
+			return out, []string{under21Name + " header is too short"}
 		}
-		w, e := parseFloat(wStr)
-		if e != nil {
-			errStrs = append(errStrs, fmt.Sprintf("%s row %d: invalid weight: %v", under21Name, i+1, e))
-			continue
+		zones := make([]struct {
+			z   string
+			col int
+		}, 0)
+		for i := 1; i < len(head); i++ {
+			z := strings.TrimSpace(head[i])
+			if z == "" {
+				continue
+			}
+			zones = append(zones, struct {
+				z   string
+				col int
+			}{z: z, col: i})
+			if out.Under21[z] == nil {
+				out.Under21[z] = map[float64]float64{}
+			}
 		}
-		if w <= 0 || w >= 21 {
-			continue
+		if len(zones) == 0 {
+			return out, []string{under21Name + ": no zone columns detected"}
 		}
-		w = round3(w)
-		for _, z := range zones {
-			feeStr := get(r, z.col)
-			if feeStr == "" {
+
+		for i := 1; i < len(rows); i++ {
+			r := rows[i]
+			wStr := get(r, 0)
+			if wStr == "" {
 				continue
 			}
-			fee, e := parseMoney(feeStr)
+			w, e := parseFloat(wStr)
 			if e != nil {
-				errStrs = append(errStrs, fmt.Sprintf("%s row %d: invalid fee for zone %s: %v", under21Name, i+1, z.z, e))
+				errStrs = append(errStrs, fmt.Sprintf("%s row %d: invalid weight: %v", under21Name, i+1, e))
 				continue
 			}
-			if fee <= 0 {
+			if w <= 0 || w >= 21 {
 				continue
 			}
-			out.Under21[z.z][w] = round3(fee)
+			w = round3(w)
+			for _, z := range zones {
+				feeStr := get(r, z.col)
+				if feeStr == "" {
+					continue
+				}
+				fee, e := parseMoney(feeStr)
+				if e != nil {
+					errStrs = append(errStrs, fmt.Sprintf("%s row %d: invalid fee for zone %s: %v", under21Name, i+1, z.z, e))
+					continue
+				}
+				if fee <= 0 {
+					continue
+				}
+				out.Under21[z.z][w] = round3(fee)
+			}
 		}
 	}
 
@@ -789,7 +954,10 @@ func parseZoneRatesSheets(f *excelize.File, under21Name string, over21Name strin
 		return out, append(errStrs, err.Error())
 	}
 	if len(rows2) <= 1 {
-		return out, append(errStrs, over21Name+" sheet has no data")
+		if !hasUnder21 {
+			return out, append(errStrs, under21Name+" and "+over21Name+" sheets have no data")
+		}
+		return out, errStrs
 	}
 	head2 := rows2[0]
 	colZone, colRange, colRate := 0, 1, 2
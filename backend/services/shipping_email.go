@@ -28,44 +28,77 @@ func carrierTrackingURL(carrier string, tracking string) string {
 	}
 }
 
-func BuildShipmentNotificationEmail(siteURL string, order models.Order) (subject, text, html string) {
+// shipmentEmailLine is one row of the "items in this shipment" table.
+type shipmentEmailLine struct {
+	SKU  string
+	Name string
+	Qty  int
+}
+
+// shipmentEmailLines lists the shipment's lines, or every order line for a LegacyShipment.
+func shipmentEmailLines(order models.Order, shipment models.Shipment) []shipmentEmailLine {
+	itemsByID := map[uint]models.OrderItem{}
+	for _, it := range order.Items {
+		itemsByID[it.ID] = it
+	}
+	lines := make([]shipmentEmailLine, 0)
+	add := func(it models.OrderItem, qty int) {
+		sku := it.DisplaySKU()
+		name := it.DisplayName()
+		if strings.TrimSpace(sku) == "" {
+			sku = fmt.Sprintf("PID-%d", it.ProductID)
+		}
+		if strings.TrimSpace(name) == "" {
+			name = "Product"
+		}
+		lines = append(lines, shipmentEmailLine{SKU: sku, Name: name, Qty: qty})
+	}
+	if len(shipment.Items) == 0 {
+		for _, it := range order.Items {
+			add(it, it.Quantity)
+		}
+		return lines
+	}
+	for _, si := range shipment.Items {
+		it, ok := itemsByID[si.OrderItemID]
+		if !ok && si.OrderItem != nil {
+			it = *si.OrderItem
+		}
+		add(it, si.Quantity)
+	}
+	return lines
+}
+
+// BuildShipmentNotificationEmail tells the customer that one shipment of the order is on its way.
+// While the order is partially fulfilled the email says more parcels will follow.
+func BuildShipmentNotificationEmail(siteURL string, order models.Order, shipment models.Shipment) (subject, text, html string) {
 	orderNo := order.OrderNumber
 	if orderNo == "" {
 		orderNo = fmt.Sprintf("ORDER-%d", order.ID)
 	}
-	carrier := strings.TrimSpace(order.ShippingCarrier)
-	tracking := strings.TrimSpace(order.TrackingNumber)
-	trackPage := ""
-	if strings.TrimSpace(siteURL) != "" {
-		trackPage = strings.TrimRight(siteURL, "/") + "/orders/track/" + orderNo
-	}
+	carrier := strings.TrimSpace(shipment.Carrier)
+	tracking := strings.TrimSpace(shipment.TrackingNumber)
+	trackPage := orderTrackURL(siteURL, order)
 	carrierURL := carrierTrackingURL(carrier, tracking)
 
 	shippedAt := ""
-	if order.ShippedAt != nil {
-		shippedAt = order.ShippedAt.UTC().Format(time.RFC3339)
+	if !shipment.ShippedAt.IsZero() {
+		shippedAt = shipment.ShippedAt.UTC().Format(time.RFC3339)
 	}
 
+	partial := order.FulfillmentStatus == models.FulfillmentPartial
+	headline := fmt.Sprintf("Good news - your order %s has shipped.", orderNo)
 	subject = fmt.Sprintf("Your order %s has shipped", orderNo)
+	if partial {
+		headline = fmt.Sprintf("Good news - part of your order %s has shipped. The remaining items will follow in a separate shipment.", orderNo)
+		subject = fmt.Sprintf("Part of your order %s has shipped", orderNo)
+	}
 
 	// Build items list
-	itemLines := make([]string, 0)
-	if len(order.Items) > 0 {
-		for _, it := range order.Items {
-			sku := ""
-			name := ""
-			if it.Product != nil {
-				sku = it.Product.SKU
-				name = it.Product.Name
-			}
-			if strings.TrimSpace(sku) == "" {
-				sku = fmt.Sprintf("PID-%d", it.ProductID)
-			}
-			if strings.TrimSpace(name) == "" {
-				name = "Product"
-			}
-			itemLines = append(itemLines, fmt.Sprintf("- %s | %s x%d", sku, name, it.Quantity))
-		}
+	lines := shipmentEmailLines(order, shipment)
+	itemLines := make([]string, 0, len(lines))
+	for _, l := range lines {
+		itemLines = append(itemLines, fmt.Sprintf("- %s | %s x%d", l.SKU, l.Name, l.Qty))
 	}
 
 	itemsText := ""
@@ -74,8 +107,8 @@ func BuildShipmentNotificationEmail(siteURL string, order models.Order) (subject
 	}
 
 	text = fmt.Sprintf(
-		"Vcocnc\n\nGood news - your order %s has shipped.\n\nCarrier: %s\nTracking number: %s\n%s\nTrack your order: %s\n%s\n\nIf you have any questions, reply to this email.\n\n--\nVcocnc Spare Parts\n",
-		orderNo,
+		"Vcocnc\n\n%s\n\nCarrier: %s\nTracking number: %s\n%s\nTrack your order: %s\n%s\n\nIf you have any questions, reply to this email.\n\n--\nVcocnc Spare Parts\n",
+		headline,
 		fallbackStr(carrier, "(not specified)"),
 		fallbackStr(tracking, "(not specified)"),
 		itemsText,
@@ -84,26 +117,14 @@ func BuildShipmentNotificationEmail(siteURL string, order models.Order) (subject
 	)
 
 	itemsHTML := ""
-	if len(order.Items) > 0 {
-		rows := make([]string, 0, len(order.Items))
-		for _, it := range order.Items {
-			sku := ""
-			name := ""
-			if it.Product != nil {
-				sku = it.Product.SKU
-				name = it.Product.Name
-			}
-			if strings.TrimSpace(sku) == "" {
-				sku = fmt.Sprintf("PID-%d", it.ProductID)
-			}
-			if strings.TrimSpace(name) == "" {
-				name = "Product"
-			}
+	if len(lines) > 0 {
+		rows := make([]string, 0, len(lines))
+		for _, l := range lines {
 			rows = append(rows,
 				"<tr>"+
-					"<td style=\"padding:8px 10px;border-top:1px solid #e5e7eb;font-family:ui-monospace,SFMono-Regular,Menlo,Monaco,Consolas,monospace;font-size:12px;color:#111827;\">"+escapeHTML(sku)+"</td>"+
-					"<td style=\"padding:8px 10px;border-top:1px solid #e5e7eb;font-family:Arial,Helvetica,sans-serif;font-size:13px;color:#111827;\">"+escapeHTML(name)+"</td>"+
-					fmt.Sprintf("<td style=\"padding:8px 10px;border-top:1px solid #e5e7eb;font-family:Arial,Helvetica,sans-serif;font-size:13px;color:#111827;text-align:right;\">%d</td>", it.Quantity)+
+					"<td style=\"padding:8px 10px;border-top:1px solid #e5e7eb;font-family:ui-monospace,SFMono-Regular,Menlo,Monaco,Consolas,monospace;font-size:12px;color:#111827;\">"+escapeHTML(l.SKU)+"</td>"+
+					"<td style=\"padding:8px 10px;border-top:1px solid #e5e7eb;font-family:Arial,Helvetica,sans-serif;font-size:13px;color:#111827;\">"+escapeHTML(l.Name)+"</td>"+
+					fmt.Sprintf("<td style=\"padding:8px 10px;border-top:1px solid #e5e7eb;font-family:Arial,Helvetica,sans-serif;font-size:13px;color:#111827;text-align:right;\">%d</td>", l.Qty)+
 					"</tr>")
 		}
 
@@ -126,7 +147,7 @@ func BuildShipmentNotificationEmail(siteURL string, order models.Order) (subject
 		"<div style=\"font-size:13px;opacity:0.9;margin-top:4px\">Shipping update</div>" +
 		"</div>" +
 		"<div style=\"border:1px solid #e5e7eb;border-top:none;border-radius:0 0 14px 14px;padding:18px 20px;background:#fff\">" +
-		fmt.Sprintf("<p style=\"margin:0 0 10px 0\">Good news - your order <b>%s</b> has shipped.</p>", escapeHTML(orderNo)) +
+		fmt.Sprintf("<p style=\"margin:0 0 10px 0\">%s</p>", escapeHTML(headline)) +
 		"<table role=\"presentation\" cellpadding=\"0\" cellspacing=\"0\" style=\"width:100%;border-collapse:separate;border-spacing:0 8px\">" +
 		fmt.Sprintf("<tr><td style=\"width:160px;color:#6b7280;font-size:13px\">Carrier</td><td style=\"font-size:14px;font-weight:700\">%s</td></tr>", escapeHTML(fallbackStr(carrier, "-"))) +
 		fmt.Sprintf("<tr><td style=\"width:160px;color:#6b7280;font-size:13px\">Tracking number</td><td style=\"font-size:14px;font-family:ui-monospace,SFMono-Regular,Menlo,Monaco,Consolas,monospace\">%s</td></tr>", escapeHTML(fallbackStr(tracking, "-")))
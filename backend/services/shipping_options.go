@@ -0,0 +1,155 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ShippingOption is one way to ship an order that the customer can pick at checkout:
+// the default country template (empty Carrier) or a carrier service template.
+type ShippingOption struct {
+	ShippingQuoteResult
+	Label        string `json:"label"`
+	FreeShipping bool   `json:"free_shipping"`
+}
+
+// ShippingChoiceError is returned when the chosen carrier/service cannot ship the order.
+type ShippingChoiceError struct {
+	Message string
+}
+
+func (e *ShippingChoiceError) Error() string { return e.Message }
+
+// activeDefaultShippingTemplate returns the country's active default template, or nil when it
+// has none (CalculateShippingQuote answers zero-weight quotes without looking).
+func activeDefaultShippingTemplate(db *gorm.DB, cc string) (*models.ShippingTemplate, error) {
+	var tpl models.ShippingTemplate
+	err := db.Where("country_code = ? AND is_active = ?", cc, true).First(&tpl).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tpl, nil
+}
+
+func shippingOptionLabel(carrier, serviceCode string) string {
+	if carrier == "" {
+		return "Standard shipping"
+	}
+	return strings.TrimSpace(carrier + " " + serviceCode)
+}
+
+// ListShippingOptions quotes every active template that can ship the parcel to a country:
+// the default template and each carrier service. Fees are converted into cur (nil = base
+// currency) and the list is sorted cheapest first. Templates whose brackets do not cover the
+// chargeable weight are left out. In free-shipping countries every option costs nothing.
+func ListShippingOptions(db *gorm.DB, countryCode string, parcel ShippingParcel, cur *models.Currency) ([]ShippingOption, error) {
+	cc := NormalizeCountryCode(countryCode)
+	if cc == "" {
+		return nil, errors.New("country_code is empty")
+	}
+	var quotes []ShippingQuoteResult
+	def, err := activeDefaultShippingTemplate(db, cc)
+	if err != nil {
+		return nil, err
+	}
+	if def != nil {
+		if q, err := CalculateShippingQuote(db, cc, parcel); err == nil {
+			q.TransitDaysMin, q.TransitDaysMax = def.TransitDaysMin, def.TransitDaysMax
+			quotes = append(quotes, q)
+		}
+	}
+
+	var tpls []models.ShippingCarrierTemplate
+	if err := db.Where("country_code = ? AND is_active = ?", cc, true).
+		Order("carrier ASC, service_code ASC").Find(&tpls).Error; err != nil {
+		return nil, err
+	}
+	for _, t := range tpls {
+		q, err := CalculateCarrierShippingQuote(db, t.Carrier, t.ServiceCode, cc, parcel)
+		if err != nil {
+			continue
+		}
+		// Zero-weight quotes skip the template lookup; keep the transit estimate anyway.
+		q.TransitDaysMin, q.TransitDaysMax = t.TransitDaysMin, t.TransitDaysMax
+		quotes = append(quotes, q)
+	}
+
+	free := IsFreeShippingCountry(db, cc)
+	out := make([]ShippingOption, 0, len(quotes))
+	for _, q := range quotes {
+		// Compare and charge in the base currency, like the order does.
+		base, err := ConvertShippingQuote(db, q, nil)
+		if err != nil {
+			continue
+		}
+		if free {
+			base.BaseQuote, base.AdditionalFee, base.ShippingFee = 0, 0, 0
+		}
+		out = append(out, ShippingOption{ShippingQuoteResult: base, Label: shippingOptionLabel(q.Carrier, q.ServiceCode), FreeShipping: free})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].ShippingFee < out[j].ShippingFee })
+
+	if cur != nil {
+		for i := range out {
+			q, err := ConvertShippingQuote(db, out[i].ShippingQuoteResult, cur)
+			if err != nil {
+				return nil, err
+			}
+			out[i].ShippingQuoteResult = q
+		}
+	}
+	return out, nil
+}
+
+// QuoteShippingOption re-quotes the option the customer chose, in the base currency.
+// An empty carrier means the default country template. A *ShippingChoiceError is returned
+// when the option does not exist for the country or cannot carry the parcel.
+func QuoteShippingOption(db *gorm.DB, countryCode string, parcel ShippingParcel, carrier, serviceCode string) (ShippingOption, error) {
+	cc := NormalizeCountryCode(countryCode)
+	carrier, serviceCode = NormalizeCarrier(carrier), NormalizeServiceCode(serviceCode)
+	var (
+		q   ShippingQuoteResult
+		err error
+	)
+	if carrier == "" {
+		var def *models.ShippingTemplate
+		if def, err = activeDefaultShippingTemplate(db, cc); err == nil && def == nil {
+			err = gorm.ErrRecordNotFound
+		}
+		if err == nil {
+			q, err = CalculateShippingQuote(db, cc, parcel)
+			q.TransitDaysMin, q.TransitDaysMax = def.TransitDaysMin, def.TransitDaysMax
+		}
+	} else {
+		var tpl models.ShippingCarrierTemplate
+		if err = db.Where("carrier = ? AND service_code = ? AND country_code = ? AND is_active = ?", carrier, serviceCode, cc, true).
+			First(&tpl).Error; err == nil {
+			q, err = CalculateCarrierShippingQuote(db, carrier, serviceCode, cc, parcel)
+			q.TransitDaysMin, q.TransitDaysMax = tpl.TransitDaysMin, tpl.TransitDaysMax
+		}
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ShippingOption{}, &ShippingChoiceError{Message: fmt.Sprintf("%s does not ship to %s, please choose another shipping option", shippingOptionLabel(carrier, serviceCode), cc)}
+	}
+	if err != nil {
+		return ShippingOption{}, &ShippingChoiceError{Message: fmt.Sprintf("%s cannot ship this order: %v", shippingOptionLabel(carrier, serviceCode), err)}
+	}
+	if q, err = ConvertShippingQuote(db, q, nil); err != nil {
+		return ShippingOption{}, err
+	}
+	opt := ShippingOption{ShippingQuoteResult: q, Label: shippingOptionLabel(carrier, serviceCode)}
+	if IsFreeShippingCountry(db, cc) {
+		opt.BaseQuote, opt.AdditionalFee, opt.ShippingFee = 0, 0, 0
+		opt.FreeShipping = true
+	}
+	return opt, nil
+}
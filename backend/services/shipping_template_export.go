@@ -0,0 +1,300 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"fanuc-backend/models"
+
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// Exports write the current shipping configuration in the layouts the importers read, so a
+// workbook can be downloaded, edited and uploaded again (with replace=1 to drop rows that
+// were deleted in the sheet). Only active templates are exported, and carrier countries
+// without weight brackets are left out: importing a template always activates it and the
+// zone layout has no empty zones. Replace-imports only touch the countries in the file, so
+// templates that were not exported are kept as they are.
+
+func formatKg(v float64) string { return strconv.FormatFloat(round3(v), 'f', -1, 64) }
+
+func styleExportHeader(f *excelize.File, sheet string, cols int) {
+	headerStyle, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}, Fill: excelize.Fill{Type: "pattern", Color: []string{"#F3F4F6"}, Pattern: 1}})
+	lastCol, _ := excelize.ColumnNumberToName(cols)
+	_ = f.SetCellStyle(sheet, "A1", lastCol+"1", headerStyle)
+	_ = f.SetPanes(sheet, &excelize.Panes{Freeze: true, Split: true, YSplit: 1, ActivePane: "bottomLeft"})
+}
+
+func writeExportRow(f *excelize.File, sheet string, row int, values ...interface{}) {
+	for i, v := range values {
+		cell, _ := excelize.CoordinatesToCellName(i+1, row)
+		_ = f.SetCellValue(sheet, cell, v)
+	}
+}
+
+// ExportShippingTemplatesXLSX exports the default country templates as a WeightKg sheet (one
+// row per weight bracket, with country name and currency) and a QuoteSurcharge sheet, the
+// layout ImportShippingTemplatesFromXLSX reads when no Under21Kg/Over21Kg sheets are present.
+func ExportShippingTemplatesXLSX(db *gorm.DB) ([]byte, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	var tpls []models.ShippingTemplate
+	if err := db.Where("is_active = ?", true).
+		Preload("WeightBrackets", func(db *gorm.DB) *gorm.DB { return db.Order("min_kg ASC, max_kg ASC") }).
+		Preload("QuoteSurcharges", func(db *gorm.DB) *gorm.DB { return db.Order("quote_amount ASC") }).
+		Order("country_code ASC").Find(&tpls).Error; err != nil {
+		return nil, err
+	}
+
+	f := excelize.NewFile()
+	sW := "WeightKg"
+	f.SetSheetName("Sheet1", sW)
+	writeExportRow(f, sW, 1, "CountryCode", "CountryName", "MinKg", "MaxKg", "RatePerKg", "Currency")
+	sQ := "QuoteSurcharge"
+	_, _ = f.NewSheet(sQ)
+	writeExportRow(f, sQ, 1, "CountryCode", "CountryName", "QuoteAmount", "AdditionalFee", "Currency")
+
+	rowW, rowQ := 2, 2
+	for _, t := range tpls {
+		cur := strings.TrimSpace(t.Currency)
+		if cur == "" {
+			cur = "USD"
+		}
+		for _, b := range t.WeightBrackets {
+			// Min 0 / max 0 rows are skipped by the importer, so they cannot exist.
+			writeExportRow(f, sW, rowW, t.CountryCode, t.CountryName, round3(b.MinKg), round3(b.MaxKg), round3(b.RatePerKg), cur)
+			rowW++
+		}
+		for _, q := range t.QuoteSurcharges {
+			writeExportRow(f, sQ, rowQ, t.CountryCode, t.CountryName, round2(q.QuoteAmount), round2(q.AdditionalFee), cur)
+			rowQ++
+		}
+	}
+
+	styleExportHeader(f, sW, 6)
+	styleExportHeader(f, sQ, 5)
+	_ = f.SetColWidth(sW, "A", "A", 12)
+	_ = f.SetColWidth(sW, "B", "B", 28)
+	_ = f.SetColWidth(sW, "C", "F", 12)
+	_ = f.SetColWidth(sQ, "A", "A", 12)
+	_ = f.SetColWidth(sQ, "B", "B", 28)
+	_ = f.SetColWidth(sQ, "C", "E", 14)
+	f.SetActiveSheet(0)
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// CarrierServiceCodes lists the service codes configured for a carrier.
+func CarrierServiceCodes(db *gorm.DB, carrier string) ([]string, error) {
+	var codes []string
+	err := db.Model(&models.ShippingCarrierTemplate{}).Where("carrier = ?", NormalizeCarrier(carrier)).
+		Distinct("service_code").Order("service_code ASC").Pluck("service_code", &codes).Error
+	return codes, err
+}
+
+// mostCommon returns the value most templates share (ties: the first one seen), written to
+// CarrierMeta so that per-country columns only show the exceptions.
+func mostCommon(values []string) string {
+	counts := map[string]int{}
+	best := ""
+	for _, v := range values {
+		counts[v]++
+		if counts[v] > counts[best] || best == "" {
+			best = v
+		}
+	}
+	return best
+}
+
+func formatTransitDays(minDays, maxDays int) string {
+	switch {
+	case minDays <= 0 && maxDays <= 0:
+		return "0"
+	case maxDays <= minDays:
+		return strconv.Itoa(minDays)
+	default:
+		return fmt.Sprintf("%d-%d", minDays, maxDays)
+	}
+}
+
+// ExportCarrierZoneTemplatesXLSX exports one carrier service in the carrier-zone layout read by
+// ImportCarrierZoneTemplatesFromXLSX. The database keeps rates per country, so countries with
+// identical weight brackets are grouped into zones Z1, Z2, ... Currency, transit days and the
+// volumetric divisor shared by most countries go to CarrierMeta; the others are written in
+// the optional CountryZones columns. Surcharges go to a QuoteSurcharge sheet.
+func ExportCarrierZoneTemplatesXLSX(db *gorm.DB, carrier, serviceCode string) ([]byte, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	carrier, serviceCode = NormalizeCarrier(carrier), NormalizeServiceCode(serviceCode)
+	if carrier == "" {
+		return nil, errors.New("carrier is empty")
+	}
+	var tpls []models.ShippingCarrierTemplate
+	if err := db.Where("carrier = ? AND service_code = ? AND is_active = ?", carrier, serviceCode, true).
+		Preload("WeightBrackets", func(db *gorm.DB) *gorm.DB { return db.Order("min_kg ASC, max_kg ASC") }).
+		Preload("QuoteSurcharges", func(db *gorm.DB) *gorm.DB { return db.Order("quote_amount ASC") }).
+		Order("country_code ASC").Find(&tpls).Error; err != nil {
+		return nil, err
+	}
+	// A country without brackets cannot be put in a zone (the importer rejects empty zones).
+	withRates := tpls[:0]
+	for _, t := range tpls {
+		if len(t.WeightBrackets) > 0 {
+			withRates = append(withRates, t)
+		}
+	}
+	tpls = withRates
+	if len(tpls) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	// Group countries into zones by their bracket set.
+	type zone struct {
+		name    string
+		under21 map[float64]float64
+		over21  []models.ShippingCarrierWeightBracket
+	}
+	var zones []*zone
+	zoneByKey := map[string]*zone{}
+	zoneOf := make([]string, len(tpls))
+	under21Weights := map[float64]bool{}
+	for i, t := range tpls {
+		z := &zone{under21: map[float64]float64{}}
+		var key strings.Builder
+		for _, b := range t.WeightBrackets {
+			minKg, maxKg := round3(b.MinKg), round3(b.MaxKg)
+			fmt.Fprintf(&key, "%s-%s:%s;", formatKg(minKg), formatKg(maxKg), formatKg(b.RatePerKg))
+			if minKg == maxKg && minKg > 0 && minKg < 21 {
+				z.under21[minKg] = round3(b.RatePerKg)
+				under21Weights[minKg] = true
+			} else {
+				// The importer creates nothing else than fixed rows under 21kg and brackets from 21kg.
+				z.over21 = append(z.over21, b)
+			}
+		}
+		if ex, ok := zoneByKey[key.String()]; ok {
+			zoneOf[i] = ex.name
+			continue
+		}
+		z.name = fmt.Sprintf("Z%d", len(zones)+1)
+		zoneByKey[key.String()] = z
+		zones = append(zones, z)
+		zoneOf[i] = z.name
+	}
+
+	var currencies, transits, divisors []string
+	for _, t := range tpls {
+		cur := strings.TrimSpace(t.Currency)
+		if cur == "" {
+			cur = "USD"
+		}
+		currencies = append(currencies, cur)
+		transits = append(transits, formatTransitDays(t.TransitDaysMin, t.TransitDaysMax))
+		divisors = append(divisors, strconv.Itoa(t.VolumetricDivisor))
+	}
+	metaCurrency, metaTransit, metaDivisor := mostCommon(currencies), mostCommon(transits), mostCommon(divisors)
+
+	f := excelize.NewFile()
+	sMeta := "CarrierMeta"
+	f.SetSheetName("Sheet1", sMeta)
+	writeExportRow(f, sMeta, 1, "Carrier", carrier)
+	writeExportRow(f, sMeta, 2, "ServiceCode", serviceCode)
+	writeExportRow(f, sMeta, 3, "Currency", metaCurrency)
+	writeExportRow(f, sMeta, 4, "TransitDays", metaTransit)
+	writeExportRow(f, sMeta, 5, "VolumetricDivisor", metaDivisor)
+	writeExportRow(f, sMeta, 6, "Notes", strings.Join([]string{
+		"Exported configuration. Edit and upload it again on the carrier-zone import (replace=1 also removes deleted rows).",
+		"Zones Z1, Z2, ... group countries that share the same rates; move a country to another zone or add a zone column to change its rates.",
+		"CountryZones currency / transit_days / volumetric_divisor override the values above for one country (transit_days 0 = unknown, volumetric_divisor 0 = default 5000).",
+	}, "\n"))
+
+	sMap := "CountryZones"
+	_, _ = f.NewSheet(sMap)
+	writeExportRow(f, sMap, 1, "country_code", "country_name", "zone", "currency", "transit_days", "volumetric_divisor")
+	for i, t := range tpls {
+		var cur, transit, divisor string
+		if currencies[i] != metaCurrency {
+			cur = currencies[i]
+		}
+		if transits[i] != metaTransit {
+			transit = transits[i]
+		}
+		if divisors[i] != metaDivisor {
+			divisor = divisors[i]
+		}
+		writeExportRow(f, sMap, i+2, t.CountryCode, t.CountryName, zoneOf[i], cur, transit, divisor)
+	}
+
+	sU := "Under21Kg_Zones"
+	_, _ = f.NewSheet(sU)
+	writeExportRow(f, sU, 1, "weight_kg")
+	for i, z := range zones {
+		cell, _ := excelize.CoordinatesToCellName(2+i, 1)
+		_ = f.SetCellValue(sU, cell, z.name)
+	}
+	weights := make([]float64, 0, len(under21Weights))
+	for w := range under21Weights {
+		weights = append(weights, w)
+	}
+	sort.Float64s(weights)
+	for r, w := range weights {
+		_ = f.SetCellValue(sU, fmt.Sprintf("A%d", r+2), w)
+		for i, z := range zones {
+			if fee, ok := z.under21[w]; ok {
+				cell, _ := excelize.CoordinatesToCellName(2+i, r+2)
+				_ = f.SetCellValue(sU, cell, fee)
+			}
+		}
+	}
+
+	sO := "Over21Kg_Zones"
+	_, _ = f.NewSheet(sO)
+	writeExportRow(f, sO, 1, "zone", "weight_range_kg", "rate_per_kg")
+	rowO := 2
+	for _, z := range zones {
+		for _, b := range z.over21 {
+			writeExportRow(f, sO, rowO, z.name, formatKg(b.MinKg)+" - "+formatKg(b.MaxKg), round3(b.RatePerKg))
+			rowO++
+		}
+	}
+
+	sQ := "QuoteSurcharge"
+	_, _ = f.NewSheet(sQ)
+	writeExportRow(f, sQ, 1, "CountryCode", "CountryName", "QuoteAmount", "AdditionalFee", "Currency")
+	rowQ := 2
+	for i, t := range tpls {
+		for _, q := range t.QuoteSurcharges {
+			writeExportRow(f, sQ, rowQ, t.CountryCode, t.CountryName, round2(q.QuoteAmount), round2(q.AdditionalFee), currencies[i])
+			rowQ++
+		}
+	}
+
+	styleExportHeader(f, sMap, 6)
+	styleExportHeader(f, sU, 1+len(zones))
+	styleExportHeader(f, sO, 3)
+	styleExportHeader(f, sQ, 5)
+	_ = f.SetColWidth(sMeta, "A", "A", 18)
+	_ = f.SetColWidth(sMeta, "B", "B", 80)
+	_ = f.SetColWidth(sMap, "A", "A", 14)
+	_ = f.SetColWidth(sMap, "B", "B", 28)
+	_ = f.SetColWidth(sMap, "C", "F", 16)
+	_ = f.SetColWidth(sO, "B", "B", 18)
+	_ = f.SetColWidth(sQ, "B", "B", 28)
+	f.SetActiveSheet(0)
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -35,6 +35,14 @@ type ShippingQuoteResult struct {
 	Source        string  `json:"source,omitempty"`
 	Carrier       string  `json:"carrier,omitempty"`
 	ServiceCode   string  `json:"service_code,omitempty"`
+
+	// WeightKg is the actual weight. Couriers bill the chargeable weight, the larger of actual
+	// and volumetric (L×W×H / divisor); BillingWeight is that rounded up to the template's steps.
+	VolumetricWeight float64 `json:"volumetric_weight_kg"`
+	ChargeableWeight float64 `json:"chargeable_weight_kg"`
+
+	TransitDaysMin int `json:"transit_days_min,omitempty"`
+	TransitDaysMax int `json:"transit_days_max,omitempty"`
 }
 
 func NormalizeCountryCode(code string) string {
@@ -85,7 +93,9 @@ func ListActiveShippingCountries(db *gorm.DB) ([]ShippingCountryPublic, error) {
 	return out, nil
 }
 
-func CalculateShippingQuote(db *gorm.DB, countryCode string, weightKg float64) (ShippingQuoteResult, error) {
+// CalculateShippingQuote prices a parcel with the country's default template, billed on the
+// chargeable weight (DefaultVolumetricDivisor).
+func CalculateShippingQuote(db *gorm.DB, countryCode string, parcel ShippingParcel) (ShippingQuoteResult, error) {
 	if db == nil {
 		return ShippingQuoteResult{}, errors.New("db is nil")
 	}
@@ -93,9 +103,9 @@ func CalculateShippingQuote(db *gorm.DB, countryCode string, weightKg float64) (
 	if cc == "" {
 		return ShippingQuoteResult{}, errors.New("country_code is empty")
 	}
-	if weightKg < 0 {
-		weightKg = 0
-	}
+	actualKg := math.Max(parcel.WeightKg, 0)
+	volumetricKg := parcel.VolumetricWeightKg(DefaultVolumetricDivisor)
+	weightKg := parcel.ChargeableWeightKg(DefaultVolumetricDivisor)
 	if weightKg == 0 {
 		return ShippingQuoteResult{CountryCode: cc, Currency: "USD", WeightKg: 0, BillingWeight: 0, RatePerKg: 0, BaseQuote: 0, AdditionalFee: 0, ShippingFee: 0, Source: "default"}, nil
 	}
@@ -200,15 +210,20 @@ func CalculateShippingQuote(db *gorm.DB, countryCode string, weightKg float64) (
 	shippingFee := round2(baseQuote + extra)
 
 	return ShippingQuoteResult{
-		CountryCode:   cc,
-		Currency:      cur,
-		WeightKg:      round3(weightKg),
-		BillingWeight: round3(billingWeightKg),
-		RatePerKg:     round3(ratePerKg),
-		BaseQuote:     baseQuote,
-		AdditionalFee: round2(extra),
-		ShippingFee:   shippingFee,
-		Source:        "default",
+		CountryCode:      cc,
+		Currency:         cur,
+		WeightKg:         round3(actualKg),
+		VolumetricWeight: round3(volumetricKg),
+		ChargeableWeight: round3(weightKg),
+		BillingWeight:    round3(billingWeightKg),
+		RatePerKg:        round3(ratePerKg),
+		BaseQuote:        baseQuote,
+		AdditionalFee:    round2(extra),
+		ShippingFee:      shippingFee,
+		Source:           "default",
+
+		TransitDaysMin: tpl.TransitDaysMin,
+		TransitDaysMax: tpl.TransitDaysMax,
 	}, nil
 }
 
@@ -416,7 +431,8 @@ type ShippingTemplateImportResult struct {
 }
 
 // ImportShippingTemplatesFromXLSX imports both sheets and upserts templates and rules.
-// If replace=true, existing rules for affected countries are deleted before insert.
+// If replace=true, existing rules for affected countries are deleted before insert; countries
+// that are not in the file keep their templates and rules.
 func ImportShippingTemplatesFromXLSX(ctx context.Context, db *gorm.DB, r io.Reader, replace bool) (ShippingTemplateImportResult, error) {
 	if db == nil {
 		return ShippingTemplateImportResult{}, errors.New("db is nil")
@@ -464,6 +480,9 @@ func ImportShippingTemplatesFromXLSX(ctx context.Context, db *gorm.DB, r io.Read
 	res := ShippingTemplateImportResult{Errors: []string{}}
 	res.Errors = append(res.Errors, qErrs...)
 	res.Errors = append(res.Errors, wErrs...)
+	if len(res.Errors) == 0 && len(weights) == 0 && len(quotes) == 0 {
+		res.Errors = append(res.Errors, "no weight brackets or quote surcharges found")
+	}
 	if len(res.Errors) > 0 {
 		res.Failed = len(res.Errors)
 		return res, fmt.Errorf("xlsx parse errors")
@@ -688,9 +707,10 @@ func parseWeightSheet(f *excelize.File) ([]weightRow, []string) {
 	if err != nil {
 		return nil, []string{err.Error()}
 	}
-	if len(rows) <= 1 {
+	if len(rows) == 0 {
 		return nil, []string{"WeightKg sheet has no data"}
 	}
+	// A header-only sheet is valid: countries may have surcharges only (exports write it so).
 	head := rows[0]
 	colCode, colName, colMin, colMax, colRate, colCur := 0, 1, 2, 3, 4, 5
 	for i, h := range head {
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"math"
+
+	"fanuc-backend/models"
+	"fanuc-backend/utils"
+)
+
+// DefaultVolumetricDivisor converts cm³ into volumetric kg (L×W×H / 5000, the IATA express
+// divisor used by FedEx and DHL). Default country templates always use it; carrier templates
+// use it unless they set their own ShippingCarrierTemplate.VolumetricDivisor.
+const DefaultVolumetricDivisor = 5000
+
+// ShippingParcel is what a quote is billed on: the actual weight and the packed volume of
+// everything that ships together.
+type ShippingParcel struct {
+	WeightKg  float64
+	VolumeCm3 float64
+}
+
+// VolumetricWeightKg is the parcel's dimensional weight for the divisor (0 = default).
+func (p ShippingParcel) VolumetricWeightKg(divisor int) float64 {
+	if divisor <= 0 {
+		divisor = DefaultVolumetricDivisor
+	}
+	if p.VolumeCm3 <= 0 {
+		return 0
+	}
+	return p.VolumeCm3 / float64(divisor)
+}
+
+// ChargeableWeightKg is what couriers bill: the larger of actual and volumetric weight.
+func (p ShippingParcel) ChargeableWeightKg(divisor int) float64 {
+	return math.Max(math.Max(p.WeightKg, 0), p.VolumetricWeightKg(divisor))
+}
+
+// Add puts quantity units of a product into the parcel.
+func (p *ShippingParcel) Add(product *models.Product, quantity int) {
+	if product == nil || quantity <= 0 {
+		return
+	}
+	if product.Weight != nil {
+		p.WeightKg += *product.Weight * float64(quantity)
+	}
+	p.VolumeCm3 += ProductVolumeCm3(product) * float64(quantity)
+}
+
+// ProductVolumeCm3 is the packed volume of one unit, or 0 when the size is unknown.
+func ProductVolumeCm3(p *models.Product) float64 {
+	if p == nil || p.LengthCm == nil || p.WidthCm == nil || p.HeightCm == nil {
+		return 0
+	}
+	return math.Max(*p.LengthCm, 0) * math.Max(*p.WidthCm, 0) * math.Max(*p.HeightCm, 0)
+}
+
+// ApplyProductDimensions fills the structured size from the free-text Dimensions when it
+// was not given, so older listings ("380x150x172mm") are billed by volume too.
+func ApplyProductDimensions(p *models.Product) {
+	if p.LengthCm != nil && p.WidthCm != nil && p.HeightCm != nil {
+		return
+	}
+	if l, w, h, ok := utils.ParseDimensionsCm(p.Dimensions); ok {
+		p.LengthCm, p.WidthCm, p.HeightCm = &l, &w, &h
+	}
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// lockProducts loads products with SELECT ... FOR UPDATE. Rows are always locked in ID order
+// so concurrent orders touching the same products cannot deadlock.
+func lockProducts(tx *gorm.DB, ids []uint) (map[uint]*models.Product, error) {
+	out := map[uint]*models.Product{}
+	if len(ids) == 0 {
+		return out, nil
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var products []models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", ids).Order("id ASC").Find(&products).Error; err != nil {
+		return nil, err
+	}
+	for i := range products {
+		out[products[i].ID] = &products[i]
+	}
+	return out, nil
+}
+
+func uniqueProductIDs(items []models.OrderItem) []uint {
+	seen := map[uint]bool{}
+	var ids []uint
+	for _, it := range items {
+		if !seen[it.ProductID] {
+			seen[it.ProductID] = true
+			ids = append(ids, it.ProductID)
+		}
+	}
+	return ids
+}
+
+// ReserveOrderStock holds stock for every line of a freshly created order.
+// It must run in the same transaction as the order insert; an *OrderLineError is returned
+// when a product no longer has enough unreserved stock. Products that allow backorders
+// reserve what is available and mark the rest of the line backordered, with an expected
+// ship date (see BackorderShipDate).
+func ReserveOrderStock(tx *gorm.DB, order *models.Order) error {
+	if tx == nil || order == nil {
+		return errors.New("invalid arguments")
+	}
+	items, err := loadOrderItems(tx, order)
+	if err != nil {
+		return err
+	}
+
+	products, err := lockProducts(tx, uniqueProductIDs(items))
+	if err != nil {
+		return err
+	}
+
+	need := map[uint]int{}
+	for _, it := range items {
+		need[it.ProductID] += it.Quantity
+	}
+	short := map[uint]int{}
+	for id, qty := range need {
+		p, ok := products[id]
+		if !ok {
+			return &OrderLineError{ProductID: id, Message: fmt.Sprintf("Product with ID %d not found", id)}
+		}
+		if avail := max(p.AvailableQuantity(), 0); avail < qty {
+			if !p.AllowsBackorder() {
+				return &OrderLineError{ProductID: id, Message: fmt.Sprintf("Insufficient stock for product %s", p.Name)}
+			}
+			short[id] = qty - avail
+		}
+	}
+
+	for id, qty := range need {
+		if held := qty - short[id]; held > 0 {
+			if err := tx.Model(&models.Product{}).Where("id = ?", id).
+				UpdateColumn("reserved_quantity", gorm.Expr("reserved_quantity + ?", held)).Error; err != nil {
+				return err
+			}
+		}
+	}
+	now := time.Now()
+	for i := len(items) - 1; i >= 0; i-- {
+		// Later lines of a product are backordered first.
+		it := items[i]
+		backordered := min(short[it.ProductID], it.Quantity)
+		short[it.ProductID] -= backordered
+		if backordered > 0 || it.BackorderedQuantity > 0 {
+			updates := map[string]interface{}{"backordered_quantity": backordered, "expected_ship_date": nil}
+			if backordered > 0 {
+				shipDate := BackorderShipDate(products[it.ProductID], now)
+				updates["expected_ship_date"] = &shipDate
+			}
+			if err := tx.Model(&models.OrderItem{}).Where("id = ?", it.ID).UpdateColumns(updates).Error; err != nil {
+				return err
+			}
+		}
+		if it.Quantity-backordered <= 0 {
+			continue
+		}
+		r := models.StockReservation{
+			OrderID:     order.ID,
+			OrderItemID: it.ID,
+			ProductID:   it.ProductID,
+			Quantity:    it.Quantity - backordered,
+			Status:      models.StockReservationActive,
+		}
+		if err := tx.Create(&r).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func activeReservations(tx *gorm.DB, orderID uint) ([]models.StockReservation, error) {
+	var rs []models.StockReservation
+	err := tx.Where("order_id = ? AND status = ?", orderID, models.StockReservationActive).
+		Order("id ASC").Find(&rs).Error
+	return rs, err
+}
+
+// ConvertOrderReservations turns an order's reservations into stock deductions when it is paid.
+// Lines without an active reservation (orders placed before reservations existed, or whose
+// hold was released) are deducted directly; stock never goes below zero.
+func ConvertOrderReservations(tx *gorm.DB, order *models.Order) error {
+	if tx == nil || order == nil {
+		return errors.New("invalid arguments")
+	}
+	items, err := loadOrderItems(tx, order)
+	if err != nil {
+		return err
+	}
+	reservations, err := activeReservations(tx, order.ID)
+	if err != nil {
+		return err
+	}
+
+	products, err := lockProducts(tx, uniqueProductIDs(items))
+	if err != nil {
+		return err
+	}
+
+	reserved := map[uint]int{}
+	for _, r := range reservations {
+		reserved[r.ProductID] += r.Quantity
+	}
+	// Backordered units are deducted when received stock is allocated to them.
+	sold := map[uint]int{}
+	for _, it := range items {
+		sold[it.ProductID] += it.Quantity - it.BackorderedQuantity
+	}
+
+	for id, qty := range sold {
+		p, ok := products[id]
+		if !ok {
+			continue
+		}
+		stock := p.StockQuantity - qty
+		if stock < 0 {
+			log.Printf("stock: order %s oversold product %s by %d", order.OrderNumber, p.SKU, -stock)
+			stock = 0
+		}
+		held := p.ReservedQuantity - reserved[id]
+		if held < 0 {
+			held = 0
+		}
+		if err := tx.Model(&models.Product{}).Where("id = ?", id).
+			UpdateColumns(map[string]interface{}{"stock_quantity": stock, "reserved_quantity": held}).Error; err != nil {
+			return err
+		}
+	}
+
+	if len(reservations) > 0 {
+		now := time.Now()
+		if err := tx.Model(&models.StockReservation{}).
+			Where("order_id = ? AND status = ?", order.ID, models.StockReservationActive).
+			Updates(map[string]interface{}{"status": models.StockReservationConverted, "converted_at": &now}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReleaseOrderReservations gives an unpaid order's held stock back and returns how many units were released.
+func ReleaseOrderReservations(tx *gorm.DB, orderID uint) (int, error) {
+	if tx == nil {
+		return 0, errors.New("db is nil")
+	}
+	reservations, err := activeReservations(tx, orderID)
+	if err != nil || len(reservations) == 0 {
+		return 0, err
+	}
+
+	release := map[uint]int{}
+	var ids []uint
+	for _, r := range reservations {
+		if _, ok := release[r.ProductID]; !ok {
+			ids = append(ids, r.ProductID)
+		}
+		release[r.ProductID] += r.Quantity
+	}
+	products, err := lockProducts(tx, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for id, qty := range release {
+		total += qty
+		p, ok := products[id]
+		if !ok {
+			continue
+		}
+		held := p.ReservedQuantity - qty
+		if held < 0 {
+			held = 0
+		}
+		if err := tx.Model(&models.Product{}).Where("id = ?", id).
+			UpdateColumn("reserved_quantity", held).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	now := time.Now()
+	if err := tx.Model(&models.StockReservation{}).
+		Where("order_id = ? AND status = ?", orderID, models.StockReservationActive).
+		Updates(map[string]interface{}{"status": models.StockReservationReleased, "released_at": &now}).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"fanuc-backend/models"
+	"fanuc-backend/utils"
+)
+
+const stripeAPIBaseDefault = "https://api.stripe.com"
+
+// StripeAPIBase returns the REST API base URL.
+// STRIPE_API_BASE overrides it (e.g. stripe-mock or a local fake server in tests).
+func StripeAPIBase() string {
+	if v := strings.TrimRight(strings.TrimSpace(os.Getenv("STRIPE_API_BASE")), "/"); v != "" {
+		return v
+	}
+	return stripeAPIBaseDefault
+}
+
+// StripeClient is a minimal Stripe REST client (PaymentIntents + Refunds, form-encoded).
+// HTTP and BaseURL are exported so callers/tests can inject their own transport or server.
+type StripeClient struct {
+	HTTP      *http.Client
+	BaseURL   string
+	SecretKey string
+
+	// WebhookSecret is the endpoint signing secret (whsec_...); only needed for webhooks.
+	WebhookSecret string
+}
+
+func NewStripeClient(secretKey, webhookSecret string) *StripeClient {
+	return &StripeClient{
+		HTTP:          &http.Client{Timeout: 20 * time.Second},
+		BaseURL:       StripeAPIBase(),
+		SecretKey:     strings.TrimSpace(secretKey),
+		WebhookSecret: strings.TrimSpace(webhookSecret),
+	}
+}
+
+// ErrStripeNotConfigured is returned when the active mode has no secret key.
+var ErrStripeNotConfigured = errors.New("stripe secret key not configured")
+
+// NewStripeClientFromSetting builds a client for the active mode, decrypting the stored secrets.
+func NewStripeClientFromSetting(s *models.StripeSetting) (*StripeClient, error) {
+	if s == nil {
+		return nil, ErrStripeNotConfigured
+	}
+	enc := strings.TrimSpace(s.EffectiveSecretKeyEnc())
+	if enc == "" {
+		return nil, ErrStripeNotConfigured
+	}
+	secret, err := utils.DecryptSecret(enc)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt stripe secret key: %w", err)
+	}
+	webhookSecret := ""
+	if enc := strings.TrimSpace(s.EffectiveWebhookSecretEnc()); enc != "" {
+		if webhookSecret, err = utils.DecryptSecret(enc); err != nil {
+			return nil, fmt.Errorf("decrypt stripe webhook secret: %w", err)
+		}
+	}
+	return NewStripeClient(secret, webhookSecret), nil
+}
+
+// StripeAPIError is a non-2xx response from the Stripe API.
+type StripeAPIError struct {
+	StatusCode int
+	Type       string `json:"type"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *StripeAPIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	if e.Code != "" {
+		msg += " (" + e.Code + ")"
+	}
+	return fmt.Sprintf("stripe api error %d: %s", e.StatusCode, msg)
+}
+
+// do sends an authenticated form request and returns the raw response body.
+// idempotencyKey is optional; Stripe replays the first response for a repeated key.
+func (c *StripeClient) do(ctx context.Context, method, path string, form url.Values, idempotencyKey string) ([]byte, error) {
+	if c.SecretKey == "" {
+		return nil, ErrStripeNotConfigured
+	}
+	var rdr io.Reader
+	if form != nil {
+		rdr = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, rdr)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.SecretKey)
+	req.Header.Set("Accept", "application/json")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		var wrapper struct {
+			Error StripeAPIError `json:"error"`
+		}
+		_ = json.Unmarshal(body, &wrapper)
+		apiErr := wrapper.Error
+		apiErr.StatusCode = resp.StatusCode
+		return nil, &apiErr
+	}
+	return body, nil
+}
+
+// stripeZeroDecimal lists currencies Stripe takes in whole units.
+var stripeZeroDecimal = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "JPY": true, "KMF": true, "KRW": true, "MGA": true,
+	"PYG": true, "RWF": true, "UGX": true, "VND": true, "VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+// stripeMinorUnits converts an amount to Stripe's integer minor units (cents).
+func stripeMinorUnits(amount float64, currency string) int64 {
+	if stripeZeroDecimal[strings.ToUpper(currency)] {
+		return int64(math.Round(amount))
+	}
+	return int64(math.Round(amount * 100))
+}
+
+// stripeFromMinorUnits converts Stripe minor units back to an amount.
+func stripeFromMinorUnits(v int64, currency string) float64 {
+	if stripeZeroDecimal[strings.ToUpper(currency)] {
+		return float64(v)
+	}
+	return round2(float64(v) / 100)
+}
+
+// StripePaymentIntent is the subset of the PaymentIntent object we rely on.
+type StripePaymentIntent struct {
+	ID             string            `json:"id"`
+	Status         string            `json:"status"` // requires_payment_method ... processing, succeeded, canceled
+	Amount         int64             `json:"amount"`
+	AmountReceived int64             `json:"amount_received"`
+	Currency       string            `json:"currency"` // lower case
+	ClientSecret   string            `json:"client_secret"`
+	LatestCharge   string            `json:"latest_charge"`
+	ReceiptEmail   string            `json:"receipt_email"`
+	Customer       string            `json:"customer"`
+	Metadata       map[string]string `json:"metadata"`
+
+	Raw json.RawMessage `json:"-"`
+}
+
+func decodeStripePaymentIntent(body []byte) (*StripePaymentIntent, error) {
+	var pi StripePaymentIntent
+	if err := json.Unmarshal(body, &pi); err != nil {
+		return nil, err
+	}
+	pi.Raw = json.RawMessage(body)
+	return &pi, nil
+}
+
+// StripePaymentIntentParams are the fields we set on a new card PaymentIntent.
+type StripePaymentIntentParams struct {
+	Amount              float64
+	Currency            string
+	OrderNumber         string // metadata[order_number], checked again at capture
+	Description         string
+	ReceiptEmail        string
+	StatementDescriptor string // suffix, max 22 chars
+	IdempotencyKey      string
+}
+
+// CreatePaymentIntent creates a card PaymentIntent: POST /v1/payment_intents
+func (c *StripeClient) CreatePaymentIntent(ctx context.Context, p StripePaymentIntentParams) (*StripePaymentIntent, error) {
+	form := url.Values{
+		"amount":                 {strconv.FormatInt(stripeMinorUnits(p.Amount, p.Currency), 10)},
+		"currency":               {strings.ToLower(p.Currency)},
+		"payment_method_types[]": {"card"},
+		"metadata[order_number]": {p.OrderNumber},
+	}
+	if p.Description != "" {
+		form.Set("description", p.Description)
+	}
+	if p.ReceiptEmail != "" {
+		form.Set("receipt_email", p.ReceiptEmail)
+	}
+	if d := strings.TrimSpace(p.StatementDescriptor); d != "" {
+		if len(d) > 22 {
+			d = d[:22]
+		}
+		form.Set("statement_descriptor_suffix", d)
+	}
+	body, err := c.do(ctx, http.MethodPost, "/v1/payment_intents", form, p.IdempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+	return decodeStripePaymentIntent(body)
+}
+
+// GetPaymentIntent fetches a PaymentIntent: GET /v1/payment_intents/{id}
+func (c *StripeClient) GetPaymentIntent(ctx context.Context, id string) (*StripePaymentIntent, error) {
+	body, err := c.do(ctx, http.MethodGet, "/v1/payment_intents/"+url.PathEscape(id), nil, "")
+	if err != nil {
+		return nil, err
+	}
+	return decodeStripePaymentIntent(body)
+}
+
+// StripeRefund is the subset of the Refund object we store.
+type StripeRefund struct {
+	ID            string            `json:"id"`
+	Status        string            `json:"status"` // pending, requires_action, succeeded, failed, canceled
+	Amount        int64             `json:"amount"`
+	Currency      string            `json:"currency"`
+	Charge        string            `json:"charge"`
+	PaymentIntent string            `json:"payment_intent"`
+	Metadata      map[string]string `json:"metadata"`
+
+	Raw json.RawMessage `json:"-"`
+}
+
+// CreateRefund refunds (part of) a charge or PaymentIntent: POST /v1/refunds
+// Stripe's reason field only takes fixed codes, so the free-text reason goes into metadata.
+func (c *StripeClient) CreateRefund(ctx context.Context, paymentID string, amount float64, currency, reason string) (*StripeRefund, error) {
+	form := url.Values{"amount": {strconv.FormatInt(stripeMinorUnits(amount, currency), 10)}}
+	if strings.HasPrefix(paymentID, "pi_") {
+		form.Set("payment_intent", paymentID)
+	} else {
+		form.Set("charge", paymentID)
+	}
+	if reason = strings.TrimSpace(reason); reason != "" {
+		if len(reason) > 500 {
+			reason = reason[:500]
+		}
+		form.Set("metadata[reason]", reason)
+	}
+	body, err := c.do(ctx, http.MethodPost, "/v1/refunds", form, "")
+	if err != nil {
+		return nil, err
+	}
+	var r StripeRefund
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, err
+	}
+	r.Raw = json.RawMessage(body)
+	return &r, nil
+}
+
+// stripeWebhookTolerance is how old a signed webhook may be (Stripe's libraries use 5 minutes).
+const stripeWebhookTolerance = 5 * time.Minute
+
+// ErrStripeWebhookSignature means the Stripe-Signature header did not match the body.
+var ErrStripeWebhookSignature = errors.New("stripe webhook signature verification failed")
+
+// VerifyWebhookSignature checks the Stripe-Signature header ("t=...,v1=...") against an
+// HMAC-SHA256 of "{t}.{body}" with the endpoint secret, and rejects stale timestamps.
+// body must be the raw request body exactly as received.
+func (c *StripeClient) VerifyWebhookSignature(header string, body []byte, now time.Time) error {
+	if c.WebhookSecret == "" {
+		return errors.New("stripe webhook secret not configured")
+	}
+	var (
+		timestamp string
+		sigs      []string
+	)
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "t":
+			timestamp = v
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return ErrStripeWebhookSignature
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > stripeWebhookTolerance || age < -stripeWebhookTolerance {
+		return ErrStripeWebhookSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(c.WebhookSecret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+	for _, s := range sigs {
+		got, err := hex.DecodeString(s)
+		if err == nil && hmac.Equal(got, expected) {
+			return nil
+		}
+	}
+	return ErrStripeWebhookSignature
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"fanuc-backend/models"
+)
+
+// StripeProvider is the PaymentProvider for card payments with Stripe PaymentIntents
+// (Stripe.js Payment Element on the checkout, confirmed in the browser).
+type StripeProvider struct {
+	Client  *StripeClient
+	Setting *models.StripeSetting
+}
+
+func (p *StripeProvider) Name() string { return PaymentMethodStripe }
+
+// CreateIntent creates a card PaymentIntent for the order total. The idempotency key makes a
+// reloaded checkout get the same PaymentIntent back instead of creating another one.
+func (p *StripeProvider) CreateIntent(ctx context.Context, order *models.Order) (*PaymentIntent, error) {
+	currency := fallbackStr(strings.ToUpper(order.Currency), "USD")
+	pi, err := p.Client.CreatePaymentIntent(ctx, StripePaymentIntentParams{
+		Amount:              order.TotalAmount,
+		Currency:            currency,
+		OrderNumber:         order.OrderNumber,
+		Description:         "Order " + orderDisplayNumber(*order),
+		ReceiptEmail:        order.CustomerEmail,
+		StatementDescriptor: p.Setting.StatementDescriptor,
+		IdempotencyKey:      fmt.Sprintf("order-%s-%d-%s", order.OrderNumber, stripeMinorUnits(order.TotalAmount, currency), currency),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &PaymentIntent{
+		Provider:     PaymentMethodStripe,
+		IntentID:     pi.ID,
+		ClientSecret: pi.ClientSecret,
+		Status:       pi.Status,
+		Amount:       stripeFromMinorUnits(pi.Amount, currency),
+		Currency:     currency,
+	}, nil
+}
+
+// stripeTransactionID is the ledger ID for a PaymentIntent: its charge, so refunds and
+// disputes (which reference the charge) find it.
+func stripeTransactionID(pi *StripePaymentIntent) string {
+	return fallbackStr(pi.LatestCharge, pi.ID)
+}
+
+// Capture loads the PaymentIntent the browser confirmed and checks it against the order.
+// The checkout posts { payment_intent_id } (Stripe.js also returns it as paymentIntent.id).
+func (p *StripeProvider) Capture(ctx context.Context, order *models.Order, data map[string]interface{}) (*CapturedPayment, error) {
+	verr := func(format string, args ...interface{}) error {
+		return &PaymentVerificationError{Provider: PaymentMethodStripe, Reason: fmt.Sprintf(format, args...)}
+	}
+	id, _ := data["payment_intent_id"].(string)
+	if id == "" {
+		if pi, ok := data["paymentIntent"].(map[string]interface{}); ok {
+			id, _ = pi["id"].(string)
+		} else {
+			id, _ = data["id"].(string)
+		}
+	}
+	if id = strings.TrimSpace(id); id == "" {
+		return nil, verr("missing PaymentIntent ID")
+	}
+
+	pi, err := p.Client.GetPaymentIntent(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if pi.Metadata["order_number"] != order.OrderNumber {
+		return nil, verr("PaymentIntent belongs to a different order")
+	}
+	received := pi.AmountReceived
+	switch pi.Status {
+	case "succeeded":
+	case "processing":
+		received = pi.Amount
+	default:
+		return nil, verr("payment status is %s", pi.Status)
+	}
+
+	expectedCurrency := fallbackStr(strings.ToUpper(strings.TrimSpace(order.Currency)), "USD")
+	if !strings.EqualFold(pi.Currency, expectedCurrency) {
+		return nil, verr("currency %s does not match order currency %s", strings.ToUpper(pi.Currency), expectedCurrency)
+	}
+	amount := stripeFromMinorUnits(received, expectedCurrency)
+	if math.Abs(amount-round2(order.TotalAmount)) > priceTolerance {
+		return nil, verr("paid amount %.2f does not match order total %.2f", amount, order.TotalAmount)
+	}
+
+	return &CapturedPayment{
+		Provider:      PaymentMethodStripe,
+		PaymentID:     pi.ID,
+		TransactionID: stripeTransactionID(pi),
+		Pending:       pi.Status != "succeeded",
+		Amount:        amount,
+		Currency:      expectedCurrency,
+		PayerID:       pi.Customer,
+		PayerEmail:    pi.ReceiptEmail,
+		Raw:           pi.Raw,
+	}, nil
+}
+
+func (p *StripeProvider) Refund(ctx context.Context, capture *models.PaymentTransaction, amount float64, currency, reason string) (*ProviderRefund, error) {
+	r, err := p.Client.CreateRefund(ctx, capture.TransactionID, amount, currency, reason)
+	if err != nil {
+		return nil, err
+	}
+	switch r.Status {
+	case "succeeded":
+		return &ProviderRefund{ID: r.ID, Raw: r.Raw}, nil
+	case "pending", "requires_action":
+		return &ProviderRefund{ID: r.ID, Pending: true, Raw: r.Raw}, nil
+	}
+	return nil, fmt.Errorf("stripe refund status %s", r.Status)
+}
+
+// stripeEvent is the envelope Stripe posts to our webhook endpoint.
+type stripeEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+type stripeDispute struct {
+	ID            string `json:"id"`
+	Amount        int64  `json:"amount"`
+	Currency      string `json:"currency"`
+	Charge        string `json:"charge"`
+	PaymentIntent string `json:"payment_intent"`
+	Reason        string `json:"reason"`
+	Status        string `json:"status"`
+}
+
+// ParseWebhook checks the Stripe-Signature header locally, then maps PaymentIntent success,
+// refunds, disputes and lost-dispute withdrawals.
+func (p *StripeProvider) ParseWebhook(ctx context.Context, headers http.Header, body []byte) (*ProviderWebhookEvent, error) {
+	if err := p.Client.VerifyWebhookSignature(headers.Get("Stripe-Signature"), body, time.Now()); err != nil {
+		if errors.Is(err, ErrStripeWebhookSignature) {
+			return nil, ErrPaymentWebhookSignature
+		}
+		return nil, err
+	}
+	var env stripeEvent
+	if err := json.Unmarshal(body, &env); err != nil || strings.TrimSpace(env.ID) == "" {
+		return nil, &PaymentWebhookPayloadError{Reason: "invalid webhook event"}
+	}
+
+	ev := &ProviderWebhookEvent{
+		Provider:  PaymentMethodStripe,
+		EventID:   env.ID,
+		EventType: env.Type,
+		Resource:  env.Data.Object,
+	}
+	switch env.Type {
+	case "payment_intent.succeeded":
+		var pi StripePaymentIntent
+		if err := json.Unmarshal(env.Data.Object, &pi); err != nil {
+			return nil, &PaymentWebhookPayloadError{Reason: err.Error()}
+		}
+		ev.Kind = PaymentEventCompleted
+		ev.ResourceID = stripeTransactionID(&pi)
+		ev.ProviderOrderID = pi.ID
+		ev.OrderNumber = pi.Metadata["order_number"]
+		ev.Amount = stripeFromMinorUnits(pi.AmountReceived, pi.Currency)
+		ev.Currency = strings.ToUpper(pi.Currency)
+	case "refund.created", "refund.updated", "charge.refund.updated":
+		var r StripeRefund
+		if err := json.Unmarshal(env.Data.Object, &r); err != nil {
+			return nil, &PaymentWebhookPayloadError{Reason: err.Error()}
+		}
+		if r.Status != "succeeded" && r.Status != "pending" {
+			break // failed/canceled refunds move no money
+		}
+		ev.Kind = PaymentEventRefunded
+		ev.ResourceID = r.ID
+		ev.CaptureID = r.Charge
+		ev.ProviderOrderID = r.PaymentIntent
+		ev.Amount = stripeFromMinorUnits(r.Amount, r.Currency)
+		ev.Currency = strings.ToUpper(r.Currency)
+	case "charge.dispute.created", "charge.dispute.funds_withdrawn":
+		var d stripeDispute
+		if err := json.Unmarshal(env.Data.Object, &d); err != nil {
+			return nil, &PaymentWebhookPayloadError{Reason: err.Error()}
+		}
+		ev.Kind = PaymentEventDisputed
+		if env.Type == "charge.dispute.funds_withdrawn" {
+			ev.Kind = PaymentEventReversed
+		}
+		ev.ResourceID = d.ID
+		ev.CaptureID = d.Charge
+		ev.ProviderOrderID = d.PaymentIntent
+		ev.Amount = stripeFromMinorUnits(d.Amount, d.Currency)
+		ev.Currency = strings.ToUpper(d.Currency)
+		ev.Reason = d.Reason
+	}
+	return ev, nil
+}
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"errors"
+
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// GetOrCreateStripeSetting loads the single-row Stripe settings (ID=1), creating defaults if missing.
+func GetOrCreateStripeSetting(db *gorm.DB) (*models.StripeSetting, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	var s models.StripeSetting
+	if err := db.First(&s, 1).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			s = models.StripeSetting{ID: 1, Enabled: false, Mode: "test"}
+			if e := db.Create(&s).Error; e != nil {
+				return nil, e
+			}
+		} else {
+			return nil, err
+		}
+	}
+	return &s, nil
+}
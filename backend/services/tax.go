@@ -0,0 +1,163 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"fanuc-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// TaxInput holds the order amounts tax is calculated on, in the order currency and in the
+// base currency.
+type TaxInput struct {
+	CountryCode string
+	VATNumber   string
+	Currency    *models.Currency // nil means the base currency
+
+	Subtotal, Discount, Shipping             float64
+	BaseSubtotal, BaseDiscount, BaseShipping float64
+}
+
+// TaxResult is the tax for an order. Adjustment/BaseAdjustment is what gets added to
+// Subtotal - Discount + Shipping to reach the total (zero when the tax is already included).
+type TaxResult struct {
+	Rule           *models.TaxRule
+	VATNumber      string
+	VATCountry     string
+	ReverseCharge  bool
+	Inclusive      bool
+	Lines          []models.OrderTaxLine
+	TaxAmount      float64
+	BaseTaxAmount  float64
+	Adjustment     float64
+	BaseAdjustment float64
+
+	decimals int // minor unit of the order currency
+}
+
+// GetTaxRule returns the active rule for a country, or nil when the country is not taxed.
+func GetTaxRule(db *gorm.DB, countryCode string) (*models.TaxRule, error) {
+	var rule models.TaxRule
+	err := db.Where("country_code = ? AND is_active = ?", NormalizeCountryCode(countryCode), true).First(&rule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// ReverseChargeApplies reports whether a buyer with a VAT number from vatCountry shipping to
+// the rule's country is exempt from being charged tax.
+func ReverseChargeApplies(rule *models.TaxRule, vatCountry string) bool {
+	return rule != nil && rule.ReverseChargeEnabled && vatCountry != "" && vatCountry == rule.CountryCode
+}
+
+func formatTaxRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64) + "%"
+}
+
+// CalculateOrderTax applies the destination's tax rule. A supplied VAT number must be valid
+// (a *VATNumberError is returned otherwise); it only removes tax when the rule allows
+// reverse charge and the number was issued in the destination country.
+func CalculateOrderTax(db *gorm.DB, in TaxInput) (*TaxResult, error) {
+	res := &TaxResult{decimals: 2}
+	if in.Currency != nil {
+		res.decimals = in.Currency.Decimals
+	}
+	if in.VATNumber != "" {
+		number, country, err := ValidateVATNumber(in.VATNumber)
+		if err != nil {
+			return nil, err
+		}
+		res.VATNumber, res.VATCountry = number, country
+	}
+
+	rule, err := GetTaxRule(db, in.CountryCode)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil || rule.Rate <= 0 {
+		return res, nil
+	}
+	res.Rule = rule
+	res.ReverseCharge = ReverseChargeApplies(rule, res.VATCountry)
+	res.Inclusive = rule.PricesIncludeTax && !res.ReverseCharge
+
+	decimals := res.decimals
+	taxOn := func(amount float64) float64 {
+		if rule.PricesIncludeTax {
+			return amount * rule.Rate / (100 + rule.Rate)
+		}
+		return amount * rule.Rate / 100
+	}
+
+	type component struct {
+		name         string
+		amount, base float64
+	}
+	comps := []component{{"items", in.Subtotal - in.Discount, in.BaseSubtotal - in.BaseDiscount}}
+	if rule.ShippingTaxable && in.Shipping > 0 {
+		comps = append(comps, component{"shipping", in.Shipping, in.BaseShipping})
+	}
+
+	for _, comp := range comps {
+		if comp.amount <= 0 {
+			continue
+		}
+		line := models.OrderTaxLine{
+			CountryCode:   rule.CountryCode,
+			Rate:          rule.Rate,
+			Component:     comp.name,
+			TaxableAmount: roundCurrency(comp.amount, decimals),
+			TaxAmount:     roundCurrency(taxOn(comp.amount), decimals),
+			BaseTaxAmount: round2(taxOn(comp.base)),
+			Inclusive:     res.Inclusive,
+			ReverseCharge: res.ReverseCharge,
+		}
+		switch {
+		case res.ReverseCharge && rule.PricesIncludeTax:
+			// Gross prices: the business buyer pays the net amount.
+			line.Name = fmt.Sprintf("%s %s deducted, reverse charge (%s)", rule.Name, formatTaxRate(rule.Rate), comp.name)
+			line.TaxAmount, line.BaseTaxAmount = -line.TaxAmount, -line.BaseTaxAmount
+		case res.ReverseCharge:
+			line.Name = fmt.Sprintf("%s reverse charge (%s)", rule.Name, comp.name)
+			line.Rate, line.TaxAmount, line.BaseTaxAmount = 0, 0, 0
+		case res.Inclusive:
+			line.Name = fmt.Sprintf("%s %s included (%s)", rule.Name, formatTaxRate(rule.Rate), comp.name)
+		default:
+			line.Name = fmt.Sprintf("%s %s (%s)", rule.Name, formatTaxRate(rule.Rate), comp.name)
+		}
+		res.Lines = append(res.Lines, line)
+		res.TaxAmount += line.TaxAmount
+		res.BaseTaxAmount += line.BaseTaxAmount
+	}
+	res.TaxAmount = roundCurrency(res.TaxAmount, decimals)
+	res.BaseTaxAmount = round2(res.BaseTaxAmount)
+	if !res.Inclusive {
+		res.Adjustment, res.BaseAdjustment = res.TaxAmount, res.BaseTaxAmount
+	}
+	return res, nil
+}
+
+// ApplyOrderTax copies a tax result onto an order that has not been saved yet.
+func ApplyOrderTax(order *models.Order, res *TaxResult) {
+	if order == nil || res == nil {
+		return
+	}
+	order.VATNumber = res.VATNumber
+	order.ReverseCharge = res.ReverseCharge
+	order.TaxInclusive = res.Inclusive
+	order.TaxAmount = res.TaxAmount
+	order.BaseTaxAmount = res.BaseTaxAmount
+	order.TaxLines = res.Lines
+	order.TotalAmount = roundCurrency(order.TotalAmount+res.Adjustment, res.decimals)
+	order.BaseTotalAmount = round2(order.BaseTotalAmount + res.BaseAdjustment)
+}
+
+// ReverseChargeNote is printed on invoices and emails for reverse-charged orders.
+const ReverseChargeNote = "Reverse charge: VAT to be accounted for by the recipient (Article 196, Council Directive 2006/112/EC)."
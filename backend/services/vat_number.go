@@ -0,0 +1,259 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// VATNumberError is returned when a VAT number is malformed or fails its check digits.
+type VATNumberError struct {
+	VATNumber string
+	Message   string
+}
+
+func (e *VATNumberError) Error() string { return e.Message }
+
+// vatFormats are the EU (VIES) and UK VAT number formats, without the country prefix.
+var vatFormats = map[string]*regexp.Regexp{
+	"AT": regexp.MustCompile(`^U\d{8}$`),
+	"BE": regexp.MustCompile(`^[01]\d{9}$`),
+	"BG": regexp.MustCompile(`^\d{9,10}$`),
+	"CY": regexp.MustCompile(`^\d{8}[A-Z]$`),
+	"CZ": regexp.MustCompile(`^\d{8,10}$`),
+	"DE": regexp.MustCompile(`^\d{9}$`),
+	"DK": regexp.MustCompile(`^\d{8}$`),
+	"EE": regexp.MustCompile(`^\d{9}$`),
+	"EL": regexp.MustCompile(`^\d{9}$`),
+	"ES": regexp.MustCompile(`^[A-Z0-9]\d{7}[A-Z0-9]$`),
+	"FI": regexp.MustCompile(`^\d{8}$`),
+	"FR": regexp.MustCompile(`^[A-HJ-NP-Z0-9]{2}\d{9}$`),
+	"HR": regexp.MustCompile(`^\d{11}$`),
+	"HU": regexp.MustCompile(`^\d{8}$`),
+	"IE": regexp.MustCompile(`^(\d{7}[A-W][A-I]?|\d[A-Z+*]\d{5}[A-W])$`),
+	"IT": regexp.MustCompile(`^\d{11}$`),
+	"LT": regexp.MustCompile(`^(\d{9}|\d{12})$`),
+	"LU": regexp.MustCompile(`^\d{8}$`),
+	"LV": regexp.MustCompile(`^\d{11}$`),
+	"MT": regexp.MustCompile(`^\d{8}$`),
+	"NL": regexp.MustCompile(`^\d{9}B\d{2}$`),
+	"PL": regexp.MustCompile(`^\d{10}$`),
+	"PT": regexp.MustCompile(`^\d{9}$`),
+	"RO": regexp.MustCompile(`^[1-9]\d{1,9}$`),
+	"SE": regexp.MustCompile(`^\d{10}(0[1-9]|[1-8]\d|9[0-4])$`),
+	"SI": regexp.MustCompile(`^[1-9]\d{7}$`),
+	"SK": regexp.MustCompile(`^[1-9]\d{9}$`),
+	"GB": regexp.MustCompile(`^(\d{9}|\d{12}|GD[0-4]\d{2}|HA[5-9]\d{2})$`),
+	"XI": regexp.MustCompile(`^(\d{9}|\d{12}|GD[0-4]\d{2}|HA[5-9]\d{2})$`),
+}
+
+// vatChecksums verify the check digits where the algorithm is public; other countries are
+// validated by format only.
+var vatChecksums = map[string]func(string) bool{
+	"AT": checkVATAT,
+	"BE": checkVATBE,
+	"DE": checkVATDE,
+	"DK": checkVATDK,
+	"FI": checkVATFI,
+	"FR": checkVATFR,
+	"IT": luhnValid,
+	"NL": checkVATNL,
+	"PL": checkVATPL,
+	"PT": checkVATPT,
+	"SE": func(n string) bool { return luhnValid(n[:10]) },
+	"GB": checkVATGB,
+	"XI": checkVATGB,
+}
+
+// VATCountryCode maps a VAT prefix to the ISO country code it belongs to (EL is Greece,
+// XI is Northern Ireland).
+func VATCountryCode(prefix string) string {
+	switch prefix {
+	case "EL":
+		return "GR"
+	case "XI":
+		return "GB"
+	}
+	return prefix
+}
+
+// NormalizeVATNumber uppercases and strips spaces, dots and dashes.
+func NormalizeVATNumber(raw string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '.', '-', '\t':
+			return -1
+		}
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		return r
+	}, raw)
+}
+
+// ValidateVATNumber checks format and check digits offline (no VIES lookup). It returns the
+// normalized number and the ISO country it was issued in.
+func ValidateVATNumber(raw string) (number, countryCode string, err error) {
+	number = NormalizeVATNumber(raw)
+	if len(number) < 4 {
+		return number, "", &VATNumberError{VATNumber: number, Message: "VAT number is too short"}
+	}
+	prefix, rest := number[:2], number[2:]
+	format, ok := vatFormats[prefix]
+	if !ok {
+		return number, "", &VATNumberError{VATNumber: number, Message: fmt.Sprintf("VAT numbers starting with %q are not supported", prefix)}
+	}
+	if !format.MatchString(rest) {
+		return number, "", &VATNumberError{VATNumber: number, Message: fmt.Sprintf("VAT number %s does not match the %s format", number, prefix)}
+	}
+	if check, ok := vatChecksums[prefix]; ok && !check(rest) {
+		return number, "", &VATNumberError{VATNumber: number, Message: fmt.Sprintf("VAT number %s is not valid (check digit mismatch)", number)}
+	}
+	return number, VATCountryCode(prefix), nil
+}
+
+func vatDigits(s string) []int {
+	out := make([]int, 0, len(s))
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return nil
+		}
+		out = append(out, int(r-'0'))
+	}
+	return out
+}
+
+func luhnValid(s string) bool {
+	d := vatDigits(s)
+	if len(d) == 0 {
+		return false
+	}
+	sum := 0
+	for i := len(d) - 1; i >= 0; i-- {
+		v := d[i]
+		if (len(d)-1-i)%2 == 1 {
+			v *= 2
+			if v > 9 {
+				v -= 9
+			}
+		}
+		sum += v
+	}
+	return sum%10 == 0
+}
+
+// weightedSum multiplies the leading digits by weights and sums them.
+func weightedSum(d []int, weights ...int) int {
+	sum := 0
+	for i, w := range weights {
+		sum += d[i] * w
+	}
+	return sum
+}
+
+func checkVATAT(n string) bool {
+	d := vatDigits(n[1:])
+	sum := 0
+	for i := 0; i < 7; i++ {
+		v := d[i]
+		if i%2 == 1 {
+			v *= 2
+			v = v/10 + v%10
+		}
+		sum += v
+	}
+	check := (10 - (sum+4)%10) % 10
+	return check == d[7]
+}
+
+func checkVATBE(n string) bool {
+	base, err1 := strconv.Atoi(n[:8])
+	check, err2 := strconv.Atoi(n[8:])
+	return err1 == nil && err2 == nil && 97-base%97 == check
+}
+
+// checkVATDE is ISO 7064 MOD 11,10.
+func checkVATDE(n string) bool {
+	d := vatDigits(n)
+	p := 10
+	for i := 0; i < 8; i++ {
+		s := (d[i] + p) % 10
+		if s == 0 {
+			s = 10
+		}
+		p = (2 * s) % 11
+	}
+	check := 11 - p
+	if check == 10 {
+		check = 0
+	}
+	return check == d[8]
+}
+
+func checkVATDK(n string) bool {
+	return weightedSum(vatDigits(n), 2, 7, 6, 5, 4, 3, 2, 1)%11 == 0
+}
+
+func checkVATFI(n string) bool {
+	d := vatDigits(n)
+	r := 11 - weightedSum(d, 7, 9, 10, 5, 8, 4, 2)%11
+	if r == 11 {
+		r = 0
+	}
+	return r != 10 && r == d[7]
+}
+
+func checkVATFR(n string) bool {
+	key, err := strconv.Atoi(n[:2])
+	if err != nil {
+		return true // new-style alphanumeric keys have no public algorithm
+	}
+	siren, err := strconv.Atoi(n[2:])
+	return err == nil && (12+3*(siren%97))%97 == key
+}
+
+// checkVATNL accepts the legacy mod-11 numbers and the mod-97 numbers issued since 2020.
+func checkVATNL(n string) bool {
+	d := vatDigits(n[:9])
+	if weightedSum(d, 9, 8, 7, 6, 5, 4, 3, 2)%11 == d[8] {
+		return true
+	}
+	// "NL" + number, letters as A=10 ... Z=35, must be 1 mod 97.
+	rem := 0
+	for _, r := range "NL" + n {
+		var v int
+		if r >= 'A' && r <= 'Z' {
+			v = int(r-'A') + 10
+			rem = (rem*100 + v) % 97
+			continue
+		}
+		v = int(r - '0')
+		rem = (rem*10 + v) % 97
+	}
+	return rem == 1
+}
+
+func checkVATPL(n string) bool {
+	d := vatDigits(n)
+	r := weightedSum(d, 6, 5, 7, 2, 3, 4, 5, 6, 7) % 11
+	return r != 10 && r == d[9]
+}
+
+func checkVATPT(n string) bool {
+	d := vatDigits(n)
+	r := 11 - weightedSum(d, 9, 8, 7, 6, 5, 4, 3, 2)%11
+	if r >= 10 {
+		r = 0
+	}
+	return r == d[8]
+}
+
+func checkVATGB(n string) bool {
+	if strings.HasPrefix(n, "GD") || strings.HasPrefix(n, "HA") {
+		return true // government departments / health authorities: format only
+	}
+	d := vatDigits(n[:9])
+	sum := weightedSum(d, 8, 7, 6, 5, 4, 3, 2) + d[7]*10 + d[8]
+	return sum%97 == 0 || (sum+55)%97 == 0
+}
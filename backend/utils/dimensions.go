@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// dimensionValue is one size with an optional label (L/W/H/D) and unit. A comma is a
+// thousands separator before groups of three digits ("1,200") and a decimal point otherwise ("1,5").
+const dimensionValue = `(?:[lwhd]\s*[:=]?\s*)?(\d{1,3}(?:,\d{3})+(?:\.\d+)?|\d+(?:\.\d+|,\d{1,2})?)\s*(mm|cm|inches|inch|in\b|m\b|")?`
+
+var dimensionsPattern = regexp.MustCompile(`(?i)` + dimensionValue + `\s*[x×*]\s*` + dimensionValue + `\s*[x×*]\s*` + dimensionValue)
+
+// ParseDimensionsCm reads a free-text size such as "380x150x172mm", "30 x 20 x 10 cm",
+// "30cm x 20cm x 10", "L30*W20*H10 cm" or "12×8×4 in" and returns length, width and height
+// in centimetres. A value without its own unit takes the unit written after the last (or any
+// other) value; without any unit the values are taken as millimetres, as in FANUC datasheets.
+func ParseDimensionsCm(s string) (l, w, h float64, ok bool) {
+	m := dimensionsPattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, 0, false
+	}
+	defaultUnit := ""
+	for _, u := range []string{m[6], m[2], m[4]} {
+		if u != "" {
+			defaultUnit = u
+			break
+		}
+	}
+	vals := make([]float64, 3)
+	for i := range vals {
+		num := m[1+2*i]
+		if strings.Count(num, ",") == 1 && len(num)-strings.Index(num, ",") <= 3 {
+			num = strings.Replace(num, ",", ".", 1) // decimal comma
+		} else {
+			num = strings.ReplaceAll(num, ",", "") // thousands separators
+		}
+		v, err := strconv.ParseFloat(num, 64)
+		if err != nil || v <= 0 {
+			return 0, 0, 0, false
+		}
+		unit := m[2+2*i]
+		if unit == "" {
+			unit = defaultUnit
+		}
+		vals[i] = math.Round(v*dimensionFactorCm(unit)*100) / 100
+	}
+	return vals[0], vals[1], vals[2], true
+}
+
+// dimensionFactorCm converts one unit of a dimension into centimetres (no unit = mm).
+func dimensionFactorCm(unit string) float64 {
+	switch strings.ToLower(unit) {
+	case "cm":
+		return 1
+	case "m":
+		return 100
+	case "in", "inch", "inches", `"`:
+		return 2.54
+	}
+	return 0.1
+}
@@ -216,6 +216,7 @@ export default function GuestCheckoutPage() {
       await OrderService.processPayment(currentOrder.id, {
         payment_method: 'paypal',
         payment_data: paymentData,
+        email: currentOrder.customer_email,
       });
       setStep('success');
       clearCart();
@@ -206,6 +206,7 @@ export default function CheckoutPage() {
       await OrderService.processPayment(currentOrder.id, {
         payment_method: 'paypal',
         payment_data: paymentData,
+        email: currentOrder.customer_email,
       });
 
       // Mark success first to avoid empty-cart redirect effect.
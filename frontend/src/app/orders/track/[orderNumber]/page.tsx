@@ -1,7 +1,7 @@
 'use client';
 
 import { useState, useEffect } from 'react';
-import { useParams } from 'next/navigation';
+import { useParams, useSearchParams } from 'next/navigation';
 import Image from 'next/image';
 import { getDefaultProductImageWithSku, getProductImageUrl } from '@/lib/utils';
 import Link from 'next/link';
@@ -25,6 +25,9 @@ import {
 export default function OrderTrackingPage() {
   const params = useParams();
   const orderNumber = params.orderNumber as string;
+  const searchParams = useSearchParams();
+  const token = searchParams.get('token') || undefined;
+  const email = searchParams.get('email') || undefined;
 
   const [order, setOrder] = useState<Order | null>(null);
   const [loading, setLoading] = useState(true);
@@ -34,13 +37,13 @@ export default function OrderTrackingPage() {
     if (orderNumber) {
       fetchOrder(orderNumber);
     }
-  }, [orderNumber]);
+  }, [orderNumber, token, email]);
 
   const fetchOrder = async (orderNum: string) => {
     try {
       setLoading(true);
       setError(null);
-      const orderData = await OrderService.getOrderByNumber(orderNum);
+      const orderData = await OrderService.getOrderByNumber(orderNum, { token, email });
       setOrder(orderData);
     } catch (err: any) {
       setError(err.message || 'Order not found');
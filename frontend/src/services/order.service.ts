@@ -15,6 +15,9 @@ export interface OrderCreateRequest {
   billing_address: string;
   notes?: string;
   coupon_code?: string; // Optional coupon code
+  shipping_carrier?: string; // Chosen shipping option (empty = standard shipping)
+  shipping_service?: string;
+  shipping_fee?: number; // Fee shown to the shopper, re-verified by the server
   items: Array<{
     product_id: number;
     quantity: number;
@@ -36,6 +39,8 @@ export interface OrderFilters {
 export interface PaymentRequest {
   payment_method: string;
   payment_data?: any;
+  tracking_token?: string; // Guests: proof the order is theirs (or email)
+  email?: string;
 }
 
 export class OrderService {
@@ -152,10 +157,12 @@ export class OrderService {
     }
   }
 
-  // Get order by order number (public - for order tracking)
-  static async getOrderByNumber(orderNumber: string): Promise<Order> {
+  // Get order by order number (public - for order tracking).
+  // Requires the signed token from the order emails or the email the order was placed with.
+  static async getOrderByNumber(orderNumber: string, auth: { token?: string; email?: string } = {}): Promise<Order> {
     const response = await apiClient.get<APIResponse<Order>>(
-      `/orders/track/${orderNumber}`
+      `/orders/track/${orderNumber}`,
+      { params: { token: auth.token || undefined, email: auth.email || undefined } }
     );
     
     if (response.data.success && response.data.data) {
@@ -9,6 +9,9 @@ export interface ShippingRate {
   country_name: string;
   currency: string;
   is_active: boolean;
+  transit_days_min?: number;
+  transit_days_max?: number;
+  volumetric_divisor?: number;
   weight_brackets?: number;
   quote_surcharges?: number;
   created_at: string;
@@ -25,6 +28,8 @@ export interface ShippingQuote {
   country_code: string;
   currency: string;
   weight_kg: number;
+  volumetric_weight_kg?: number;
+  chargeable_weight_kg?: number; // max(actual, volumetric)
   billing_weight_kg?: number;
   rate_per_kg: number;
   base_quote: number;
@@ -33,6 +38,19 @@ export interface ShippingQuote {
   source?: 'default' | 'carrier' | 'default_fallback' | string;
   carrier?: string;
   service_code?: string;
+  transit_days_min?: number;
+  transit_days_max?: number;
+}
+
+export interface ShippingOption extends ShippingQuote {
+  label: string;
+  free_shipping: boolean;
+}
+
+export interface ShippingOptionsResult {
+  country_code: string;
+  weight_kg: number;
+  options: ShippingOption[];
 }
 
 export interface ShippingRateImportResult {
@@ -89,6 +107,17 @@ export class ShippingRateService {
     throw new Error(res.data.message || res.data.error || 'Failed to calculate shipping');
   }
 
+  static async options(country: string, weightKg: number, currency?: string, volumeCm3?: number): Promise<ShippingOptionsResult> {
+    const qs = new URLSearchParams();
+    qs.set('country', country);
+    qs.set('weight_kg', String(weightKg || 0));
+    if (volumeCm3) qs.set('volume_cm3', String(volumeCm3));
+    if (currency) qs.set('currency', currency);
+    const res = await apiClient.get<APIResponse<ShippingOptionsResult>>(`/public/shipping/options?${qs.toString()}`);
+    if (res.data.success && res.data.data) return res.data.data;
+    throw new Error(res.data.message || res.data.error || 'Failed to load shipping options');
+  }
+
   static async adminList(
     q?: string,
     opts?: { type?: 'country' | 'carrier'; carrier?: string; service?: string }
@@ -104,6 +133,17 @@ export class ShippingRateService {
     throw new Error(res.data.message || 'Failed to fetch shipping rates');
   }
 
+  // Settings outside the rate sheets; volumetric_divisor only applies to carrier templates.
+  static async update(
+    id: number,
+    payload: { is_active?: boolean; transit_days_min?: number; transit_days_max?: number; volumetric_divisor?: number },
+    opts?: { type?: 'country' | 'carrier' }
+  ): Promise<void> {
+    const suffix = opts?.type ? `?type=${opts.type}` : '';
+    const res = await apiClient.put<APIResponse<any>>(`/admin/shipping-rates/${id}${suffix}`, payload);
+    if (res.data.success) return;
+    throw new Error(res.data.message || 'Failed to update shipping template');
+  }
 
   static async bulkDelete(
     payload: { all?: boolean; country_codes?: string[] },
@@ -130,6 +170,17 @@ export class ShippingRateService {
     return res.data as Blob;
   }
 
+  // Current templates in the import layout (edit and re-upload with replace).
+  static async exportXlsx(opts?: { type?: 'country' | 'carrier-zone'; carrier?: string; service?: string }): Promise<Blob> {
+    const qs = new URLSearchParams();
+    if (opts?.type) qs.set('type', opts.type);
+    if (opts?.carrier) qs.set('carrier', opts.carrier);
+    if (opts?.service) qs.set('service', opts.service);
+    const suffix = qs.toString() ? `?${qs.toString()}` : '';
+    const res = await apiClient.get(`/admin/shipping-rates/export/xlsx${suffix}`, { responseType: 'blob' });
+    return res.data as Blob;
+  }
+
   static async importXlsx(
     file: File,
     opts?: { replace?: boolean; type?: 'country' | 'carrier-zone'; carrier?: string; service?: string; currency?: string }
@@ -73,6 +73,9 @@ export interface Product {
   min_stock_level: number;
   weight?: number;
   dimensions: string;
+  length_cm?: number; // packed size, used for volumetric shipping weight
+  width_cm?: number;
+  height_cm?: number;
   brand: string;
   model: string;
   part_number: string;
@@ -320,6 +323,9 @@ export interface ProductCreateRequest {
   stock_quantity: number;
   weight?: number;
   dimensions: string;
+  length_cm?: number; // packed size, used for volumetric shipping weight
+  width_cm?: number;
+  height_cm?: number;
   brand: string;
   model: string;
   part_number: string;